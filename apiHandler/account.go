@@ -0,0 +1,128 @@
+package apiHandler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// AccountExport is everything the server keeps about a user, GET /me/export
+type AccountExport struct {
+	Username   string                     `json:"username"`
+	ExportedAt time.Time                  `json:"exported_at"`
+	Admin      bool                       `json:"admin"`
+	Sessions   []authHandler.RefreshToken `json:"sessions"` //remember-me logins with their device and IP
+	Shelves    []dh.ShelfEntry            `json:"shelves"`
+	Public     []dh.PublicShelf           `json:"public_shelves"` //shelves shared by link
+	Lists      []dh.ListMember            `json:"lists"`          //other users' shelves shared with them
+	Reviews    []dh.Review                `json:"reviews"`
+	Comments   []dh.Comment               `json:"comments"`
+	Inbox      []dh.Notification          `json:"notifications"`
+	Devices    []dh.Device                `json:"devices"`
+	Prefs      dh.Preferences             `json:"preferences"`
+	Quota      QuotaUsage                 `json:"quota"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"` //confirms it is really the user asking
+}
+
+func (h *Handler) accountExport(r *http.Request, user string) (AccountExport, error) {
+	now := h.Clock.Now()
+	exp := AccountExport{Username: user, ExportedAt: now, Admin: h.Auth.Admins[user], Shelves: []dh.ShelfEntry{}, Public: []dh.PublicShelf{}, Lists: []dh.ListMember{}, Reviews: []dh.Review{}, Comments: []dh.Comment{}, Inbox: []dh.Notification{}, Devices: []dh.Device{}}
+	var err error
+	if exp.Sessions, err = h.Auth.Refresh.ListRefresh(r.Context(), user); err != nil {
+		return exp, err
+	}
+	if h.Shelves != nil {
+		if exp.Shelves, err = h.Shelves.ListShelf(r.Context(), user); err != nil {
+			return exp, err
+		}
+		if exp.Public, err = h.Shelves.ListPublicShelves(r.Context(), user); err != nil {
+			return exp, err
+		}
+		if exp.Lists, err = h.Shelves.ListMemberships(r.Context(), user); err != nil {
+			return exp, err
+		}
+	}
+	if h.Reviews != nil {
+		if exp.Reviews, exp.Comments, err = h.Reviews.UserPosts(r.Context(), user); err != nil {
+			return exp, err
+		}
+	}
+	if h.Inbox != nil {
+		if exp.Inbox, err = h.Inbox.ListNotifications(r.Context(), user, false); err != nil {
+			return exp, err
+		}
+		if exp.Devices, err = h.Inbox.ListDevices(r.Context(), user); err != nil {
+			return exp, err
+		}
+		if exp.Prefs, err = h.Inbox.GetPreferences(r.Context(), user); err != nil {
+			return exp, err
+		}
+	}
+	exp.Quota = h.quotas.usage(user, now, h.Runtime.get().DailyQuota)
+	return exp, nil
+}
+
+// getAccountExport downloads the caller's data as JSON, GET /me/export
+func (h *Handler) getAccountExport(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	exp, err := h.accountExport(r, user)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="bookserver-account.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, exp)
+	return nil
+}
+
+// deleteAccount removes the caller's account and what is kept about them, DELETE /me with their password.
+// Catalog changes they made stay, the change log doesn't record who made them.
+// Admins are refused, their name would stay in --admins for whoever registers it next.
+func (h *Handler) deleteAccount(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	var req DeleteAccountRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	ok, err := h.Auth.CheckCredentials(r.Context(), user, req.Password)
+	if err != nil {
+		return authHandler.Throttled(w, err)
+	}
+	if !ok {
+		return eh.New(http.StatusForbidden, eh.InvalidCredentials, i18n.InvalidCredentials)
+	}
+	if h.Auth.Admins[user] {
+		return eh.New(http.StatusForbidden, eh.AdminAccount, i18n.AdminAccount)
+	}
+
+	if h.Shelves != nil {
+		if err := h.Shelves.DeleteShelf(r.Context(), user); err != nil {
+			return err
+		}
+	}
+	if h.Reviews != nil { //their posts stay in the threads as deleted, like the ones they deleted themselves
+		if err := h.Reviews.DeletePosts(r.Context(), user); err != nil {
+			return err
+		}
+	}
+	if h.Inbox != nil {
+		if err := h.Inbox.DeleteNotifications(r.Context(), user); err != nil {
+			return err
+		}
+	}
+	h.imports.forget(user)
+	h.quotas.forget(user)
+	if err := h.Auth.DeleteAccount(w, r, user); err != nil {
+		return err
+	}
+	h.logf(r, "account %q deleted", user)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -1,121 +1,873 @@
 package apiHandler
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	"github.com/Sabnaj-42/BookServer-API/blobHandler"
+	"github.com/Sabnaj-42/BookServer-API/cacheHandler"
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
 
-	//"fmt"
-	//"github.com/Sabnaj-42/BookServer-API/authHandler"
 	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/ipHandler"
+	"github.com/Sabnaj-42/BookServer-API/jobHandler"
+	"github.com/Sabnaj-42/BookServer-API/mailHandler"
+	mh "github.com/Sabnaj-42/BookServer-API/metricsHandler"
+	"github.com/Sabnaj-42/BookServer-API/moderationHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+	"github.com/Sabnaj-42/BookServer-API/pushHandler"
+	"github.com/Sabnaj-42/BookServer-API/secretHandler"
+	trace "github.com/Sabnaj-42/BookServer-API/traceHandler"
+	"github.com/Sabnaj-42/BookServer-API/uiHandler"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-func getAllBooks(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(dh.BookList)
-	if err != nil {
-		http.Error(w, "Cannot encode data", http.StatusInternalServerError)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
+type Handler struct { //book endpoints and their dependencies
+	Store      dh.Store
+	Auth       *authHandler.Handler
+	Logger     *log.Logger //lines of every level go out through it, see Logs
+	Logs       Logs        //by level, dropping the ones below Runtime.LogLevel
+	Clock      dh.Clock
+	IDs        dh.IDGenerator
+	Config     Config
+	Events     eventHandler.Bus            //book changes, shared between replicas when backed by Redis
+	Jobs       *jobHandler.Scheduler       //background jobs, run on one replica at a time
+	Queue      *jobHandler.Queue           //one-off background work such as resizing covers
+	Authors    *dh.AuthorAliases           //spellings that name the same author
+	Blobs      blobHandler.Store           //covers and e-book files
+	Shelves    dh.ShelfStore               //users' reading lists, /me routes are left out when nil
+	Reviews    dh.ReviewStore              //book reviews and their comment threads, their routes are left out when nil
+	Inbox      dh.NotificationStore        //users' notifications, /me/notifications is left out when nil
+	Push       pushHandler.Mux             //senders by device platform, /me/devices is left out when empty
+	Mail       mailHandler.Mailer          //sends notification emails, nil sends none
+	Moderation moderationHandler.Moderator //judges new reviews and comments, nil publishes them unchecked
+	Secrets    *secretHandler.Resolver     //secrets fetched from Vault or KMS at startup, their leases are renewed while serving
+	Caches     *cacheHandler.Budget        //memory shared by the in-process caches
+	Translit   *dh.TranslitIndex           //romanized titles and authors for ?q=, set T for another transliteration provider; nil disables
+	Invalidate []dh.CacheInvalidator       //further caches of book data, told about every book change with the book cache and Translit
+
+	DownloadGate DownloadGate        //extra check before e-book downloads, nil allows every logged in user
+	Scanner      blobHandler.Scanner //malware check before uploads are stored, nil stores them unscanned
+	TenantOf     TenantFunc          //who a request is billed to in /admin/usage
 
+	Prefix     string                            //path Routes is mounted under, e.g. by NewServer; the links the API hands out start with it
+	Middleware []func(http.Handler) http.Handler //embedders' own, run on every request after request IDs, logging and panic recovery
+
+	AdminFilter  ipHandler.Filter //IP rules for /admin, its Trusted proxies apply to every request
+	Maintenance  maintenanceState
+	Runtime      runtimeState
+	limiter      rateLimiter
+	posts        rateLimiter //reviews, comments and reports per user
+	confirms     rateLimiter //email confirmation codes per user
+	imports      shelfImports
+	quotas       quotaState
+	usage        usageState
+	hooks        bookHooks
+	reindexState reindexState
+	warmSteps    []warmStep
+	search       searchIndex
+	indexes      sync.Mutex  //held while the report or snapshot index in the blob store is read and rewritten
+	warming      atomic.Bool //a warmup is running, /readyz reports not ready
 }
 
-func AddNewBook(w http.ResponseWriter, r *http.Request) {
-	var book dh.Book
-	err := json.NewDecoder(r.Body).Decode(&book)
-	if err != nil {
-		http.Error(w, "Cannot decode data", http.StatusBadRequest)
-		return
+func NewHandler(store dh.Store, auth *authHandler.Handler, logger *log.Logger, clock dh.Clock, ids dh.IDGenerator) *Handler {
+	if logger == nil {
+		logger = log.Default()
 	}
-	if len(book.Name) == 0 || len(book.ISBN) == 0 || len(book.Authors) == 0 {
-		http.Error(w, "Invalid Data Entry", http.StatusBadRequest)
-		return
+	if clock == nil {
+		clock = dh.SystemClock{}
 	}
+	if ids == nil {
+		ids = dh.UUIDGenerator{}
+	}
+	h := &Handler{Store: store, Auth: auth, Logger: logger, Clock: clock, IDs: ids, Config: DefaultConfig(), Events: eventHandler.NewLocalBus(), Authors: dh.NewAuthorAliases(), Blobs: blobHandler.NewMemStore(), TenantOf: HostTenants(nil), Caches: cacheHandler.NewBudget(0)}
+	h.Logs = h.newLogs()
+	h.Jobs, h.Queue = jobHandler.NewScheduler(nil, h.Logs.Error), jobHandler.NewQueue(2, h.Logs.Error)
+	h.Translit = dh.NewTranslitIndex(dh.Romanizer, h.Caches)
+	h.Moderation = moderationHandler.Pipeline{moderationHandler.NewWordList(func() []string { return h.Runtime.get().BannedWords })}
+	return h
+}
 
-	_, exists := dh.BookList[book.ISBN]
-	if exists {
-		http.Error(w, "Book already exists", http.StatusConflict)
-		return
+// maxRequestIDLength bounds an X-Request-Id taken from a proxy, generated IDs are UUIDs
+const maxRequestIDLength = 128
+
+// validRequestID reports whether id is short and made of letters, digits and -_.: only, so it
+// can go into logs and outbound headers as it is
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.:", c) >= 0) {
+			return false
+		}
 	}
+	return true
+}
 
-	dh.BookList[book.ISBN] = book
-	w.WriteHeader(http.StatusCreated)
+// requestID keeps the X-Request-Id a trusted proxy sent when it is valid, and otherwise assigns one
+// from h.IDs
+func (h *Handler) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(middleware.RequestIDHeader)
+		if !validRequestID(id) || !ipHandler.FromTrusted(r, h.AdminFilter.Trusted) {
+			id = h.IDs.NewID()
+		}
+		w.Header().Set(middleware.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(trace.WithRequestID(r.Context(), id)))
+	})
+}
 
+// AddNewBook creates a book, POST /api/v1/books and the older POST /newBook
+func (h *Handler) AddNewBook(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.createBook)(w, r)
 }
 
-func deleteBook(w http.ResponseWriter, r *http.Request) {
-	var ISBN string
-	ISBN = chi.URLParam(r, "ISBN")
+func (h *Handler) createBook(w http.ResponseWriter, r *http.Request) error {
+	dry := dryRun(w, r)
+	var req CreateBookRequest
+	if err := readText(r, MaxBookBody, &req); err != nil {
+		return err
+	}
+	book := req.Book()
+	book.Normalize()
+	book.ID = h.IDs.NewID()
+	book.Touch(h.Clock.Now())
+	if errs := book.Validate(); len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
 
-	if len(ISBN) == 0 {
-		http.Error(w, "Invalid ISBN", http.StatusBadRequest)
-		return
+	if dry {
+		if err := h.checkCreate(r.Context(), book); err != nil {
+			return err
+		}
+		writeJSON(w, r, http.StatusCreated, NewBookResponse(book, h.Prefix)) //no Location, the ID is thrown away
+		return nil
 	}
-	_, exists := dh.BookList[ISBN]
-	if !exists {
-		http.Error(w, "Book does not exist", http.StatusNotFound)
-		return
+	if err := h.Store.CreateBook(r.Context(), book); err != nil {
+		return err
 	}
-	delete(dh.BookList, ISBN)
-	w.WriteHeader(http.StatusOK)
+	h.publish(r, eventHandler.BookCreated, book)
+	w.Header().Set("Location", bookLocation(h.Prefix, book.ID))
+	w.Header().Set("ETag", bookETag(book))
+	writeJSON(w, r, http.StatusCreated, NewBookResponse(book, h.Prefix))
+	return nil
 }
 
-func updateBook(w http.ResponseWriter, r *http.Request) {
-	var ISBN string
-	ISBN = chi.URLParam(r, "ISBN")
-	if len(ISBN) == 0 {
-		http.Error(w, "Invalid ISBN", http.StatusBadRequest)
-		return
+func (h *Handler) getBook(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
 	}
-	_, exists := dh.BookList[ISBN]
-	if !exists {
-		http.Error(w, "Book does not exist", http.StatusNotFound)
-		return
+	w.Header().Set("ETag", bookETag(book))
+	writeJSON(w, r, http.StatusOK, NewBookResponse(book, h.Prefix))
+	return nil
+}
+
+// bookETag names b's version, clients send it back in If-Match to update only what they read
+func bookETag(b dh.Book) string {
+	return `"` + strconv.FormatInt(b.Version, 10) + `"`
+}
+
+// ifMatch refuses a write with 412 when the request's If-Match names none of current's versions.
+// Without the header the write goes ahead, a change landing meanwhile still fails with 409.
+func ifMatch(r *http.Request, current dh.Book) error {
+	header := r.Header.Get("If-Match")
+	if len(header) == 0 {
+		return nil
 	}
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == bookETag(current) {
+			return nil
+		}
+	}
+	return eh.New(http.StatusPreconditionFailed, eh.PreconditionFailed, i18n.PreconditionFailed)
+}
 
-	var newBook dh.Book
-	err := json.NewDecoder(r.Body).Decode(&newBook)
+func (h *Handler) deleteBook(w http.ResponseWriter, r *http.Request) error {
+	dry := dryRun(w, r)
+	ref := chi.URLParam(r, "ref")
+	if len(ref) == 0 {
+		return eh.New(http.StatusBadRequest, eh.InvalidISBN, i18n.InvalidISBN)
+	}
+	book, err := h.Store.GetBook(r.Context(), ref)
+	if err == nil && !dry {
+		err = h.Store.DeleteBook(r.Context(), book.ID)
+	}
 	if err != nil {
-		http.Error(w, "Cannot decode data", http.StatusBadRequest)
-		return
+		return err
+	}
+	if !dry {
+		h.publish(r, eventHandler.BookDeleted, book)
 	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
 
-	dh.BookList[ISBN] = newBook
-	_, err = w.Write([]byte("Book updated successfully"))
+func (h *Handler) updateBook(w http.ResponseWriter, r *http.Request) error {
+	dry := dryRun(w, r)
+	ref := chi.URLParam(r, "ref")
+	if len(ref) == 0 {
+		return eh.New(http.StatusBadRequest, eh.InvalidISBN, i18n.InvalidISBN)
+	}
+	var req UpdateBookRequest
+	if err := readText(r, MaxBookBody, &req); err != nil {
+		return err
+	}
+	current, err := h.Store.GetBook(r.Context(), ref)
 	if err != nil {
-		http.Error(w, "Can not write data", http.StatusInternalServerError)
+		return err
+	}
+	if err := ifMatch(r, current); err != nil {
+		return err
+	}
+	newBook := req.Book(current)
+	newBook.Normalize()
+	newBook.Touch(h.Clock.Now())
+	if errs := newBook.Validate(); len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+
+	if err := h.writeUpdate(r, newBook, dry); err != nil {
+		return err
+	}
+	if !dry {
+		w.Header().Set("ETag", bookETag(newBook))
+	}
+	writeJSON(w, r, http.StatusOK, NewBookResponse(newBook, h.Prefix))
+	return nil
+}
+
+// writeUpdate stores an updated book and publishes the change, a dry run only checks its ISBN is free
+func (h *Handler) writeUpdate(r *http.Request, book dh.Book, dry bool) error {
+	if dry {
+		return h.checkISBN(r.Context(), book)
 	}
-	w.WriteHeader(http.StatusOK)
+	if err := h.Store.UpdateBook(r.Context(), book); err != nil {
+		return err
+	}
+	h.publish(r, eventHandler.BookUpdated, book)
+	return nil
+}
 
+// storeError answers storage errors, for handle and the middleware
+func (h *Handler) storeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := storeProblem(err)
+	if p.Code() == eh.StorageError || p.Code() == eh.StorageTimeout {
+		h.errorf(r, "store: %v", err)
+	}
+	p.Write(w, r)
 }
 
-func RunServer(port int) {
+// storeProblem maps a storage error to the problem answering it
+func storeProblem(err error) *eh.Error {
+	switch {
+	case errors.Is(err, dh.ErrBookNotFound):
+		return eh.New(http.StatusNotFound, eh.BookNotFound, i18n.BookNotFound)
+	case errors.Is(err, dh.ErrBookExists):
+		return eh.New(http.StatusConflict, eh.DuplicateISBN, i18n.BookExists)
+	case errors.Is(err, dh.ErrShelfNotFound):
+		return eh.New(http.StatusNotFound, eh.ShelfNotFound, i18n.ShelfNotFound)
+	case errors.Is(err, dh.ErrInvitationNotFound):
+		return eh.New(http.StatusNotFound, eh.InvitationNotFound, i18n.InvitationNotFound)
+	case errors.Is(err, dh.ErrReviewNotFound):
+		return eh.New(http.StatusNotFound, eh.ReviewNotFound, i18n.ReviewNotFound)
+	case errors.Is(err, dh.ErrCommentNotFound):
+		return eh.New(http.StatusNotFound, eh.CommentNotFound, i18n.CommentNotFound)
+	case errors.Is(err, dh.ErrNotificationNotFound):
+		return eh.New(http.StatusNotFound, eh.NotificationNotFound, i18n.NotificationNotFound)
+	case errors.Is(err, dh.ErrDeviceNotFound):
+		return eh.New(http.StatusNotFound, eh.DeviceNotFound, i18n.DeviceNotFound)
+	case errors.Is(err, dh.ErrUserNotFound):
+		return eh.New(http.StatusNotFound, eh.UserNotFound, i18n.UserNotFound)
+	case errors.Is(err, dh.ErrForbidden):
+		return eh.New(http.StatusForbidden, eh.Forbidden, i18n.Forbidden)
+	case errors.Is(err, dh.ErrVersionConflict):
+		return eh.New(http.StatusConflict, eh.VersionConflict, i18n.VersionConflict)
+	case errors.Is(err, dh.ErrNoTx):
+		return eh.New(http.StatusNotImplemented, eh.NoTransactions, i18n.NoTransactions)
+	case errors.Is(err, context.DeadlineExceeded):
+		return eh.New(http.StatusGatewayTimeout, eh.StorageTimeout, i18n.StorageTimeout)
+	default:
+		return eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError)
+	}
+}
 
-	dh.Init()
+func bookLocation(prefix, id string) string { //canonical URL of a book, under the Handler.Prefix the API is mounted at
+	return prefix + "/api/v1/books/" + url.PathEscape(id)
+}
 
+// writeJSON encodes v with the given status, status codes used across handlers:
+// 200 for reads and updates returning the resource, 201 for creates, 204 for deletes
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	b := getJSONBuffer()
+	defer putJSONBuffer(b)
+	var err error
+	if c := ch.Of(r); c == ch.Snake {
+		err = b.enc.Encode(v)
+	} else {
+		err = ch.Encode(&b.buf, v, c)
+	}
+	if err != nil {
+		eh.New(http.StatusInternalServerError, eh.Internal, i18n.CannotEncode).Write(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b.buf.Bytes())
+}
+
+// Routes builds the router serving the book API
+func (h *Handler) Routes() http.Handler {
 	r := chi.NewRouter()
-	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	r.Use(h.requestID)
+	r.Use(ipHandler.RealIP(h.AdminFilter.Trusted))
+	r.Use(h.accessLog)
+	r.Use(h.countRequests)
+	r.Use(h.meterRequests)
+	r.Use(h.recoverer)
+	r.Use(h.Middleware...)
+	r.Use(securityHeaders(h.Config.CSP, h.AdminFilter.Trusted))
+	r.Use(h.cors)
+	r.Use(h.jsonCasing)
+	r.Use(h.rateLimit)
 	r.Use(middleware.URLFormat)
+	r.Use(h.maintenance)
+	r.Use(h.validateRequests)
+	r.NotFound(notFound)
+	r.MethodNotAllowed(methodNotAllowed(r))
+
+	r.Get("/healthz", healthz)
+	r.Get("/readyz", h.readyz)
+	r.With(h.AdminFilter.Middleware).Get("/metrics", getMetrics) //no login so Prometheus can scrape, --admin-allow limits who
+
+	r.Post("/signIn", h.Auth.SignIn)
+	r.Post("/login", h.Auth.Login) // request for login:  curl -i  -X POST http://localhost:8080/login      -H "Content-Type: application/json"      -d '{"username": "sabnaj", "password": "1234"}'
+	r.Post("/logout", h.Auth.Logout)
+	r.Post("/refresh", h.Auth.RefreshLogin)
 
 	//Protected
-	r.Post("/signIn", authHandler.SignIn)
-	r.Post("/login", authHandler.Login) // request for login:  curl -i  -X POST http://localhost:8080/login      -H "Content-Type: application/json"      -d '{"username": "sabnaj", "password": "1234"}'
-	r.Post("/logout", authHandler.Logout)
-	r.Post("/newBook", AddNewBook)
-	r.Put("/updateBook", updateBook)
-	r.Delete("/deleteBook", deleteBook)
+	r.Group(func(r chi.Router) {
+		r.Use(h.Auth.Authenticate)
+		r.Use(h.quota)
+		r.Post("/newBook", h.AddNewBook)
+		r.Put("/updateBook/{ref}", h.handle(h.updateBook))
+		r.Delete("/deleteBook/{ref}", h.handle(h.deleteBook))
+
+		r.Get("/sessions", h.Auth.ListSessions)
+		r.Delete("/sessions/{id}", h.Auth.RevokeSession)
+		r.Put("/me/password", h.Auth.ChangePassword)
+		r.Get("/me/export", h.handle(h.getAccountExport))
+		r.Delete("/me", h.handle(h.deleteAccount))
+
+		if h.Inbox != nil {
+			r.Get("/me/notifications", h.handle(h.getNotifications))
+			r.Get("/me/notifications/unread", h.handle(h.getUnread))
+			r.Post("/me/notifications/read", h.handle(h.markAllRead))
+			r.Put("/me/notifications/{id}/read", h.handle(h.markRead(true)))
+			r.Delete("/me/notifications/{id}/read", h.handle(h.markRead(false)))
+			r.Get("/me/preferences", h.handle(h.getPreferences))
+			r.Put("/me/preferences", h.handle(h.putPreferences))
+			r.Post("/me/preferences/email/confirm", h.handle(h.confirmEmail))
+		}
+		if h.Inbox != nil && len(h.Push) != 0 {
+			r.Get("/me/devices", h.handle(h.getDevices))
+			r.Post("/me/devices", h.handle(h.addDevice))
+			r.Delete("/me/devices/{id}", h.handle(h.deleteDevice))
+			if _, ok := h.Push[dh.PlatformWebPush]; ok {
+				r.Get("/me/devices/vapid-key", h.handle(h.getVAPIDKey))
+			}
+		}
+		if h.Shelves != nil {
+			r.Get("/me/shelves", h.handle(h.getShelves))
+			r.Post("/me/shelves/import", h.handle(h.previewShelfImport))
+			r.Post("/me/shelves/import/{id}", h.handle(h.confirmShelfImport))
+			r.Get("/me/shelves/public", h.handle(h.getPublicShelves))
+			r.Put("/me/shelves/{shelf}/public", h.handle(h.publishShelf))
+			r.Delete("/me/shelves/{shelf}/public", h.handle(h.unpublishShelf))
+			r.Get("/me/lists", h.handle(h.getMemberships))
+			r.Get("/me/invitations", h.handle(h.getInvitations))
+			r.Post("/me/invitations/{id}", h.handle(h.acceptInvitation))
+			r.Delete("/me/invitations/{id}", h.handle(h.declineInvitation))
+
+			r.Route("/shelves/{owner}/{shelf}", func(r chi.Router) { //a user's shelf, for them and who they share it with
+				r.Get("/", h.handle(h.getSharedShelf))
+				r.Put("/books/{isbn}", h.handle(h.putShelfBook))
+				r.Delete("/books/{isbn}", h.handle(h.deleteShelfBook))
+				r.Get("/members", h.handle(h.getShelfMembers))
+				r.Delete("/members/{user}", h.handle(h.removeShelfMember))
+				r.Post("/invitations", h.handle(h.inviteToShelf))
+			})
+		}
+	})
 
 	//unprotected
-	r.Get("/getBooks", getAllBooks) //request for getBooks: curl http://localhost:8080/getBooks
+	r.With(h.loginFor("as_of")).Get("/getBooks", h.handle(h.getLegacyBooks)) //request for getBooks: curl http://localhost:8080/getBooks
+	r.Get("/changes", h.handle(h.getChanges))
+	if h.Shelves != nil {
+		r.Get("/lists/{slug}", h.handle(h.getPublicList)) //shared shelves, URLFormat serves .rss and .opds too
+	}
+
+	ui := uiHandler.Handler() //admin page: http://localhost:8080/
+	r.With(h.requireFeature("ui")).Get("/", ui.ServeHTTP)
+	r.With(h.requireFeature("ui")).Handle("/ui/*", http.StripPrefix("/ui", ui))
+
+	catalog := &uiHandler.Catalog{Store: h.Store, Translit: h.Translit, Logger: h.Logs.Error} //server rendered pages
+	r.With(h.requireFeature("catalog")).Get("/catalog", catalog.List)
+	r.With(h.requireFeature("catalog")).Get("/catalog/{ref}", catalog.Book)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(h.AdminFilter.Middleware)
+		r.Use(h.signedOr(h.Auth.Authenticate))
+		r.Use(h.Auth.RequireAdmin) //no quota, so admins can always lift one
+		r.Get("/users", h.Auth.ListUsers)
+		r.Put("/users/{user}/password", h.Auth.ResetPassword)
+		r.Get("/dashboard", h.getDashboard)
+		r.Get("/usage", h.handle(h.getUsage))
+		r.Get("/maintenance", h.getMaintenance)
+		r.Put("/maintenance", h.handle(h.setMaintenance))
+		r.Get("/runtime", h.getRuntime)
+		r.Post("/reload", h.handle(h.reload))
+		r.Get("/authors/aliases", h.handle(h.getAliases))
+		r.Put("/authors/aliases", h.handle(h.setAlias))
+		r.Delete("/authors/aliases/{alias}", h.handle(h.deleteAlias))
+		r.Get("/quotas", h.getQuotas)
+		r.Put("/quotas/{user}", h.handle(h.setQuota))
+		r.Delete("/quotas/{user}", h.deleteQuota)
+		r.Get("/caches", h.getCaches)
+		r.Get("/reindex", h.getReindex)
+		r.Post("/reindex", h.handle(h.postReindex))
+		r.Delete("/reindex", h.cancelReindex)
+		r.Get("/catalog-reports", h.handle(h.getCatalogReports))
+		r.Post("/catalog-reports/run", h.handle(h.runCatalogReports))
+		r.Get("/catalog-reports/{name}", h.handle(h.getCatalogReport))
+		r.Get("/snapshots", h.handle(h.getSnapshots))
+		r.Post("/snapshots", h.handle(h.postSnapshot))
+		r.Get("/diff", h.handle(h.getDiff))
+		r.Post("/signed-urls", h.handle(h.postSignedURL))
+		if h.Reviews != nil {
+			r.Get("/reports", h.handle(h.getReports))
+			r.Get("/quarantine", h.handle(h.getQuarantine))
+			r.Post("/reviews/{id}/approve", h.handle(h.moderate(dh.ReportReview, dh.StatusVisible)))
+			r.Post("/comments/{id}/approve", h.handle(h.moderate(dh.ReportComment, dh.StatusVisible)))
+			r.Delete("/reports/{kind}/{id}", h.handle(h.dismissReports))
+			r.Put("/reviews/{id}/hidden", h.handle(h.moderate(dh.ReportReview, dh.StatusHidden)))
+			r.Delete("/reviews/{id}/hidden", h.handle(h.moderate(dh.ReportReview, dh.StatusVisible)))
+			r.Delete("/reviews/{id}", h.handle(h.moderate(dh.ReportReview, dh.StatusDeleted)))
+			r.Put("/comments/{id}/hidden", h.handle(h.moderate(dh.ReportComment, dh.StatusHidden)))
+			r.Delete("/comments/{id}/hidden", h.handle(h.moderate(dh.ReportComment, dh.StatusVisible)))
+			r.Delete("/comments/{id}", h.handle(h.moderate(dh.ReportComment, dh.StatusDeleted)))
+		}
+		r.Mount("/debug", middleware.Profiler()) //pprof under /admin/debug/pprof/, expvar at /admin/debug/vars
+	})
+
+	r.Get("/api/v1/schema", getSchema)
+	r.Get("/api/v1/openapi", getOpenAPI) //URLFormat strips .json, so this serves /api/v1/openapi.json
+	r.Get("/api/v1/export", h.handle(h.exportCatalog))
+	r.Get("/api/v1/authors", h.handle(h.getAuthors))
+	r.Get("/api/v1/search", h.handle(h.searchBooks))
+	r.With(h.Auth.Authenticate, h.quota, h.validateBodies).Post("/api/v1/import", h.handle(h.importBooks))
+
+	r.Route("/api/v1/books", func(r chi.Router) {
+		r.With(h.loginFor("as_of")).Get("/", h.handle(h.getAllBooks))
+		r.Get("/{ref}", h.handle(h.getBook)) //ref is the book ID or its ISBN
+		r.Get("/{ref}/citation", h.handle(h.getCitation))
+		r.Get("/{ref}/editions", h.handle(h.getEditions))
+		r.Get("/{ref}/cover", h.handle(h.getCover))
+		if h.Reviews != nil {
+			r.Get("/{ref}/reviews", h.handle(h.getReviews))
+		}
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.signedOr(h.Auth.Authenticate)) //e-books can be shared with signed URLs
+			r.Use(h.quota)
+			r.Use(h.validateBodies)
+			r.Post("/", h.handle(h.createBook))
+			r.Put("/{ref}", h.handle(h.updateBook))
+			r.Patch("/{ref}", h.handle(h.patchBook))
+			r.Delete("/{ref}", h.handle(h.deleteBook))
+			r.Post("/{ref}/editions", h.handle(h.linkEdition))
+			r.Delete("/{ref}/work", h.handle(h.unlinkEdition))
+			r.Get("/{ref}/history", h.handle(h.getBookHistory)) //replays the change log, not for anonymous clients
+			r.Get("/{ref}/file", h.handle(h.getFile))
+			r.With(h.Auth.RequireAdmin).Put("/{ref}/file", h.handle(h.putFile))
+			r.With(h.Auth.RequireAdmin).Delete("/{ref}/file", h.handle(h.deleteFile))
+			r.With(h.Auth.RequireAdmin).Put("/{ref}/cover", h.handle(h.putCover))
+			r.With(h.Auth.RequireAdmin).Delete("/{ref}/cover", h.handle(h.deleteCover))
+			if h.Reviews != nil {
+				r.With(h.postRate).Put("/{ref}/review", h.handle(h.putReview))
+				r.With(h.postRate).Post("/{ref}/reports", h.handle(h.reportBook))
+			}
+		})
+	})
+
+	if h.Reviews != nil {
+		r.Route("/api/v1/reviews/{id}", func(r chi.Router) {
+			r.Get("/", h.handle(h.getReviewThread))
+			r.Group(func(r chi.Router) {
+				r.Use(h.Auth.Authenticate)
+				r.Use(h.quota)
+				r.Use(h.validateBodies)
+				r.Delete("/", h.handle(h.deletePost(dh.ReportReview)))
+				r.With(h.postRate).Post("/comments", h.handle(h.addComment))
+				r.With(h.postRate).Post("/reports", h.handle(h.reportPost(dh.ReportReview)))
+			})
+		})
+		r.With(h.Auth.Authenticate, h.quota, h.validateBodies, h.postRate).Post("/api/v1/users/{user}/reports", h.handle(h.reportUser))
+		r.Route("/api/v1/comments/{id}", func(r chi.Router) {
+			r.Use(h.Auth.Authenticate)
+			r.Use(h.quota)
+			r.Use(h.validateBodies)
+			r.Delete("/", h.handle(h.deletePost(dh.ReportComment)))
+			r.With(h.postRate).Post("/reports", h.handle(h.reportPost(dh.ReportComment)))
+		})
+	}
+
+	return r
+}
+
+// FromConfig wires the stores, auth and handler described by cfg
+func FromConfig(cfg Config) (*Handler, error) {
+	outbound := outboundHandler.DefaultOptions()
+	outbound.Attempts, outbound.Proxy = cfg.OutboundAttempts, cfg.OutboundProxy
+	if err := outboundHandler.Configure(outbound); err != nil {
+		return nil, err
+	}
+	if err := LoadPlugins(cfg.Plugins); err != nil {
+		return nil, err
+	}
+	logger := log.Default()
+	clock := dh.SystemClock{}
+	secrets := secretHandler.NewResolver(logger)
+	if len(cfg.VaultAddr) != 0 {
+		secrets.Providers["vault"] = secretHandler.NewVaultProvider(cfg.VaultAddr, cfg.VaultToken)
+	}
+	secrets.Providers["kms"] = secretHandler.NewKMSProvider(cfg.KMSRegion, cfg.S3Key, cfg.S3Secret)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if len(cfg.JWTSecretFrom) != 0 {
+		lease, err := secrets.Resolve(ctx, cfg.JWTSecretFrom)
+		if err != nil {
+			return nil, fmt.Errorf("jwt secret: %w", err)
+		}
+		if cfg.JWTSecret, err = lease.Value(); err != nil {
+			return nil, fmt.Errorf("jwt secret: %w", err)
+		}
+	}
+	var redisAuth func() (string, string)
+	if len(cfg.RedisAuthFrom) != 0 {
+		lease, err := secrets.Resolve(ctx, cfg.RedisAuthFrom)
+		if err != nil {
+			return nil, fmt.Errorf("redis credentials: %w", err)
+		}
+		redisAuth = redisCredentials(lease)
+	}
+	var ring *secretHandler.Keyring
+	if len(cfg.EncryptionKeys) != 0 {
+		var err error
+		if ring, err = secretHandler.ParseKeyring(cfg.EncryptionKeys); err != nil {
+			return nil, fmt.Errorf("encryption keys: %w", err)
+		}
+	}
+	backend := Backend{Config: cfg, Keys: ring, RedisAuth: redisAuth, Logger: logger, Clock: clock}
+	store, users, err := openStores(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+	auth := authHandler.NewHandler(users, authHandler.JWTIssuer{Secret: []byte(cfg.JWTSecret), Audience: []string{"sabnaj"}}, logger, clock)
+	switch cfg.AuthMode {
+	case authHandler.ModeJWT, authHandler.ModeSession:
+		auth.Mode = cfg.AuthMode
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.AuthMode)
+	}
+	auth.Basic = cfg.BasicAuth
+	if cfg.PasswordClasses < 0 || cfg.PasswordClasses > 4 {
+		return nil, fmt.Errorf("password classes must be between 0 and 4, got %d", cfg.PasswordClasses)
+	}
+	auth.Policy = authHandler.PasswordPolicy{MinLength: cfg.PasswordMinLength, Classes: cfg.PasswordClasses, NoUsername: true}
+	if cfg.BreachCheck {
+		auth.Policy.Breached = authHandler.NewPwnedPasswords()
+	}
+	auth.Admins = make(map[string]bool)
+	for _, name := range cfg.Admins {
+		auth.Admins[name] = true
+	}
+	openSessions, err := sessionStores.get(cfg.SessionStore)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Sessions, err = openSessions(ctx, backend); err != nil {
+		return nil, fmt.Errorf("session store %s: %w", cfg.SessionStore, err)
+	}
+	if len(cfg.SigningKeys) != 0 {
+		keys, err := authHandler.LoadKeys(cfg.SigningKeys, ring)
+		if err != nil {
+			return nil, fmt.Errorf("signing keys: %w", err)
+		}
+		auth.Keys = keys
+	}
+	openRefresh, err := refreshStores.get(cfg.RefreshStore)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Refresh, err = openRefresh(ctx, backend); err != nil {
+		return nil, fmt.Errorf("refresh store %s: %w", cfg.RefreshStore, err)
+	}
+	openNonces, err := nonceStores.get(cfg.NonceStore)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Nonces, err = openNonces(ctx, backend); err != nil {
+		return nil, fmt.Errorf("nonce store %s: %w", cfg.NonceStore, err)
+	}
+	if len(cfg.CertUsers) != 0 {
+		users, err := authHandler.LoadCertUsers(cfg.CertUsers)
+		if err != nil {
+			return nil, fmt.Errorf("certificate users: %w", err)
+		}
+		auth.CertUsers = make(map[string]string)
+		for _, u := range users {
+			auth.CertUsers[u.Subject] = u.User
+			if u.Admin {
+				auth.Admins[u.User] = true
+			}
+		}
+	}
+	h := NewHandler(store, auth, logger, clock, dh.UUIDGenerator{})
+	auth.Logger = h.Logs.Warn //failed logins and user stores, and password resets
+	h.Config = cfg
+	h.Secrets = secrets
+	h.TenantOf = HostTenants(cfg.Tenants)
+	if u, err := url.Parse(cfg.BaseURL); len(cfg.BaseURL) != 0 && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0) {
+		return nil, fmt.Errorf("base url %q must be an absolute http or https URL", cfg.BaseURL)
+	}
+	if cfg.StoreReadTimeout < 0 || cfg.StoreWriteTimeout < 0 {
+		return nil, fmt.Errorf("store timeouts can't be negative")
+	}
+	if cfg.StoreAttempts < 1 {
+		return nil, fmt.Errorf("store attempts must be at least 1, got %d", cfg.StoreAttempts)
+	}
+	books := store
+	if len(cfg.ReplicaStore) != 0 {
+		if cfg.ReplicaMaxLag < 0 {
+			return nil, fmt.Errorf("replica max lag can't be negative")
+		}
+		switch cfg.ReplicaStore { //both would open a catalog of their own that never sees the writes
+		case cfg.Store:
+			return nil, fmt.Errorf("replica store %s is --store itself", cfg.ReplicaStore)
+		case "memory":
+			return nil, fmt.Errorf("replica store memory can't replicate --store, use a store a plugin registers")
+		}
+		openReplica, err := stores.get(cfg.ReplicaStore)
+		if err != nil {
+			return nil, err
+		}
+		replica, err := openReplica(ctx, backend)
+		if err != nil {
+			return nil, fmt.Errorf("replica store %s: %w", cfg.ReplicaStore, err)
+		}
+		rs := dh.NewReplicaStore(store, replica, cfg.ReplicaMaxLag)
+		rs.Clock = clock
+		books = rs
+	}
+	retried := dh.NewRetryStore(books, cfg.StoreAttempts)
+	timed := dh.NewTimedStore(dh.NewDeadlineStore(retried, cfg.StoreReadTimeout, cfg.StoreWriteTimeout), h.observeStore)
+	timed.Clock = clock
+	h.Store = dh.NewCoalescingStore(timed, func() { mh.Coalesced.Add(1) })
+	if cfg.CacheMemoryMB < 0 {
+		return nil, fmt.Errorf("cache memory can't be negative")
+	}
+	h.Caches = cacheHandler.NewBudget(int64(cfg.CacheMemoryMB) << 20)
+	h.Caches.Publish()
+	h.Translit = dh.NewTranslitIndex(dh.Romanizer, h.Caches)
+	if cfg.CacheMemoryMB > 0 {
+		cached, err := dh.NewCachedStore(h.Store, cfg.CachePolicy, h.Caches)
+		if err != nil {
+			return nil, err
+		}
+		h.Store = cached
+	}
+	var statements func() mh.StatementStats //of the backend, /metrics reports them
+	if sc, ok := store.(dh.StatementCache); ok {
+		statements = func() mh.StatementStats {
+			prepared, executed, cached := sc.StatementStats()
+			return mh.StatementStats{Prepared: prepared, Executed: executed, Cached: cached}
+		}
+	}
+	mh.PublishStatements(statements)
+	//only book calls go through the retries, deadlines, timing and cache above, the other stores
+	//are the backend as is
+	h.Shelves, _ = store.(dh.ShelfStore)
+	h.Reviews, _ = store.(dh.ReviewStore)
+	h.Inbox, _ = store.(dh.NotificationStore)
+	switch {
+	case len(cfg.S3Bucket) != 0:
+		h.Blobs = blobHandler.NewS3Store(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3Key, cfg.S3Secret)
+	case len(cfg.BlobDir) != 0:
+		blobs, err := blobHandler.NewFSStore(cfg.BlobDir)
+		if err != nil {
+			return nil, fmt.Errorf("blob store: %w", err)
+		}
+		h.Blobs = blobs
+	}
+	if len(cfg.ClamdAddr) != 0 {
+		h.Scanner = blobHandler.NewClamdScanner(cfg.ClamdAddr)
+	}
+	if len(cfg.ModerationWebhook) != 0 {
+		hook := moderationHandler.NewWebhook(cfg.ModerationWebhook, []byte(cfg.ModerationSecret))
+		hook.Client = h.webhookClient(5 * time.Second)
+		h.Moderation = append(h.Moderation.(moderationHandler.Pipeline), hook)
+	}
+	h.Push = pushHandler.Mux{}
+	if len(cfg.VAPIDKey) != 0 {
+		wp, err := pushHandler.NewWebPush(cfg.VAPIDKey, cfg.VAPIDSubject)
+		if err != nil {
+			return nil, fmt.Errorf("web push: %w", err)
+		}
+		h.Push[dh.PlatformWebPush] = wp
+	}
+	if len(cfg.FCMCredentials) != 0 {
+		creds, err := os.ReadFile(cfg.FCMCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("fcm: %w", err)
+		}
+		fcm, err := pushHandler.NewFCM(creds)
+		if err != nil {
+			return nil, err
+		}
+		h.Push[dh.PlatformFCM] = fcm
+	}
+	if len(cfg.SMTPAddr) != 0 {
+		if len(cfg.MailFrom) == 0 {
+			return nil, fmt.Errorf("--mail-from is needed to send mail through %s", cfg.SMTPAddr)
+		}
+		h.Mail = mailHandler.NewSMTP(cfg.SMTPAddr, cfg.MailFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	}
+	filter, err := cfg.AdminFilter()
+	if err != nil {
+		return nil, fmt.Errorf("admin ip rules: %w", err)
+	}
+	h.AdminFilter = filter
+	node := h.IDs.NewID() //names this instance to the other replicas
+	switch cfg.EventBus {
+	case "local":
+	case "redis":
+		bus := eventHandler.NewRedisBus(cfg.RedisAddr, node)
+		bus.Client.Auth = redisAuth
+		h.Events = bus
+	default:
+		return nil, fmt.Errorf("unknown event bus %q", cfg.EventBus)
+	}
+	switch cfg.JobLock {
+	case "local":
+	case "redis":
+		lock := jobHandler.NewRedisLock(cfg.RedisAddr, node)
+		lock.Client.Auth = redisAuth
+		h.Jobs.Lock = lock
+	default:
+		return nil, fmt.Errorf("unknown job lock %q", cfg.JobLock)
+	}
+	h.Events.Subscribe(h.invalidate)
+	h.Events.Subscribe(h.followMaintenance)
+	switch cfg.Warmup {
+	case WarmupOff, WarmupSync, WarmupAsync:
+	default:
+		return nil, fmt.Errorf("unknown warmup mode %q, want off, sync or async", cfg.Warmup)
+	}
+	h.Jobs.Every("catalog reports", time.Hour, h.generateReports)
+	if len(cfg.BookWebhook) != 0 {
+		if err := h.startBookHooks(context.Background()); err != nil {
+			return nil, fmt.Errorf("book webhook: %w", err)
+		}
+	}
+	if cfg.SnapshotInterval > 0 {
+		h.Jobs.Every("catalog snapshot", cfg.SnapshotInterval, func(ctx context.Context) error {
+			_, err := h.takeSnapshot(ctx)
+			return err
+		})
+	}
+	if cfg.ChangeRetention > 0 || cfg.ChangeLogMax > 0 {
+		h.Jobs.Every("change log retention", time.Hour, h.compactChanges)
+	}
+	if cfg.GCInterval > 0 {
+		h.Jobs.Every("garbage collection", cfg.GCInterval, h.collectGarbage)
+	}
+	h.OnWarmup("book cache", h.warmBooks)
+	h.OnWarmup("transliteration index", h.warmTranslit)
+	h.Events.Subscribe(h.dispatch)
+	if len(cfg.RuntimeFile) != 0 {
+		if _, err := h.Reload(); err != nil {
+			return nil, fmt.Errorf("runtime settings: %w", err)
+		}
+	}
+	return h, nil
+}
 
-	if err := http.ListenAndServe("127.0.0.1:8080", r); err != nil {
+// redisCredentials reads the current username and password on every new connection, so rotated
+// credentials are picked up; a secret with a single value is taken as the password
+func redisCredentials(lease *secretHandler.Lease) func() (string, string) {
+	return func() (string, string) {
+		pass, err := lease.Get("password")
+		if err != nil {
+			pass, _ = lease.Value()
+		}
+		user, _ := lease.Get("username")
+		return user, pass
+	}
+}
+
+const ShutdownTimeout = 30 * time.Second //how long in-flight requests get to finish on shutdown or restart
+
+func RunServer(cfg Config) {
+	s, err := NewServer(ServerOptions{Config: cfg})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !s.logChecks(s.SelfCheck(context.Background())) {
+		log.Fatalln("self-check failed, run `BookServer doctor` for details")
+	}
+	s.reloadOnSIGHUP()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		log.Fatalln(err)
+	}
+
+	ln, err := listen(cfg.Addr())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	srv := &http.Server{Handler: s}
+	done := handleSignals(srv, ln, s.Logger) //restarts hand over the plain socket, TLS is set up again on top
+	notifyParent()
+
+	tlsConf, err := cfg.TLSConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	serveOn := ln
+	if tlsConf != nil {
+		serveOn = tls.NewListener(ln, tlsConf)
+	}
+	if err := srv.Serve(serveOn); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalln(err)
 	}
+	<-done
 }
@@ -0,0 +1,102 @@
+package apiHandler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+type AuthorStats struct {
+	Name  string `json:"name"` //the most common spelling in the catalog
+	Key   string `json:"key"`  //canonical key, pass as ?author= to filter books
+	Books int    `json:"books"`
+}
+
+// getAuthors lists authors with their book counts, spellings of one author counted together, GET /api/v1/authors
+func (h *Handler) getAuthors(w http.ResponseWriter, r *http.Request) error {
+	books, err := h.Store.ListBooks(r.Context())
+	if err != nil {
+		return err
+	}
+
+	type tally struct {
+		books     int
+		spellings map[string]int
+	}
+	byKey := make(map[string]*tally)
+	for _, b := range books {
+		seen := make(map[string]bool) //an author listed twice on one book counts once
+		for _, a := range b.Authors {
+			key := h.Authors.Resolve(a.Name)
+			if len(key) == 0 {
+				continue
+			}
+			t := byKey[key]
+			if t == nil {
+				t = &tally{spellings: make(map[string]int)}
+				byKey[key] = t
+			}
+			t.spellings[strings.TrimSpace(a.Name)]++
+			if !seen[key] {
+				seen[key] = true
+				t.books++
+			}
+		}
+	}
+
+	stats := make([]AuthorStats, 0, len(byKey))
+	for key, t := range byKey {
+		s := AuthorStats{Key: key, Books: t.books}
+		for name, n := range t.spellings {
+			if n > t.spellings[s.Name] || (n == t.spellings[s.Name] && name < s.Name) {
+				s.Name = name
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Books != stats[j].Books {
+			return stats[i].Books > stats[j].Books
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	writeJSON(w, r, http.StatusOK, stats)
+	return nil
+}
+
+type AliasRequest struct {
+	Alias     string `json:"alias"`
+	Canonical string `json:"canonical"`
+}
+
+// getAliases shows the author alias table by key, GET /admin/authors/aliases
+func (h *Handler) getAliases(w http.ResponseWriter, r *http.Request) error {
+	writeJSON(w, r, http.StatusOK, h.Authors.All())
+	return nil
+}
+
+// setAlias makes one spelling resolve to another, PUT /admin/authors/aliases
+func (h *Handler) setAlias(w http.ResponseWriter, r *http.Request) error {
+	var req AliasRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(req.Alias)) == 0 || len(strings.TrimSpace(req.Canonical)) == 0 {
+		return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidData)
+	}
+	h.Authors.Set(req.Alias, req.Canonical)
+	h.logf(r, "authors: %q is now an alias of %q", req.Alias, req.Canonical)
+	writeJSON(w, r, http.StatusOK, h.Authors.All())
+	return nil
+}
+
+// deleteAlias removes an alias, DELETE /admin/authors/aliases/{alias}
+func (h *Handler) deleteAlias(w http.ResponseWriter, r *http.Request) error {
+	h.Authors.Delete(chi.URLParam(r, "alias"))
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
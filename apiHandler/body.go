@@ -0,0 +1,58 @@
+package apiHandler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// bytes of the JSON bodies the handlers read whole, larger ones are refused with 413
+const (
+	MaxBookBody     = 64 << 10 //a book, created, replaced or patched
+	MaxPostBody     = 64 << 10 //a review, comment or report, MaxPostLength characters fit escaped
+	MaxSettingsBody = 8 << 10  //preferences, devices, invitations and other small settings
+)
+
+var errInvalidUTF8 = errors.New("request body is not valid UTF-8")
+
+// readUTF8 reads r's whole body up to limit bytes, refusing one that isn't valid UTF-8; encoding/json
+// would otherwise quietly turn the bad bytes into U+FFFD and the book would be stored garbled.
+// Larger bodies fail with a *http.MaxBytesError.
+func readUTF8(r *http.Request, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	if !utf8.Valid(data) {
+		return nil, errInvalidUTF8
+	}
+	return data, nil
+}
+
+// readText decodes a JSON request body of at most limit bytes carrying user text, a book or a review,
+// into v, failing with 413 when it is larger and 400 when it can't be decoded
+func readText(r *http.Request, limit int64, v any) error {
+	data, err := readUTF8(r, limit)
+	if err == nil {
+		err = ch.Decode(bytes.NewReader(data), v, ch.Of(r))
+	}
+	var tooBig *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooBig):
+		return eh.New(http.StatusRequestEntityTooLarge, eh.BodyTooLarge, i18n.BodyTooLarge, tooBig.Limit>>10)
+	case errors.Is(err, errInvalidUTF8):
+		return eh.New(http.StatusBadRequest, eh.InvalidEncoding, i18n.InvalidUTF8)
+	case err != nil:
+		return eh.New(http.StatusBadRequest, eh.InvalidBody, i18n.CannotDecode)
+	}
+	return nil
+}
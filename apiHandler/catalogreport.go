@@ -0,0 +1,239 @@
+package apiHandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/blobHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// report periods
+const (
+	Weekly  = "weekly"  //Monday to Monday, UTC
+	Monthly = "monthly" //calendar months, UTC
+)
+
+const reportIndexKey = "reports/index.json"
+
+// CatalogReport sums up what happened to the catalog in a week or month. Lending isn't tracked by
+// this server, so there are no borrow or overdue figures.
+type CatalogReport struct {
+	Name         string        `json:"name"` //e.g. weekly-2026-W41 or monthly-2026-09
+	Period       string        `json:"period"`
+	From         time.Time     `json:"from"`
+	To           time.Time     `json:"to"` //exclusive
+	GeneratedAt  time.Time     `json:"generated_at"`
+	TotalBooks   int           `json:"total_books"`  //in the catalog when it was generated
+	Acquisitions []Acquisition `json:"acquisitions"` //books added in the period, oldest first
+}
+
+type Acquisition struct {
+	ID      string    `json:"id"`
+	ISBN    string    `json:"isbn,omitempty"`
+	Name    string    `json:"name"`
+	Authors string    `json:"authors"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// ReportInfo lists a stored report, its JSON and CSV are under reports/<name>.json and .csv in the blob store
+type ReportInfo struct {
+	Name         string    `json:"name"`
+	Period       string    `json:"period"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	GeneratedAt  time.Time `json:"generated_at"`
+	Acquisitions int       `json:"acquisitions"`
+}
+
+// lastPeriod is the latest complete week or month before now
+func lastPeriod(period string, now time.Time) (string, time.Time, time.Time) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if period == Weekly {
+		to := today.AddDate(0, 0, -(int(today.Weekday())+6)%7) //this Monday
+		from := to.AddDate(0, 0, -7)
+		year, week := from.ISOWeek()
+		return fmt.Sprintf("weekly-%d-W%02d", year, week), from, to
+	}
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, -1, 0)
+	return "monthly-" + from.Format("2006-01"), from, to
+}
+
+// errPeriodEnd stops catalogReport's scan at the first change after the period
+var errPeriodEnd = errors.New("past the report period")
+
+// catalogReport reads the change log for the books created between from and to, starting at the
+// first change committed in the period
+func (h *Handler) catalogReport(ctx context.Context, name, period string, from, to time.Time) (CatalogReport, error) {
+	rep := CatalogReport{Name: name, Period: period, From: from, To: to, GeneratedAt: h.Clock.Now(), Acquisitions: []Acquisition{}}
+	after, err := dh.ChangesFrom(ctx, h.Store, from)
+	if err != nil {
+		return rep, err
+	}
+	err = dh.EachChange(ctx, h.Store, after, func(c dh.Change) error {
+		if !c.At.Before(to) {
+			return errPeriodEnd //the log is in commit order
+		}
+		if c.Op != dh.OpCreate || c.Book == nil || c.At.Before(from) {
+			return nil
+		}
+		authors := make([]string, 0, len(c.Book.Authors))
+		for _, a := range c.Book.Authors {
+			authors = append(authors, a.Name)
+		}
+		rep.Acquisitions = append(rep.Acquisitions, Acquisition{ID: c.ID, ISBN: c.ISBN, Name: c.Book.Name, Authors: strings.Join(authors, "; "), AddedAt: c.At})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPeriodEnd) {
+		return rep, err
+	}
+	books, err := h.Store.ListBooks(ctx)
+	if err != nil {
+		return rep, err
+	}
+	rep.TotalBooks = len(books)
+	return rep, nil
+}
+
+func reportCSV(rep CatalogReport) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"added_at", "id", "isbn", "name", "authors"})
+	for _, a := range rep.Acquisitions {
+		w.Write([]string{a.AddedAt.Format(time.RFC3339), a.ID, a.ISBN, a.Name, a.Authors})
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+func (h *Handler) reportIndex(ctx context.Context) ([]ReportInfo, error) {
+	index := []ReportInfo{}
+	_, err := h.readBlobJSON(ctx, reportIndexKey, &index)
+	return index, err
+}
+
+// generateReports writes the reports of the last complete week and month unless they exist, mailing
+// them to Config.ReportEmail. It runs hourly on h.Jobs, so a missed run catches up within the hour.
+// Runs are serialized, so one started by POST /admin/catalog-reports/run while the job writes a
+// report finds it in the index.
+func (h *Handler) generateReports(ctx context.Context) error {
+	h.indexes.Lock()
+	defer h.indexes.Unlock()
+	index, err := h.reportIndex(ctx)
+	if err != nil {
+		return err
+	}
+	done := make(map[string]bool, len(index))
+	for _, info := range index {
+		done[info.Name] = true
+	}
+	now := h.Clock.Now()
+	for _, period := range []string{Weekly, Monthly} {
+		name, from, to := lastPeriod(period, now)
+		if done[name] {
+			continue
+		}
+		rep, err := h.catalogReport(ctx, name, period, from, to)
+		if err != nil {
+			return fmt.Errorf("report %s: %w", name, err)
+		}
+		data, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return err
+		}
+		table, err := reportCSV(rep)
+		if err != nil {
+			return err
+		}
+		if _, err := h.Blobs.Put(ctx, "reports/"+name+".json", "application/json", bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("report %s: %w", name, err)
+		}
+		if _, err := h.Blobs.Put(ctx, "reports/"+name+".csv", "text/csv; charset=utf-8", bytes.NewReader(table)); err != nil {
+			return fmt.Errorf("report %s: %w", name, err)
+		}
+		index = append(index, ReportInfo{Name: name, Period: period, From: from, To: to, GeneratedAt: rep.GeneratedAt, Acquisitions: len(rep.Acquisitions)})
+		if err := h.writeBlobJSON(ctx, reportIndexKey, index); err != nil {
+			return fmt.Errorf("report index: %w", err)
+		}
+		h.Logs.Info.Printf("reports: %s written, %d books added", name, len(rep.Acquisitions))
+		h.mailReport(ctx, rep)
+	}
+	return nil
+}
+
+// mailReport sends a report's summary to Config.ReportEmail, failures are logged
+func (h *Handler) mailReport(ctx context.Context, rep CatalogReport) {
+	if h.Mail == nil || len(h.Config.ReportEmail) == 0 {
+		return
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s to %s\n\n", rep.From.Format(time.DateOnly), rep.To.AddDate(0, 0, -1).Format(time.DateOnly))
+	fmt.Fprintf(&body, "Books added: %d\nBooks in the catalog: %d\n\n", len(rep.Acquisitions), rep.TotalBooks)
+	for _, a := range rep.Acquisitions {
+		fmt.Fprintf(&body, "%s  %s, %s\n", a.AddedAt.Format(time.DateOnly), a.Name, a.Authors)
+	}
+	fmt.Fprintf(&body, "\nThe full report is at GET /admin/catalog-reports/%s.csv and .json\n", rep.Name)
+	subject := fmt.Sprintf("BookServer %s report: %d books added", rep.Name, len(rep.Acquisitions))
+	for _, to := range h.Config.ReportEmail {
+		if err := h.Mail.Send(ctx, to, subject, body.String()); err != nil {
+			h.Logs.Error.Printf("reports: mailing %s to %s: %v", rep.Name, to, err)
+		}
+	}
+}
+
+// getCatalogReports lists the stored reports, newest first, GET /admin/catalog-reports
+func (h *Handler) getCatalogReports(w http.ResponseWriter, r *http.Request) error {
+	index, err := h.reportIndex(r.Context())
+	if err != nil {
+		return fmt.Errorf("reports: %w", err)
+	}
+	sort.SliceStable(index, func(i, j int) bool { return index[i].GeneratedAt.After(index[j].GeneratedAt) })
+	writeJSON(w, r, http.StatusOK, index)
+	return nil
+}
+
+// runCatalogReports writes the reports due now instead of waiting for the hourly job,
+// POST /admin/catalog-reports/run
+func (h *Handler) runCatalogReports(w http.ResponseWriter, r *http.Request) error {
+	if err := h.generateReports(r.Context()); err != nil {
+		return fmt.Errorf("reports: %w", err)
+	}
+	return h.getCatalogReports(w, r)
+}
+
+// getCatalogReport downloads a report, GET /admin/catalog-reports/{name}.json or .csv
+func (h *Handler) getCatalogReport(w http.ResponseWriter, r *http.Request) error {
+	format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string)
+	if format != "csv" {
+		format = "json"
+	}
+	name := chi.URLParam(r, "name")
+	if strings.ContainsAny(name, "/.") {
+		return routeNotFound()
+	}
+	f, info, err := h.Blobs.Get(r.Context(), "reports/"+name+"."+format)
+	if errors.Is(err, blobHandler.ErrNotFound) {
+		return routeNotFound()
+	}
+	if err != nil {
+		return fmt.Errorf("reports: %w", err)
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format}))
+	http.ServeContent(w, r, "", info.Modified, f)
+	return nil
+}
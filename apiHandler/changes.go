@@ -0,0 +1,83 @@
+package apiHandler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+const (
+	changesPageSize    = 100
+	changesMaxPageSize = 1000
+)
+
+type ChangeResponse struct {
+	Seq  int64            `json:"seq"`
+	Op   string           `json:"op"` //create, update or delete
+	ID   string           `json:"id"`
+	ISBN string           `json:"isbn,omitempty"`
+	Book *BookResponse    `json:"book,omitempty"` //state after the change, missing for deletes
+	Diff []dh.FieldChange `json:"diff,omitempty"` //fields an update changed
+	At   time.Time        `json:"at"`
+}
+
+func NewChangeResponse(c dh.Change, prefix string) ChangeResponse {
+	cr := ChangeResponse{Seq: c.Seq, Op: c.Op, ID: c.ID, ISBN: c.ISBN, Diff: c.Diff, At: c.At}
+	if c.Book != nil {
+		b := NewBookResponse(*c.Book, prefix)
+		cr.Book = &b
+	}
+	return cr
+}
+
+type ChangesResponse struct {
+	Changes    []ChangeResponse `json:"changes"`
+	NextCursor string           `json:"next_cursor"` //pass back as ?cursor= to continue
+	HasMore    bool             `json:"has_more"`
+}
+
+// getChanges returns the change feed after ?cursor= (start without one), at most ?limit= entries,
+// GET /changes. Clients replay the changes in order and keep next_cursor for their next poll.
+func (h *Handler) getChanges(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	var after int64
+	if v := q.Get("cursor"); len(v) != 0 {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return eh.New(http.StatusBadRequest, eh.InvalidCursor, i18n.InvalidCursor)
+		}
+		after = n
+	}
+	limit := changesPageSize
+	if v := q.Get("limit"); len(v) != 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidData)
+		}
+		limit = min(n, changesMaxPageSize)
+	}
+
+	log, ok := h.Store.(dh.ChangeLog)
+	if !ok {
+		return dh.ErrNoChangeLog
+	}
+	changes, err := log.Changes(r.Context(), after, limit+1) //one extra tells whether more follow
+	if err != nil {
+		return err
+	}
+
+	resp := ChangesResponse{Changes: make([]ChangeResponse, 0, len(changes)), NextCursor: strconv.FormatInt(after, 10)}
+	if len(changes) > limit {
+		changes, resp.HasMore = changes[:limit], true
+	}
+	for _, c := range changes {
+		resp.Changes = append(resp.Changes, NewChangeResponse(c, h.Prefix))
+		resp.NextCursor = strconv.FormatInt(c.Seq, 10)
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+	return nil
+}
@@ -0,0 +1,35 @@
+package apiHandler
+
+import (
+	"fmt"
+	"net/http"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/exportHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+// getCitation renders a book as a citation, GET /api/v1/books/{ref}/citation?style=bibtex|apa|mla
+func (h *Handler) getCitation(w http.ResponseWriter, r *http.Request) error {
+	style := r.URL.Query().Get("style")
+	if len(style) == 0 {
+		style = "bibtex"
+	}
+	contentType, ok := exportHandler.CitationStyles[style]
+	if !ok {
+		return eh.New(http.StatusBadRequest, eh.InvalidFormat, i18n.InvalidFormat, style)
+	}
+
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	citation, err := exportHandler.Citation(book, style)
+	if err != nil {
+		return eh.New(http.StatusInternalServerError, eh.Internal, i18n.InternalError).WithCause(fmt.Errorf("citation: %w", err))
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(citation))
+	return nil
+}
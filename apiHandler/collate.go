@@ -0,0 +1,76 @@
+package apiHandler
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strings"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// titleCollator compares titles the way readers of ?locale= expect, e.g. ?locale=de puts "Äpfel"
+// next to "Apfel" and ?locale=bn orders Bengali titles by their script. Without ?locale= the
+// language picked from Accept-Language is used. Collators are not safe for concurrent use.
+func titleCollator(r *http.Request) (*collate.Collator, error) {
+	tag, err := language.Parse(titleLocale(r))
+	if err != nil {
+		return nil, err
+	}
+	return collate.New(tag, collate.IgnoreCase), nil
+}
+
+// titleLocale is the language titles are collated for, ?locale= or the Accept-Language one
+func titleLocale(r *http.Request) string {
+	if locale := r.URL.Query().Get("locale"); len(locale) != 0 {
+		return locale
+	}
+	return i18n.Lang(r)
+}
+
+// bookPos is where a book falls in a listing: its sort key, then its ISBN and ID to order books with
+// equal keys, so every book has one place whatever order the store returned them in
+type bookPos struct {
+	key      []byte
+	isbn, id string
+}
+
+func (p bookPos) compare(q bookPos, desc bool) int {
+	if c := bytes.Compare(p.key, q.key); c != 0 {
+		if desc {
+			return -c
+		}
+		return c
+	}
+	if c := strings.Compare(p.isbn, q.isbn); c != 0 {
+		return c
+	}
+	return strings.Compare(p.id, q.id)
+}
+
+type bookOrder struct { //books with their positions, sorted together
+	books []dh.Book
+	pos   []bookPos
+	desc  bool
+}
+
+func (o bookOrder) Len() int           { return len(o.books) }
+func (o bookOrder) Less(i, j int) bool { return o.pos[i].compare(o.pos[j], o.desc) < 0 }
+func (o bookOrder) Swap(i, j int) {
+	o.books[i], o.books[j] = o.books[j], o.books[i]
+	o.pos[i], o.pos[j] = o.pos[j], o.pos[i]
+}
+
+// sortBooks orders books by key, computing each key once instead of on every comparison, and returns
+// their positions in the new order
+func sortBooks(books []dh.Book, key func(dh.Book) []byte, desc bool) []bookPos {
+	o := bookOrder{books: books, pos: make([]bookPos, len(books)), desc: desc}
+	for i, b := range books {
+		o.pos[i] = bookPos{key: key(b), isbn: b.ISBN, id: b.ID}
+	}
+	sort.Sort(o)
+	return o.pos
+}
@@ -0,0 +1,180 @@
+package apiHandler
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	"github.com/Sabnaj-42/BookServer-API/ipHandler"
+)
+
+type Config struct { //server settings, filled from the start command flags
+	Port    int
+	CSP     string //Content-Security-Policy header, empty disables it
+	BaseURL string //scheme and host clients reach the server at, e.g. https://books.example.com; links the API hands out start with it, empty makes them relative
+
+	Store          string            //registered book store, "memory" or one added with RegisterStore
+	UserStore      string            //registered user store logins are checked against, empty for Store
+	ReplicaStore   string            //registered book store reads go to, a read replica of Store other than Store and memory; empty reads from Store
+	ReplicaMaxLag  time.Duration     //how long after a write of this instance book reads stay on Store
+	Plugins        []string          //Go plugins loaded at startup, whose init functions register more backends
+	BackendOptions map[string]string //settings of registered backends that have no flag of their own
+
+	AuthMode     authHandler.Mode
+	SessionStore string //"memory", "redis" or one added with RegisterSessionStore, for the session auth mode
+	RefreshStore string //"memory", "redis" or one added with RegisterRefreshStore, where remember-me logins are kept
+	RedisAddr    string
+	EventBus     string //"local" or "redis", how book changes reach other replicas
+	JobLock      string //"local" or "redis", which replica runs background jobs
+	BasicAuth    bool   //accept HTTP Basic credentials, for scripts and monitoring
+	SigningKeys  string //JSON file of HMAC keys for signed machine requests, empty refuses them
+	NonceStore   string //"memory", "redis" or one added with RegisterNonceStore, where nonces of signed requests are remembered
+
+	TLSCert     string //PEM certificate, with TLSKey the listener serves HTTPS
+	TLSKey      string
+	ClientCA    string //PEM bundle client certificates are verified against, empty asks for none
+	ClientCerts string //"optional" or "require", whether connections without a client certificate are accepted
+	CertUsers   string //JSON file mapping client certificate subjects to users
+
+	Admins         []string //usernames allowed on /admin
+	AdminAllow     []string //CIDRs allowed on /admin, empty allows all
+	AdminDeny      []string //CIDRs refused on /admin
+	TrustedProxies []string //CIDRs of proxies whose X-Forwarded-For, X-Forwarded-Proto and X-Request-Id are believed
+	Tenants        []string //host names billed as tenants of their own in /admin/usage, requests to others go to the default tenant
+
+	StoreReadTimeout  time.Duration //how long a book storage read may take before the request fails with 504, 0 for no limit
+	StoreWriteTimeout time.Duration //the same for book storage writes
+	StoreAttempts     int           //how often a storage call failing with a transient error is tried, within the timeouts
+	SlowStoreCall     time.Duration //book storage calls taking this long or longer are logged and counted, 0 for none
+
+	CacheMemoryMB int    //memory budget shared by the in-process caches, 0 disables caching
+	CachePolicy   string //"lru" or "lfu", what the caches evict first
+	Warmup        string //WarmupOff, WarmupSync or WarmupAsync
+
+	RuntimeFile string //JSON file with Runtime settings, reloaded on SIGHUP
+	BlobDir     string //directory for covers and e-book files, empty keeps them in memory
+
+	S3Endpoint string //S3 or MinIO endpoint, with S3Bucket set blobs go there instead of BlobDir
+	S3Region   string
+	S3Bucket   string
+	S3Key      string //access key id, from $AWS_ACCESS_KEY_ID
+	S3Secret   string //secret access key, from $AWS_SECRET_ACCESS_KEY
+	ClamdAddr  string //clamd address uploads are scanned with, host:port or a unix socket path, empty skips scanning
+
+	ModerationWebhook    string //URL of an external service judging new reviews and comments after the banned words
+	ModerationSecret     string //HMAC key signing the webhook calls, from $BOOKSERVER_MODERATION_SECRET
+	BookWebhook          string //URL every book change is POSTed to, with the fields updates changed; empty posts none
+	BookWebhookSecret    string //HMAC key signing those calls, from $BOOKSERVER_WEBHOOK_SECRET
+	AllowPrivateWebhooks bool   //let the webhooks above call hosts inside private networks
+	OutboundAttempts     int    //how often an outbound HTTP call failing with a network error or 429, 502, 503 or 504 is tried
+	OutboundProxy        string //proxy URL for outbound HTTP calls, empty honors $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY
+
+	VAPIDKey       string //Web Push private key from `BookServer secret vapid-keygen`, from $BOOKSERVER_VAPID_KEY; empty sends no browser pushes
+	VAPIDSubject   string //mailto: or https: contact push services reach the operator at
+	FCMCredentials string //Firebase service account key file, empty sends no app pushes
+
+	SMTPAddr     string   //mail server notification emails go through, host:port; empty sends none
+	MailFrom     string   //sender of notification emails
+	SMTPUsername string   //from $BOOKSERVER_SMTP_USERNAME, empty skips authentication
+	SMTPPassword string   //from $BOOKSERVER_SMTP_PASSWORD
+	ReportEmail  []string //addresses the weekly and monthly catalog reports are mailed to
+
+	SnapshotInterval time.Duration //how often the catalog is backed up to the blob store for /admin/diff, 0 only on request
+	GCInterval       time.Duration //how often unused blobs and expired tokens are deleted, 0 never
+
+	ChangeRetention time.Duration //age after which the change log is compacted, 0 keeps every change
+	ChangeLogMax    int           //latest changes the log always keeps in full, older ones are compacted; 0 for no limit
+	ChangeArchive   bool          //write the updates compaction drops to the blob store under changes/ first
+
+	PasswordMinLength int  //characters new passwords need at least
+	PasswordClasses   int  //how many of lower case, upper case, digits and symbols new passwords mix
+	BreachCheck       bool //refuse new passwords found in the Have I Been Pwned range API
+
+	JWTSecret      string //HS256 key, from $BOOKSERVER_JWT_SECRET when set
+	URLSigningKey  string //HMAC key of signed URLs, from $BOOKSERVER_URL_SIGNING_KEY; derived from JWTSecret when unset
+	EncryptionKeys string //AES-256 keys for what the Redis session and refresh stores keep and for --signing-keys secrets, "id:base64,..." newest first, from $BOOKSERVER_ENCRYPTION_KEYS
+
+	JWTSecretFrom string //secret reference such as vault:secret/data/bookserver#jwt_secret, fetched at startup instead of JWTSecret
+	RedisAuthFrom string //secret reference holding redis "username" and "password", e.g. vault:database/creds/bookserver
+	VaultAddr     string //from $VAULT_ADDR
+	VaultToken    string //from $VAULT_TOKEN
+	KMSRegion     string //region of the KMS key kms: references were encrypted with, from $AWS_REGION
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Port:              8080,
+		CSP:               DefaultCSP,
+		Store:             "memory",
+		AuthMode:          authHandler.ModeJWT,
+		SessionStore:      "memory",
+		RefreshStore:      "memory",
+		NonceStore:        "memory",
+		ClientCerts:       "optional",
+		RedisAddr:         "127.0.0.1:6379",
+		EventBus:          "local",
+		JobLock:           "local",
+		Admins:            []string{"Admin"},
+		S3Endpoint:        "https://s3.amazonaws.com",
+		S3Region:          "us-east-1",
+		S3Key:             os.Getenv("AWS_ACCESS_KEY_ID"),
+		S3Secret:          os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		StoreReadTimeout:  5 * time.Second,
+		StoreWriteTimeout: 10 * time.Second,
+		StoreAttempts:     3,
+		ReplicaMaxLag:     5 * time.Second,
+		OutboundAttempts:  3,
+		SlowStoreCall:     500 * time.Millisecond,
+		GCInterval:        6 * time.Hour,
+		CacheMemoryMB:     64,
+		CachePolicy:       "lru",
+		Warmup:            WarmupOff,
+		PasswordMinLength: authHandler.DefaultPasswordPolicy.MinLength,
+		JWTSecret:         jwtSecretFromEnv(),
+		URLSigningKey:     os.Getenv("BOOKSERVER_URL_SIGNING_KEY"),
+		EncryptionKeys:    os.Getenv("BOOKSERVER_ENCRYPTION_KEYS"),
+		VaultAddr:         os.Getenv("VAULT_ADDR"),
+		VaultToken:        os.Getenv("VAULT_TOKEN"),
+		KMSRegion:         kmsRegionFromEnv(),
+		ModerationSecret:  os.Getenv("BOOKSERVER_MODERATION_SECRET"),
+		BookWebhookSecret: os.Getenv("BOOKSERVER_WEBHOOK_SECRET"),
+		VAPIDKey:          os.Getenv("BOOKSERVER_VAPID_KEY"),
+		SMTPUsername:      os.Getenv("BOOKSERVER_SMTP_USERNAME"),
+		SMTPPassword:      os.Getenv("BOOKSERVER_SMTP_PASSWORD"),
+	}
+}
+
+func jwtSecretFromEnv() string {
+	if s := os.Getenv("BOOKSERVER_JWT_SECRET"); len(s) != 0 {
+		return s
+	}
+	return string(authHandler.Secret)
+}
+
+func kmsRegionFromEnv() string {
+	if s := os.Getenv("AWS_REGION"); len(s) != 0 {
+		return s
+	}
+	return "us-east-1"
+}
+
+func (c Config) Addr() string { //address the server listens on
+	return fmt.Sprintf("127.0.0.1:%d", c.Port)
+}
+
+// AdminFilter parses the admin IP rules
+func (c Config) AdminFilter() (ipHandler.Filter, error) {
+	var f ipHandler.Filter
+	var err error
+	if f.Allow, err = ipHandler.ParsePrefixes(c.AdminAllow); err != nil {
+		return f, err
+	}
+	if f.Deny, err = ipHandler.ParsePrefixes(c.AdminDeny); err != nil {
+		return f, err
+	}
+	if f.Trusted, err = ipHandler.ParsePrefixes(c.TrustedProxies); err != nil {
+		return f, err
+	}
+	return f, nil
+}
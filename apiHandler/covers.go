@@ -0,0 +1,178 @@
+package apiHandler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Sabnaj-42/BookServer-API/blobHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/imageHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	coverKeyPrefix   = "covers/sha256/"   //original covers by checksum
+	variantKeyPrefix = "covers/variants/" //followed by the original's checksum, the size and the extension
+	webp             = "image/webp"
+)
+
+func variantKey(sum, size, contentType string) string {
+	return variantKeyPrefix + sum + "/" + size + imageHandler.Extensions[contentType]
+}
+
+// putCover sets a JPEG or PNG cover sent as the request body, PUT /api/v1/books/{ref}/cover (admins).
+// The smaller sizes, and WebP copies when a WebP encoder is registered, are made by a background job.
+func (h *Handler) putCover(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	f, mt, err := h.receiveUpload(w, r, "image/jpeg", "image/png")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, cfg, err := imageHandler.Decode(f)
+	if errors.Is(err, imageHandler.ErrTooLarge) {
+		return eh.New(http.StatusRequestEntityTooLarge, eh.ImageTooLarge, i18n.ImageTooLarge, imageHandler.MaxPixels/1_000_000)
+	}
+	if err != nil {
+		return eh.New(http.StatusUnprocessableEntity, eh.InvalidImage, i18n.InvalidImage)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("cover: %w", err))
+	}
+	info, err := blobHandler.PutDeduped(r.Context(), h.Blobs, coverKeyPrefix, mt, f)
+	if err != nil {
+		return eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("cover: %w", err))
+	}
+	h.meter(r, Usage{BytesUploaded: info.Size})
+
+	book.Cover = &dh.BookCover{Key: info.Key, ContentType: mt, SHA256: info.SHA256, Width: cfg.Width, Height: cfg.Height}
+	book.Touch(h.Clock.Now())
+	if err := h.Store.UpdateBook(r.Context(), book); err != nil {
+		return err
+	}
+	h.Queue.Enqueue("cover "+info.SHA256, h.coverVariants(*book.Cover))
+	h.publish(r, eventHandler.BookUpdated, book)
+	writeJSON(w, r, http.StatusOK, NewBookResponse(book, h.Prefix))
+	return nil
+}
+
+// coverVariants makes the resized and re-encoded copies of c, until they exist the original is served
+func (h *Handler) coverVariants(c dh.BookCover) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		f, _, err := h.Blobs.Get(ctx, c.Key)
+		if err != nil {
+			return err
+		}
+		img, _, err := imageHandler.Decode(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		types := []string{c.ContentType}
+		if _, ok := imageHandler.EncoderFor(webp); ok {
+			types = append(types, webp)
+		}
+		for size, width := range imageHandler.Sizes {
+			small := imageHandler.Resize(img, width)
+			for _, ct := range types {
+				if ct == c.ContentType && small == img { //same as the original
+					continue
+				}
+				enc, _ := imageHandler.EncoderFor(ct)
+				var buf bytes.Buffer
+				if err := enc(&buf, small); err != nil {
+					return err
+				}
+				if _, err := h.Blobs.Put(ctx, variantKey(c.SHA256, size, ct), ct, &buf); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// getCover serves a cover, GET /api/v1/books/{ref}/cover?size=thumbnail|medium|original. With a
+// WebP encoder registered, clients that accept WebP get it once that variant has been made. Until
+// the wanted variant exists a stand-in is served, which must not be cached in its place.
+func (h *Handler) getCover(w http.ResponseWriter, r *http.Request) error {
+	size := r.URL.Query().Get("size")
+	if len(size) == 0 {
+		size = "original"
+	}
+	if _, ok := imageHandler.Sizes[size]; !ok {
+		return eh.New(http.StatusBadRequest, eh.InvalidSize, i18n.InvalidSize, size)
+	}
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	if book.Cover == nil {
+		return eh.New(http.StatusNotFound, eh.CoverNotFound, i18n.CoverNotFound)
+	}
+
+	var keys []string //best first
+	if _, ok := imageHandler.EncoderFor(webp); ok {
+		w.Header().Set("Vary", "Accept")
+		if strings.Contains(r.Header.Get("Accept"), webp) {
+			keys = append(keys, variantKey(book.Cover.SHA256, size, webp))
+		}
+	}
+	if size != "original" {
+		keys = append(keys, variantKey(book.Cover.SHA256, size, book.Cover.ContentType))
+	}
+	keys = append(keys, book.Cover.Key)
+
+	for i, key := range keys {
+		f, info, err := h.Blobs.Get(r.Context(), key)
+		if errors.Is(err, blobHandler.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("cover: %w", err))
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", info.ContentType)
+		if i == 0 {
+			w.Header().Set("Cache-Control", "public, max-age=86400")
+		} else {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		http.ServeContent(w, r, "", info.Modified, f)
+		return nil
+	}
+	return eh.New(http.StatusNotFound, eh.CoverNotFound, i18n.CoverNotFound)
+}
+
+// deleteCover detaches the cover, DELETE /api/v1/books/{ref}/cover (admins)
+func (h *Handler) deleteCover(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	if book.Cover == nil {
+		return eh.New(http.StatusNotFound, eh.CoverNotFound, i18n.CoverNotFound)
+	}
+	book.Cover = nil
+	book.Touch(h.Clock.Now())
+	if err := h.Store.UpdateBook(r.Context(), book); err != nil {
+		return err
+	}
+	h.publish(r, eventHandler.BookUpdated, book)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
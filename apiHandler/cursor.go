@@ -0,0 +1,70 @@
+package apiHandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	booksPageSize    = 100
+	booksMaxPageSize = 1000
+)
+
+var errBadCursor = errors.New("invalid cursor")
+
+// bookCursor is the position after the last book of a page: its sort key, ISBN and ID, which together
+// order every book one way. The next page starts after that position rather than at an offset, so
+// books created or deleted in between don't shift it and none are skipped or shown twice.
+type bookCursor struct {
+	Sort   string `json:"s"`           //?sort= the cursor was made for, "-" included
+	Locale string `json:"l,omitempty"` //?locale= for sort=name
+	Key    string `json:"k"`
+	ISBN   string `json:"i,omitempty"`
+	ID     string `json:"d"`
+}
+
+// cursorMAC signs cursors with a key derived from the JWT secret, which every replica shares, so a
+// cursor handed out by one replica is accepted by the others
+func (h *Handler) cursorMAC(payload []byte) []byte {
+	m := hmac.New(sha256.New, []byte(h.Config.JWTSecret))
+	m.Write([]byte("book cursor\n"))
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+// encodeCursor makes c opaque, its JSON and signature in base64url separated by a dot
+func (h *Handler) encodeCursor(c bookCursor) string {
+	payload, _ := json.Marshal(c) //only strings
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(h.cursorMAC(payload))
+}
+
+// decodeCursor checks the signature of a cursor from encodeCursor, errBadCursor when it was altered
+// or made for another sort order
+func (h *Handler) decodeCursor(s, sort, locale string) (bookCursor, error) {
+	var c bookCursor
+	p, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return c, errBadCursor
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(p)
+	if err != nil {
+		return c, errBadCursor
+	}
+	mac, err := enc.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, h.cursorMAC(payload)) {
+		return c, errBadCursor
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, errBadCursor
+	}
+	if c.Sort != sort || c.Locale != locale {
+		return c, errBadCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,88 @@
+package apiHandler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	mh "github.com/Sabnaj-42/BookServer-API/metricsHandler"
+	trace "github.com/Sabnaj-42/BookServer-API/traceHandler"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type LatencyStats struct {
+	PerMinute int64   `json:"per_minute"`
+	ErrorRate float64 `json:"error_rate"`
+	AvgMS     float64 `json:"avg_ms"`
+	MaxMS     float64 `json:"max_ms"`
+}
+
+type Dashboard struct { //GET /admin/dashboard, everything covers the last minute unless named otherwise
+	Requests    LatencyStats `json:"requests"` //5xx responses are errors
+	Storage     LatencyStats `json:"storage"`  //book store calls
+	PendingJobs int          `json:"pending_jobs"`
+	Panics      int64        `json:"panics_total"`
+	Coalesced   int64        `json:"coalesced_total"` //book lookups that shared a concurrent identical lookup
+	Maintenance bool         `json:"maintenance"`
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+func latencyStats(s mh.Summary) LatencyStats {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return LatencyStats{PerMinute: s.Count, ErrorRate: s.ErrorRate(), AvgMS: ms(s.Avg), MaxMS: ms(s.Max)}
+}
+
+// countRequests feeds mh.Requests for the dashboard
+func (h *Handler) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := h.Clock.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			now := h.Clock.Now()
+			mh.Requests.Observe(now, now.Sub(start), ww.Status() >= 500)
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// observeStore feeds a book store call to the dashboard and /metrics, and logs it when it took
+// Config.SlowStoreCall or longer
+func (h *Handler) observeStore(ctx context.Context, c dh.Call) {
+	slow := h.Config.SlowStoreCall > 0 && c.Took >= h.Config.SlowStoreCall
+	mh.StoreCalls.Observe(c.At, c.Took, c.Failed)
+	mh.StoreOps.Observe(c.Op, c.Took, c.Failed, slow)
+	if slow {
+		msg := fmt.Sprintf("slow store call: %s(%s) took %s", c.Op, c.Key, c.Took)
+		if c.Err != nil {
+			msg += ": " + c.Err.Error()
+		}
+		h.Logs.Warn.Printf("[%s] %s", trace.RequestID(ctx), msg)
+	}
+}
+
+// getMetrics serves the counters and store call histograms for Prometheus, GET /metrics
+func getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mh.WritePrometheus(w)
+}
+
+// getDashboard reports operational numbers for a monitoring page without needing Prometheus, GET /admin/dashboard
+func (h *Handler) getDashboard(w http.ResponseWriter, r *http.Request) {
+	now := h.Clock.Now()
+	writeJSON(w, r, http.StatusOK, Dashboard{
+		Requests:    latencyStats(mh.Requests.Summary(now)),
+		Storage:     latencyStats(mh.StoreCalls.Summary(now)),
+		PendingJobs: h.Queue.Pending(),
+		Panics:      mh.Panics.Value(),
+		Coalesced:   mh.Coalesced.Value(),
+		Maintenance: h.Maintenance.get().Enabled,
+		GeneratedAt: now,
+	})
+}
+
+// getCaches reports the cache memory budget and each cache's hit and eviction counts, GET /admin/caches
+func (h *Handler) getCaches(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.Caches.Stats())
+}
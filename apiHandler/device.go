@@ -0,0 +1,168 @@
+package apiHandler
+
+import (
+	"context"
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+	"github.com/Sabnaj-42/BookServer-API/pushHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const MaxDeviceName = 100 //characters
+
+// DeviceRequest registers a device for pushes. Browsers send their PushSubscription.toJSON()
+// with platform "webpush", apps their FCM registration token with platform "fcm".
+type DeviceRequest struct {
+	Platform string   `json:"platform"`
+	Name     string   `json:"name"`
+	Endpoint string   `json:"endpoint"`
+	Keys     PushKeys `json:"keys"`
+	Token    string   `json:"token"`
+}
+
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+type VAPIDKeyResponse struct {
+	PublicKey string `json:"public_key"` //applicationServerKey for pushManager.subscribe
+}
+
+// platforms lists the platforms pushes can be sent to, for validation messages
+func (h *Handler) platforms() string {
+	names := make([]string, 0, len(h.Push))
+	for p := range h.Push {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// device checks a registration and turns it into the device to store
+func (h *Handler) device(ctx context.Context, req DeviceRequest, user string) (dh.Device, []dh.FieldError) {
+	var errs []dh.FieldError
+	d := dh.Device{Username: user, Platform: req.Platform, Name: strings.TrimSpace(dh.Clean(req.Name))}
+	if utf8.RuneCountInString(d.Name) > MaxDeviceName {
+		errs = append(errs, dh.FieldError{Field: "name", Rule: specHandler.RuleMaxLength, Args: []any{MaxDeviceName}})
+	}
+	switch _, ok := h.Push[req.Platform]; {
+	case !ok:
+		errs = append(errs, dh.FieldError{Field: "platform", Rule: specHandler.RuleEnum, Args: []any{h.platforms()}})
+	case req.Platform == dh.PlatformWebPush:
+		if u, err := url.Parse(req.Endpoint); err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+			errs = append(errs, dh.FieldError{Field: "endpoint", Rule: specHandler.RuleFormat, Args: []any{"https URL"}})
+		} else if err := outboundHandler.CheckURL(ctx, req.Endpoint); err != nil { //pushes are POSTed there
+			errs = append(errs, dh.FieldError{Field: "endpoint", Rule: specHandler.RuleFormat, Args: []any{"https URL of a public host"}})
+		}
+		if raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(req.Keys.P256dh, "=")); err != nil {
+			errs = append(errs, dh.FieldError{Field: "keys.p256dh", Rule: specHandler.RuleFormat, Args: []any{"P-256 public key"}})
+		} else if _, err := ecdh.P256().NewPublicKey(raw); err != nil {
+			errs = append(errs, dh.FieldError{Field: "keys.p256dh", Rule: specHandler.RuleFormat, Args: []any{"P-256 public key"}})
+		}
+		if raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(req.Keys.Auth, "=")); err != nil || len(raw) != 16 {
+			errs = append(errs, dh.FieldError{Field: "keys.auth", Rule: specHandler.RuleFormat, Args: []any{"16 byte auth secret"}})
+		}
+		d.Endpoint, d.P256dh, d.Auth = req.Endpoint, strings.TrimRight(req.Keys.P256dh, "="), strings.TrimRight(req.Keys.Auth, "=")
+	case req.Platform == dh.PlatformFCM:
+		if d.Token = strings.TrimSpace(req.Token); len(d.Token) == 0 {
+			errs = append(errs, dh.FieldError{Field: "token", Rule: dh.RuleRequired})
+		}
+	}
+	return d, errs
+}
+
+// getDevices lists the caller's devices, GET /me/devices
+func (h *Handler) getDevices(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	devices, err := h.Inbox.ListDevices(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, devices)
+	return nil
+}
+
+// addDevice registers a device for pushes, POST /me/devices; registering it again updates it
+func (h *Handler) addDevice(w http.ResponseWriter, r *http.Request) error {
+	var req DeviceRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	d, errs := h.device(r.Context(), req, user)
+	if len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+	d.ID, d.CreatedAt = h.IDs.NewID(), h.Clock.Now()
+	d, err := h.Inbox.PutDevice(r.Context(), d)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusCreated, d)
+	return nil
+}
+
+// deleteDevice stops pushes to a device, DELETE /me/devices/{id}
+func (h *Handler) deleteDevice(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	if err := h.Inbox.DeleteDevice(r.Context(), user, chi.URLParam(r, "id")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getVAPIDKey is the key browsers subscribe to pushes with, GET /me/devices/vapid-key
+func (h *Handler) getVAPIDKey(w http.ResponseWriter, r *http.Request) error {
+	wp, ok := h.Push[dh.PlatformWebPush].(*pushHandler.WebPush)
+	if !ok {
+		return routeNotFound()
+	}
+	writeJSON(w, r, http.StatusOK, VAPIDKeyResponse{PublicKey: wp.PublicKey()})
+	return nil
+}
+
+// pushData is what devices get of a notification, their app or service worker words it
+func pushData(n dh.Notification) map[string]string {
+	data := map[string]string{"id": n.ID, "kind": n.Kind}
+	for k, v := range map[string]string{"actor": n.Actor, "review_id": n.ReviewID, "comment_id": n.CommentID, "url": n.URL} {
+		if len(v) != 0 {
+			data[k] = v
+		}
+	}
+	return data
+}
+
+// push sends n to every device of its user, forgetting the ones their push service no longer knows.
+// It runs on h.Queue.
+func (h *Handler) push(ctx context.Context, n dh.Notification) error {
+	devices, err := h.Inbox.ListDevices(ctx, n.Username)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, d := range devices {
+		err := h.Push.Send(ctx, d, pushData(n))
+		if errors.Is(err, pushHandler.ErrGone) {
+			h.Logs.Info.Printf("push: %s device %s of %s is gone, removing it", d.Platform, d.ID, d.Username)
+			err = h.Inbox.DeleteDevice(ctx, d.Username, d.ID)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,182 @@
+package apiHandler
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+)
+
+type CheckStatus string
+
+const (
+	CheckOK   CheckStatus = "ok"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+type Check struct { //result of one self-check
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// SelfCheck verifies storage, directories, secrets, clock and the listen port
+func (h *Handler) SelfCheck(ctx context.Context) []Check {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var checks []Check
+	add := func(name string, status CheckStatus, format string, args ...any) {
+		checks = append(checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if _, err := h.Store.ListBooks(ctx); err != nil {
+		add("storage", CheckFail, "book store unreachable: %v", err)
+	} else {
+		add("storage", CheckOK, "book store answers")
+	}
+	if p, ok := h.Auth.Sessions.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			add("session store", CheckFail, "%v, check --redis-addr or use --session-store=memory", err)
+		} else {
+			add("session store", CheckOK, "reachable")
+		}
+	}
+	refresh := h.Auth.Refresh
+	if e, ok := refresh.(*authHandler.EncryptedRefreshStore); ok {
+		refresh = e.Store
+	}
+	if p, ok := refresh.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			add("refresh store", CheckFail, "%v, check --redis-addr or use --refresh-store=memory", err)
+		} else {
+			add("refresh store", CheckOK, "reachable")
+		}
+	}
+	if p, ok := h.Auth.Nonces.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			add("nonce store", CheckFail, "%v, check --redis-addr or use --nonce-store=memory", err)
+		} else {
+			add("nonce store", CheckOK, "reachable")
+		}
+	}
+	if p, ok := h.Events.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			add("event bus", CheckFail, "%v, check --redis-addr or use --event-bus=local", err)
+		} else {
+			add("event bus", CheckOK, "reachable")
+		}
+	}
+	if p, ok := h.Blobs.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			add("blob store", CheckFail, "%v, check --s3-bucket, --s3-endpoint and the AWS_* keys", err)
+		} else {
+			add("blob store", CheckOK, "reachable")
+		}
+	}
+	if p, ok := h.Scanner.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			add("virus scanner", CheckFail, "%v, check --clamd-addr", err)
+		} else {
+			add("virus scanner", CheckOK, "reachable")
+		}
+	}
+	if h.Secrets != nil {
+		if err := h.Secrets.Ping(ctx); err != nil {
+			add("secrets provider", CheckFail, "%v, check $VAULT_ADDR and $VAULT_TOKEN", err)
+		} else if len(h.Config.VaultAddr) != 0 {
+			add("secrets provider", CheckOK, "vault reachable")
+		}
+	}
+	if p, ok := h.Jobs.Lock.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			add("job lock", CheckFail, "%v, check --redis-addr or use --job-lock=local", err)
+		} else {
+			add("job lock", CheckOK, "reachable")
+		}
+	}
+
+	if f, err := os.CreateTemp("", "bookserver-check-*"); err != nil {
+		add("temp directory", CheckFail, "%s is not writable (%v), uploads spill there; set TMPDIR", os.TempDir(), err)
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		add("temp directory", CheckOK, "%s is writable", os.TempDir())
+	}
+	if len(h.Config.RuntimeFile) != 0 {
+		if _, err := LoadRuntime(h.Config.RuntimeFile); err != nil {
+			add("runtime config", CheckFail, "%v", err)
+		} else {
+			add("runtime config", CheckOK, "%s is valid", h.Config.RuntimeFile)
+		}
+	}
+
+	now := h.Clock.Now()
+	if conf, err := h.Config.TLSConfig(); err != nil {
+		add("tls", CheckFail, "%v", err)
+	} else if conf != nil {
+		leaf, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+		switch {
+		case err != nil:
+			add("tls", CheckFail, "%v", err)
+		case now.After(leaf.NotAfter):
+			add("tls", CheckFail, "certificate expired on %s", leaf.NotAfter.Format(time.DateOnly))
+		case leaf.NotAfter.Sub(now) < 30*24*time.Hour:
+			add("tls", CheckWarn, "certificate expires on %s, renew it", leaf.NotAfter.Format(time.DateOnly))
+		default:
+			add("tls", CheckOK, "certificate valid until %s", leaf.NotAfter.Format(time.DateOnly))
+		}
+	}
+
+	switch secret := h.Config.JWTSecret; {
+	case secret == string(authHandler.Secret):
+		add("jwt secret", CheckWarn, "the built-in default secret is used, set BOOKSERVER_JWT_SECRET to 32+ random bytes")
+	case len(secret) < 32:
+		add("jwt secret", CheckWarn, "secret is only %d bytes, use at least 32", len(secret))
+	default:
+		add("jwt secret", CheckOK, "%d bytes", len(secret))
+	}
+	if h.urlSigningKey() == nil {
+		add("url signing", CheckWarn, "signed URLs are off with the built-in JWT secret, set BOOKSERVER_JWT_SECRET or BOOKSERVER_URL_SIGNING_KEY")
+	}
+
+	if now.Year() < 2025 || now.Year() > 2100 {
+		add("clock", CheckFail, "system time %s looks wrong, tokens would expire incorrectly; check NTP", now.Format(time.RFC3339))
+	} else {
+		add("clock", CheckOK, "%s", now.Format(time.RFC3339))
+	}
+
+	if inheritedListener() {
+		add("port", CheckOK, "%s handed over by the previous process", h.Config.Addr())
+	} else if ln, err := net.Listen("tcp", h.Config.Addr()); err != nil {
+		add("port", CheckFail, "cannot listen on %s: %v; pick another --port", h.Config.Addr(), err)
+	} else {
+		ln.Close()
+		add("port", CheckOK, "%s is free", h.Config.Addr())
+	}
+	return checks
+}
+
+// logChecks logs problems found by SelfCheck and reports whether the server can start
+func (h *Handler) logChecks(checks []Check) bool {
+	ok := true
+	for _, c := range checks {
+		switch c.Status {
+		case CheckWarn:
+			h.Logs.Warn.Printf("self-check warning: %s: %s", c.Name, c.Detail)
+		case CheckFail:
+			h.Logs.Error.Printf("self-check failed: %s: %s", c.Name, c.Detail)
+			ok = false
+		}
+	}
+	return ok
+}
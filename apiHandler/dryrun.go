@@ -0,0 +1,54 @@
+package apiHandler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+const DryRunHeader = "Dry-Run"
+
+// dryRun reports whether the caller only wants to know what a write would do, ?dry_run=true or a
+// Dry-Run: true header. Dry runs validate like the real write and answer with its status and body,
+// marked with a Dry-Run: true header, but store nothing and publish no events.
+func dryRun(w http.ResponseWriter, r *http.Request) bool {
+	v := r.URL.Query().Get("dry_run")
+	if len(v) == 0 {
+		v = r.Header.Get(DryRunHeader)
+	}
+	dry, _ := strconv.ParseBool(v)
+	if dry {
+		w.Header().Set(DryRunHeader, "true")
+	}
+	return dry
+}
+
+// checkCreate finds the conflict CreateBook would report for book, without storing it
+func (h *Handler) checkCreate(ctx context.Context, book dh.Book) error {
+	_, err := h.Store.GetBook(ctx, book.ID)
+	switch {
+	case err == nil:
+		return dh.ErrBookExists
+	case !errors.Is(err, dh.ErrBookNotFound):
+		return err
+	}
+	return h.checkISBN(ctx, book)
+}
+
+// checkISBN reports ErrBookExists when another book than book has its ISBN
+func (h *Handler) checkISBN(ctx context.Context, book dh.Book) error {
+	if len(book.ISBN) == 0 {
+		return nil
+	}
+	taken, err := h.Store.GetBooksByISBNs(ctx, []string{book.ISBN})
+	if err != nil {
+		return err
+	}
+	if other, ok := taken[book.ISBN]; ok && other.ID != book.ID {
+		return dh.ErrBookExists
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package apiHandler
+
+import (
+	"encoding/json"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/imageHandler"
+)
+
+// Transport types: only fields listed here are read from or written to clients,
+// so anything added to dh.Book for internal use stays out of the API.
+
+type AuthorPayload struct {
+	Name string `json:"name"`
+	Home string `json:"home"`
+}
+
+type CreateBookRequest struct { //the ID is assigned by the server
+	Name          string          `json:"name"`
+	Authors       []AuthorPayload `json:"authors"`
+	ISBN          string          `json:"isbn"` //optional
+	Genre         string          `json:"genre"`
+	Pub           string          `json:"pub"`
+	Published     string          `json:"published"`
+	Language      string          `json:"language"`
+	OriginalTitle string          `json:"original_title"`
+	Translator    string          `json:"translator"`
+	Format        string          `json:"format"`
+}
+
+type UpdateBookRequest struct { //the book comes from the URL
+	Name          string          `json:"name"`
+	ISBN          Optional        `json:"isbn"` //left out keeps the current ISBN, null or empty clears it
+	Authors       []AuthorPayload `json:"authors"`
+	Genre         string          `json:"genre"`
+	Pub           string          `json:"pub"`
+	Published     string          `json:"published"`
+	Language      string          `json:"language"`
+	OriginalTitle string          `json:"original_title"`
+	Translator    string          `json:"translator"`
+	Format        string          `json:"format"`
+}
+
+// Optional is a string member that tells being left out, which keeps the current value, from being sent
+type Optional struct {
+	Set   bool   //the member was sent
+	Value string //empty when it was null
+}
+
+func (o *Optional) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if string(b) == "null" {
+		o.Value = ""
+		return nil
+	}
+	return json.Unmarshal(b, &o.Value)
+}
+
+// Or is the value sent, or current when the member was left out
+func (o Optional) Or(current string) string {
+	if o.Set {
+		return o.Value
+	}
+	return current
+}
+
+type BookResponse struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Authors       []AuthorPayload `json:"authors"`
+	ISBN          string          `json:"isbn"`
+	Genre         string          `json:"genre"`
+	Pub           string          `json:"pub"`
+	Published     string          `json:"published,omitempty"`
+	Language      string          `json:"language,omitempty"`
+	OriginalTitle string          `json:"original_title,omitempty"`
+	Translator    string          `json:"translator,omitempty"`
+	WorkID        string          `json:"work_id,omitempty"`
+	Format        string          `json:"format,omitempty"`
+	File          *FileResponse   `json:"file,omitempty"`
+	Cover         *CoverResponse  `json:"cover,omitempty"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	Version       int64           `json:"version"`
+}
+
+func toAuthors(in []AuthorPayload) []dh.Author {
+	out := make([]dh.Author, 0, len(in))
+	for _, a := range in {
+		out = append(out, dh.Author{Name: a.Name, Home: a.Home})
+	}
+	return out
+}
+
+func fromAuthors(in []dh.Author) []AuthorPayload {
+	out := make([]AuthorPayload, 0, len(in))
+	for _, a := range in {
+		out = append(out, AuthorPayload{Name: a.Name, Home: a.Home})
+	}
+	return out
+}
+
+func (req CreateBookRequest) Book() dh.Book {
+	return dh.Book{
+		Name:          req.Name,
+		Authors:       toAuthors(req.Authors),
+		ISBN:          req.ISBN,
+		Genre:         req.Genre,
+		Pub:           req.Pub,
+		Published:     req.Published,
+		Language:      dh.SmStr(req.Language),
+		OriginalTitle: req.OriginalTitle,
+		Translator:    req.Translator,
+		Format:        req.Format,
+	}
+}
+
+// Book applies the request to the stored book current
+func (req UpdateBookRequest) Book(current dh.Book) dh.Book {
+	return dh.Book{
+		ID:            current.ID,
+		Name:          req.Name,
+		Authors:       toAuthors(req.Authors),
+		ISBN:          req.ISBN.Or(current.ISBN),
+		Genre:         req.Genre,
+		Pub:           req.Pub,
+		Published:     req.Published,
+		Language:      dh.SmStr(req.Language),
+		OriginalTitle: req.OriginalTitle,
+		Translator:    req.Translator,
+		Format:        req.Format,
+		WorkID:        current.WorkID,
+		File:          current.File,
+		Cover:         current.Cover,
+		Version:       current.Version,
+	}
+}
+
+type FileResponse struct {
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256,omitempty"`
+	URL         string `json:"url"` //download, needs a login
+}
+
+type CoverResponse struct {
+	ContentType string            `json:"content_type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	SHA256      string            `json:"sha256"`
+	URLs        map[string]string `json:"urls"` //by size
+}
+
+func NewBookResponse(b dh.Book, prefix string) BookResponse { //prefix is the Handler.Prefix of the links
+	var file *FileResponse
+	if b.File != nil {
+		file = &FileResponse{ContentType: b.File.ContentType, Size: b.File.Size, SHA256: b.File.SHA256, URL: bookLocation(prefix, b.ID) + "/file"}
+	}
+	var cover *CoverResponse
+	if b.Cover != nil {
+		cover = &CoverResponse{ContentType: b.Cover.ContentType, Width: b.Cover.Width, Height: b.Cover.Height, SHA256: b.Cover.SHA256, URLs: make(map[string]string)}
+		for size := range imageHandler.Sizes {
+			cover.URLs[size] = bookLocation(prefix, b.ID) + "/cover?size=" + size
+		}
+	}
+	return BookResponse{
+		ID:            b.ID,
+		Name:          b.Name,
+		Authors:       fromAuthors(b.Authors),
+		ISBN:          b.ISBN,
+		Genre:         b.Genre,
+		Pub:           b.Pub,
+		Published:     b.Published,
+		Language:      b.Language,
+		OriginalTitle: b.OriginalTitle,
+		Translator:    b.Translator,
+		WorkID:        b.WorkID,
+		Format:        b.Format,
+		File:          file,
+		Cover:         cover,
+		UpdatedAt:     b.UpdatedAt,
+		Version:       b.Version,
+	}
+}
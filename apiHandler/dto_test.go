@@ -0,0 +1,58 @@
+package apiHandler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sabnaj-42/BookServer-API/apiHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+func TestUpdateClearsISBN(t *testing.T) {
+	cfg := apiHandler.DefaultConfig()
+	cfg.BasicAuth = true
+	h, err := apiHandler.FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("FromConfig: %v", err)
+	}
+	routes := h.Routes()
+	do := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.SetBasicAuth("editor", "Passw0rd!long")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := do(http.MethodPost, "/signIn", "application/json", `{"username":"editor","password":"Passw0rd!long"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("signIn = %d %s", rec.Code, rec.Body)
+	}
+
+	const isbn = "9780000000028"
+	book := `"name":"Cleared","authors":[{"name":"Author"}],"genre":"test"`
+	for _, c := range []struct {
+		name, method, contentType, body, want string
+	}{
+		{"put without isbn", http.MethodPut, "application/json", "{" + book + "}", isbn},
+		{"put empty isbn", http.MethodPut, "application/json", `{"isbn":"",` + book + "}", ""},
+		{"put null isbn", http.MethodPut, "application/json", `{"isbn":null,` + book + "}", ""},
+		{"patch without isbn", http.MethodPatch, apiHandler.MergePatchType, `{"genre":"other"}`, isbn},
+		{"patch null isbn", http.MethodPatch, apiHandler.MergePatchType, `{"isbn":null}`, ""},
+	} {
+		id := strings.ReplaceAll(c.name, " ", "-")
+		if err := h.Store.CreateBook(context.Background(), dh.Book{ID: id, ISBN: isbn, Name: "Cleared", Genre: "test", Authors: []dh.Author{{Name: "Author"}}, Version: 1}); err != nil {
+			t.Fatalf("%s: CreateBook: %v", c.name, err)
+		}
+		if rec := do(c.method, "/api/v1/books/"+id, c.contentType, c.body); rec.Code != http.StatusOK {
+			t.Errorf("%s = %d %s", c.name, rec.Code, rec.Body)
+		} else if got, err := h.Store.GetBook(context.Background(), id); err != nil || got.ISBN != c.want {
+			t.Errorf("%s: ISBN %q, %v, want %q", c.name, got.ISBN, err, c.want)
+		}
+		if err := h.Store.DeleteBook(context.Background(), id); err != nil {
+			t.Fatalf("%s: DeleteBook: %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package apiHandler
+
+import (
+	"net/http"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+type LinkEditionRequest struct {
+	Edition string `json:"edition"` //ID or ISBN of the book to add to the work
+}
+
+// editions returns every book of work, including b itself when it has no work yet
+func (h *Handler) editions(r *http.Request, b dh.Book) ([]dh.Book, error) {
+	if len(b.WorkID) == 0 {
+		return []dh.Book{b}, nil
+	}
+	all, err := h.Store.ListBooks(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	var books []dh.Book
+	for _, o := range all {
+		if o.WorkID == b.WorkID {
+			books = append(books, o)
+		}
+	}
+	return books, nil
+}
+
+// getEditions lists every edition of the book's work, GET /api/v1/books/{ref}/editions
+func (h *Handler) getEditions(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	return h.writeEditions(w, r, book)
+}
+
+func (h *Handler) writeEditions(w http.ResponseWriter, r *http.Request, book dh.Book) error {
+	books, err := h.editions(r, book)
+	if err != nil {
+		return err
+	}
+	h.writeBooks(w, r, books)
+	return nil
+}
+
+// linkEdition puts another book, with all editions of its own work, into the book's work,
+// POST /api/v1/books/{ref}/editions
+func (h *Handler) linkEdition(w http.ResponseWriter, r *http.Request) error {
+	var req LinkEditionRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	edition, err := h.Store.GetBook(r.Context(), req.Edition)
+	if err != nil {
+		return err
+	}
+
+	work := book.WorkID
+	if len(work) == 0 {
+		work = h.IDs.NewID()
+	}
+	moving, err := h.editions(r, edition)
+	if err != nil {
+		return err
+	}
+	if len(book.WorkID) == 0 {
+		moving = append(moving, book)
+	}
+	for _, b := range moving {
+		if b.WorkID == work {
+			continue
+		}
+		b.WorkID = work
+		b.Touch(h.Clock.Now())
+		if err := h.Store.UpdateBook(r.Context(), b); err != nil {
+			return err
+		}
+		h.publish(r, eventHandler.BookUpdated, b)
+	}
+
+	book.WorkID = work
+	return h.writeEditions(w, r, book)
+}
+
+// unlinkEdition takes the book out of its work, DELETE /api/v1/books/{ref}/work
+func (h *Handler) unlinkEdition(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	if len(book.WorkID) != 0 {
+		book.WorkID = ""
+		book.Touch(h.Clock.Now())
+		if err := h.Store.UpdateBook(r.Context(), book); err != nil {
+			return err
+		}
+		h.publish(r, eventHandler.BookUpdated, book)
+	}
+	writeJSON(w, r, http.StatusOK, NewBookResponse(book, h.Prefix))
+	return nil
+}
@@ -0,0 +1,91 @@
+package apiHandler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// maxPooledBuffer keeps the odd huge response from pinning its buffer in the pool for good
+const maxPooledBuffer = 64 << 10
+
+type jsonBuffer struct { //a buffer with an encoder writing into it, reused across responses
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var jsonBuffers = sync.Pool{New: func() any {
+	b := new(jsonBuffer)
+	b.enc = json.NewEncoder(&b.buf)
+	return b
+}}
+
+var responseWriters = sync.Pool{New: func() any { return bufio.NewWriterSize(nil, 32<<10) }}
+
+func getJSONBuffer() *jsonBuffer {
+	b := jsonBuffers.Get().(*jsonBuffer)
+	b.buf.Reset()
+	return b
+}
+
+func putJSONBuffer(b *jsonBuffer) {
+	if b.buf.Cap() <= maxPooledBuffer {
+		jsonBuffers.Put(b)
+	}
+}
+
+// booksPerChunk is how many books writeBooks encodes at a time
+const booksPerChunk = 256
+
+// writeBooks answers 200 with books as a JSON array of BookResponse, the same bytes writeJSON would send,
+// encoding a chunk of books at a time into a pooled buffer so large catalogs need neither a
+// []BookResponse copy of every book nor the whole body in memory
+func (h *Handler) writeBooks(w http.ResponseWriter, r *http.Request, books []dh.Book) {
+	b := getJSONBuffer()
+	defer putJSONBuffer(b)
+	out := responseWriters.Get().(*bufio.Writer)
+	out.Reset(w)
+	defer func() {
+		out.Reset(nil)
+		responseWriters.Put(out)
+	}()
+
+	c := ch.Of(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	out.WriteByte('[')
+	chunk := make([]BookResponse, 0, min(len(books), booksPerChunk))
+	for start := 0; start < len(books); start += booksPerChunk {
+		chunk = chunk[:0]
+		for _, book := range books[start:min(start+booksPerChunk, len(books))] {
+			chunk = append(chunk, NewBookResponse(book, h.Prefix))
+		}
+		if start > 0 {
+			out.WriteByte(',')
+		}
+		b.buf.Reset()
+		var err error
+		if c == ch.Snake {
+			err = b.enc.Encode(chunk)
+		} else {
+			err = ch.Encode(&b.buf, chunk, c)
+		}
+		if err != nil {
+			//the 200 is sent, leaving the array unclosed is how the client learns the body is cut short
+			out.Flush()
+			h.errorf(r, "encoding books %d to %d: %v", start, start+len(chunk), err)
+			return
+		}
+		js := b.buf.Bytes()
+		if _, err := out.Write(js[1 : len(js)-2]); err != nil { //without the brackets and the newline
+			return //the client went away, there is nobody to send the rest to
+		}
+	}
+	out.WriteString("]\n")
+	out.Flush()
+}
@@ -0,0 +1,67 @@
+package apiHandler
+
+import (
+	"context"
+	"net/http"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+)
+
+// publish tells the other replicas that a book changed, failures are logged and the request still succeeds.
+// Every created book passes through here, so it is also where creations are metered.
+func (h *Handler) publish(r *http.Request, kind string, b dh.Book) {
+	if kind == eventHandler.BookCreated {
+		h.meter(r, Usage{BooksCreated: 1})
+	}
+	e := eventHandler.Event{Type: kind, ID: b.ID, ISBN: b.ISBN, At: h.Clock.Now()}
+	if err := h.Events.Publish(r.Context(), e); err != nil {
+		h.errorf(r, "events: publish %s %s: %v", kind, b.ID, err)
+	}
+}
+
+// invalidate drops a changed book from every cache, the one path they all go through for writes on
+// this instance and, with a shared bus, on the others
+func (h *Handler) invalidate(e eventHandler.Event) {
+	if len(e.ID) == 0 { //reply events name no book
+		return
+	}
+	for _, c := range h.invalidators() {
+		c.Invalidate(e.ID, e.ISBN)
+	}
+}
+
+// invalidators are the caches of book data: the book cache, the transliteration index, the search
+// index and Invalidate
+func (h *Handler) invalidators() []dh.CacheInvalidator {
+	var cs []dh.CacheInvalidator
+	if c, ok := h.Store.(dh.CacheInvalidator); ok {
+		cs = append(cs, c)
+	}
+	if h.Translit != nil {
+		cs = append(cs, h.Translit)
+	}
+	cs = append(cs, &h.search)
+	return append(cs, h.Invalidate...)
+}
+
+// ownEvent reports whether e was published by this instance
+func (h *Handler) ownEvent(e eventHandler.Event) bool {
+	bus, ok := h.Events.(*eventHandler.RedisBus)
+	return !ok || e.Node == bus.Node
+}
+
+// listenEvents starts receiving events from other replicas when the bus is shared. They reach
+// invalidate and dispatch like local ones, the subscriber here only traces them at debug level.
+func (h *Handler) listenEvents(ctx context.Context) {
+	bus, ok := h.Events.(*eventHandler.RedisBus)
+	if !ok {
+		return
+	}
+	h.Events.Subscribe(func(e eventHandler.Event) {
+		if e.Node != bus.Node {
+			h.Logs.Debug.Printf("events: %s %s from %s", e.Type, e.ID, e.Node)
+		}
+	})
+	go bus.Listen(ctx)
+}
@@ -0,0 +1,31 @@
+package apiHandler
+
+import (
+	"net/http"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/exportHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// exportCatalog writes the whole catalog for other library systems, GET /api/v1/export?format=marcxml
+func (h *Handler) exportCatalog(w http.ResponseWriter, r *http.Request) error {
+	format := r.URL.Query().Get("format")
+	if len(format) == 0 {
+		format = "marcxml"
+	}
+	if format != "marcxml" {
+		return eh.New(http.StatusBadRequest, eh.InvalidFormat, i18n.InvalidFormat, format)
+	}
+
+	books, err := h.Store.ListBooks(r.Context())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", exportHandler.MARCXMLType)
+	w.Header().Set("Content-Disposition", `attachment; filename="catalog.marcxml"`)
+	if err := exportHandler.MARCXML(w, books); err != nil {
+		h.errorf(r, "export: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package apiHandler
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	"github.com/Sabnaj-42/BookServer-API/blobHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const PresignTTL = 15 * time.Minute //how long a direct download link from the blob store stays valid
+
+// e-book content types that can be attached, with the file extension used for downloads
+var fileTypes = map[string]string{
+	"application/epub+zip": ".epub",
+	"application/pdf":      ".pdf",
+}
+
+// DownloadGate decides whether user may download b's file, e.g. only while they have it on loan
+type DownloadGate func(r *http.Request, user string, b dh.Book) bool
+
+// Identical files share a blob, so one a book stops using is left to collectGarbage, which deletes
+// it once no book refers to it. Deleting it here would race another upload of the same file.
+const fileKeyPrefix = "files/sha256/" //followed by the content's SHA-256, see blobHandler.PutDeduped
+
+// putFile attaches an EPUB or PDF sent as the request body, PUT /api/v1/books/{ref}/file (admins)
+func (h *Handler) putFile(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	f, mt, err := h.receiveUpload(w, r, "application/epub+zip", "application/pdf")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := blobHandler.PutDeduped(r.Context(), h.Blobs, fileKeyPrefix, mt, f)
+	if err != nil {
+		return eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("file: %w", err))
+	}
+	h.meter(r, Usage{BytesUploaded: info.Size})
+
+	book.File = &dh.BookFile{Key: info.Key, ContentType: mt, Size: info.Size, SHA256: info.SHA256}
+	book.Touch(h.Clock.Now())
+	if err := h.Store.UpdateBook(r.Context(), book); err != nil {
+		return err
+	}
+	h.publish(r, eventHandler.BookUpdated, book)
+	writeJSON(w, r, http.StatusOK, NewBookResponse(book, h.Prefix))
+	return nil
+}
+
+// deleteFile removes the attached e-book, DELETE /api/v1/books/{ref}/file (admins)
+func (h *Handler) deleteFile(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	if book.File == nil {
+		return eh.New(http.StatusNotFound, eh.FileNotFound, i18n.FileNotFound)
+	}
+	book.File = nil
+	book.Touch(h.Clock.Now())
+	if err := h.Store.UpdateBook(r.Context(), book); err != nil {
+		return err
+	}
+	h.publish(r, eventHandler.BookUpdated, book)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getFile downloads the attached e-book, with Range support, GET /api/v1/books/{ref}/file
+func (h *Handler) getFile(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	if book.File == nil {
+		return eh.New(http.StatusNotFound, eh.FileNotFound, i18n.FileNotFound)
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	if h.DownloadGate != nil && !h.DownloadGate(r, user, book) {
+		return eh.New(http.StatusForbidden, eh.Forbidden, i18n.Forbidden)
+	}
+
+	name := fileName(book, book.File.ContentType)
+	if p, ok := h.Blobs.(blobHandler.Presigner); ok { //let the client fetch it straight from the bucket
+		url, err := p.PresignGet(book.File.Key, name, PresignTTL)
+		if err != nil {
+			return eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("file: %w", err))
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, url, http.StatusFound)
+		return nil
+	}
+
+	f, info, err := h.Blobs.Get(r.Context(), book.File.Key)
+	if errors.Is(err, blobHandler.ErrNotFound) {
+		return eh.New(http.StatusNotFound, eh.FileNotFound, i18n.FileNotFound)
+	}
+	if err != nil {
+		return eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("file: %w", err))
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	if len(book.File.SHA256) == 0 { //attached before checksums were kept
+		http.ServeContent(w, r, name, info.Modified, f)
+		return nil
+	}
+	if sum, err := hex.DecodeString(book.File.SHA256); err == nil {
+		w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":") //RFC 9530, lets clients check what they got
+	}
+	w.Header().Set("ETag", `"`+book.File.SHA256+`"`)
+	v := blobHandler.NewVerifier(f, info.Size, book.File.SHA256)
+	http.ServeContent(w, r, name, info.Modified, v)
+	if v.Mismatch {
+		h.errorf(r, "file: %s of book %s is corrupt, its content no longer matches sha256 %s", book.File.Key, book.ID, book.File.SHA256)
+	}
+	return nil
+}
+
+// fileName is the download name for b's file, the title is only a hint so the header stays simple
+func fileName(b dh.Book, contentType string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '"' || r == '\\' || r == '/' {
+			return '_'
+		}
+		return r
+	}, b.Name) + fileTypes[contentType]
+}
@@ -0,0 +1,78 @@
+package apiHandler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/blobHandler"
+	mh "github.com/Sabnaj-42/BookServer-API/metricsHandler"
+)
+
+// BlobGCGrace is how old a blob no book uses must be before it is deleted, an upload is stored a
+// moment before its book is updated to use it
+const BlobGCGrace = 24 * time.Hour
+
+// collectGarbage deletes the covers, cover variants and e-book files no book uses any more, and
+// expired sessions and remember-me tokens. It runs every Config.GCInterval on h.Jobs; the counts
+// go to /metrics.
+func (h *Handler) collectGarbage(ctx context.Context) error {
+	start := h.Clock.Now()
+	blobs, size, err := h.collectBlobs(ctx)
+	mh.GCBlobs.Add(int64(blobs))
+	mh.GCBlobBytes.Add(size)
+	tokens, terr := h.Auth.PruneExpired(ctx)
+	mh.GCTokens.Add(int64(tokens))
+	if blobs != 0 || tokens != 0 {
+		h.Logs.Info.Printf("gc: deleted %d unused blobs (%d bytes) and %d expired tokens in %v", blobs, size, tokens, h.Clock.Now().Sub(start).Round(time.Millisecond))
+	}
+	return errors.Join(err, terr)
+}
+
+// collectBlobs deletes the blobs under the cover and file prefixes that no book uses and that are
+// older than BlobGCGrace, when the blob store can list them. Reports and snapshots aren't touched.
+func (h *Handler) collectBlobs(ctx context.Context) (int, int64, error) {
+	l, ok := h.Blobs.(blobHandler.Lister)
+	if !ok {
+		return 0, 0, nil
+	}
+	books, err := h.Store.ListBooks(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	used := make(map[string]bool) //keys, and cover checksums for their variants
+	for _, b := range books {
+		if b.File != nil {
+			used[b.File.Key] = true
+		}
+		if b.Cover != nil {
+			used[b.Cover.Key] = true
+			used[strings.TrimPrefix(b.Cover.Key, coverKeyPrefix)] = true
+		}
+	}
+
+	cutoff := h.Clock.Now().Add(-BlobGCGrace)
+	n, size := 0, int64(0)
+	for _, prefix := range []string{coverKeyPrefix, variantKeyPrefix, fileKeyPrefix} {
+		err := l.List(ctx, prefix, func(info blobHandler.Info) error {
+			key := info.Key
+			if prefix == variantKeyPrefix {
+				key, _, _ = strings.Cut(strings.TrimPrefix(key, variantKeyPrefix), "/") //the original's checksum
+			}
+			if used[key] || info.Modified.After(cutoff) {
+				return nil
+			}
+			if err := h.Blobs.Delete(ctx, info.Key); err != nil {
+				return err
+			}
+			n++
+			size += info.Size
+			return nil
+		})
+		if err != nil {
+			return n, size, err
+		}
+	}
+	return n, size, nil
+}
@@ -0,0 +1,22 @@
+package apiHandler
+
+import (
+	"net/http"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+)
+
+// handle adapts fn to http.HandlerFunc: eh.Error and eh.ValidationError are answered as they say,
+// other errors as storeError maps them
+func (h *Handler) handle(fn eh.HandlerFunc) http.HandlerFunc {
+	return h.responder().Handle(fn)
+}
+
+// fail answers err like handle does, for middleware, which has no result to return it as
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	h.responder().Write(w, r, err)
+}
+
+func (h *Handler) responder() eh.Responder {
+	return eh.Responder{Logf: h.errorf, Other: h.storeError}
+}
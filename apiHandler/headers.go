@@ -0,0 +1,30 @@
+package apiHandler
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/Sabnaj-42/BookServer-API/ipHandler"
+)
+
+const DefaultCSP = "default-src 'self'; img-src 'self' data:; object-src 'none'; frame-ancestors 'none'; base-uri 'self'"
+
+// securityHeaders sets the standard hardening headers on every API and UI response. Browsers
+// ignore HSTS over plain HTTP, so it is only sent when the client used HTTPS, see ipHandler.HTTPS.
+func securityHeaders(csp string, trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			if ipHandler.HTTPS(r, trusted) {
+				h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+			}
+			h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			if len(csp) != 0 {
+				h.Set("Content-Security-Policy", csp)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
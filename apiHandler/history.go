@@ -0,0 +1,28 @@
+package apiHandler
+
+import (
+	"net/http"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+type BookHistoryResponse struct {
+	ID       string           `json:"id"`
+	Versions []ChangeResponse `json:"versions"` //oldest first, a delete ends the book's life, a later create under the same ID starts a new one
+}
+
+// getBookHistory returns every version of a book from the change log, GET /api/v1/books/{ref}/history.
+// Deleted books keep their history, their ISBN names the last book that had it.
+func (h *Handler) getBookHistory(w http.ResponseWriter, r *http.Request) error {
+	changes, err := dh.History(r.Context(), h.Store, chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	resp := BookHistoryResponse{ID: changes[0].ID, Versions: make([]ChangeResponse, 0, len(changes))}
+	for _, c := range changes {
+		resp.Versions = append(resp.Versions, NewChangeResponse(c, h.Prefix))
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+	return nil
+}
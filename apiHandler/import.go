@@ -0,0 +1,133 @@
+package apiHandler
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/importHandler"
+)
+
+const MaxImportSize = 10 << 20 //bytes accepted by POST /api/v1/import
+
+type ImportReport struct {
+	Created    int                      `json:"created"`               //or would be, on a dry run
+	Skipped    []importHandler.RowError `json:"skipped"`               //rows left out, with the reason
+	RolledBack bool                     `json:"rolled_back,omitempty"` //an atomic import failed, nothing was created
+}
+
+// errRolledBack ends the transaction of an atomic import with skipped rows
+var errRolledBack = errors.New("import rolled back")
+
+// importBooks creates many books at once, POST /api/v1/import.
+// It takes a JSON array of books like POST /api/v1/books, or with Content-Type: text/csv
+// a Calibre CSV catalog export. Existing and invalid books are skipped and reported.
+// A dry run reports the same without creating anything. With ?atomic=true the import is all or
+// nothing: a single skipped row rolls it back and it is answered with 422.
+func (h *Handler) importBooks(w http.ResponseWriter, r *http.Request) error {
+	dry := dryRun(w, r)
+	atomic, _ := strconv.ParseBool(r.URL.Query().Get("atomic"))
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+
+	var books []dh.Book
+	switch mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt {
+	case "text/csv":
+		data, err := readUTF8(r, MaxImportSize)
+		if errors.Is(err, errInvalidUTF8) {
+			return eh.New(http.StatusBadRequest, eh.InvalidEncoding, i18n.InvalidUTF8)
+		}
+		if err == nil {
+			books, err = importHandler.ParseCalibreCSV(bytes.NewReader(data))
+		}
+		if err != nil {
+			return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidDataDetail, err.Error())
+		}
+	case "application/json", "":
+		var reqs []CreateBookRequest
+		if err := readText(r, MaxImportSize, &reqs); err != nil {
+			return err
+		}
+		for _, req := range reqs {
+			books = append(books, req.Book())
+		}
+	default:
+		return eh.New(http.StatusUnsupportedMediaType, eh.UnsupportedMediaType, i18n.UnsupportedMediaType, "application/json or text/csv")
+	}
+
+	var report ImportReport
+	var created []dh.Book
+	var err error
+	if atomic && !dry {
+		err = dh.InTx(r.Context(), h.Store, func(tx dh.Store) error {
+			if report, created, err = h.importRows(r, tx, books, false); err == nil && len(report.Skipped) != 0 {
+				err = errRolledBack
+			}
+			return err
+		})
+	} else {
+		report, created, err = h.importRows(r, h.Store, books, dry)
+	}
+	if err != nil && !errors.Is(err, errRolledBack) {
+		return err
+	}
+	if atomic && len(report.Skipped) != 0 {
+		report.Created, report.RolledBack = 0, true
+		writeJSON(w, r, http.StatusUnprocessableEntity, report)
+		return nil
+	}
+	if !dry {
+		for _, book := range created {
+			h.publish(r, eventHandler.BookCreated, book)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, report)
+	return nil
+}
+
+// importRows creates books through st in order, skipping and reporting the ones that are invalid
+// or exist already, and returns the books created. A dry run only checks them.
+func (h *Handler) importRows(r *http.Request, st dh.Store, books []dh.Book, dry bool) (ImportReport, []dh.Book, error) {
+	report := ImportReport{Skipped: []importHandler.RowError{}}
+	var created []dh.Book
+	planned := make(map[string]bool) //IDs and ISBNs a dry run would have created so far
+	for i, book := range books {
+		skip := func(msg string) {
+			report.Skipped = append(report.Skipped, importHandler.RowError{Row: i + 1, Err: msg})
+		}
+		book.Normalize()
+		if errs := book.Validate(); len(errs) != 0 {
+			skip(rowError(r, errs[0]))
+			continue
+		}
+		if len(book.ID) == 0 {
+			book.ID = h.IDs.NewID()
+		}
+		book.Touch(h.Clock.Now())
+		var err error
+		switch {
+		case !dry:
+			err = st.CreateBook(r.Context(), book)
+		case planned[book.ID] || (len(book.ISBN) != 0 && planned["isbn:"+book.ISBN]):
+			err = dh.ErrBookExists
+		default:
+			err = h.checkCreate(r.Context(), book)
+			planned[book.ID], planned["isbn:"+book.ISBN] = true, true
+		}
+		switch {
+		case errors.Is(err, dh.ErrBookExists):
+			skip(i18n.T(r, i18n.BookExists))
+			continue
+		case err != nil:
+			return report, nil, err
+		}
+		created = append(created, book)
+		report.Created++
+	}
+	return report, created, nil
+}
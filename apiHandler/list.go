@@ -0,0 +1,244 @@
+package apiHandler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"golang.org/x/text/collate"
+)
+
+// sortable fields for /getBooks?sort=, a leading "-" reverses the order; each gives the text books
+// are ordered by, compared bytewise except names, which are collated by ?locale=
+var bookSortKeys = map[string]func(b dh.Book) string{
+	"isbn":      func(b dh.Book) string { return b.ISBN },
+	"name":      func(b dh.Book) string { return b.Name },
+	"published": func(b dh.Book) string { return b.Published },
+	"updated":   func(b dh.Book) string { return b.UpdatedAt.UTC().Format(sortTime) },
+}
+
+const sortTime = "2006-01-02T15:04:05.000000000Z" //fixed width, so later times sort after earlier ones
+
+// getAllBooks lists books as an array, supporting
+// ?q=tolkien (title, author or ISBN, also across scripts: ?q=dostoevsky finds Достоевский), ?author=jrr tolkien (any spelling or alias), ?language=bn, ?format=ebook, ?published_after=2020-01-01, ?published_before=2021-01-01,
+// ?updated_after=2024-05-01T10:00:00Z for sync clients fetching what changed since their last poll,
+// ?as_of=2024-05-01T10:00:00Z for the catalog as it was then, replayed from the change log, ?sort=-published
+// and ?sort=name&locale=de to order titles the way German readers expect.
+// With ?limit= or ?cursor= the list comes in pages, a Link header with rel="next" points to the next one.
+func (h *Handler) getAllBooks(w http.ResponseWriter, r *http.Request) error {
+	books, err := h.findBooks(w, r)
+	if err != nil {
+		return err
+	}
+	h.writeBooks(w, r, books)
+	return nil
+}
+
+// getLegacyBooks answers /getBooks in the shape it had before the list became an array, an object
+// of books by ISBN, by ID for books without one. It takes the same parameters as getAllBooks.
+func (h *Handler) getLegacyBooks(w http.ResponseWriter, r *http.Request) error {
+	books, err := h.findBooks(w, r)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]BookResponse, len(books))
+	for _, book := range books {
+		key := book.ISBN
+		if len(key) == 0 {
+			key = book.ID
+		}
+		byKey[key] = NewBookResponse(book, h.Prefix)
+	}
+	writeJSON(w, r, http.StatusOK, byKey)
+	return nil
+}
+
+// findBooks reads the query of a book list and returns the page of books it asks for, setting the
+// Link header of the next page
+func (h *Handler) findBooks(w http.ResponseWriter, r *http.Request) ([]dh.Book, error) {
+	q := r.URL.Query()
+
+	var after, before, updatedAfter, asOf time.Time
+	var err error
+	if v := q.Get("published_after"); len(v) != 0 {
+		if after, err = dh.ParseDate(v); err != nil {
+			return nil, eh.New(http.StatusBadRequest, eh.InvalidDate, i18n.InvalidDate)
+		}
+	}
+	if v := q.Get("published_before"); len(v) != 0 {
+		if before, err = dh.ParseDate(v); err != nil {
+			return nil, eh.New(http.StatusBadRequest, eh.InvalidDate, i18n.InvalidDate)
+		}
+	}
+
+	if v := q.Get("updated_after"); len(v) != 0 {
+		if updatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, eh.New(http.StatusBadRequest, eh.InvalidTimestamp, i18n.InvalidTimestamp)
+		}
+	}
+	if v := q.Get("as_of"); len(v) != 0 {
+		if asOf, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, eh.New(http.StatusBadRequest, eh.InvalidTimestamp, i18n.InvalidTimestamp)
+		}
+	}
+
+	sortBy, desc := q.Get("sort"), false
+	if len(sortBy) > 0 && sortBy[0] == '-' {
+		sortBy, desc = sortBy[1:], true
+	}
+	if len(sortBy) == 0 {
+		sortBy = "isbn"
+	}
+	keyOf, ok := bookSortKeys[sortBy]
+	if !ok {
+		return nil, eh.New(http.StatusBadRequest, eh.InvalidSort, i18n.InvalidSort)
+	}
+	coll, err := titleCollator(r)
+	if err != nil {
+		return nil, eh.New(http.StatusBadRequest, eh.InvalidLocale, i18n.InvalidLocale)
+	}
+	sortKey := func(s string) []byte { return []byte(s) }
+	var locale string
+	if sortBy == "name" {
+		var buf collate.Buffer
+		sortKey = func(s string) []byte { return coll.KeyFromString(&buf, s) }
+		locale = titleLocale(r)
+	}
+
+	paged := q.Has("limit") || q.Has("cursor")
+	limit := booksPageSize
+	if v := q.Get("limit"); len(v) != 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidData)
+		}
+		limit = min(n, booksMaxPageSize)
+	}
+	order := sortBy
+	if desc {
+		order = "-" + sortBy
+	}
+	var cursor *bookCursor
+	if v := q.Get("cursor"); len(v) != 0 {
+		c, err := h.decodeCursor(v, order, locale)
+		if err != nil {
+			return nil, eh.New(http.StatusBadRequest, eh.InvalidCursor, i18n.InvalidCursor)
+		}
+		cursor = &c
+	}
+
+	search := dh.SmStr(strings.TrimSpace(dh.Clean(q.Get("q")))) //stored titles are NFC, see Book.Normalize
+	var romanized string
+	if len(search) != 0 && h.Translit != nil {
+		romanized = h.Translit.Query(search)
+	}
+	lang := dh.SmStr(q.Get("language"))
+	format := dh.SmStr(q.Get("format"))
+	var author string
+	if v := q.Get("author"); len(v) != 0 {
+		author = h.Authors.Resolve(dh.Clean(v))
+	}
+	match := func(book dh.Book) bool { //called from several goroutines on large catalogs
+		if len(lang) != 0 && dh.SmStr(book.Language) != lang {
+			return false
+		}
+		if len(format) != 0 && book.Format != format {
+			return false
+		}
+		if len(search) != 0 && !book.Matches(search) && (h.Translit == nil || !h.Translit.Matches(book, romanized)) {
+			return false
+		}
+		if len(author) != 0 && !h.hasAuthor(book, author) {
+			return false
+		}
+		if !after.IsZero() || !before.IsZero() {
+			pub, err := dh.ParseDate(book.Published)
+			if err != nil { //books without a publication date can't match a date range
+				return false
+			}
+			if !after.IsZero() && !pub.After(after) {
+				return false
+			}
+			if !before.IsZero() && !pub.Before(before) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var books []dh.Book
+	switch {
+	case !asOf.IsZero():
+		var all []dh.Book
+		all, err = dh.BooksAsOf(r.Context(), h.Store, asOf)
+		for _, book := range all {
+			if book.UpdatedAt.After(updatedAfter) && match(book) {
+				books = append(books, book)
+			}
+		}
+	case updatedAfter.IsZero():
+		books, err = dh.FindBooks(r.Context(), h.Store, match)
+	default:
+		var all []dh.Book
+		all, err = h.Store.ListBooksUpdatedAfter(r.Context(), updatedAfter)
+		books = all[:0] //filtered in place, the store hands out its own slice
+		for _, book := range all {
+			if match(book) {
+				books = append(books, book)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pos := sortBooks(books, func(b dh.Book) []byte { return sortKey(keyOf(b)) }, desc)
+	if paged {
+		start := 0
+		if cursor != nil {
+			after := bookPos{key: sortKey(cursor.Key), isbn: cursor.ISBN, id: cursor.ID}
+			start = sort.Search(len(pos), func(i int) bool { return pos[i].compare(after, desc) > 0 })
+		}
+		end := min(start+limit, len(books))
+		if end < len(books) {
+			last := books[end-1]
+			next := q
+			next.Set("cursor", h.encodeCursor(bookCursor{Sort: order, Locale: locale, Key: keyOf(last), ISBN: last.ISBN, ID: last.ID}))
+			next.Set("limit", strconv.Itoa(limit))
+			w.Header().Set("Link", "<"+h.Prefix+r.URL.Path+"?"+next.Encode()+`>; rel="next"`)
+		}
+		books = books[start:end]
+	}
+
+	return books, nil
+}
+
+// hasAuthor reports whether one of b's authors resolves to the canonical key
+func (h *Handler) hasAuthor(b dh.Book, key string) bool {
+	for _, a := range b.Authors {
+		if h.Authors.Resolve(a.Name) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// loginFor sends requests with the query parameter param through Authenticate and the quota, for
+// public routes where it makes them expensive, such as ?as_of= replaying the whole change log
+func (h *Handler) loginFor(param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		login := h.Auth.Authenticate(h.quota(next))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has(param) {
+				login.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
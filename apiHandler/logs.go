@@ -0,0 +1,57 @@
+package apiHandler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	trace "github.com/Sabnaj-42/BookServer-API/traceHandler"
+)
+
+// Logs are the Handler's loggers by level. They write through Handler.Logger, dropping the lines
+// below Runtime.LogLevel.
+type Logs struct {
+	Debug, Info, Warn, Error *log.Logger
+}
+
+func (h *Handler) newLogs() Logs {
+	at := func(level string) *log.Logger {
+		return log.New(levelWriter{h: h, level: logLevels[level]}, "", 0)
+	}
+	return Logs{Debug: at("debug"), Info: at("info"), Warn: at("warn"), Error: at("error")}
+}
+
+// levelWriter passes the lines of a logger at level on to h.Logger while Runtime.LogLevel lets them through
+type levelWriter struct {
+	h     *Handler
+	level int
+}
+
+func (w levelWriter) Write(p []byte) (int, error) {
+	if w.h.logged(w.level) {
+		w.h.Logger.Print(string(p))
+	}
+	return len(p), nil
+}
+
+// logged reports whether lines at level get through Runtime.LogLevel
+func (h *Handler) logged(level int) bool {
+	return level >= logLevels[h.Runtime.get().LogLevel]
+}
+
+// logf, warnf and errorf log a line about r with its request ID
+func (h *Handler) logf(r *http.Request, format string, args ...any) {
+	logRequest(h.Logs.Info, r, format, args...)
+}
+
+func (h *Handler) warnf(r *http.Request, format string, args ...any) {
+	logRequest(h.Logs.Warn, r, format, args...)
+}
+
+func (h *Handler) errorf(r *http.Request, format string, args ...any) {
+	logRequest(h.Logs.Error, r, format, args...)
+}
+
+func logRequest(l *log.Logger, r *http.Request, format string, args ...any) {
+	l.Printf("[%s] %s", trace.RequestID(r.Context()), fmt.Sprintf(format, args...))
+}
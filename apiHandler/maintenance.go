@@ -0,0 +1,109 @@
+package apiHandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+type Maintenance struct { //maintenance mode as seen and set through /admin/maintenance
+	Enabled    bool `json:"enabled"`
+	All        bool `json:"all"`         //also refuse reads, not only writes
+	RetryAfter int  `json:"retry_after"` //seconds sent in Retry-After
+}
+
+type maintenanceState struct {
+	mu sync.RWMutex
+	m  Maintenance
+}
+
+func (s *maintenanceState) get() Maintenance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m
+}
+
+func (s *maintenanceState) set(m Maintenance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m = m
+}
+
+// paths that keep working in maintenance so operators can check health, log in, renew a remembered
+// login and switch it off
+var maintenanceExempt = []string{"/healthz", "/readyz", "/admin", "/login", "/refresh"}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// maintenance answers 503 with Retry-After while maintenance mode is on
+func (h *Handler) maintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := h.Maintenance.get()
+		if !m.Enabled || (!m.All && isRead(r.Method)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, p := range maintenanceExempt {
+			if r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if m.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(m.RetryAfter))
+		}
+		h.fail(w, r, eh.New(http.StatusServiceUnavailable, eh.Maintenance, i18n.Maintenance))
+	})
+}
+
+func (h *Handler) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.Maintenance.get())
+}
+
+// setMaintenance switches maintenance mode, PUT /admin/maintenance {"enabled":true,"all":false,"retry_after":120}.
+// The other replicas follow through the event bus; one started later comes up out of maintenance.
+func (h *Handler) setMaintenance(w http.ResponseWriter, r *http.Request) error {
+	var m Maintenance
+	if err := readText(r, MaxSettingsBody, &m); err != nil {
+		return err
+	}
+	if m.RetryAfter < 0 {
+		m.RetryAfter = 0
+	}
+	h.Maintenance.set(m)
+	h.logf(r, "maintenance mode: %+v", m)
+	payload, _ := json.Marshal(m)
+	e := eventHandler.Event{Type: eventHandler.MaintenanceChanged, Maintenance: payload, At: h.Clock.Now()}
+	if err := h.Events.Publish(r.Context(), e); err != nil {
+		h.errorf(r, "events: publish %s: %v", e.Type, err)
+	}
+	writeJSON(w, r, http.StatusOK, m)
+	return nil
+}
+
+// followMaintenance switches maintenance mode as another replica did
+func (h *Handler) followMaintenance(e eventHandler.Event) {
+	if e.Type != eventHandler.MaintenanceChanged || h.ownEvent(e) {
+		return
+	}
+	var m Maintenance
+	if err := json.Unmarshal(e.Maintenance, &m); err != nil {
+		h.Logs.Error.Printf("maintenance mode from %s: %v", e.Node, err)
+		return
+	}
+	h.Maintenance.set(m)
+	h.Logs.Info.Printf("maintenance mode from %s: %+v", e.Node, m)
+}
+
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,187 @@
+package apiHandler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+type SharedShelfResponse struct {
+	Owner   string          `json:"owner"`
+	Shelf   string          `json:"shelf"`
+	Role    dh.ListRole     `json:"role"` //the caller's
+	Entries []dh.ShelfEntry `json:"entries"`
+}
+
+type ShelfEntryRequest struct { //the ISBN and shelf come from the URL, both fields are optional
+	Rating   int    `json:"rating"`    //1-5, 0 when unrated
+	DateRead string `json:"date_read"` //YYYY-MM-DD
+}
+
+type InviteRequest struct {
+	Username string      `json:"username"`
+	Role     dh.ListRole `json:"role"` //editor or viewer
+}
+
+// getSharedShelf shows a shelf the caller owns or was invited to, GET /shelves/{owner}/{shelf}
+func (h *Handler) getSharedShelf(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	owner, shelf := chi.URLParam(r, "owner"), chi.URLParam(r, "shelf")
+	role, err := h.Shelves.ListRole(r.Context(), user, owner, shelf)
+	if err != nil {
+		return err
+	}
+	entries, err := h.Shelves.ListEntries(r.Context(), user, owner, shelf)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, SharedShelfResponse{Owner: owner, Shelf: shelf, Role: role, Entries: entries})
+	return nil
+}
+
+// putShelfBook puts a book on the shelf, moving it off the owner's other shelves; editors and the owner,
+// moving a book needs the editor role on both shelves. PUT /shelves/{owner}/{shelf}/books/{isbn}
+func (h *Handler) putShelfBook(w http.ResponseWriter, r *http.Request) error {
+	var req ShelfEntryRequest
+	if err := ch.Decode(http.MaxBytesReader(w, r.Body, MaxSettingsBody), &req, ch.Of(r)); err != nil && !errors.Is(err, io.EOF) { //no body is fine
+		return eh.New(http.StatusBadRequest, eh.InvalidBody, i18n.CannotDecode)
+	}
+	var errs []dh.FieldError
+	if req.Rating < 0 {
+		errs = append(errs, dh.FieldError{Field: "rating", Rule: specHandler.RuleMinimum, Args: []any{0}})
+	}
+	if req.Rating > 5 {
+		errs = append(errs, dh.FieldError{Field: "rating", Rule: specHandler.RuleMaximum, Args: []any{5}})
+	}
+	if _, err := dh.ParseDate(req.DateRead); len(req.DateRead) != 0 && err != nil {
+		errs = append(errs, dh.FieldError{Field: "date_read", Rule: dh.RuleDate})
+	}
+	if len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+
+	user, _ := authHandler.UserFrom(r.Context())
+	e := dh.ShelfEntry{
+		ISBN:      chi.URLParam(r, "isbn"),
+		Shelf:     chi.URLParam(r, "shelf"),
+		Rating:    req.Rating,
+		DateRead:  req.DateRead,
+		DateAdded: h.Clock.Now().Format(dh.DateLayout),
+	}
+	if err := h.Shelves.PutListEntry(r.Context(), user, chi.URLParam(r, "owner"), e); err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, e)
+	return nil
+}
+
+// deleteShelfBook takes a book off the shelf; editors and the owner, DELETE /shelves/{owner}/{shelf}/books/{isbn}
+func (h *Handler) deleteShelfBook(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	if err := h.Shelves.DeleteListEntry(r.Context(), user, chi.URLParam(r, "owner"), chi.URLParam(r, "shelf"), chi.URLParam(r, "isbn")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getShelfMembers lists who the shelf is shared with, GET /shelves/{owner}/{shelf}/members
+func (h *Handler) getShelfMembers(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	members, err := h.Shelves.ListMembers(r.Context(), user, chi.URLParam(r, "owner"), chi.URLParam(r, "shelf"))
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, members)
+	return nil
+}
+
+// removeShelfMember takes someone off the shelf, the owner removes anyone and members remove themselves,
+// DELETE /shelves/{owner}/{shelf}/members/{user}
+func (h *Handler) removeShelfMember(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	if err := h.Shelves.RemoveMember(r.Context(), user, chi.URLParam(r, "owner"), chi.URLParam(r, "shelf"), chi.URLParam(r, "user")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// inviteToShelf invites a user as editor or viewer, they join once they accept; the owner only.
+// Unknown users get the same answer. POST /shelves/{owner}/{shelf}/invitations
+func (h *Handler) inviteToShelf(w http.ResponseWriter, r *http.Request) error {
+	var req InviteRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	owner := chi.URLParam(r, "owner")
+	var errs []dh.FieldError
+	if len(req.Username) == 0 || req.Username == owner {
+		errs = append(errs, dh.FieldError{Field: "username", Rule: dh.RuleRequired})
+	}
+	if req.Role != dh.RoleEditor && req.Role != dh.RoleViewer {
+		errs = append(errs, dh.FieldError{Field: "role", Rule: specHandler.RuleEnum, Args: []any{"editor, viewer"}})
+	}
+	if len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+
+	user, _ := authHandler.UserFrom(r.Context())
+	inv := dh.Invitation{ID: h.IDs.NewID(), Owner: owner, Shelf: chi.URLParam(r, "shelf"), Username: req.Username, Role: req.Role, InvitedAt: h.Clock.Now()}
+	if err := h.Shelves.Invite(r.Context(), user, inv); err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusCreated, inv)
+	return nil
+}
+
+// getInvitations lists the caller's pending invitations, GET /me/invitations
+func (h *Handler) getInvitations(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	invites, err := h.Shelves.ListInvitations(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, invites)
+	return nil
+}
+
+// acceptInvitation joins the shelf, POST /me/invitations/{id}
+func (h *Handler) acceptInvitation(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	m, err := h.Shelves.AcceptInvitation(r.Context(), user, chi.URLParam(r, "id"))
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, m)
+	return nil
+}
+
+// declineInvitation drops an invitation, DELETE /me/invitations/{id}
+func (h *Handler) declineInvitation(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	if err := h.Shelves.DeclineInvitation(r.Context(), user, chi.URLParam(r, "id")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getMemberships lists other users' shelves the caller was invited to, GET /me/lists
+func (h *Handler) getMemberships(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	lists, err := h.Shelves.ListMemberships(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, lists)
+	return nil
+}
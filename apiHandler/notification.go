@@ -0,0 +1,277 @@
+package apiHandler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/eventHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const MaxEmailLength = 254 //characters in an address, RFC 5321's limit
+
+type NotificationsResponse struct {
+	Unread        int               `json:"unread"`
+	Notifications []dh.Notification `json:"notifications"` //newest first
+}
+
+type UnreadResponse struct {
+	Unread int `json:"unread"`
+}
+
+type ReadAllResponse struct {
+	Read int `json:"read"` //how many were marked read
+}
+
+// notifyKinds maps the events that land in users' inboxes to the kind of notification they become
+var notifyKinds = map[string]string{
+	eventHandler.ReviewReplied:  dh.NotifyReviewReply,
+	eventHandler.CommentReplied: dh.NotifyCommentReply,
+}
+
+// mailSubjects are the i18n keys of the email subjects for each notification kind
+var mailSubjects = map[string]string{
+	dh.NotifyReviewReply:  i18n.MailReviewReply,
+	dh.NotifyCommentReply: i18n.MailCommentReply,
+}
+
+// notifyReplies tells the authors of the review and the comment c replies to, but not c's own
+// author and not about deleted posts. Events go over the bus so every replica fills its inboxes.
+func (h *Handler) notifyReplies(r *http.Request, rv dh.Review, c dh.Comment) {
+	told := map[string]bool{c.Username: true}
+	if len(c.ParentID) != 0 {
+		parent, err := h.Reviews.GetComment(r.Context(), c.ParentID)
+		if err == nil && parent.Status != dh.StatusDeleted && !told[parent.Username] {
+			h.publishReply(r, eventHandler.CommentReplied, parent.Username, c)
+			told[parent.Username] = true
+		}
+	}
+	if rv.Status != dh.StatusDeleted && !told[rv.Username] {
+		h.publishReply(r, eventHandler.ReviewReplied, rv.Username, c)
+	}
+}
+
+// publishReply sends one reply event for user, failures are logged and the comment stays posted
+func (h *Handler) publishReply(r *http.Request, kind, user string, c dh.Comment) {
+	e := eventHandler.Event{Type: kind, User: user, Actor: c.Username, Review: c.ReviewID, Comment: c.ID, URL: h.externalURL("/api/v1/reviews/" + c.ReviewID), At: h.Clock.Now()}
+	if err := h.Events.Publish(r.Context(), e); err != nil {
+		h.errorf(r, "events: publish %s %s: %v", kind, c.ID, err)
+	}
+}
+
+// dispatch sends a reply event to the user it is for on the channels their preferences pick, it is
+// subscribed to h.Events. Every replica fills its inbox, the one that published the event pushes and emails.
+func (h *Handler) dispatch(e eventHandler.Event) {
+	kind, ok := notifyKinds[e.Type]
+	if !ok || h.Inbox == nil || len(e.User) == 0 {
+		return
+	}
+	ctx := context.Background()
+	prefs, err := h.Inbox.GetPreferences(ctx, e.User)
+	if err != nil {
+		h.Logs.Error.Printf("notifications: preferences of %s: %v", e.User, err)
+		return
+	}
+	n := dh.Notification{ID: h.IDs.NewID(), Username: e.User, Kind: kind, Actor: e.Actor, ReviewID: e.Review, CommentID: e.Comment, URL: e.URL, CreatedAt: e.At}
+	if prefs.Wants(dh.ChannelInApp, kind) {
+		if err := h.Inbox.AddNotification(ctx, n); err != nil {
+			h.Logs.Error.Printf("notifications: delivering %s to %s: %v", e.Type, e.User, err)
+		}
+	}
+	if !h.ownEvent(e) {
+		return
+	}
+	if len(h.Push) != 0 && prefs.Wants(dh.ChannelPush, kind) {
+		h.Queue.Enqueue("push "+kind, func(ctx context.Context) error { return h.push(ctx, n) })
+	}
+	if h.Mail != nil && prefs.EmailConfirmed && prefs.Wants(dh.ChannelEmail, kind) {
+		h.Queue.Enqueue("mail "+kind, func(ctx context.Context) error { return h.mail(ctx, n, prefs) })
+	}
+}
+
+// mail emails n to the address in prefs, in the language they were saved in. It runs on h.Queue.
+func (h *Handler) mail(ctx context.Context, n dh.Notification, prefs dh.Preferences) error {
+	subject := i18n.Message(prefs.Language, mailSubjects[n.Kind], n.Actor)
+	body := subject + "\n\n" + n.URL + "\n\n-- \n" + i18n.Message(prefs.Language, i18n.MailFooter) + "\n"
+	return h.Mail.Send(ctx, prefs.EmailAddress, subject, body)
+}
+
+// confirmMailsPerMinute caps the confirmation emails one user makes the server send, every change of
+// the address sends one
+const confirmMailsPerMinute = 3
+
+// mailConfirmation sends code to the address in prefs, which gets no other email until the code
+// comes back. It runs on h.Queue.
+func (h *Handler) mailConfirmation(ctx context.Context, prefs dh.Preferences, code string) error {
+	subject := i18n.Message(prefs.Language, i18n.MailConfirm)
+	body := i18n.Message(prefs.Language, i18n.MailConfirmBody, prefs.EmailAddress, code) + "\n"
+	return h.Mail.Send(ctx, prefs.EmailAddress, subject, body)
+}
+
+// emailCode hashes a confirmation code the way Preferences.EmailCode keeps it
+func emailCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// getNotifications lists the caller's notifications, GET /me/notifications, ?unread=true for the unread ones only
+func (h *Handler) getNotifications(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	list, err := h.Inbox.ListNotifications(r.Context(), user, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		return err
+	}
+	unread, err := h.Inbox.UnreadCount(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, NotificationsResponse{Unread: unread, Notifications: list})
+	return nil
+}
+
+// getUnread is the caller's unread count for badges, cheap enough to poll, GET /me/notifications/unread
+func (h *Handler) getUnread(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	unread, err := h.Inbox.UnreadCount(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, UnreadResponse{Unread: unread})
+	return nil
+}
+
+// markRead marks one notification read or, with read false, unread again,
+// PUT and DELETE /me/notifications/{id}/read
+func (h *Handler) markRead(read bool) eh.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user, _ := authHandler.UserFrom(r.Context())
+		if err := h.Inbox.MarkRead(r.Context(), user, chi.URLParam(r, "id"), read); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+// markAllRead clears the caller's unread count, POST /me/notifications/read
+func (h *Handler) markAllRead(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	n, err := h.Inbox.MarkAllRead(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, ReadAllResponse{Read: n})
+	return nil
+}
+
+// getPreferences shows which notifications reach the caller where, GET /me/preferences
+func (h *Handler) getPreferences(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	p, err := h.Inbox.GetPreferences(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, p)
+	return nil
+}
+
+// putPreferences replaces the caller's preferences, PUT /me/preferences with e.g.
+// {"email": {"review.reply": true}, "email_address": "me@example.com", "push": {"comment.reply": false}}.
+// Emails are written in the language of the request. A new address is mailed a code first and gets
+// no notifications until it is confirmed with POST /me/preferences/email/confirm.
+func (h *Handler) putPreferences(w http.ResponseWriter, r *http.Request) error {
+	var p dh.Preferences
+	if err := readText(r, MaxSettingsBody, &p); err != nil {
+		return err
+	}
+	var errs []dh.FieldError
+	for channel, kinds := range map[string]map[string]bool{dh.ChannelInApp: p.InApp, dh.ChannelPush: p.Push, dh.ChannelEmail: p.Email} {
+		for kind := range kinds {
+			if !dh.NotifyKinds[kind] {
+				errs = append(errs, dh.FieldError{Field: channel + "." + kind, Rule: specHandler.RuleUnknownField})
+			}
+		}
+	}
+	if len(p.EmailAddress) != 0 {
+		if a, err := mail.ParseAddress(p.EmailAddress); err != nil || a.Address != p.EmailAddress || len(p.EmailAddress) > MaxEmailLength {
+			errs = append(errs, dh.FieldError{Field: "email_address", Rule: specHandler.RuleFormat, Args: []any{"email address"}})
+		}
+	}
+	p.Language = i18n.Lang(r)
+	if len(errs) != 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return eh.ValidationError(errs)
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	cur, err := h.Inbox.GetPreferences(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	p.EmailConfirmed, p.EmailCode = false, ""
+	var code string
+	switch {
+	case p.EmailAddress == cur.EmailAddress:
+		p.EmailConfirmed, p.EmailCode = cur.EmailConfirmed, cur.EmailCode
+	case len(p.EmailAddress) != 0 && h.Mail != nil:
+		now := h.Clock.Now()
+		if count, reset := h.confirms.allow(user, now); count > confirmMailsPerMinute {
+			w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+			return eh.New(http.StatusTooManyRequests, eh.RateLimited, i18n.TooManyRequests)
+		}
+		code = rand.Text()
+		p.EmailCode = emailCode(code)
+	}
+	if err := h.Inbox.PutPreferences(r.Context(), user, p); err != nil {
+		return err
+	}
+	if len(code) != 0 {
+		h.Queue.Enqueue("mail confirmation", func(ctx context.Context) error { return h.mailConfirmation(ctx, p, code) })
+	}
+	p, err = h.Inbox.GetPreferences(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, p)
+	return nil
+}
+
+type ConfirmEmailRequest struct {
+	Code string `json:"code"` //from the email PUT /me/preferences sent
+}
+
+// confirmEmail confirms the caller's email address with the code mailed to it, notification emails
+// go there from then on. POST /me/preferences/email/confirm
+func (h *Handler) confirmEmail(w http.ResponseWriter, r *http.Request) error {
+	var req ConfirmEmailRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	p, err := h.Inbox.GetPreferences(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	if len(p.EmailCode) == 0 || subtle.ConstantTimeCompare([]byte(emailCode(req.Code)), []byte(p.EmailCode)) != 1 {
+		return eh.New(http.StatusBadRequest, eh.InvalidCode, i18n.InvalidCode)
+	}
+	p.EmailConfirmed, p.EmailCode = true, ""
+	if err := h.Inbox.PutPreferences(r.Context(), user, p); err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, p)
+	return nil
+}
@@ -0,0 +1,86 @@
+package apiHandler
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const MergePatchType = "application/merge-patch+json"
+
+// patchBook applies an RFC 7386 merge patch to a book, PATCH /api/v1/books/{ref}.
+// Members set to null are cleared, members left out keep their value, the ID can't be changed.
+func (h *Handler) patchBook(w http.ResponseWriter, r *http.Request) error {
+	dry := dryRun(w, r)
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != MergePatchType {
+		w.Header().Set("Accept-Patch", MergePatchType)
+		return eh.New(http.StatusUnsupportedMediaType, eh.UnsupportedMediaType, i18n.UnsupportedMediaType, MergePatchType)
+	}
+	var patch any
+	if err := readText(r, MaxBookBody, &patch); err != nil {
+		return err
+	}
+
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	if err := ifMatch(r, book); err != nil {
+		return err
+	}
+	patch = ch.Keys(patch, reflect.TypeFor[UpdateBookRequest](), ch.Of(r)) //merged with the book's snake_case fields
+	var doc any
+	current, _ := json.Marshal(NewBookResponse(book, h.Prefix))
+	json.Unmarshal(current, &doc)
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return eh.New(http.StatusInternalServerError, eh.Internal, i18n.CannotEncode)
+	}
+	var req UpdateBookRequest
+	if err := json.Unmarshal(merged, &req); err != nil {
+		return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidData)
+	}
+	req.ISBN.Set = true //merged is the whole book, an ISBN left out of it was patched to null
+	newBook := req.Book(book)
+	newBook.Normalize()
+	newBook.Touch(h.Clock.Now())
+	if errs := newBook.Validate(); len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+
+	if err := h.writeUpdate(r, newBook, dry); err != nil {
+		return err
+	}
+	if !dry {
+		w.Header().Set("ETag", bookETag(newBook))
+	}
+	writeJSON(w, r, http.StatusOK, NewBookResponse(newBook, h.Prefix))
+	return nil
+}
+
+// mergePatch implements the MergePatch algorithm of RFC 7386 over decoded JSON values
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch //anything but an object replaces the target
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = mergePatch(t[name], value)
+	}
+	return t
+}
@@ -0,0 +1,169 @@
+package apiHandler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"plugin"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/secretHandler"
+)
+
+// Backend is what a registered backend is opened with
+type Backend struct {
+	Config    Config
+	Keys      *secretHandler.Keyring  //Config.EncryptionKeys, nil when none are set
+	RedisAuth func() (string, string) //credentials from Config.RedisAuthFrom, nil when unset
+	Logger    *log.Logger
+	Clock     dh.Clock //the server's, backends that expire entries themselves use it
+}
+
+// openers of the backends a deployment picks by name, with --store, --user-store, --session-store,
+// --refresh-store and --nonce-store
+type (
+	StoreOpener        func(ctx context.Context, b Backend) (dh.Store, error)
+	UserStoreOpener    func(ctx context.Context, b Backend) (dh.UserStore, error)
+	SessionStoreOpener func(ctx context.Context, b Backend) (authHandler.SessionStore, error)
+	RefreshStoreOpener func(ctx context.Context, b Backend) (authHandler.RefreshStore, error)
+	NonceStoreOpener   func(ctx context.Context, b Backend) (authHandler.NonceStore, error)
+)
+
+type registry[T any] struct {
+	kind    string
+	mu      sync.Mutex
+	openers map[string]T
+}
+
+func (r *registry[T]) register(name string, open T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(name) == 0 {
+		panic(fmt.Sprintf("%s backend without a name", r.kind))
+	}
+	if _, dup := r.openers[name]; dup {
+		panic(fmt.Sprintf("%s backend %q registered twice", r.kind, name))
+	}
+	if r.openers == nil {
+		r.openers = make(map[string]T)
+	}
+	r.openers[name] = open
+}
+
+func (r *registry[T]) get(name string) (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	open, ok := r.openers[name]
+	if !ok {
+		return open, fmt.Errorf("unknown %s %q, have %s", r.kind, name, strings.Join(slices.Sorted(maps.Keys(r.openers)), ", "))
+	}
+	return open, nil
+}
+
+var (
+	stores        = registry[StoreOpener]{kind: "store"}
+	userStores    = registry[UserStoreOpener]{kind: "user store"}
+	sessionStores = registry[SessionStoreOpener]{kind: "session store"}
+	refreshStores = registry[RefreshStoreOpener]{kind: "refresh store"}
+	nonceStores   = registry[NonceStoreOpener]{kind: "nonce store"}
+)
+
+// RegisterStore makes a book store available as --store=name. A store that also implements
+// dh.UserStore keeps the accounts unless --user-store is set; the routes of shelves, reviews and
+// notifications are left out unless it implements dh.ShelfStore, ReviewStore or NotificationStore.
+// dataHandler/storetest checks a store behaves like the built-in one. Like the other Register
+// functions it is meant for init functions and panics when name is taken.
+//
+// memory is the only book store built in. A SQL store registered here should prepare its statements
+// once in open and reuse them, implementing dh.StatementCache to have the counts reported at /metrics.
+func RegisterStore(name string, open StoreOpener) { stores.register(name, open) }
+
+// RegisterUserStore makes a user store available as --user-store=name, the accounts logins are
+// checked against, e.g. a directory service
+func RegisterUserStore(name string, open UserStoreOpener) { userStores.register(name, open) }
+
+// RegisterSessionStore makes a session store available as --session-store=name
+func RegisterSessionStore(name string, open SessionStoreOpener) { sessionStores.register(name, open) }
+
+// RegisterRefreshStore makes a store of remember-me tokens available as --refresh-store=name
+func RegisterRefreshStore(name string, open RefreshStoreOpener) { refreshStores.register(name, open) }
+
+// RegisterNonceStore makes a nonce store available as --nonce-store=name
+func RegisterNonceStore(name string, open NonceStoreOpener) { nonceStores.register(name, open) }
+
+func init() {
+	RegisterStore("memory", func(context.Context, Backend) (dh.Store, error) { return dh.Init(), nil })
+	RegisterSessionStore("memory", func(context.Context, Backend) (authHandler.SessionStore, error) {
+		return authHandler.NewMemSessionStore(), nil
+	})
+	RegisterSessionStore("redis", func(_ context.Context, b Backend) (authHandler.SessionStore, error) {
+		sessions := authHandler.NewRedisSessionStore(b.Config.RedisAddr)
+		sessions.Keys = b.Keys
+		sessions.Client.Auth = b.RedisAuth
+		return sessions, nil
+	})
+	RegisterRefreshStore("memory", func(context.Context, Backend) (authHandler.RefreshStore, error) {
+		return authHandler.NewMemRefreshStore(), nil
+	})
+	RegisterRefreshStore("redis", func(_ context.Context, b Backend) (authHandler.RefreshStore, error) {
+		tokens := authHandler.NewRedisRefreshStore(b.Config.RedisAddr)
+		tokens.Client.Auth = b.RedisAuth
+		if b.Keys != nil {
+			return &authHandler.EncryptedRefreshStore{Store: tokens, Keys: b.Keys}, nil
+		}
+		return tokens, nil
+	})
+	RegisterNonceStore("memory", func(_ context.Context, b Backend) (authHandler.NonceStore, error) {
+		return authHandler.NewMemNonceStore(b.Clock), nil
+	})
+	RegisterNonceStore("redis", func(_ context.Context, b Backend) (authHandler.NonceStore, error) {
+		nonces := authHandler.NewRedisNonceStore(b.Config.RedisAddr)
+		nonces.Client.Auth = b.RedisAuth
+		return nonces, nil
+	})
+}
+
+// LoadPlugins opens the Go plugins at paths, whose init functions register their backends. A
+// plugin has to be built with -buildmode=plugin against the same version of this module and its
+// dependencies as the server, and plugins only load on Linux, FreeBSD and macOS.
+func LoadPlugins(paths []string) error {
+	for _, path := range paths {
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// openStores opens the book store and the user store b.Config names, without a user store the
+// book store has to keep the users
+func openStores(ctx context.Context, b Backend) (dh.Store, dh.UserStore, error) {
+	open, err := stores.get(b.Config.Store)
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := open(ctx, b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("store %s: %w", b.Config.Store, err)
+	}
+	if len(b.Config.UserStore) == 0 {
+		if users, ok := store.(dh.UserStore); ok {
+			return store, users, nil
+		}
+		return nil, nil, fmt.Errorf("store %s keeps no users, pick a --user-store", b.Config.Store)
+	}
+	openUsers, err := userStores.get(b.Config.UserStore)
+	if err != nil {
+		return nil, nil, err
+	}
+	users, err := openUsers(ctx, b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("user store %s: %w", b.Config.UserStore, err)
+	}
+	return store, users, nil
+}
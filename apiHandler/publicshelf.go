@@ -0,0 +1,151 @@
+package apiHandler
+
+import (
+	"crypto/rand"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/exportHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type PublicShelfResponse struct {
+	dh.PublicShelf
+	URL  string `json:"url"`  //JSON, anyone with the link can read it
+	RSS  string `json:"rss"`  //the same list for feed readers
+	OPDS string `json:"opds"` //the same list for e-reader apps
+}
+
+type PublicListResponse struct {
+	Shelf string         `json:"shelf"`
+	Owner string         `json:"owner"`
+	Since time.Time      `json:"since"`
+	Books []BookResponse `json:"books"` //most recently added first
+}
+
+// externalURL makes path, below h.Prefix, absolute with Config.BaseURL. The Host header isn't used,
+// clients choose it and these links reach shared caches, other users' inboxes and emails.
+// Without a base URL the links are relative to the server.
+func (h *Handler) externalURL(path string) string {
+	return strings.TrimSuffix(h.Config.BaseURL, "/") + h.Prefix + path
+}
+
+func (h *Handler) publicShelfResponse(p dh.PublicShelf) PublicShelfResponse {
+	url := h.externalURL("/lists/" + p.Slug)
+	return PublicShelfResponse{PublicShelf: p, URL: url, RSS: url + ".rss", OPDS: url + ".opds"}
+}
+
+// getPublicShelves lists the caller's shelves that are public, GET /me/shelves/public
+func (h *Handler) getPublicShelves(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	shelves, err := h.Shelves.ListPublicShelves(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	resp := make([]PublicShelfResponse, 0, len(shelves))
+	for _, p := range shelves {
+		resp = append(resp, h.publicShelfResponse(p))
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+	return nil
+}
+
+// publishShelf makes one of the caller's shelves readable without logging in at an unguessable URL,
+// PUT /me/shelves/{shelf}/public; repeating it returns the same URL
+func (h *Handler) publishShelf(w http.ResponseWriter, r *http.Request) error {
+	shelf := chi.URLParam(r, "shelf")
+	if !dh.Shelves[shelf] {
+		return eh.New(http.StatusNotFound, eh.ShelfNotFound, i18n.ShelfNotFound)
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	p, err := h.Shelves.PublishShelf(r.Context(), dh.PublicShelf{Slug: rand.Text(), Username: user, Shelf: shelf, Since: h.Clock.Now()})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, h.publicShelfResponse(p))
+	return nil
+}
+
+// unpublishShelf makes a shelf private again, its link stops working, DELETE /me/shelves/{shelf}/public
+func (h *Handler) unpublishShelf(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	if err := h.Shelves.UnpublishShelf(r.Context(), user, chi.URLParam(r, "shelf")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getPublicList serves a public shelf as JSON, or as a feed with a .rss or .opds suffix,
+// GET /lists/{slug}
+func (h *Handler) getPublicList(w http.ResponseWriter, r *http.Request) error {
+	p, err := h.Shelves.GetPublicShelf(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		return err
+	}
+	entries, err := h.Shelves.ListShelf(r.Context(), p.Username)
+	if err != nil {
+		return err
+	}
+	entries = slices.DeleteFunc(entries, func(e dh.ShelfEntry) bool { return e.Shelf != p.Shelf })
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].DateAdded > entries[j].DateAdded })
+	isbns := make([]string, 0, len(entries))
+	for _, e := range entries {
+		isbns = append(isbns, e.ISBN)
+	}
+	found, err := h.Store.GetBooksByISBNs(r.Context(), isbns)
+	if err != nil {
+		return err
+	}
+	books := make([]dh.Book, 0, len(found))
+	for _, isbn := range isbns {
+		if b, ok := found[isbn]; ok { //books deleted since they were shelved are left out
+			books = append(books, b)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	title := i18n.T(r, i18n.PublicListTitle, p.Username, p.Shelf)
+	feed := exportHandler.Feed{
+		ID:      p.Slug,
+		Title:   title,
+		Link:    h.externalURL("/lists/" + p.Slug),
+		Updated: p.Since,
+		Books:   books,
+		BookURL: func(b dh.Book) string { return h.externalURL(bookLocation("", b.ID)) },
+	}
+	for _, b := range books {
+		if b.UpdatedAt.After(feed.Updated) {
+			feed.Updated = b.UpdatedAt
+		}
+	}
+	switch format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); format {
+	case "rss":
+		w.Header().Set("Content-Type", exportHandler.RSSType)
+		if err := exportHandler.RSS(w, feed); err != nil {
+			h.errorf(r, "list %s: %v", p.Slug, err)
+		}
+	case "opds":
+		w.Header().Set("Content-Type", exportHandler.OPDSType)
+		if err := exportHandler.OPDS(w, feed); err != nil {
+			h.errorf(r, "list %s: %v", p.Slug, err)
+		}
+	case "", "json":
+		resp := PublicListResponse{Shelf: p.Shelf, Owner: p.Username, Since: p.Since, Books: make([]BookResponse, 0, len(books))}
+		for _, b := range books {
+			resp.Books = append(resp.Books, NewBookResponse(b, h.Prefix))
+		}
+		writeJSON(w, r, http.StatusOK, resp)
+	default:
+		return routeNotFound()
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package apiHandler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+type quotaState struct { //requests per user today (UTC) and per user overrides of Runtime.DailyQuota, kept per instance
+	mu     sync.Mutex
+	day    string
+	used   map[string]int
+	limits map[string]int
+}
+
+// take counts a request from user and returns the count so far today and when the day ends
+func (q *quotaState) take(user string, now time.Time) (int, time.Time) {
+	now = now.UTC()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if day := now.Format(dh.DateLayout); day != q.day {
+		q.day, q.used = day, make(map[string]int)
+	}
+	q.used[user]++
+	y, m, d := now.Date()
+	return q.used[user], time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// limit is user's daily quota, 0 is unlimited
+func (q *quotaState) limit(user string, fallback int) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if l, ok := q.limits[user]; ok {
+		return l
+	}
+	return fallback
+}
+
+func (q *quotaState) setLimit(user string, limit int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limits == nil {
+		q.limits = make(map[string]int)
+	}
+	q.limits[user] = limit
+}
+
+func (q *quotaState) deleteLimit(user string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.limits, user)
+}
+
+// usage is where user stands today, fallback being Runtime.DailyQuota
+func (q *quotaState) usage(user string, now time.Time, fallback int) QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := QuotaUsage{User: user, Limit: fallback}
+	if q.day == now.UTC().Format(dh.DateLayout) {
+		u.Used = q.used[user]
+	}
+	if l, ok := q.limits[user]; ok {
+		u.Limit, u.Own = l, true
+	}
+	return u
+}
+
+// forget drops everything kept about user, for deleted accounts
+func (q *quotaState) forget(user string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.used, user)
+	delete(q.limits, user)
+}
+
+// quota counts requests of the authenticated user against their daily quota, use after Authenticate.
+// Every counted response says where the user stands, going over is refused with 429.
+func (h *Handler) quota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := authHandler.UserFrom(r.Context())
+		limit := h.quotas.limit(user, h.Runtime.get().DailyQuota)
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := h.Clock.Now()
+		used, reset := h.quotas.take(user, now)
+		hdr := w.Header()
+		hdr.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		hdr.Set("X-RateLimit-Remaining", strconv.Itoa(max(limit-used, 0)))
+		hdr.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if used > limit {
+			hdr.Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+			h.fail(w, r, eh.New(http.StatusTooManyRequests, eh.QuotaExceeded, i18n.QuotaExceeded, limit, reset.Format(time.RFC3339)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type QuotaUsage struct {
+	User  string `json:"user"`
+	Limit int    `json:"limit"` //0 is unlimited
+	Used  int    `json:"used"`  //today, UTC
+	Own   bool   `json:"own"`   //set for this user rather than the default
+}
+
+type QuotasResponse struct {
+	Default int          `json:"default"` //Runtime.DailyQuota
+	Users   []QuotaUsage `json:"users"`   //users with requests today or an own quota
+}
+
+type QuotaRequest struct {
+	Limit int `json:"limit"` //requests per day, 0 is unlimited
+}
+
+// getQuotas lists today's usage and quota overrides, GET /admin/quotas
+func (h *Handler) getQuotas(w http.ResponseWriter, r *http.Request) {
+	def := h.Runtime.get().DailyQuota
+	today := h.Clock.Now().UTC().Format(dh.DateLayout)
+
+	q := &h.quotas
+	q.mu.Lock()
+	users := make(map[string]QuotaUsage)
+	if q.day == today {
+		for u, n := range q.used {
+			users[u] = QuotaUsage{User: u, Limit: def, Used: n}
+		}
+	}
+	for u, l := range q.limits {
+		usage := users[u]
+		usage.User, usage.Limit, usage.Own = u, l, true
+		users[u] = usage
+	}
+	q.mu.Unlock()
+
+	resp := QuotasResponse{Default: def, Users: make([]QuotaUsage, 0, len(users))}
+	for _, u := range users {
+		resp.Users = append(resp.Users, u)
+	}
+	sort.Slice(resp.Users, func(i, j int) bool { return resp.Users[i].User < resp.Users[j].User })
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// setQuota gives one user their own daily quota, PUT /admin/quotas/{user}
+func (h *Handler) setQuota(w http.ResponseWriter, r *http.Request) error {
+	var req QuotaRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	if req.Limit < 0 {
+		return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidData)
+	}
+	user := chi.URLParam(r, "user")
+	h.quotas.setLimit(user, req.Limit)
+	h.logf(r, "quota: %s may make %d requests a day", user, req.Limit)
+	h.getQuotas(w, r)
+	return nil
+}
+
+// deleteQuota puts a user back on the default quota, DELETE /admin/quotas/{user}
+func (h *Handler) deleteQuota(w http.ResponseWriter, r *http.Request) {
+	h.quotas.deleteLimit(chi.URLParam(r, "user"))
+	w.WriteHeader(http.StatusNoContent)
+}
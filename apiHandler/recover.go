@@ -0,0 +1,34 @@
+package apiHandler
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	mh "github.com/Sabnaj-42/BookServer-API/metricsHandler"
+)
+
+// recoverer replaces middleware.Recoverer: the stack goes to the log with the request ID,
+// the client only gets a generic problem+json 500
+func (h *Handler) recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler { //deliberate abort, let net/http handle it
+				panic(rec)
+			}
+
+			mh.Panics.Add(1)
+			h.errorf(r, "panic: %v\n%s", rec, debug.Stack())
+
+			if r.Header.Get("Connection") != "Upgrade" {
+				h.fail(w, r, eh.New(http.StatusInternalServerError, eh.Internal, i18n.InternalError))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,190 @@
+package apiHandler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// states of a reindex
+const (
+	ReindexIdle     = "idle" //none since the server started
+	ReindexQueued   = "queued"
+	ReindexRunning  = "running"
+	ReindexDone     = "done"
+	ReindexCanceled = "canceled"
+	ReindexFailed   = "failed"
+)
+
+type ReindexStatus struct { //the last reindex as seen through /admin/reindex
+	State      string    `json:"state"`
+	By         string    `json:"by,omitempty"` //the admin who started it
+	Total      int       `json:"total"`        //books to go through, known once it runs
+	Done       int       `json:"done"`
+	QueuedAt   time.Time `json:"queued_at,omitzero"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func (s ReindexStatus) active() bool {
+	return s.State == ReindexQueued || s.State == ReindexRunning
+}
+
+type reindexState struct {
+	mu     sync.Mutex
+	s      ReindexStatus
+	cancel context.CancelFunc
+}
+
+func (s *reindexState) get() ReindexStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.s.State) == 0 {
+		return ReindexStatus{State: ReindexIdle}
+	}
+	return s.s
+}
+
+// queue records a new reindex and reports false when one is active already
+func (s *reindexState) queue(st ReindexStatus, cancel context.CancelFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.s.active() {
+		return false
+	}
+	s.s, s.cancel = st, cancel
+	return true
+}
+
+func (s *reindexState) update(fn func(st *ReindexStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.s)
+}
+
+// stop cancels the active reindex; one still queued is canceled right away, a running one when it
+// notices between two books. It reports false when none was active.
+func (s *reindexState) stop(now time.Time) (ReindexStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.s.active() {
+		return s.s, false
+	}
+	s.cancel()
+	if s.s.State == ReindexQueued {
+		s.s.State, s.s.FinishedAt = ReindexCanceled, now
+	}
+	return s.s, true
+}
+
+// reindex drops every book from the caches of book data and computes its search key again, then
+// warms the book cache up, on h.Queue. It is for recovery after writes that bypassed the events
+// keeping the caches current, such as bulk imports straight into the database or migrations.
+// Each replica has its own caches, so it only reindexes this one.
+func (h *Handler) reindex(ctx context.Context) error {
+	if ctx.Err() != nil { //canceled while queued
+		return nil
+	}
+	h.reindexState.update(func(st *ReindexStatus) { st.State, st.StartedAt = ReindexRunning, h.Clock.Now() })
+	err := h.reindexBooks(ctx)
+	if err == nil {
+		err = h.warmBooks(ctx)
+	}
+	now := h.Clock.Now()
+	var st ReindexStatus
+	h.reindexState.update(func(s *ReindexStatus) {
+		switch {
+		case ctx.Err() != nil:
+			s.State = ReindexCanceled
+		case err != nil:
+			s.State, s.Error = ReindexFailed, err.Error()
+		default:
+			s.State = ReindexDone
+		}
+		s.FinishedAt = now
+		st = *s
+	})
+	h.Logs.Info.Printf("reindex: %s, %d of %d books in %v", st.State, st.Done, st.Total, now.Sub(st.StartedAt).Round(time.Millisecond))
+	if st.State == ReindexFailed {
+		return err
+	}
+	return nil
+}
+
+func (h *Handler) reindexBooks(ctx context.Context) error {
+	books, err := h.Store.ListBooks(ctx)
+	if err != nil {
+		return err
+	}
+	h.reindexState.update(func(st *ReindexStatus) { st.Total = len(books) })
+	caches := h.invalidators()
+	for i, b := range books {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, c := range caches {
+			c.Invalidate(b.ID, b.ISBN)
+		}
+		if h.Translit != nil {
+			h.Translit.Key(b)
+		}
+		h.reindexState.update(func(st *ReindexStatus) { st.Done = i + 1 })
+	}
+	return nil
+}
+
+// getReindex reports the progress of the last reindex, GET /admin/reindex
+func (h *Handler) getReindex(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.reindexState.get())
+}
+
+// postReindex starts rebuilding the search keys and caches of every book in the background,
+// POST /admin/reindex; follow it with GET /admin/reindex, stop it with DELETE /admin/reindex
+func (h *Handler) postReindex(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	ctx, cancel := context.WithCancel(context.Background())
+	st := ReindexStatus{State: ReindexQueued, By: user, QueuedAt: h.Clock.Now()}
+	if !h.reindexState.queue(st, cancel) {
+		cancel()
+		return eh.New(http.StatusConflict, eh.ReindexRunning, i18n.ReindexRunning)
+	}
+	ok := h.Queue.Enqueue("reindex", func(qctx context.Context) error {
+		defer cancel()
+		stop := context.AfterFunc(qctx, cancel) //the server stopping cancels it too
+		defer stop()
+		return h.reindex(ctx)
+	})
+	if !ok {
+		cancel()
+		h.reindexState.update(func(st *ReindexStatus) {
+			st.State, st.FinishedAt, st.Error = ReindexFailed, h.Clock.Now(), "queue full"
+		})
+		return eh.New(http.StatusServiceUnavailable, eh.QueueFull, i18n.QueueFull)
+	}
+	h.logf(r, "reindex: queued by %s", user)
+	w.Header().Set("Location", h.Prefix+"/admin/reindex")
+	writeJSON(w, r, http.StatusAccepted, st)
+	return nil
+}
+
+// cancelReindex stops the active reindex, DELETE /admin/reindex. The books it went through stay
+// reindexed, the others are as they were.
+func (h *Handler) cancelReindex(w http.ResponseWriter, r *http.Request) {
+	st, ok := h.reindexState.stop(h.Clock.Now())
+	if !ok {
+		writeJSON(w, r, http.StatusOK, h.reindexState.get())
+		return
+	}
+	h.logf(r, "reindex: canceled")
+	if st.active() {
+		writeJSON(w, r, http.StatusAccepted, st) //a running one stops between two books
+		return
+	}
+	writeJSON(w, r, http.StatusOK, st)
+}
@@ -0,0 +1,61 @@
+package apiHandler_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/apiHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// a primary and a replica of it as a plugin would register them: the replica reads what the primary holds
+var replicated = dh.NewMemStore()
+
+func init() {
+	apiHandler.RegisterStore("test-primary", func(context.Context, apiHandler.Backend) (dh.Store, error) { return replicated, nil })
+	apiHandler.RegisterStore("test-replica", func(context.Context, apiHandler.Backend) (dh.Store, error) { return replicated, nil })
+}
+
+func replicaConfig(store, replica string) apiHandler.Config {
+	cfg := apiHandler.DefaultConfig()
+	cfg.Store, cfg.ReplicaStore = store, replica
+	cfg.ReplicaMaxLag = time.Millisecond
+	cfg.CacheMemoryMB = 0 //reads reach the stores
+	return cfg
+}
+
+func TestReplicaStoreRefused(t *testing.T) {
+	for _, c := range []struct{ store, replica, want string }{
+		{"memory", "memory", "is --store itself"},
+		{"test-primary", "memory", "can't replicate"},
+		{"test-primary", "test-primary", "is --store itself"},
+	} {
+		_, err := apiHandler.FromConfig(replicaConfig(c.store, c.replica))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("store %s, replica %s: got %v, want an error with %q", c.store, c.replica, err, c.want)
+		}
+	}
+}
+
+func TestReplicaReadsAfterMaxLag(t *testing.T) {
+	h, err := apiHandler.FromConfig(replicaConfig("test-primary", "test-replica"))
+	if err != nil {
+		t.Fatalf("FromConfig: %v", err)
+	}
+	ctx := context.Background()
+	b := dh.Book{ID: "r1", ISBN: "9780000000011", Name: "Replicated", Genre: "test", Authors: []dh.Author{{Name: "Author"}}, Version: 1}
+	if err := h.Store.CreateBook(ctx, b); err != nil {
+		t.Fatalf("CreateBook: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond) //past ReplicaMaxLag, reads go to the replica
+	got, err := h.Store.GetBook(ctx, b.ISBN)
+	if err != nil || got.Name != b.Name {
+		t.Fatalf("GetBook after the max lag = %+v, %v, want %q", got, err, b.Name)
+	}
+	books, err := h.Store.ListBooks(ctx)
+	if err != nil || len(books) != 1 {
+		t.Fatalf("ListBooks after the max lag = %d books, %v, want 1", len(books), err)
+	}
+}
@@ -0,0 +1,203 @@
+package apiHandler
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+type ReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReportedItem is a post, book or user in the moderation queue with everything reported about it
+type ReportedItem struct {
+	Kind    string        `json:"kind"`
+	ID      string        `json:"id"`               //the username for reported users
+	Author  string        `json:"author,omitempty"` //of a review or comment
+	Body    string        `json:"body,omitempty"`   //a post's text, hidden text included, or a book's title
+	Status  dh.PostStatus `json:"status,omitempty"` //of a review or comment, deleted for books removed since
+	Reports []dh.Report   `json:"reports"`          //oldest first
+}
+
+// report files the caller's report on kind id, answering 202. Reviews and comments reported by
+// Runtime.ReportThreshold users are hidden until a moderator looks at them, for a user so reported
+// it is all their posts.
+func (h *Handler) report(w http.ResponseWriter, r *http.Request, kind, id string) error {
+	var req ReportRequest
+	if r.ContentLength != 0 { //the reason is optional
+		if err := readText(r, MaxPostBody, &req); err != nil {
+			return err
+		}
+	}
+	req.Reason = strings.TrimSpace(dh.Clean(req.Reason))
+	if utf8.RuneCountInString(req.Reason) > MaxReasonLength {
+		return eh.ValidationError{{Field: "reason", Rule: specHandler.RuleMaxLength, Args: []any{MaxReasonLength}}}
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	count, err := h.Reviews.AddReport(r.Context(), dh.Report{Kind: kind, ID: id, Username: user, Reason: req.Reason, At: h.Clock.Now()})
+	if errors.Is(err, dh.ErrUserNotFound) { //answered like a filed report so user names can't be probed
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if threshold := h.Runtime.get().ReportThreshold; threshold > 0 && count >= threshold {
+		switch kind {
+		case dh.ReportReview, dh.ReportComment:
+			h.hidePost(r, kind, id, count)
+		case dh.ReportUser:
+			h.hideUserPosts(r, id, count)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// hidePost hides a visible review or comment that reached the report threshold. The report is
+// filed either way, failing to hide it only leaves it for the moderators.
+func (h *Handler) hidePost(r *http.Request, kind, id string, count int) {
+	_, status, err := h.postAuthor(r, kind, id)
+	if err == nil && status == dh.StatusVisible {
+		err = h.setPostStatus(r, kind, id, dh.StatusHidden)
+		h.logf(r, "moderation: %s %s hidden after %d reports", kind, id, count)
+	}
+	if err != nil {
+		h.errorf(r, "moderation: hiding %s %s: %v", kind, id, err)
+	}
+}
+
+// hideUserPosts hides every visible review and comment of a user who reached the report threshold,
+// a moderator restores them one by one or by dismissing the reports and moderating the posts
+func (h *Handler) hideUserPosts(r *http.Request, username string, count int) {
+	reviews, comments, err := h.Reviews.UserPosts(r.Context(), username)
+	if err != nil {
+		h.errorf(r, "moderation: hiding the posts of user %s: %v", username, err)
+		return
+	}
+	hidden := 0
+	for _, rv := range reviews {
+		if rv.Status != dh.StatusVisible {
+			continue
+		}
+		if err := h.Reviews.SetReviewStatus(r.Context(), rv.ID, dh.StatusHidden); err != nil {
+			h.errorf(r, "moderation: hiding review %s of user %s: %v", rv.ID, username, err)
+			continue
+		}
+		hidden++
+	}
+	for _, c := range comments {
+		if c.Status != dh.StatusVisible {
+			continue
+		}
+		if err := h.Reviews.SetCommentStatus(r.Context(), c.ID, dh.StatusHidden); err != nil {
+			h.errorf(r, "moderation: hiding comment %s of user %s: %v", c.ID, username, err)
+			continue
+		}
+		hidden++
+	}
+	if hidden > 0 {
+		h.logf(r, "moderation: %d posts of user %s hidden after %d reports", hidden, username, count)
+	}
+}
+
+// reportPost flags a review or comment to the moderators, POST /api/v1/reviews/{id}/reports and
+// POST /api/v1/comments/{id}/reports; reporting again replaces the reason
+func (h *Handler) reportPost(kind string) eh.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return h.report(w, r, kind, chi.URLParam(r, "id"))
+	}
+}
+
+// reportBook flags a book, e.g. for infringing or offensive content, POST /api/v1/books/{ref}/reports
+func (h *Handler) reportBook(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	return h.report(w, r, dh.ReportBook, book.ID)
+}
+
+// reportUser flags a user, e.g. for harassment across their posts, POST /api/v1/users/{user}/reports
+func (h *Handler) reportUser(w http.ResponseWriter, r *http.Request) error {
+	return h.report(w, r, dh.ReportUser, chi.URLParam(r, "user"))
+}
+
+// reportedItem looks up what the moderators need to judge a report on kind id
+func (h *Handler) reportedItem(r *http.Request, kind, id string) (ReportedItem, error) {
+	item := ReportedItem{Kind: kind, ID: id}
+	switch kind {
+	case dh.ReportReview:
+		rv, err := h.Reviews.GetReview(r.Context(), id)
+		if err != nil {
+			return item, err
+		}
+		item.Author, item.Body, item.Status = rv.Username, rv.Body, rv.Status
+	case dh.ReportComment:
+		c, err := h.Reviews.GetComment(r.Context(), id)
+		if err != nil {
+			return item, err
+		}
+		item.Author, item.Body, item.Status = c.Username, c.Body, c.Status
+	case dh.ReportBook:
+		b, err := h.Store.GetBook(r.Context(), id)
+		switch {
+		case errors.Is(err, dh.ErrBookNotFound): //deleted since, the reports can be dismissed
+			item.Status = dh.StatusDeleted
+		case err != nil:
+			return item, err
+		default:
+			item.Body = b.Name
+		}
+	}
+	return item, nil
+}
+
+// getReports is the moderation queue, reported items with the most reports first, GET /admin/reports
+func (h *Handler) getReports(w http.ResponseWriter, r *http.Request) error {
+	reports, err := h.Reviews.ListReports(r.Context())
+	if err != nil {
+		return err
+	}
+	queue := []ReportedItem{}
+	index := make(map[[2]string]int)
+	for _, rep := range reports {
+		key := [2]string{rep.Kind, rep.ID}
+		i, ok := index[key]
+		if !ok {
+			item, err := h.reportedItem(r, rep.Kind, rep.ID)
+			if err != nil {
+				return err
+			}
+			i = len(queue)
+			index[key] = i
+			queue = append(queue, item)
+		}
+		queue[i].Reports = append(queue[i].Reports, rep)
+	}
+	sort.SliceStable(queue, func(i, j int) bool { return len(queue[i].Reports) > len(queue[j].Reports) }) //ties stay oldest first
+	writeJSON(w, r, http.StatusOK, queue)
+	return nil
+}
+
+// dismissReports drops the reports on an item, leaving it as it is, DELETE /admin/reports/{kind}/{id}
+func (h *Handler) dismissReports(w http.ResponseWriter, r *http.Request) error {
+	kind := chi.URLParam(r, "kind")
+	if !dh.ReportKinds[kind] {
+		return routeNotFound()
+	}
+	if err := h.Reviews.ResolveReports(r.Context(), kind, chi.URLParam(r, "id")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
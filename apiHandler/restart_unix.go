@@ -0,0 +1,96 @@
+//go:build !windows
+
+package apiHandler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+// A restart (SIGUSR2) starts a new copy of the binary that inherits the listening
+// socket as fd 3; once the child serves it sends SIGTERM back and the old process drains.
+const listenFDEnv = "BOOKSERVER_LISTEN_FD"
+
+func inheritedListener() bool {
+	return len(os.Getenv(listenFDEnv)) != 0
+}
+
+// listen reuses the socket handed down by the parent process, or opens addr
+func listen(addr string) (net.Listener, error) {
+	if fd := os.Getenv(listenFDEnv); len(fd) != 0 {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", listenFDEnv, err)
+		}
+		f := os.NewFile(uintptr(n), "listener")
+		defer f.Close()
+		return net.FileListener(f)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// notifyParent tells the process we took the socket over from that it can drain and exit
+func notifyParent() {
+	if inheritedListener() {
+		syscall.Kill(os.Getppid(), syscall.SIGTERM)
+	}
+}
+
+func restart(ln net.Listener) error {
+	tl, ok := ln.(*net.TCPListener)
+	if !ok {
+		return fmt.Errorf("listener %T cannot be passed on", ln)
+	}
+	f, err := tl.File()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{f} //becomes fd 3
+	cmd.Env = append(os.Environ(), listenFDEnv+"=3")
+	return cmd.Start()
+}
+
+// handleSignals drains on SIGINT/SIGTERM and hands the listener to a new process on SIGUSR2,
+// done is closed once in-flight requests finished
+func handleSignals(srv *http.Server, ln net.Listener, logger *log.Logger) (done chan struct{}) {
+	done = make(chan struct{})
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
+	go func() {
+		for sig := range ch {
+			if sig == syscall.SIGUSR2 {
+				if err := restart(ln); err != nil {
+					logger.Printf("restart: %v", err)
+				} else {
+					logger.Printf("restart: new process started, waiting for it to take over")
+				}
+				continue
+			}
+			logger.Printf("%v: draining connections", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Printf("shutdown: %v", err)
+			}
+			cancel()
+			close(done)
+			return
+		}
+	}()
+	return done
+}
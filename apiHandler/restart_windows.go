@@ -0,0 +1,39 @@
+//go:build windows
+
+package apiHandler
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+// socket hand-over needs fd passing, on Windows the server only drains on interrupt
+
+func inheritedListener() bool { return false }
+
+func listen(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+func notifyParent() {}
+
+func handleSignals(srv *http.Server, _ net.Listener, logger *log.Logger) (done chan struct{}) {
+	done = make(chan struct{})
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		sig := <-ch
+		logger.Printf("%v: draining connections", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Printf("shutdown: %v", err)
+		}
+		close(done)
+	}()
+	return done
+}
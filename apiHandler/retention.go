@@ -0,0 +1,63 @@
+package apiHandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	mh "github.com/Sabnaj-42/BookServer-API/metricsHandler"
+)
+
+const changeArchivePrefix = "changes/" //followed by the first and last Seq of an archive
+
+// compactionPoint is the Seq up to which the change log is compacted: the last change older than
+// Config.ChangeRetention or the last before the Config.ChangeLogMax latest, whichever is later
+func (h *Handler) compactionPoint(ctx context.Context) (int64, error) {
+	cutoff := h.Clock.Now().Add(-h.Config.ChangeRetention)
+	var upTo, last int64
+	err := dh.EachChange(ctx, h.Store, 0, func(c dh.Change) error {
+		if h.Config.ChangeRetention > 0 && !c.At.After(cutoff) {
+			upTo = c.Seq
+		}
+		last = c.Seq
+		return nil
+	})
+	if keep := int64(h.Config.ChangeLogMax); keep > 0 && last-keep > upTo {
+		upTo = last - keep
+	}
+	return upTo, err
+}
+
+// compactChanges keeps the change log within Config.ChangeRetention and ChangeLogMax, hourly on
+// h.Jobs. Compaction drops the updates a later change of the same book follows, and keeps creates,
+// deletes and the last version of every book, so cursors of /changes and the book webhook stay
+// valid and the catalog can still be replayed; with Config.ChangeArchive the dropped updates are
+// written to the blob store first and nothing is dropped when that fails.
+func (h *Handler) compactChanges(ctx context.Context) error {
+	upTo, err := h.compactionPoint(ctx)
+	if err != nil || upTo == 0 {
+		return err
+	}
+	if h.Config.ChangeArchive {
+		dropped, err := dh.Superseded(ctx, h.Store, upTo)
+		if err != nil || len(dropped) == 0 {
+			return err
+		}
+		archive := make([]ChangeResponse, len(dropped))
+		for i, c := range dropped {
+			archive[i] = NewChangeResponse(c, h.Prefix)
+		}
+		key := fmt.Sprintf("%s%020d-%020d.json", changeArchivePrefix, dropped[0].Seq, dropped[len(dropped)-1].Seq)
+		if err := h.writeBlobJSON(ctx, key, archive); err != nil {
+			return fmt.Errorf("archiving changes: %w", err)
+		}
+	}
+	start := h.Clock.Now()
+	n, err := dh.CompactChanges(ctx, h.Store, upTo)
+	mh.ChangesCompacted.Add(int64(n))
+	if n != 0 {
+		h.Logs.Info.Printf("change log: dropped %d changes up to %d in %v", n, upTo, h.Clock.Now().Sub(start).Round(time.Millisecond))
+	}
+	return err
+}
@@ -0,0 +1,336 @@
+package apiHandler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/moderationHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	MaxPostLength   = 10000 //characters in a review or comment
+	MaxReasonLength = 500   //characters in a report's reason
+)
+
+type ReviewRequest struct {
+	Rating int    `json:"rating"` //1-5, 0 when unrated
+	Body   string `json:"body"`
+}
+
+type CommentRequest struct {
+	Body     string `json:"body"`
+	ParentID string `json:"parent_id"` //the comment replied to, empty to reply to the review
+}
+
+// CommentThread is a comment and the replies below it, oldest first
+type CommentThread struct {
+	dh.Comment
+	Replies []CommentThread `json:"replies"`
+}
+
+type ReviewThread struct {
+	Review   dh.Review       `json:"review"`
+	Comments []CommentThread `json:"comments"` //replies to the review, oldest first
+}
+
+type QuarantineResponse struct { //posts moderation held back, oldest first
+	Reviews  []dh.Review  `json:"reviews"`
+	Comments []dh.Comment `json:"comments"`
+}
+
+// postRate refuses users writing more than Runtime.PostRate reviews, comments and reports a minute
+// with 429, use after Authenticate
+func (h *Handler) postRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := h.Runtime.get().PostRate
+		user, _ := authHandler.UserFrom(r.Context())
+		if limit <= 0 || h.Auth.Admins[user] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := h.Clock.Now()
+		if count, reset := h.posts.allow(user, now); count > limit {
+			w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+			h.fail(w, r, eh.New(http.StatusTooManyRequests, eh.RateLimited, i18n.TooManyPosts, limit))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// postText cleans up the text of a review or comment and checks its length
+func postText(field, body string, errs []dh.FieldError) (string, []dh.FieldError) {
+	body = strings.TrimSpace(dh.Clean(body))
+	switch n := utf8.RuneCountInString(body); {
+	case n == 0:
+		errs = append(errs, dh.FieldError{Field: field, Rule: dh.RuleRequired})
+	case n > MaxPostLength:
+		errs = append(errs, dh.FieldError{Field: field, Rule: specHandler.RuleMaxLength, Args: []any{MaxPostLength}})
+	}
+	return body, errs
+}
+
+// screen runs a new post through h.Moderation and returns the status to store it with and why it
+// was held. Rejected posts fail with 422.
+func (h *Handler) screen(r *http.Request, p moderationHandler.Post) (dh.PostStatus, string, error) {
+	if h.Moderation == nil {
+		return dh.StatusVisible, "", nil
+	}
+	v, err := h.Moderation.Moderate(r.Context(), p)
+	if err != nil {
+		h.errorf(r, "moderation of a %s by %s: %v", p.Kind, p.Username, err)
+	}
+	switch v.Action {
+	case moderationHandler.Reject:
+		h.logf(r, "moderation: rejected a %s by %s: %s", p.Kind, p.Username, v.Reason)
+		return "", "", eh.New(http.StatusUnprocessableEntity, eh.ContentRejected, i18n.ContentRejected, v.Reason)
+	case moderationHandler.Quarantine:
+		h.logf(r, "moderation: quarantined a %s by %s: %s", p.Kind, p.Username, v.Reason)
+		return dh.StatusQuarantined, v.Reason, nil
+	}
+	return dh.StatusVisible, "", nil
+}
+
+// postStatusCode is 202 for posts held in quarantine, ok otherwise
+func postStatusCode(status dh.PostStatus, ok int) int {
+	if status == dh.StatusQuarantined {
+		return http.StatusAccepted
+	}
+	return ok
+}
+
+// shownComment blanks what readers may not see of a hidden or deleted comment, it keeps its
+// place in the thread for the replies below it
+func shownComment(c dh.Comment) dh.Comment {
+	switch c.Status {
+	case dh.StatusHidden:
+		c.Body = ""
+	case dh.StatusDeleted:
+		c.Body, c.Username = "", ""
+	}
+	return c
+}
+
+// thread nests comments, oldest first, below their parents
+func thread(comments []dh.Comment) []CommentThread {
+	children := make(map[string][]dh.Comment)
+	for _, c := range comments {
+		children[c.ParentID] = append(children[c.ParentID], c)
+	}
+	var build func(parent string) []CommentThread
+	build = func(parent string) []CommentThread {
+		replies := make([]CommentThread, 0, len(children[parent]))
+		for _, c := range children[parent] {
+			if c.Status == dh.StatusQuarantined { //for moderators only
+				continue
+			}
+			replies = append(replies, CommentThread{Comment: shownComment(c), Replies: build(c.ID)})
+		}
+		return replies
+	}
+	return build("")
+}
+
+// getReviews lists a book's reviews, newest first, GET /api/v1/books/{ref}/reviews
+func (h *Handler) getReviews(w http.ResponseWriter, r *http.Request) error {
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	reviews, err := h.Reviews.ListReviews(r.Context(), book.ID)
+	if err != nil {
+		return err
+	}
+	shown := make([]dh.Review, 0, len(reviews))
+	for _, rv := range reviews {
+		if rv.Status == dh.StatusVisible {
+			shown = append(shown, rv)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, shown)
+	return nil
+}
+
+// putReview writes the caller's review of a book, replacing their earlier one,
+// PUT /api/v1/books/{ref}/review
+func (h *Handler) putReview(w http.ResponseWriter, r *http.Request) error {
+	var req ReviewRequest
+	if err := readText(r, MaxPostBody, &req); err != nil {
+		return err
+	}
+	var errs []dh.FieldError
+	if req.Rating < 0 {
+		errs = append(errs, dh.FieldError{Field: "rating", Rule: specHandler.RuleMinimum, Args: []any{0}})
+	}
+	if req.Rating > 5 {
+		errs = append(errs, dh.FieldError{Field: "rating", Rule: specHandler.RuleMaximum, Args: []any{5}})
+	}
+	body, errs := postText("body", req.Body, errs)
+	if len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+
+	book, err := h.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	now := h.Clock.Now()
+	rv := dh.Review{ID: h.IDs.NewID(), BookID: book.ID, Username: user, Rating: req.Rating, Body: body, CreatedAt: now, UpdatedAt: now}
+	if rv.Status, rv.Held, err = h.screen(r, moderationHandler.Post{Kind: dh.ReportReview, Username: user, Text: body}); err != nil {
+		return err
+	}
+	if rv, err = h.Reviews.PutReview(r.Context(), rv); err != nil {
+		return err
+	}
+	writeJSON(w, r, postStatusCode(rv.Status, http.StatusOK), rv)
+	return nil
+}
+
+// getReviewThread shows a review with its comments nested below it, GET /api/v1/reviews/{id}.
+// Hidden and deleted comments keep their place without their text, so the replies to them still make sense.
+func (h *Handler) getReviewThread(w http.ResponseWriter, r *http.Request) error {
+	rv, err := h.Reviews.GetReview(r.Context(), chi.URLParam(r, "id"))
+	if err == nil && rv.Status != dh.StatusVisible {
+		err = dh.ErrReviewNotFound
+	}
+	if err != nil {
+		return err
+	}
+	comments, err := h.Reviews.ListComments(r.Context(), rv.ID)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, ReviewThread{Review: rv, Comments: thread(comments)})
+	return nil
+}
+
+// addComment replies to a review or to one of its comments, POST /api/v1/reviews/{id}/comments
+func (h *Handler) addComment(w http.ResponseWriter, r *http.Request) error {
+	var req CommentRequest
+	if err := readText(r, MaxPostBody, &req); err != nil {
+		return err
+	}
+	body, errs := postText("body", req.Body, nil)
+	if len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+
+	rv, err := h.Reviews.GetReview(r.Context(), chi.URLParam(r, "id"))
+	if err == nil && rv.Status != dh.StatusVisible {
+		err = dh.ErrReviewNotFound
+	}
+	if err == nil && len(req.ParentID) != 0 { //no replies to removed comments
+		var parent dh.Comment
+		if parent, err = h.Reviews.GetComment(r.Context(), req.ParentID); err == nil && parent.Status != dh.StatusVisible {
+			err = dh.ErrCommentNotFound
+		}
+	}
+	if err != nil {
+		return err
+	}
+	user, _ := authHandler.UserFrom(r.Context())
+	c := dh.Comment{ID: h.IDs.NewID(), ReviewID: rv.ID, ParentID: req.ParentID, Username: user, Body: body, CreatedAt: h.Clock.Now()}
+	if c.Status, c.Held, err = h.screen(r, moderationHandler.Post{Kind: dh.ReportComment, Username: user, Text: body}); err != nil {
+		return err
+	}
+	if err := h.Reviews.AddComment(r.Context(), c); err != nil {
+		return err
+	}
+	if c.Status == dh.StatusVisible && h.Inbox != nil {
+		h.notifyReplies(r, rv, c)
+	}
+	writeJSON(w, r, postStatusCode(c.Status, http.StatusCreated), c)
+	return nil
+}
+
+// postAuthor looks up who wrote a review or comment and whether it is still visible
+func (h *Handler) postAuthor(r *http.Request, kind, id string) (string, dh.PostStatus, error) {
+	if kind == dh.ReportReview {
+		rv, err := h.Reviews.GetReview(r.Context(), id)
+		return rv.Username, rv.Status, err
+	}
+	c, err := h.Reviews.GetComment(r.Context(), id)
+	return c.Username, c.Status, err
+}
+
+func postNotFound(kind string) error {
+	if kind == dh.ReportReview {
+		return dh.ErrReviewNotFound
+	}
+	return dh.ErrCommentNotFound
+}
+
+func (h *Handler) setPostStatus(r *http.Request, kind, id string, status dh.PostStatus) error {
+	if kind == dh.ReportReview {
+		return h.Reviews.SetReviewStatus(r.Context(), id, status)
+	}
+	return h.Reviews.SetCommentStatus(r.Context(), id, status)
+}
+
+// deletePost lets authors delete their own review or comment, DELETE /api/v1/reviews/{id} and
+// DELETE /api/v1/comments/{id}; replies to it stay
+func (h *Handler) deletePost(kind string) eh.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id := chi.URLParam(r, "id")
+		author, status, err := h.postAuthor(r, kind, id)
+		if err == nil && status == dh.StatusDeleted {
+			err = postNotFound(kind)
+		}
+		if err != nil {
+			return err
+		}
+		if user, _ := authHandler.UserFrom(r.Context()); user != author {
+			return eh.New(http.StatusForbidden, eh.Forbidden, i18n.Forbidden)
+		}
+		if err := h.setPostStatus(r, kind, id, dh.StatusDeleted); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+// getQuarantine lists the posts moderation held back, GET /admin/quarantine
+func (h *Handler) getQuarantine(w http.ResponseWriter, r *http.Request) error {
+	reviews, comments, err := h.Reviews.ListQuarantined(r.Context())
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, QuarantineResponse{Reviews: reviews, Comments: comments})
+	return nil
+}
+
+// moderate hides, restores, approves or deletes a review or comment and settles its reports,
+// PUT and DELETE /admin/reviews/{id}/hidden, POST /admin/reviews/{id}/approve, DELETE /admin/reviews/{id}
+// and the same below /admin/comments
+func (h *Handler) moderate(kind string, status dh.PostStatus) eh.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id := chi.URLParam(r, "id")
+		if err := h.setPostStatus(r, kind, id, status); err != nil {
+			return err
+		}
+		if err := h.Reviews.ResolveReports(r.Context(), kind, id); err != nil {
+			return err
+		}
+		if kind == dh.ReportComment && status == dh.StatusVisible && h.Inbox != nil { //an approved reply is news, a restored one was told already
+			if c, err := h.Reviews.GetComment(r.Context(), id); err == nil {
+				if rv, err := h.Reviews.GetReview(r.Context(), c.ReviewID); err == nil {
+					h.notifyReplies(r, rv, c)
+				}
+			}
+		}
+		user, _ := authHandler.UserFrom(r.Context())
+		h.logf(r, "moderation: %s set %s %s to %s", user, kind, id, status)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package apiHandler
+
+import (
+	"net/http"
+	"strings"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	routeNotFound().Write(w, r)
+}
+
+// routeNotFound fails a request for a path with nothing behind it like an unknown route
+func routeNotFound() *eh.Error {
+	return eh.New(http.StatusNotFound, eh.RouteNotFound, i18n.RouteNotFound)
+}
+
+// methodNotAllowed answers 405 with an Allow header listing the methods routes serves for the path
+func methodNotAllowed(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, m := range routeMethods {
+			if routes.Match(chi.NewRouteContext(), m, r.URL.Path) {
+				allowed = append(allowed, m)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		eh.New(http.StatusMethodNotAllowed, eh.MethodNotAllowed, i18n.MethodNotAllowed).Write(w, r)
+	}
+}
@@ -0,0 +1,312 @@
+package apiHandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/ipHandler"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Runtime holds the settings that can change without a restart, reloaded from
+// Config.RuntimeFile on SIGHUP or POST /admin/reload
+type Runtime struct {
+	LogLevel    string          `json:"log_level"`    //debug, info, warn or error: lines below it are dropped, access logs are info
+	RateLimit   int             `json:"rate_limit"`   //requests per minute per client IP, 0 disables
+	RateWarn    int             `json:"rate_warn"`    //requests per minute after which X-RateLimit-Warning is sent, 0 disables
+	DailyQuota  int             `json:"daily_quota"`  //requests per day per logged in user, 0 disables; /admin/quotas sets it per user
+	CORSOrigins []string        `json:"cors_origins"` //origins allowed for cross origin calls with credentials, "*" for any without
+	Features    map[string]bool `json:"features"`     //feature flags, a missing flag is on
+
+	PostRate        int      `json:"post_rate"`        //reviews, comments and reports per minute per user, 0 disables
+	BannedWords     []string `json:"banned_words"`     //words and phrases that send new reviews and comments to quarantine
+	ReportThreshold int      `json:"report_threshold"` //users reporting a review or comment after which it is hidden for a moderator to judge, or a user after which all their posts are, 0 disables
+
+	JSONCasing   string            `json:"json_casing"`   //field names in JSON responses and request bodies: snake (the default) or camel
+	TenantCasing map[string]string `json:"tenant_casing"` //json_casing by tenant, see Handler.TenantOf
+}
+
+func DefaultRuntime() Runtime {
+	return Runtime{LogLevel: "info", PostRate: 10, ReportThreshold: 5}
+}
+
+var logLevels = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+func (rt Runtime) validate() error {
+	if _, ok := logLevels[rt.LogLevel]; !ok {
+		return fmt.Errorf("unknown log level %q", rt.LogLevel)
+	}
+	if rt.RateLimit < 0 {
+		return fmt.Errorf("rate limit must not be negative")
+	}
+	if rt.RateWarn < 0 || (rt.RateLimit > 0 && rt.RateWarn > rt.RateLimit) {
+		return fmt.Errorf("rate warning threshold must be between 0 and the rate limit")
+	}
+	if rt.DailyQuota < 0 {
+		return fmt.Errorf("daily quota must not be negative")
+	}
+	if rt.PostRate < 0 {
+		return fmt.Errorf("post rate must not be negative")
+	}
+	if rt.ReportThreshold < 0 {
+		return fmt.Errorf("report threshold must not be negative")
+	}
+	if _, err := ch.Parse(rt.JSONCasing); err != nil {
+		return err
+	}
+	for tenant, c := range rt.TenantCasing {
+		if _, err := ch.Parse(c); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+// LoadRuntime reads runtime settings from a JSON file, fields left out keep their defaults
+func LoadRuntime(path string) (Runtime, error) {
+	rt := DefaultRuntime()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rt, err
+	}
+	if err := json.Unmarshal(data, &rt); err != nil {
+		return rt, fmt.Errorf("%s: %w", path, err)
+	}
+	return rt, rt.validate()
+}
+
+type runtimeState struct {
+	p atomic.Pointer[Runtime]
+}
+
+func (s *runtimeState) get() Runtime {
+	if rt := s.p.Load(); rt != nil {
+		return *rt
+	}
+	return DefaultRuntime()
+}
+
+func (s *runtimeState) set(rt Runtime) {
+	s.p.Store(&rt)
+}
+
+// Reload re-reads Config.RuntimeFile and swaps the settings in, in-flight requests are not touched
+func (h *Handler) Reload() (Runtime, error) {
+	if len(h.Config.RuntimeFile) == 0 {
+		return h.Runtime.get(), fmt.Errorf("no runtime file configured")
+	}
+	rt, err := LoadRuntime(h.Config.RuntimeFile)
+	if err != nil {
+		return h.Runtime.get(), err
+	}
+	h.Runtime.set(rt)
+	h.Logs.Info.Printf("runtime settings reloaded from %s", h.Config.RuntimeFile)
+	return rt, nil
+}
+
+// reloadOnSIGHUP reloads the runtime settings every time the process gets SIGHUP
+func (h *Handler) reloadOnSIGHUP() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if _, err := h.Reload(); err != nil {
+				h.Logs.Error.Printf("reload: %v", err)
+			}
+		}
+	}()
+}
+
+func (h *Handler) getRuntime(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.Runtime.get())
+}
+
+func (h *Handler) reload(w http.ResponseWriter, r *http.Request) error {
+	rt, err := h.Reload()
+	if err != nil {
+		h.warnf(r, "reload: %v", err)
+		return eh.New(http.StatusUnprocessableEntity, eh.InvalidData, i18n.InvalidDataDetail, err.Error())
+	}
+	writeJSON(w, r, http.StatusOK, rt)
+	return nil
+}
+
+// feature reports whether a feature flag is on
+func (h *Handler) feature(name string) bool {
+	on, ok := h.Runtime.get().Features[name]
+	return !ok || on
+}
+
+// requireFeature hides routes behind a feature flag
+func (h *Handler) requireFeature(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.feature(name) {
+				notFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// jsonCasing picks how field names are spelled in JSON: a casing parameter on the Accept header
+// (Accept: application/json; casing=camel) wins over Runtime.TenantCasing, which wins over
+// Runtime.JSONCasing. Responses are written in it and request bodies read in it; snake_case names,
+// like the API specification has them, are read whatever the casing.
+func (h *Handler) jsonCasing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		rt := h.Runtime.get()
+		name := rt.JSONCasing
+		if c, ok := rt.TenantCasing[h.TenantOf(r)]; ok {
+			name = c
+		}
+		if c, ok := acceptedCasing(r); ok {
+			name = c
+		}
+		c, err := ch.Parse(name)
+		if err != nil {
+			h.fail(w, r, eh.New(http.StatusNotAcceptable, eh.InvalidFormat, i18n.InvalidFormat, name))
+			return
+		}
+		if c != ch.Snake {
+			r = ch.WithCasing(r, c)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptedCasing returns the casing parameter of the first Accept media range carrying one
+func acceptedCasing(r *http.Request) (string, bool) {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			if _, params, err := mime.ParseMediaType(mediaRange); err == nil {
+				if c, ok := params["casing"]; ok {
+					return c, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// accessLog writes chi's request log at info level
+func (h *Handler) accessLog(next http.Handler) http.Handler {
+	logged := middleware.Logger(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.logged(logLevels["info"]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logged.ServeHTTP(w, r)
+	})
+}
+
+// cors answers preflights and sets CORS headers for the configured origins. Listed origins may send
+// credentials; "*" lets any other origin read responses without them, browsers then leave out
+// cookies and refuse Authorization-bearing preflights.
+func (h *Handler) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		origins := h.Runtime.get().CORSOrigins
+		listed := origin != "*" && slices.Contains(origins, origin)
+		if len(origin) == 0 || !(listed || slices.Contains(origins, "*")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		hdr := w.Header()
+		hdr.Add("Vary", "Origin")
+		if listed {
+			hdr.Set("Access-Control-Allow-Origin", origin)
+			hdr.Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			hdr.Set("Access-Control-Allow-Origin", "*")
+		}
+		if r.Method == http.MethodOptions && len(r.Header.Get("Access-Control-Request-Method")) != 0 {
+			hdr.Set("Access-Control-Allow-Methods", strings.Join(routeMethods, ", "))
+			hdr.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept-Language, X-Request-Id")
+			hdr.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type rateWindow struct { //requests seen from one IP in the current minute
+	start time.Time
+	count int
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+// allow counts a request from key, returning the count in the current minute and when that window resets
+func (l *rateLimiter) allow(key string, now time.Time) (int, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.windows == nil {
+		l.windows = make(map[string]*rateWindow)
+	}
+	win, ok := l.windows[key]
+	if !ok || now.Sub(win.start) >= time.Minute {
+		if len(l.windows) > 100000 { //drop stale windows now and then
+			for k, v := range l.windows {
+				if now.Sub(v.start) >= time.Minute {
+					delete(l.windows, k)
+				}
+			}
+		}
+		win = &rateWindow{start: now}
+		l.windows[key] = win
+	}
+	win.count++
+	return win.count, win.start.Add(time.Minute)
+}
+
+// rateLimit refuses clients over Runtime.RateLimit requests per minute with 429. Past Runtime.RateWarn
+// responses carry X-RateLimit-Warning first, so well-behaved clients can slow down before being refused.
+func (h *Handler) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := h.Runtime.get()
+		if (rt.RateLimit <= 0 && rt.RateWarn <= 0) || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := h.Clock.Now()
+		count, reset := h.limiter.allow(ipHandler.IP(r, h.AdminFilter.Trusted).String(), now)
+		resetIn := int(reset.Sub(now).Seconds()) + 1
+		if rt.RateWarn > 0 && count > rt.RateWarn {
+			limit := "no limit"
+			if rt.RateLimit > 0 {
+				limit = strconv.Itoa(rt.RateLimit)
+			}
+			w.Header().Set("X-RateLimit-Warning", fmt.Sprintf("%d requests this minute, limit %s, window resets in %ds", count, limit, resetIn))
+		}
+		if rt.RateLimit > 0 && count > rt.RateLimit {
+			w.Header().Set("Retry-After", strconv.Itoa(resetIn))
+			h.fail(w, r, eh.New(http.StatusTooManyRequests, eh.RateLimited, i18n.TooManyRequests))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
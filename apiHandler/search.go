@@ -0,0 +1,274 @@
+package apiHandler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// search limits, ?limit= picks one up to MaxSearchResults. Longer queries are refused, every query
+// word is compared with every word of the catalog.
+const (
+	DefaultSearchResults = 20
+	MaxSearchResults     = 100
+	MaxSearchQuery       = 200 //characters of ?q=
+	MaxSearchWords       = 8
+)
+
+type SearchResponse struct {
+	Query      string         `json:"query"`
+	DidYouMean string         `json:"did_you_mean,omitempty"` //the query with its typos corrected from words in the catalog, when it has any
+	Books      []BookResponse `json:"books"`                  //best match first
+}
+
+type searchHit struct {
+	book  dh.Book
+	edits int //typos forgiven to match, 0 for exact words
+}
+
+// maxChanged is how many changed books the next search reads one by one, more and it reads the catalog
+const maxChanged = 1024
+
+// searchIndex keeps the words of every book for search, built on the first search and kept up to
+// date through the book change path: changed books are read again on the next search
+type searchIndex struct {
+	mu     sync.Mutex //held by searches while they bring the index up to date and read it
+	loaded bool
+	books  map[string]indexedBook     //by ID
+	vocab  map[string]map[string]bool //word -> IDs of the books using it
+
+	changedMu sync.Mutex //apart from mu, so writes don't wait for searches
+	changed   map[string]bool
+	reload    bool //too many changed to read them one by one
+}
+
+type indexedBook struct {
+	book  dh.Book
+	words []string
+}
+
+// Invalidate has book id read again on the next search
+func (x *searchIndex) Invalidate(id string, _ ...string) {
+	x.changedMu.Lock()
+	defer x.changedMu.Unlock()
+	if x.reload {
+		return
+	}
+	if x.changed == nil {
+		x.changed = make(map[string]bool)
+	}
+	if x.changed[id] = true; len(x.changed) > maxChanged {
+		x.changed, x.reload = nil, true
+	}
+}
+
+// takeChanged returns the books changed since the last call, or reload when the catalog must be read
+func (x *searchIndex) takeChanged() (changed map[string]bool, reload bool) {
+	x.changedMu.Lock()
+	defer x.changedMu.Unlock()
+	changed, reload = x.changed, x.reload
+	x.changed, x.reload = nil, false
+	return changed, reload
+}
+
+// add indexes b, the caller holds x.mu
+func (x *searchIndex) add(b dh.Book, words []string) {
+	x.books[b.ID] = indexedBook{book: b, words: words}
+	for _, w := range words {
+		if x.vocab[w] == nil {
+			x.vocab[w] = make(map[string]bool)
+		}
+		x.vocab[w][b.ID] = true
+	}
+}
+
+// remove drops book id from the index, the caller holds x.mu
+func (x *searchIndex) remove(id string) {
+	for _, w := range x.books[id].words {
+		if delete(x.vocab[w], id); len(x.vocab[w]) == 0 {
+			delete(x.vocab, w)
+		}
+	}
+	delete(x.books, id)
+}
+
+// refreshSearch brings h.search up to date, reading the whole catalog the first time and when
+// many books changed, e.g. after a reindex, and the changed books otherwise. Changes are taken
+// before reading, so one landing meanwhile is read again by the next search. Callers hold h.search.mu.
+func (h *Handler) refreshSearch(ctx context.Context) error {
+	x := &h.search
+	changed, reload := x.takeChanged()
+	if x.loaded && !reload {
+		for id := range changed {
+			b, err := h.Store.GetBook(ctx, id)
+			if err != nil && !errors.Is(err, dh.ErrBookNotFound) {
+				for id := range changed { //the rest are read next time
+					x.Invalidate(id)
+				}
+				return err
+			}
+			x.remove(id)
+			if err == nil {
+				x.add(b, h.bookWords(b))
+			}
+			delete(changed, id)
+		}
+		return nil
+	}
+	books, err := h.Store.ListBooks(ctx)
+	if err != nil {
+		x.loaded = false
+		return err
+	}
+	x.books, x.vocab = make(map[string]indexedBook, len(books)), make(map[string]map[string]bool)
+	for _, b := range books {
+		x.add(b, h.bookWords(b))
+	}
+	x.loaded = true
+	return nil
+}
+
+// searchWords splits text into the lower case words search compares, romanized when
+// transliteration is on so typos and scripts are forgiven together
+func (h *Handler) searchWords(text string) []string {
+	if h.Translit != nil {
+		return strings.Fields(h.Translit.Query(text))
+	}
+	return strings.Fields(dh.SmStr(text))
+}
+
+// bookWords lists the words of a book's title, original title and authors
+func (h *Handler) bookWords(b dh.Book) []string {
+	if h.Translit != nil {
+		return strings.Fields(h.Translit.Key(b))
+	}
+	words := h.searchWords(b.Name + " " + b.OriginalTitle)
+	for _, a := range b.Authors {
+		words = append(words, h.searchWords(a.Name)...)
+	}
+	return words
+}
+
+// searchBooks finds books every word of ?q= matches a word of, allowing a few typos per word
+// (see dh.MaxEdits) and prefixes of the last word, best matches first, GET /api/v1/search?q=dostoyevski&limit=20
+func (h *Handler) searchBooks(w http.ResponseWriter, r *http.Request) error {
+	query := strings.TrimSpace(dh.Clean(r.URL.Query().Get("q")))
+	limit := DefaultSearchResults
+	if v := r.URL.Query().Get("limit"); len(v) != 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxSearchResults {
+			return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidLimit, MaxSearchResults)
+		}
+		limit = n
+	}
+	words := h.searchWords(query)
+	if utf8.RuneCountInString(query) > MaxSearchQuery || len(words) > MaxSearchWords {
+		return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.QueryTooLong, MaxSearchQuery, MaxSearchWords)
+	}
+	if len(words) == 0 {
+		writeJSON(w, r, http.StatusOK, SearchResponse{Query: query, Books: []BookResponse{}})
+		return nil
+	}
+
+	x := &h.search
+	x.mu.Lock()
+	if err := h.refreshSearch(r.Context()); err != nil {
+		x.mu.Unlock()
+		return err
+	}
+	hits := matchIndex(words, x)
+	suggestion := suggest(words, x.vocab)
+	x.mu.Unlock()
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].edits != hits[j].edits {
+			return hits[i].edits < hits[j].edits
+		}
+		if a, b := dh.SmStr(hits[i].book.Name), dh.SmStr(hits[j].book.Name); a != b {
+			return a < b
+		}
+		return hits[i].book.ID < hits[j].book.ID
+	})
+
+	resp := SearchResponse{Query: query, DidYouMean: suggestion, Books: make([]BookResponse, 0, min(len(hits), limit))}
+	for _, hit := range hits[:min(len(hits), limit)] {
+		resp.Books = append(resp.Books, NewBookResponse(hit.book, h.Prefix))
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+	return nil
+}
+
+// matchIndex finds the books of x every query word matches a word of, comparing the query with the
+// distinct words of the catalog rather than with every book; the caller holds x.mu
+func matchIndex(query []string, x *searchIndex) []searchHit {
+	var edits map[string]int //book ID -> typos so far, only books every word so far matched
+	for i, q := range query {
+		next := make(map[string]int)
+		for w, ids := range x.vocab {
+			d, ok := matchWord(q, w, i == len(query)-1)
+			if !ok {
+				continue
+			}
+			for id := range ids {
+				if prev, seen := edits[id]; i == 0 || seen {
+					if cur, found := next[id]; !found || prev+d < cur {
+						next[id] = prev + d
+					}
+				}
+			}
+		}
+		if edits = next; len(edits) == 0 {
+			return nil
+		}
+	}
+	hits := make([]searchHit, 0, len(edits))
+	for id, n := range edits {
+		hits = append(hits, searchHit{book: x.books[id].book, edits: n})
+	}
+	return hits
+}
+
+// matchWord reports whether query word q matches word w, and with how many typos; the last query
+// word also matches as a prefix since people search while typing
+func matchWord(q, w string, last bool) (int, bool) {
+	if w == q || (last && len(q) >= 3 && strings.HasPrefix(w, q)) {
+		return 0, true
+	}
+	d := dh.EditDistance(q, w, dh.MaxEdits(q))
+	return d, d <= dh.MaxEdits(q)
+}
+
+// suggest corrects the query words the catalog doesn't have to the closest, then most common, word
+// it does, returning "" when nothing needed correcting
+func suggest(query []string, vocabulary map[string]map[string]bool) string {
+	corrected, changed := make([]string, len(query)), false
+	for i, q := range query {
+		corrected[i] = q
+		if len(vocabulary[q]) > 0 {
+			continue
+		}
+		best, bestEdits := "", dh.MaxEdits(q)+1
+		for w, ids := range vocabulary {
+			n := len(ids)
+			d := dh.EditDistance(q, w, bestEdits)
+			if d < bestEdits || (d == bestEdits && len(best) != 0 && (n > len(vocabulary[best]) || (n == len(vocabulary[best]) && w < best))) {
+				best, bestEdits = w, d
+			}
+		}
+		if len(best) != 0 && bestEdits <= dh.MaxEdits(q) {
+			corrected[i], changed = best, true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(corrected, " ")
+}
@@ -0,0 +1,59 @@
+package apiHandler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ServerOptions is what NewServer builds the Book API from
+type ServerOptions struct {
+	Config     Config
+	Prefix     string                            //path to mount the API under, such as "/books"; empty for the root
+	Middleware []func(http.Handler) http.Handler //become Handler.Middleware, the first one outermost
+}
+
+// Server is the Book API for other Go programs to embed: an http.Handler to mount in their own mux
+// or router, with the Handler behind it for its stores and settings
+type Server struct {
+	*Handler
+	routes http.Handler
+}
+
+// NewServer wires the API from opts.Config as FromConfig does. Under a Prefix the API sees the
+// paths below it and the links it hands out start with it; the admin page and the /catalog pages
+// link from the root, turn the "ui" and "catalog" features off when that clashes with the
+// embedding program.
+// Call Start before serving.
+func NewServer(opts ServerOptions) (*Server, error) {
+	prefix := strings.TrimSuffix(opts.Prefix, "/")
+	if len(prefix) != 0 && !strings.HasPrefix(prefix, "/") {
+		return nil, fmt.Errorf("prefix %q doesn't start with /", opts.Prefix)
+	}
+	h, err := FromConfig(opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	h.Prefix = prefix
+	h.Middleware = append(h.Middleware, opts.Middleware...)
+	routes := h.Routes()
+	if len(prefix) != 0 {
+		routes = http.StripPrefix(prefix, routes)
+	}
+	return &Server{Handler: h, routes: routes}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.routes.ServeHTTP(w, r)
+}
+
+// Start runs the background work until ctx is done: the event listeners, scheduled jobs, the queue
+// and secret lease renewals, then the warmup as Config.Warmup says
+func (s *Server) Start(ctx context.Context) error {
+	s.listenEvents(ctx)
+	s.Jobs.Start(ctx)
+	s.Queue.Start(ctx)
+	s.Secrets.KeepRenewed(ctx)
+	return s.startWarmup(ctx)
+}
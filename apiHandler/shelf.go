@@ -0,0 +1,162 @@
+package apiHandler
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/importHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const ImportPreviewLifetime = 30 * time.Minute //how long an uploaded shelf import waits for confirmation
+
+type shelfImport struct {
+	Username string
+	Rows     []importHandler.GoodreadsRow
+	Expires  time.Time
+}
+
+type shelfImports struct { //uploaded imports waiting for confirmation
+	mu sync.Mutex
+	m  map[string]shelfImport
+}
+
+func (s *shelfImports) put(id string, imp shelfImport, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]shelfImport)
+	}
+	for k, v := range s.m {
+		if now.After(v.Expires) {
+			delete(s.m, k)
+		}
+	}
+	s.m[id] = imp
+}
+
+// take removes and returns username's import id
+func (s *shelfImports) take(id, username string, now time.Time) (shelfImport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	imp, ok := s.m[id]
+	if !ok || imp.Username != username || now.After(imp.Expires) {
+		return shelfImport{}, false
+	}
+	delete(s.m, id)
+	return imp, true
+}
+
+// forget drops pending imports of username, for deleted accounts
+func (s *shelfImports) forget(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.m {
+		if v.Username == username {
+			delete(s.m, k)
+		}
+	}
+}
+
+type ShelfImportPreview struct {
+	ID      string                       `json:"id"` //confirm with POST /me/shelves/import/{id}
+	Expires time.Time                    `json:"expires"`
+	Valid   int                          `json:"valid"`
+	Rows    []importHandler.GoodreadsRow `json:"rows"`
+}
+
+type ShelfImportResult struct {
+	Imported int                          `json:"imported"`
+	Failed   []importHandler.GoodreadsRow `json:"failed"`
+}
+
+// getShelves lists the caller's reading lists, GET /me/shelves
+func (h *Handler) getShelves(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	entries, err := h.Shelves.ListShelf(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, r, http.StatusOK, entries)
+	return nil
+}
+
+// previewShelfImport checks an uploaded Goodreads library export against the catalog without
+// changing anything, POST /me/shelves/import with Content-Type: text/csv
+func (h *Handler) previewShelfImport(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+	rows, err := importHandler.ParseGoodreadsCSV(r.Body)
+	if err != nil {
+		return eh.New(http.StatusBadRequest, eh.InvalidData, i18n.InvalidDataDetail, err.Error())
+	}
+
+	preview := ShelfImportPreview{ID: h.IDs.NewID(), Expires: h.Clock.Now().Add(ImportPreviewLifetime), Rows: rows}
+	isbns := make([]string, 0, len(rows))
+	for i, row := range rows {
+		if row.Invalid != nil {
+			rows[i].Error = rowError(r, *row.Invalid)
+			continue
+		}
+		isbns = append(isbns, row.ISBN)
+	}
+	known, err := h.Store.GetBooksByISBNs(r.Context(), isbns)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		if len(row.Error) != 0 {
+			continue
+		}
+		if _, ok := known[row.ISBN]; !ok {
+			rows[i].Error = i18n.T(r, i18n.BookNotFound)
+			continue
+		}
+		preview.Valid++
+	}
+
+	user, _ := authHandler.UserFrom(r.Context())
+	h.imports.put(preview.ID, shelfImport{Username: user, Rows: rows, Expires: preview.Expires}, h.Clock.Now())
+	writeJSON(w, r, http.StatusOK, preview)
+	return nil
+}
+
+// rowError words why an imported row was left out in the caller's language, naming the field the
+// way the caller spells field names
+func rowError(r *http.Request, e dh.FieldError) string {
+	return i18n.T(r, "rule_"+e.Rule, append([]any{ch.Of(r).Name(e.Field)}, e.Args...)...)
+}
+
+// confirmShelfImport applies the valid rows of a previewed import, POST /me/shelves/import/{id}
+func (h *Handler) confirmShelfImport(w http.ResponseWriter, r *http.Request) error {
+	user, _ := authHandler.UserFrom(r.Context())
+	imp, ok := h.imports.take(chi.URLParam(r, "id"), user, h.Clock.Now())
+	if !ok {
+		return eh.New(http.StatusNotFound, eh.ImportNotFound, i18n.ImportNotFound)
+	}
+
+	result := ShelfImportResult{Failed: []importHandler.GoodreadsRow{}}
+	for _, row := range imp.Rows {
+		if len(row.Error) != 0 {
+			continue
+		}
+		err := h.Shelves.PutShelfEntry(r.Context(), user, row.ShelfEntry)
+		switch {
+		case errors.Is(err, dh.ErrBookNotFound): //deleted since the preview
+			row.Error = i18n.T(r, i18n.BookNotFound)
+			result.Failed = append(result.Failed, row)
+		case err != nil:
+			return err
+		default:
+			result.Imported++
+		}
+	}
+	writeJSON(w, r, http.StatusOK, result)
+	return nil
+}
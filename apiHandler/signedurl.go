@@ -0,0 +1,149 @@
+package apiHandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/authHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+)
+
+// query parameters of a signed URL
+const (
+	signedExpires = "expires"   //unix seconds
+	signedBy      = "signed_by" //the admin who minted it, whose access it lends
+	signedSig     = "signature"
+)
+
+const (
+	SignedURLTTL    = time.Hour
+	MaxSignedURLTTL = 7 * 24 * time.Hour
+)
+
+// signable are the paths signed URLs can be minted for, as path.Match patterns: GET routes behind a
+// login whose responses can be shared. Book records and covers are public already.
+var signable = []string{
+	"/api/v1/books/*/file",     //e-books
+	"/admin/catalog-reports/*", //generated reports
+}
+
+type SignedURLRequest struct {
+	Path string `json:"path"` //with its query, e.g. /admin/catalog-reports/monthly-2026-09
+	TTL  int    `json:"ttl"`  //seconds, SignedURLTTL when 0
+}
+
+type SignedURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func signablePath(p string) bool {
+	for _, pattern := range signable {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// urlSigningKey is Config.URLSigningKey, or else a key derived from the JWT secret, so every replica
+// accepts the URL and rotating the secret revokes them all. It is nil while the built-in JWT secret is
+// used and no key is set: anyone could sign URLs with it.
+func (h *Handler) urlSigningKey() []byte {
+	switch {
+	case len(h.Config.URLSigningKey) != 0:
+		return []byte(h.Config.URLSigningKey)
+	case h.Config.JWTSecret == string(authHandler.Secret):
+		return nil
+	}
+	m := hmac.New(sha256.New, []byte(h.Config.JWTSecret))
+	m.Write([]byte("signed url key"))
+	return m.Sum(nil)
+}
+
+// urlSignature signs a path with its query, which names the expiry and signer
+func urlSignature(key []byte, p string, q url.Values) string {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte("signed url\n" + p + "?" + q.Encode()))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// postSignedURL mints a URL that lends the calling admin's access to one resource until it expires,
+// for sharing it with people without an account, POST /admin/signed-urls
+func (h *Handler) postSignedURL(w http.ResponseWriter, r *http.Request) error {
+	key := h.urlSigningKey()
+	if key == nil {
+		return eh.New(http.StatusServiceUnavailable, eh.SigningDisabled, i18n.SigningDisabled)
+	}
+	var req SignedURLRequest
+	if err := readText(r, MaxSettingsBody, &req); err != nil {
+		return err
+	}
+	var errs []dh.FieldError
+	u, err := url.Parse(req.Path)
+	if err != nil || u.IsAbs() || len(u.Host) != 0 || !signablePath(u.Path) {
+		errs = append(errs, dh.FieldError{Field: "path", Rule: specHandler.RuleEnum, Args: []any{strings.Join(signable, ", ")}})
+	}
+	ttl := SignedURLTTL
+	if req.TTL != 0 {
+		ttl = time.Duration(req.TTL) * time.Second
+	}
+	switch {
+	case ttl < time.Second:
+		errs = append(errs, dh.FieldError{Field: "ttl", Rule: specHandler.RuleMinimum, Args: []any{1}})
+	case ttl > MaxSignedURLTTL:
+		errs = append(errs, dh.FieldError{Field: "ttl", Rule: specHandler.RuleMaximum, Args: []any{int(MaxSignedURLTTL / time.Second)}})
+	}
+	if len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+
+	user, _ := authHandler.UserFrom(r.Context())
+	expires := h.Clock.Now().Add(ttl).Truncate(time.Second)
+	q := u.Query()
+	q.Del(signedSig)
+	q.Set(signedExpires, strconv.FormatInt(expires.Unix(), 10))
+	q.Set(signedBy, user)
+	sig := urlSignature(key, u.Path, q)
+	q.Set(signedSig, sig)
+	h.logf(r, "signed url: %s for %s until %s", user, u.Path, expires.Format(time.RFC3339))
+	writeJSON(w, r, http.StatusCreated, SignedURLResponse{URL: h.externalURL(u.EscapedPath() + "?" + q.Encode()), ExpiresAt: expires})
+	return nil
+}
+
+// signedOr lets GET requests with a valid signed URL through as the admin who minted it, others
+// must pass login. A signed URL that expired or was altered is refused with 403. Signatures are
+// ignored while urlSigningKey is nil.
+func (h *Handler) signedOr(login func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		checked := login(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			sig, key := q.Get(signedSig), h.urlSigningKey()
+			if len(sig) == 0 || key == nil || r.Method != http.MethodGet && r.Method != http.MethodHead {
+				checked.ServeHTTP(w, r)
+				return
+			}
+			q.Del(signedSig)
+			expires, err := strconv.ParseInt(q.Get(signedExpires), 10, 64)
+			user := q.Get(signedBy)
+			if err != nil || !signablePath(r.URL.Path) || !h.Clock.Now().Before(time.Unix(expires, 0)) ||
+				!hmac.Equal([]byte(sig), []byte(urlSignature(key, r.URL.Path, q))) || !h.Auth.Admins[user] {
+				h.fail(w, r, eh.New(http.StatusForbidden, eh.InvalidSignature, i18n.InvalidSignature))
+				return
+			}
+			w.Header().Set("Cache-Control", "private, no-store")
+			next.ServeHTTP(w, r.WithContext(authHandler.WithUser(r.Context(), user)))
+		})
+	}
+}
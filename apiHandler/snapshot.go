@@ -0,0 +1,224 @@
+package apiHandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/blobHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+const (
+	snapshotIndexKey = "snapshots/index.json"
+	CurrentCatalog   = "current" //names the live catalog in /admin/diff
+)
+
+var errSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot is a backup of the whole catalog, kept in the blob store under snapshots/<name>.json
+type Snapshot struct {
+	Name    string    `json:"name"` //when it was taken, e.g. 20261016T202400Z
+	TakenAt time.Time `json:"taken_at"`
+	Books   []dh.Book `json:"books"`
+}
+
+type SnapshotInfo struct {
+	Name    string    `json:"name"`
+	TakenAt time.Time `json:"taken_at"`
+	Books   int       `json:"books"`
+}
+
+// BookChange is a book that differs between two snapshots
+type BookChange struct {
+	ID     string   `json:"id"`
+	Fields []string `json:"fields"` //JSON names of the fields that differ, updated_at and version aside
+	Before dh.Book  `json:"before"`
+	After  dh.Book  `json:"after"`
+}
+
+type DiffResponse struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Added    []dh.Book    `json:"added"`
+	Removed  []dh.Book    `json:"removed"`
+	Modified []BookChange `json:"modified"`
+}
+
+// readBlobJSON decodes the JSON blob at key into v and reports false when there is none
+func (h *Handler) readBlobJSON(ctx context.Context, key string, v any) (bool, error) {
+	f, _, err := h.Blobs.Get(ctx, key)
+	if errors.Is(err, blobHandler.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (h *Handler) writeBlobJSON(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = h.Blobs.Put(ctx, key, "application/json", bytes.NewReader(data))
+	return err
+}
+
+func (h *Handler) snapshotIndex(ctx context.Context) ([]SnapshotInfo, error) {
+	index := []SnapshotInfo{}
+	_, err := h.readBlobJSON(ctx, snapshotIndexKey, &index)
+	return index, err
+}
+
+// takeSnapshot backs up the catalog as it is now. It is also the job behind Config.SnapshotInterval.
+func (h *Handler) takeSnapshot(ctx context.Context) (SnapshotInfo, error) {
+	books, err := h.Store.ListBooks(ctx)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	now := h.Clock.Now().UTC()
+	snap := Snapshot{Name: now.Format("20060102T150405Z"), TakenAt: now, Books: books}
+	if err := h.writeBlobJSON(ctx, "snapshots/"+snap.Name+".json", snap); err != nil {
+		return SnapshotInfo{}, err
+	}
+	h.indexes.Lock()
+	defer h.indexes.Unlock()
+	index, err := h.snapshotIndex(ctx)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	info := SnapshotInfo{Name: snap.Name, TakenAt: snap.TakenAt, Books: len(books)}
+	index = append(index, info)
+	if err := h.writeBlobJSON(ctx, snapshotIndexKey, index); err != nil {
+		return SnapshotInfo{}, err
+	}
+	h.Logs.Info.Printf("snapshot %s taken, %d books", snap.Name, len(books))
+	return info, nil
+}
+
+// catalogAt loads the books of a snapshot, or of the live catalog for CurrentCatalog
+func (h *Handler) catalogAt(ctx context.Context, name string) ([]dh.Book, error) {
+	if name == CurrentCatalog {
+		return h.Store.ListBooks(ctx)
+	}
+	var snap Snapshot
+	if len(name) == 0 || !validSnapshotName(name) {
+		return nil, errSnapshotNotFound
+	}
+	found, err := h.readBlobJSON(ctx, "snapshots/"+name+".json", &snap)
+	if err == nil && !found {
+		err = errSnapshotNotFound
+	}
+	return snap.Books, err
+}
+
+func validSnapshotName(name string) bool {
+	_, err := time.Parse("20060102T150405Z", name)
+	return err == nil
+}
+
+// diffBooks compares two catalogs by book ID, each result sorted by ID
+func diffBooks(from, to []dh.Book) ([]dh.Book, []dh.Book, []BookChange) {
+	before := make(map[string]dh.Book, len(from))
+	for _, b := range from {
+		before[b.ID] = b
+	}
+	added, removed, modified := []dh.Book{}, []dh.Book{}, []BookChange{}
+	for _, b := range to {
+		old, ok := before[b.ID]
+		if !ok {
+			added = append(added, b)
+			continue
+		}
+		delete(before, b.ID)
+		if fields := changedFields(old, b); len(fields) != 0 {
+			modified = append(modified, BookChange{ID: b.ID, Fields: fields, Before: old, After: b})
+		}
+	}
+	for _, b := range before {
+		removed = append(removed, b)
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].ID < added[j].ID })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].ID < removed[j].ID })
+	sort.Slice(modified, func(i, j int) bool { return modified[i].ID < modified[j].ID })
+	return added, removed, modified
+}
+
+// changedFields lists the JSON names of the fields a and b differ in, leaving out the bookkeeping
+// every write changes
+func changedFields(a, b dh.Book) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var fields []string
+	for i := 0; i < va.NumField(); i++ {
+		name, _, _ := strings.Cut(va.Type().Field(i).Tag.Get("json"), ",")
+		if name == "updated_at" || name == "version" || name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// getSnapshots lists the catalog backups, newest first, GET /admin/snapshots
+func (h *Handler) getSnapshots(w http.ResponseWriter, r *http.Request) error {
+	index, err := h.snapshotIndex(r.Context())
+	if err != nil {
+		return fmt.Errorf("snapshots: %w", err)
+	}
+	sort.SliceStable(index, func(i, j int) bool { return index[i].TakenAt.After(index[j].TakenAt) })
+	writeJSON(w, r, http.StatusOK, index)
+	return nil
+}
+
+// postSnapshot backs up the catalog now, POST /admin/snapshots
+func (h *Handler) postSnapshot(w http.ResponseWriter, r *http.Request) error {
+	info, err := h.takeSnapshot(r.Context())
+	if err != nil {
+		return fmt.Errorf("snapshots: %w", err)
+	}
+	writeJSON(w, r, http.StatusCreated, info)
+	return nil
+}
+
+// getDiff compares two snapshots, GET /admin/diff?from=20261001T000000Z&to=20261008T000000Z.
+// to defaults to the live catalog, which from and to can also name as "current".
+func (h *Handler) getDiff(w http.ResponseWriter, r *http.Request) error {
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if len(from) == 0 {
+		return eh.ValidationError{{Field: "from", Rule: dh.RuleRequired}}
+	}
+	if len(to) == 0 {
+		to = CurrentCatalog
+	}
+	before, err := h.catalogAt(r.Context(), from)
+	var after []dh.Book
+	if err == nil {
+		after, err = h.catalogAt(r.Context(), to)
+	}
+	switch {
+	case errors.Is(err, errSnapshotNotFound):
+		return eh.New(http.StatusNotFound, eh.SnapshotNotFound, i18n.SnapshotNotFound)
+	case err != nil:
+		return fmt.Errorf("snapshots: %w", err)
+	}
+	added, removed, modified := diffBooks(before, after)
+	writeJSON(w, r, http.StatusOK, DiffResponse{From: from, To: to, Added: added, Removed: removed, Modified: modified})
+	return nil
+}
@@ -0,0 +1,82 @@
+package apiHandler
+
+import (
+	_ "embed"
+	"net/http"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+)
+
+//go:embed openapi.json
+var openAPIDoc []byte
+
+// APISpec is the OpenAPI document of the /api/v1 routes, requests to them are checked against it
+var APISpec = specHandler.MustLoad(openAPIDoc)
+
+// SchemaTypes are the data model types GET /api/v1/schema publishes, with the types they refer to
+var SchemaTypes = []string{"Book", "Author", "CreateBookRequest", "UpdateBookRequest", "Review", "Problem", "ValidationError"}
+
+var schemaDoc = mustJSONSchema()
+
+func mustJSONSchema() []byte {
+	doc, err := APISpec.JSONSchema("/api/v1/schema", SchemaTypes...)
+	if err != nil {
+		panic("json schema: " + err.Error())
+	}
+	return doc
+}
+
+// validateRequests refuses requests whose parameters don't conform to APISpec with 400, before they
+// reach a handler. Routes the document leaves out pass, the "request_validation" feature flag turns
+// checking off.
+func (h *Handler) validateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.feature("request_validation") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if errs := APISpec.CheckRequest(r); len(errs) != 0 {
+			eh.Nonconforming(w, r, errs)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateBodies is validateRequests for JSON bodies. It buffers up to specHandler.MaxBody, so it
+// goes after Authenticate: anonymous clients don't get the server to hold their bodies.
+func (h *Handler) validateBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.feature("request_validation") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		errs, err := APISpec.CheckBody(r)
+		if err != nil {
+			h.fail(w, r, eh.New(http.StatusBadRequest, eh.InvalidBody, i18n.CannotReadBody))
+			return
+		}
+		if len(errs) != 0 {
+			eh.Nonconforming(w, r, errs)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getOpenAPI serves the OpenAPI document, GET /api/v1/openapi.json
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(APISpec.Raw)
+}
+
+// getSchema serves JSON Schema definitions of the data model for client and form generators,
+// GET /api/v1/schema; they come from the OpenAPI document so both always agree
+func getSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(schemaDoc)
+}
@@ -0,0 +1,43 @@
+package apiHandler_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sabnaj-42/BookServer-API/apiHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// statementStore is a book store as a SQL plugin would register it, with a cache of prepared statements
+type statementStore struct {
+	*dh.MemStore
+}
+
+func (statementStore) StatementStats() (prepared, executed, cached int64) { return 4, 42, 3 }
+
+func init() {
+	apiHandler.RegisterStore("test-statements", func(context.Context, apiHandler.Backend) (dh.Store, error) {
+		return statementStore{dh.NewMemStore()}, nil
+	})
+}
+
+func TestStatementMetrics(t *testing.T) {
+	cfg := apiHandler.DefaultConfig()
+	cfg.Store = "test-statements"
+	h, err := apiHandler.FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("FromConfig: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{"store_statements_prepared_total 4\n", "store_statements_executed_total 42\n", "store_statements_cached 3\n"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics = %d without %q", rec.Code, want)
+		}
+	}
+}
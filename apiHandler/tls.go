@@ -0,0 +1,44 @@
+package apiHandler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSConfig builds the listener's TLS settings, nil when the server speaks plain HTTP
+func (c Config) TLSConfig() (*tls.Config, error) {
+	if len(c.TLSCert) == 0 {
+		if len(c.ClientCA) != 0 {
+			return nil, fmt.Errorf("--client-ca needs --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if len(c.ClientCA) == 0 {
+		return conf, nil
+	}
+
+	pem, err := os.ReadFile(c.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+	conf.ClientCAs = x509.NewCertPool()
+	if !conf.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no PEM certificates", c.ClientCA)
+	}
+	switch c.ClientCerts {
+	case "optional":
+		conf.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client certificate mode %q", c.ClientCerts)
+	}
+	return conf, nil
+}
@@ -0,0 +1,80 @@
+package apiHandler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Sabnaj-42/BookServer-API/blobHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// UploadLimits caps uploads by content type, in bytes
+var UploadLimits = map[string]int64{
+	"application/epub+zip": 100 << 20,
+	"application/pdf":      200 << 20,
+	"image/jpeg":           10 << 20,
+	"image/png":            10 << 20,
+}
+
+// receiveUpload spools the request body to a temporary file once it passes every check: the Content-Type is one of
+// accepted, the body fits that type's limit, its magic bytes say the same type and h.Scanner, when set, finds nothing.
+// It returns the file rewound and its type, the caller closes it and the file is already unlinked.
+func (h *Handler) receiveUpload(w http.ResponseWriter, r *http.Request, accepted ...string) (f *os.File, contentType string, err error) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !slices.Contains(accepted, mt) {
+		return nil, "", eh.New(http.StatusUnsupportedMediaType, eh.UnsupportedMediaType, i18n.UnsupportedMediaType, strings.Join(accepted, " or "))
+	}
+
+	f, err = os.CreateTemp("", "bookserver-upload-*")
+	if err != nil {
+		return nil, "", eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("upload: %w", err))
+	}
+	os.Remove(f.Name()) //gone once closed, whatever happens
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+
+	_, err = io.Copy(f, http.MaxBytesReader(w, r.Body, UploadLimits[mt]))
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		return nil, "", eh.New(http.StatusRequestEntityTooLarge, eh.FileTooLarge, i18n.FileTooLarge, tooBig.Limit>>20)
+	}
+	if err != nil {
+		return nil, "", eh.New(http.StatusBadRequest, eh.InvalidBody, i18n.CannotReadBody)
+	}
+
+	head := make([]byte, blobHandler.SniffLen)
+	n, _ := f.ReadAt(head, 0)
+	if blobHandler.Sniff(head[:n]) != mt {
+		return nil, "", eh.New(http.StatusUnsupportedMediaType, eh.ContentMismatch, i18n.ContentMismatch, mt)
+	}
+
+	if h.Scanner != nil {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, "", eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("upload: %w", err))
+		}
+		err := h.Scanner.Scan(r.Context(), f)
+		var infected *blobHandler.InfectedError
+		if errors.As(err, &infected) {
+			h.warnf(r, "upload: rejected %s: %s", mt, infected.Signature)
+			return nil, "", eh.New(http.StatusUnprocessableEntity, eh.FileInfected, i18n.FileInfected)
+		}
+		if err != nil {
+			return nil, "", eh.New(http.StatusServiceUnavailable, eh.ScanUnavailable, i18n.ScanUnavailable).WithCause(fmt.Errorf("upload: scan: %w", err))
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).WithCause(fmt.Errorf("upload: %w", err))
+	}
+	return f, mt, nil
+}
@@ -0,0 +1,158 @@
+package apiHandler
+
+import (
+	"encoding/csv"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+const monthLayout = "2006-01"
+
+// TenantFunc names the tenant a request is billed to
+type TenantFunc func(r *http.Request) string
+
+// maxUsageEntries bounds the tenant and month pairs kept, a TenantFunc naming too many tenants has
+// the rest billed to "other"
+const maxUsageEntries = 10000
+
+// HostTenants bills requests to the host name they were sent to, for hosted deployments giving each
+// tenant its own domain. Clients choose the Host header, so only the listed hosts count as tenants,
+// requests to others go to the default tenant.
+func HostTenants(hosts []string) TenantFunc {
+	known := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		known[strings.ToLower(host)] = true
+	}
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if host = strings.ToLower(host); known[host] {
+			return host
+		}
+		return ""
+	}
+}
+
+type Usage struct { //what one tenant used in one month (UTC)
+	Tenant        string `json:"tenant"`
+	Month         string `json:"month"` //YYYY-MM
+	Requests      int64  `json:"requests"`
+	BytesUploaded int64  `json:"bytes_uploaded"` //covers and e-book files as sent, before deduplication
+	BooksCreated  int64  `json:"books_created"`
+}
+
+type usageKey struct{ tenant, month string }
+
+type usageState struct { //usage per tenant and month, kept per instance since start
+	mu sync.Mutex
+	m  map[usageKey]*Usage
+}
+
+// meter adds delta to the usage of r's tenant in the current month
+func (h *Handler) meter(r *http.Request, delta Usage) {
+	tenant := h.TenantOf(r)
+	if len(tenant) == 0 {
+		tenant = "default"
+	}
+	key := usageKey{tenant, h.Clock.Now().UTC().Format(monthLayout)}
+
+	u := &h.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.m == nil {
+		u.m = make(map[usageKey]*Usage)
+	}
+	cur, ok := u.m[key]
+	if !ok && len(u.m) >= maxUsageEntries {
+		oldest := h.Clock.Now().UTC().AddDate(-1, 0, 0).Format(monthLayout)
+		for k := range u.m {
+			if k.month < oldest {
+				delete(u.m, k)
+			}
+		}
+		if len(u.m) >= maxUsageEntries {
+			key.tenant = "other"
+			cur, ok = u.m[key]
+		}
+	}
+	if !ok {
+		cur = &Usage{Tenant: key.tenant, Month: key.month}
+		u.m[key] = cur
+	}
+	cur.Requests += delta.Requests
+	cur.BytesUploaded += delta.BytesUploaded
+	cur.BooksCreated += delta.BooksCreated
+}
+
+// meterRequests counts every request towards its tenant's usage
+func (h *Handler) meterRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.meter(r, Usage{Requests: 1})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getUsage reports usage per tenant for charge-back, GET /admin/usage?month=2024-05&format=json|csv,
+// the current month by default
+func (h *Handler) getUsage(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	month := q.Get("month")
+	if len(month) == 0 {
+		month = h.Clock.Now().UTC().Format(monthLayout)
+	}
+	if _, err := time.Parse(monthLayout, month); err != nil {
+		return eh.New(http.StatusBadRequest, eh.InvalidMonth, i18n.InvalidMonth)
+	}
+	format := q.Get("format")
+	if len(format) == 0 {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		return eh.New(http.StatusBadRequest, eh.InvalidFormat, i18n.InvalidFormat, format)
+	}
+
+	h.usage.mu.Lock()
+	report := make([]Usage, 0)
+	for k, u := range h.usage.m {
+		if k.month == month {
+			report = append(report, *u)
+		}
+	}
+	h.usage.mu.Unlock()
+	sort.Slice(report, func(i, j int) bool { return report[i].Tenant < report[j].Tenant })
+
+	if format == "json" {
+		writeJSON(w, r, http.StatusOK, report)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="usage-`+month+`.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"tenant", "month", "requests", "bytes_uploaded", "books_created"})
+	for _, u := range report {
+		cw.Write([]string{csvCell(u.Tenant), u.Month, strconv.FormatInt(u.Requests, 10), strconv.FormatInt(u.BytesUploaded, 10), strconv.FormatInt(u.BooksCreated, 10)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		h.errorf(r, "usage: %v", err)
+	}
+	return nil
+}
+
+// csvCell keeps spreadsheets from running s as a formula, prefixing it with ' when it starts like one
+func csvCell(s string) string {
+	if len(s) != 0 && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
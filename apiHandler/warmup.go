@@ -0,0 +1,102 @@
+package apiHandler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// warmup modes of Config.Warmup
+const (
+	WarmupOff   = "off"   //serve right away with cold caches
+	WarmupSync  = "sync"  //warm up before listening
+	WarmupAsync = "async" //listen right away, /readyz answers 503 until warm
+)
+
+const WarmupBooks = 1000 //most recently updated books loaded into the book cache
+
+type warmStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// OnWarmup adds a step to the warmup, call it before RunServer; steps run in the order added
+func (h *Handler) OnWarmup(name string, run func(ctx context.Context) error) {
+	h.warmSteps = append(h.warmSteps, warmStep{name: name, run: run})
+}
+
+// Warmup runs every warmup step while /readyz reports not ready. A failing step is logged and
+// skipped, a cold cache is slower but not wrong.
+func (h *Handler) Warmup(ctx context.Context) {
+	h.warming.Store(true)
+	defer h.warming.Store(false)
+	start := h.Clock.Now()
+	for _, s := range h.warmSteps {
+		t := h.Clock.Now()
+		if err := s.run(ctx); err != nil {
+			h.Logs.Warn.Printf("warmup: %s: %v", s.name, err)
+			continue
+		}
+		h.Logs.Info.Printf("warmup: %s in %v", s.name, h.Clock.Now().Sub(t).Round(time.Millisecond))
+	}
+	h.Logs.Info.Printf("warmup: done in %v", h.Clock.Now().Sub(start).Round(time.Millisecond))
+}
+
+// startWarmup warms up as Config.Warmup says
+func (h *Handler) startWarmup(ctx context.Context) error {
+	switch h.Config.Warmup {
+	case WarmupOff, "":
+	case WarmupSync:
+		h.Warmup(ctx)
+	case WarmupAsync:
+		h.warming.Store(true) //not ready before the goroutine got going
+		go h.Warmup(ctx)
+	default:
+		return fmt.Errorf("unknown warmup mode %q", h.Config.Warmup)
+	}
+	return nil
+}
+
+// warmBooks loads the most recently updated books into the book cache
+func (h *Handler) warmBooks(ctx context.Context) error {
+	cached, ok := h.Store.(*dh.CachedStore)
+	if !ok {
+		return nil
+	}
+	return cached.Prime(ctx, func(ctx context.Context) ([]dh.Book, error) {
+		books, err := cached.ListBooksUpdatedAfter(ctx, time.Time{})
+		if len(books) > WarmupBooks {
+			books = books[len(books)-WarmupBooks:]
+		}
+		return books, err
+	})
+}
+
+// warmTranslit romanizes every book up front, so the first searches don't pay for it
+func (h *Handler) warmTranslit(ctx context.Context) error {
+	if h.Translit == nil {
+		return nil
+	}
+	books, err := h.Store.ListBooks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range books {
+		h.Translit.Key(b)
+	}
+	return nil
+}
+
+// readyz answers 200 once the server is warmed up, 503 before, for load balancer readiness probes
+func (h *Handler) readyz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if h.warming.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("warming up\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
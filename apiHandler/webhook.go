@@ -0,0 +1,114 @@
+package apiHandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/moderationHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+)
+
+const (
+	webhookPoll  = time.Second //how often the change log is checked for changes to deliver
+	webhookBatch = 100         //changes per POST at most
+)
+
+// BookWebhookPayload is what Config.BookWebhook receives, the book changes in the order they were
+// made. Updates carry a diff of the fields they changed, so receivers can apply them without
+// fetching the book.
+type BookWebhookPayload struct {
+	Changes []ChangeResponse `json:"changes"`
+}
+
+type bookHooks struct {
+	client *http.Client
+	seq    atomic.Int64 //last change delivered
+}
+
+// webhookClient is the client of the book and moderation webhooks. It refuses hosts inside private
+// networks unless Config.AllowPrivateWebhooks, so a webhook URL can't be used to reach internal services.
+func (h *Handler) webhookClient(timeout time.Duration) *http.Client {
+	if h.Config.AllowPrivateWebhooks {
+		return outboundHandler.Client(timeout)
+	}
+	return outboundHandler.PublicClient(timeout)
+}
+
+// startBookHooks delivers the changes made from now on, the job runs on one replica at a time
+func (h *Handler) startBookHooks(ctx context.Context) error {
+	err := dh.EachChange(ctx, h.Store, 0, func(c dh.Change) error {
+		h.hooks.seq.Store(c.Seq)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.hooks.client = h.webhookClient(30 * time.Second)
+	h.Jobs.Every("book webhooks", webhookPoll, h.deliverChanges)
+	return nil
+}
+
+// deliverChanges POSTs the changes since the last delivery to Config.BookWebhook. A failed POST is
+// tried again on the next run from the same change, so receivers get every change at least once
+// and in order, and tell repeats apart by seq.
+func (h *Handler) deliverChanges(ctx context.Context) error {
+	log, ok := h.Store.(dh.ChangeLog)
+	if !ok {
+		return dh.ErrNoChangeLog
+	}
+	for {
+		changes, err := log.Changes(ctx, h.hooks.seq.Load(), webhookBatch)
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		p := BookWebhookPayload{Changes: make([]ChangeResponse, 0, len(changes))}
+		for _, c := range changes {
+			p.Changes = append(p.Changes, NewChangeResponse(c, h.Prefix))
+		}
+		if err := h.postWebhook(ctx, p); errors.Is(err, outboundHandler.ErrCircuitOpen) {
+			return nil //logged when it opened, tried again once it closes
+		} else if err != nil {
+			return err
+		}
+		h.hooks.seq.Store(changes[len(changes)-1].Seq)
+	}
+}
+
+// postWebhook sends p signed like the moderation webhook, any 2xx answer counts as delivered
+func (h *Handler) postWebhook(ctx context.Context, p BookWebhookPayload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Config.BookWebhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(h.Config.BookWebhookSecret) != 0 {
+		m := hmac.New(sha256.New, []byte(h.Config.BookWebhookSecret))
+		m.Write(body)
+		req.Header.Set(moderationHandler.SignatureHeader, "sha256="+hex.EncodeToString(m.Sum(nil)))
+	}
+	resp, err := h.hooks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("book webhook: %s", resp.Status)
+	}
+	return nil
+}
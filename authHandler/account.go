@@ -0,0 +1,51 @@
+package authHandler
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// generation identifies the current incarnation of username's account. It is taken from the stored
+// password hash, whose salt differs for every account created under a name, so a login issued
+// to a deleted account doesn't carry over to one registered later under the same name.
+func (h *Handler) generation(ctx context.Context, username string) (string, error) {
+	hash, err := h.Users.GetPasswordHash(ctx, username)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// active reports whether the account a login was issued to still exists, so logins of a deleted
+// account stop working at once instead of when their cookie expires
+func (h *Handler) active(ctx context.Context, username, gen string) bool {
+	cur, err := h.generation(ctx, username)
+	if errors.Is(err, dh.ErrUserNotFound) {
+		return false
+	}
+	return err != nil || subtle.ConstantTimeCompare([]byte(cur), []byte(gen)) == 1 //a failing store is left to the handlers
+}
+
+// DeleteAccount removes username's login and remember-me tokens and clears the caller's cookies.
+// Data kept elsewhere about the user is for the caller to remove first.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request, username string) error {
+	h.revokeRefresh(r, username, "")
+	h.endSessions(r, username)
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		if err := h.Sessions.Delete(r.Context(), c.Value); err != nil {
+			h.logf(r, "delete account: %v", err)
+		}
+	}
+	if err := h.Users.DeleteUser(r.Context(), username); err != nil {
+		return err
+	}
+	clearCookies(w)
+	return nil
+}
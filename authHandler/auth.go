@@ -1,101 +1,250 @@
 package authHandler
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-
-	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
-	"github.com/lestrrat-go/jwx/v2/jwa"
-	"github.com/lestrrat-go/jwx/v2/jwt"
-	"io/ioutil"
+	"io"
+	"log"
 	"net/http"
 	"time"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/ipHandler"
+	trace "github.com/Sabnaj-42/BookServer-API/traceHandler"
 )
 
 var Secret = []byte("this_is_my_secret_key")
 
-func Login(w http.ResponseWriter, r *http.Request) {
-	var cred dh.Credentials
+const TokenLifetime = 20 * time.Minute
 
-	err := json.NewDecoder(r.Body).Decode(&cred)
+type Mode string //how logins are remembered between requests
 
-	if err != nil {
-		http.Error(w, "Cannot decode data", http.StatusBadRequest)
-		return
+const (
+	ModeJWT     Mode = "jwt"     //stateless signed token cookie
+	ModeSession Mode = "session" //opaque cookie pointing into a SessionStore
+)
+
+const (
+	JWTCookie     = "jwt"
+	SessionCookie = "session"
+)
+
+type Handler struct { //login, logout and signup endpoints
+	Users     dh.UserStore
+	Tokens    TokenIssuer
+	Sessions  SessionStore //used when Mode is ModeSession
+	Refresh   RefreshStore //remember-me logins
+	Mode      Mode
+	Basic     bool              //also accept HTTP Basic credentials on protected routes
+	Keys      KeyStore          //HMAC signing keys of machine clients, nil refuses signed requests
+	Nonces    NonceStore        //nonces of signed requests, against replays
+	CertUsers map[string]string //verified client certificate subject -> username, for mTLS
+	Admins    map[string]bool   //usernames allowed on admin routes
+	Policy    PasswordPolicy    //rules for new passwords
+	Logger    *log.Logger
+	Clock     dh.Clock
+
+	throttle loginThrottle //failed logins per account
+}
+
+func NewHandler(users dh.UserStore, tokens TokenIssuer, logger *log.Logger, clock dh.Clock) *Handler {
+	if logger == nil {
+		logger = log.Default()
 	}
+	if clock == nil {
+		clock = dh.SystemClock{}
+	}
+	return &Handler{Users: users, Tokens: tokens, Sessions: NewMemSessionStore(), Refresh: NewMemRefreshStore(), Nonces: NewMemNonceStore(clock), Mode: ModeJWT, Admins: map[string]bool{"Admin": true}, Policy: DefaultPasswordPolicy, Logger: logger, Clock: clock}
+}
 
-	password, ok := dh.CredentialList[cred.Username]
+func (h *Handler) logf(r *http.Request, format string, args ...any) {
+	h.Logger.Printf("[%s] %s", trace.RequestID(r.Context()), fmt.Sprintf(format, args...))
+}
+
+const maxCredentialsBody = 4 << 10 //bytes of a login, registration or password change
+
+type loginRequest struct {
+	dh.Credentials
+	RememberMe bool `json:"remember_me"` //also issue a long lived refresh token for this device
+}
+
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.login)(w, r)
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) error {
+	var cred loginRequest
+	if err := readCredentials(w, r, &cred); err != nil {
+		return err
+	}
+
+	ok, err := h.CheckCredentials(r.Context(), cred.Username, cred.Password)
+	if err != nil {
+		return Throttled(w, fmt.Errorf("login: %w", err))
+	}
 	if !ok {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
+		return eh.New(http.StatusUnauthorized, eh.InvalidCredentials, i18n.InvalidCredentials)
+	}
+
+	if err := h.startLogin(w, r, cred.Username); err != nil {
+		return eh.New(http.StatusInternalServerError, eh.Internal, i18n.InternalError).WithCause(fmt.Errorf("login: %w", err))
+	}
+	if cred.RememberMe {
+		if err := h.issueRefresh(w, r, cred.Username); err != nil {
+			h.logf(r, "login: %v", err) //the short login still works
+		}
 	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(i18n.T(r, i18n.LoginSuccessful)))
+	return nil
+}
 
-	if password != cred.Password {
-		http.Error(w, "Wrong password", http.StatusNotFound)
-		return
+// CheckCredentials verifies a username and password against the user store,
+// unknown users are checked against a dummy hash so both failures look and take the same.
+// After freeLoginFailures wrong passwords in a row from the client address ipHandler.RealIP put in
+// ctx an account name gets a *ThrottledError there instead until its wait is over.
+func (h *Handler) CheckCredentials(ctx context.Context, username, password string) (bool, error) {
+	now := h.Clock.Now()
+	addr, _ := ipHandler.FromContext(ctx)
+	if wait := h.throttle.wait(username, addr, now); wait > 0 {
+		return false, &ThrottledError{Wait: wait}
+	}
+	hash, err := h.Users.GetPasswordHash(ctx, username)
+	known := true
+	if errors.Is(err, dh.ErrUserNotFound) {
+		hash, known = dh.DummyHash(), false
+	} else if err != nil {
+		return false, err
 	}
 
-	//JWT token generation
-	et := time.Now().Add(20 * time.Minute)
-	token, err := jwt.NewBuilder().Audience([]string{"sabnaj"}).Expiration(et).Build()
+	ok, err := dh.CheckPassword(hash, password)
 	if err != nil {
-		http.Error(w, "Cannot create token", http.StatusInternalServerError)
-		return
+		return false, err
+	}
+	if !ok || !known {
+		h.throttle.failed(username, addr, now)
+		return false, nil
 	}
-	signed, err := jwt.Sign(token, jwt.WithKey(jwa.HS256, Secret))
+	h.throttle.succeeded(username, addr)
+	return true, nil
+}
+
+// startLogin sets the short lived login cookie for the configured mode
+func (h *Handler) startLogin(w http.ResponseWriter, r *http.Request, username string) error {
+	gen, err := h.generation(r.Context(), username)
 	if err != nil {
-		http.Error(w, "Cannot sign token", http.StatusInternalServerError)
-		return
+		return err
+	}
+	now := h.Clock.Now()
+	et := now.Add(TokenLifetime)
+	cookie := &http.Cookie{Path: "/", Expires: et, HttpOnly: true, SameSite: http.SameSiteLaxMode}
+
+	if h.Mode == ModeSession {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		if err := h.Sessions.Save(r.Context(), Session{ID: id, Username: username, Gen: gen, Expires: et}); err != nil {
+			return err
+		}
+		cookie.Name, cookie.Value = SessionCookie, id
+	} else {
+		//JWT token generation
+		signed, err := h.Tokens.Issue(username, gen, now, et)
+		if err != nil {
+			return err
+		}
+		cookie.Name, cookie.Value = JWTCookie, signed
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:    "jwt",
-		Value:   string(signed),
-		Expires: et,
-	})
-	w.Write([]byte("Login successful"))
+	http.SetCookie(w, cookie)
+	return nil
+}
 
-	w.WriteHeader(http.StatusOK)
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(SessionCookie); err == nil && h.Mode == ModeSession {
+		if err := h.Sessions.Delete(r.Context(), c.Value); err != nil {
+			h.logf(r, "logout: %v", err)
+		}
+	}
+	if t, ok := h.refreshToken(r); ok {
+		if err := h.Refresh.DeleteRefresh(r.Context(), t.ID); err != nil {
+			h.logf(r, "logout: %v", err)
+		}
+	}
+	clearCookies(w)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func Logout(w http.ResponseWriter, _ *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:    "jwt",
-		Expires: time.Now(),
-	})
-	w.WriteHeader(http.StatusOK)
+func clearCookies(w http.ResponseWriter) {
+	for _, name := range []string{JWTCookie, SessionCookie, RefreshCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:    name,
+			Path:    "/",
+			Expires: time.Unix(0, 0),
+			MaxAge:  -1,
+		})
+	}
 }
 
 // function for signin
-func SignIn(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SignIn(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.signIn)(w, r)
+}
+
+func (h *Handler) signIn(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
+		return eh.New(http.StatusMethodNotAllowed, eh.MethodNotAllowed, i18n.InvalidMethod)
 	}
 
 	// Read the request body
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCredentialsBody))
 	if err != nil {
-		http.Error(w, "Unable to read request body", http.StatusBadRequest)
-		return
+		return eh.New(http.StatusBadRequest, eh.InvalidBody, i18n.CannotReadBody)
 	}
 
 	var user dh.Credentials
 	// Unmarshal JSON into the User struct
-	err = json.Unmarshal(body, &user)
-	if err != nil {
-		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
-		return
+	if err := ch.Unmarshal(body, &user, ch.Of(r)); err != nil {
+		return eh.New(http.StatusBadRequest, eh.InvalidBody, i18n.InvalidJSON)
 	}
 
-	// Check if user already exists
-	if _, exists := dh.CredentialList[user.Username]; exists {
-		http.Error(w, "User already exists", http.StatusConflict)
-		return
+	if errs := user.Validate(); len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+	if err := h.checkPassword(r, "password", user.Username, user.Password); err != nil {
+		return err
 	}
 
-	// Add user to the map
-	dh.CredentialList[user.Username] = user.Password
+	hash, err := dh.HashPassword(user.Password)
+	if err != nil {
+		return eh.New(http.StatusInternalServerError, eh.Internal, i18n.InternalError).WithCause(fmt.Errorf("signup: %w", err))
+	}
+
+	// Add user to the store, failing if it already exists
+	if err := h.Users.CreateUser(r.Context(), user.Username, hash); err != nil {
+		return fmt.Errorf("signup: %w", err)
+	}
 	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintf(w, "User %s registered successfully", user.Username)
+	fmt.Fprint(w, i18n.T(r, i18n.UserRegistered, user.Username))
+	return nil
+}
+
+// ListUsers shows registered usernames, GET /admin/users
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.listUsers)(w, r)
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) error {
+	users, err := h.Users.ListUsers(r.Context())
+	if err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	ch.Encode(w, users, ch.Of(r))
+	return nil
 }
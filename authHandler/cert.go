@@ -0,0 +1,45 @@
+package authHandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+type CertUser struct { //maps a client certificate to a user, for service to service calls over mTLS
+	Subject string `json:"subject"` //distinguished name as Go prints it, e.g. "CN=billing,O=Acme"
+	User    string `json:"user"`
+	Admin   bool   `json:"admin"`
+}
+
+// LoadCertUsers reads a JSON array of {"subject", "user", "admin"}
+func LoadCertUsers(path string) ([]CertUser, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var users []CertUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, u := range users {
+		if len(u.Subject) == 0 || len(u.User) == 0 {
+			return nil, fmt.Errorf("%s: every entry needs a subject and a user", path)
+		}
+	}
+	return users, nil
+}
+
+// certUser is the user mapped to the verified client certificate of the connection, "" without one
+func (h *Handler) certUser(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject.String()
+	user, ok := h.CertUsers[subject]
+	if !ok {
+		h.logf(r, "client certificate %q is not mapped to a user", subject)
+	}
+	return user
+}
@@ -0,0 +1,87 @@
+package authHandler
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/secretHandler"
+)
+
+// EncryptedRefreshStore encrypts the token hash, device and IP of remember-me tokens before they reach the
+// wrapped store. Tokens read under an older key are written back under the primary one. It is for stores
+// that keep tokens outside the process, MemRefreshStore's never leave its memory.
+type EncryptedRefreshStore struct {
+	Store RefreshStore
+	Keys  *secretHandler.Keyring
+}
+
+func (s *EncryptedRefreshStore) seal(t RefreshToken) (RefreshToken, error) {
+	var err error
+	for _, f := range []*string{&t.Hash, &t.Device, &t.IP} {
+		if *f, err = s.Keys.Encrypt(*f, t.ID); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func (s *EncryptedRefreshStore) open(t RefreshToken) (RefreshToken, bool, error) {
+	stale := false
+	var err error
+	for _, f := range []*string{&t.Hash, &t.Device, &t.IP} {
+		stale = stale || s.Keys.Stale(*f)
+		if *f, err = s.Keys.Decrypt(*f, t.ID); err != nil {
+			return t, false, err
+		}
+	}
+	return t, stale, nil
+}
+
+func (s *EncryptedRefreshStore) SaveRefresh(ctx context.Context, t RefreshToken) error {
+	sealed, err := s.seal(t)
+	if err != nil {
+		return err
+	}
+	return s.Store.SaveRefresh(ctx, sealed)
+}
+
+func (s *EncryptedRefreshStore) GetRefresh(ctx context.Context, id string) (RefreshToken, error) {
+	t, err := s.Store.GetRefresh(ctx, id)
+	if err != nil {
+		return t, err
+	}
+	t, stale, err := s.open(t)
+	if err != nil {
+		return t, err
+	}
+	if stale {
+		s.SaveRefresh(ctx, t) //rotate, the read still succeeds if this fails
+	}
+	return t, nil
+}
+
+func (s *EncryptedRefreshStore) ListRefresh(ctx context.Context, username string) ([]RefreshToken, error) {
+	list, err := s.Store.ListRefresh(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i], _, err = s.open(list[i]); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func (s *EncryptedRefreshStore) DeleteRefresh(ctx context.Context, id string) error {
+	return s.Store.DeleteRefresh(ctx, id)
+}
+
+// Prune forwards to the wrapped store, expiry times aren't encrypted
+func (s *EncryptedRefreshStore) Prune(ctx context.Context, now time.Time) (int, error) {
+	p, ok := s.Store.(Pruner)
+	if !ok {
+		return 0, nil
+	}
+	return p.Prune(ctx, now)
+}
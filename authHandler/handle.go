@@ -0,0 +1,38 @@
+package authHandler
+
+import (
+	"errors"
+	"net/http"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// handle adapts fn to http.HandlerFunc: eh.Error and eh.ValidationError are answered as they say,
+// other errors as storeError maps them
+func (h *Handler) handle(fn eh.HandlerFunc) http.HandlerFunc {
+	return eh.Responder{Logf: h.logf, Other: h.storeError}.Handle(fn)
+}
+
+// storeError answers errors of the user, session and token stores
+func (h *Handler) storeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, dh.ErrUserNotFound):
+		eh.New(http.StatusNotFound, eh.UserNotFound, i18n.UserNotFound).Write(w, r)
+	case errors.Is(err, dh.ErrUserExists):
+		eh.New(http.StatusConflict, eh.UserExists, i18n.UserExists).Write(w, r)
+	default:
+		h.logf(r, "%v", err)
+		eh.New(http.StatusInternalServerError, eh.StorageError, i18n.StorageError).Write(w, r)
+	}
+}
+
+// readCredentials decodes a JSON body of at most maxCredentialsBody bytes into v, failing with 400
+func readCredentials(w http.ResponseWriter, r *http.Request, v any) error {
+	if err := ch.Decode(http.MaxBytesReader(w, r.Body, maxCredentialsBody), v, ch.Of(r)); err != nil {
+		return eh.New(http.StatusBadRequest, eh.InvalidBody, i18n.CannotDecode)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package authHandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/secretHandler"
+)
+
+const (
+	SignatureScheme = "HMAC-SHA256"    //Authorization: HMAC-SHA256 key=<id>, ts=<unix seconds>, nonce=<random>, signature=<hex>
+	MaxClockSkew    = 5 * time.Minute  //signed requests older or further in the future are refused
+	MaxSignedBody   = 16 << 20         //bodies of signed requests are buffered to check the signature
+	nonceTTL        = 2 * MaxClockSkew //a replayed nonce is refused by its timestamp after this
+)
+
+var (
+	ErrBadSignature = errors.New("bad request signature")
+	ErrReplayed     = errors.New("nonce already used")
+	ErrKeyNotFound  = errors.New("signing key not found")
+)
+
+type SigningKey struct { //shared secret of a machine client, requests signed with it act as User
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+	User   string `json:"user"`
+}
+
+type KeyStore interface {
+	SigningKey(ctx context.Context, id string) (SigningKey, error)
+}
+
+type MemKeyStore map[string]SigningKey //by ID
+
+func (s MemKeyStore) SigningKey(_ context.Context, id string) (SigningKey, error) {
+	k, ok := s[id]
+	if !ok {
+		return SigningKey{}, ErrKeyNotFound
+	}
+	return k, nil
+}
+
+// LoadKeys reads signing keys from a JSON array of {"id", "secret", "user"}.
+// Secrets may be stored encrypted (see `BookServer secret encrypt --aad <id>`), they are opened with ring.
+func LoadKeys(path string, ring *secretHandler.Keyring) (MemKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []SigningKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	store := make(MemKeyStore, len(keys))
+	for _, k := range keys {
+		if strings.HasPrefix(k.Secret, secretHandler.Prefix) {
+			if ring == nil {
+				return nil, fmt.Errorf("%s: key %q is encrypted but no encryption keys are configured", path, k.ID)
+			}
+			if k.Secret, err = ring.Decrypt(k.Secret, k.ID); err != nil {
+				return nil, fmt.Errorf("%s: key %q: %w", path, k.ID, err)
+			}
+		}
+		if len(k.ID) == 0 || len(k.Secret) < 32 || len(k.User) == 0 {
+			return nil, fmt.Errorf("%s: key %q needs an id, a user and a secret of 32+ bytes", path, k.ID)
+		}
+		store[k.ID] = k
+	}
+	return store, nil
+}
+
+// NonceStore remembers nonces of signed requests for ttl, Use reports false for one seen before
+type NonceStore interface {
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+type MemNonceStore struct { //nonces kept in process memory, enough for a single instance
+	Clock  dh.Clock //the one signed request timestamps are checked against
+	mu     sync.Mutex
+	nonces map[string]time.Time //nonce -> expiry
+	swept  time.Time
+}
+
+func NewMemNonceStore(clock dh.Clock) *MemNonceStore {
+	if clock == nil {
+		clock = dh.SystemClock{}
+	}
+	return &MemNonceStore{Clock: clock, nonces: make(map[string]time.Time)}
+}
+
+func (s *MemNonceStore) Use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	now := s.Clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.swept) > ttl {
+		for n, exp := range s.nonces {
+			if now.After(exp) {
+				delete(s.nonces, n)
+			}
+		}
+		s.swept = now
+	}
+	if exp, ok := s.nonces[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// StringToSign is what a signed request's signature covers
+func StringToSign(method, requestURI string, ts int64, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{method, requestURI, strconv.FormatInt(ts, 10), nonce, hex.EncodeToString(sum[:])}, "\n")
+}
+
+func sign(secret, s string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(s))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// SignRequest signs req for the server, for Go clients; nonce must be unique per request
+func SignRequest(req *http.Request, key SigningKey, now time.Time, nonce string) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	ts := now.Unix()
+	sig := sign(key.Secret, StringToSign(req.Method, req.URL.RequestURI(), ts, nonce, body))
+	req.Header.Set("Authorization", fmt.Sprintf("%s key=%s, ts=%d, nonce=%s, signature=%s", SignatureScheme, key.ID, ts, nonce, sig))
+	return nil
+}
+
+// signedUser checks an HMAC signed request and returns the user of its key.
+// The body is buffered and put back for the handler.
+func (h *Handler) signedUser(r *http.Request, params string) (string, error) {
+	if h.Keys == nil {
+		return "", ErrKeyNotFound
+	}
+	p := make(map[string]string)
+	for _, part := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		p[k] = v
+	}
+	ts, err := strconv.ParseInt(p["ts"], 10, 64)
+	if err != nil || len(p["key"]) == 0 || len(p["nonce"]) == 0 || len(p["signature"]) == 0 {
+		return "", ErrBadSignature
+	}
+	if skew := h.Clock.Now().Sub(time.Unix(ts, 0)); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return "", fmt.Errorf("%w: timestamp off by %s", ErrBadSignature, skew.Round(time.Second))
+	}
+	key, err := h.Keys.SigningKey(r.Context(), p["key"])
+	if err != nil {
+		return "", err
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, MaxSignedBody+1))
+		if err != nil {
+			return "", err
+		}
+		if len(body) > MaxSignedBody {
+			return "", fmt.Errorf("%w: body over %d bytes", ErrBadSignature, MaxSignedBody)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	uri := r.RequestURI //as the client sent it, r.URL loses the prefix of an API mounted with http.StripPrefix
+	if len(uri) == 0 {
+		uri = r.URL.RequestURI()
+	}
+	want := sign(key.Secret, StringToSign(r.Method, uri, ts, p["nonce"], body))
+	if !hmac.Equal([]byte(want), []byte(p["signature"])) {
+		return "", ErrBadSignature
+	}
+
+	//checked last, so forged requests can't burn a client's nonces
+	fresh, err := h.Nonces.Use(r.Context(), key.ID+":"+p["nonce"], nonceTTL)
+	if err != nil {
+		return "", err
+	}
+	if !fresh {
+		return "", ErrReplayed
+	}
+	return key.User, nil
+}
@@ -0,0 +1,124 @@
+package authHandler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+type ctxKey struct{}
+
+// UserFrom returns the authenticated username stored by Authenticate
+func UserFrom(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(ctxKey{}).(string)
+	return user, ok && len(user) != 0
+}
+
+func WithUser(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, username)
+}
+
+// user resolves the caller from an HMAC signature, a client certificate, the cookie of the configured mode
+// or Basic credentials when enabled, "" when not logged in
+func (h *Handler) user(r *http.Request) string {
+	if params, ok := strings.CutPrefix(r.Header.Get("Authorization"), SignatureScheme+" "); ok {
+		user, err := h.signedUser(r, params)
+		if err != nil {
+			h.logf(r, "signed request: %v", err)
+			return ""
+		}
+		return user
+	}
+	if user := h.certUser(r); len(user) != 0 {
+		return user
+	}
+	if user, gen := h.cookieUser(r); len(user) != 0 && h.active(r.Context(), user, gen) {
+		return user
+	}
+	if username, password, ok := r.BasicAuth(); ok && h.Basic {
+		valid, err := h.CheckCredentials(r.Context(), username, password)
+		var te *ThrottledError
+		if err != nil && !errors.As(err, &te) { //throttled logins are answered 401 like wrong ones
+			h.logf(r, "basic auth: %v", err)
+		}
+		if valid {
+			return username
+		}
+	}
+	return ""
+}
+
+// cookieUser returns the user and account generation of the login cookie
+func (h *Handler) cookieUser(r *http.Request) (string, string) {
+	switch h.Mode {
+	case ModeSession:
+		c, err := r.Cookie(SessionCookie)
+		if err != nil {
+			return "", ""
+		}
+		s, err := h.Sessions.Get(r.Context(), c.Value)
+		if err != nil {
+			return "", ""
+		}
+		if !h.Clock.Now().Before(s.Expires) {
+			h.Sessions.Delete(r.Context(), s.ID)
+			return "", ""
+		}
+		return s.Username, s.Gen
+	default:
+		c, err := r.Cookie(JWTCookie)
+		if err != nil {
+			return "", ""
+		}
+		user, gen, err := h.Tokens.Verify(c.Value, h.Clock.Now())
+		if err != nil {
+			return "", ""
+		}
+		return user, gen
+	}
+}
+
+// Authenticate rejects requests without a valid login with 401
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := h.user(r)
+		if len(user) == 0 {
+			if h.Basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="BookServer", charset="UTF-8"`)
+			}
+			eh.New(http.StatusUnauthorized, loginCode(r), i18n.Unauthorized).Write(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
+
+// RequireAdmin lets only configured admins through, use after Authenticate
+func (h *Handler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFrom(r.Context())
+		if !h.Admins[user] {
+			eh.New(http.StatusForbidden, eh.Forbidden, i18n.Forbidden).Write(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loginCode tells a client whose login cookie or signature was refused apart from one that sent none
+func loginCode(r *http.Request) eh.Code {
+	if _, err := r.Cookie(JWTCookie); err == nil {
+		return eh.InvalidToken
+	}
+	if _, err := r.Cookie(SessionCookie); err == nil {
+		return eh.InvalidToken
+	}
+	if strings.HasPrefix(r.Header.Get("Authorization"), SignatureScheme+" ") {
+		return eh.InvalidToken
+	}
+	return eh.Unauthenticated
+}
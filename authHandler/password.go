@@ -0,0 +1,256 @@
+package authHandler
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+// PasswordPolicy is checked whenever a password is set: on signup, change and admin reset
+type PasswordPolicy struct {
+	MinLength  int           //in characters
+	Classes    int           //how many of lower case, upper case, digits and symbols must appear
+	NoUsername bool          //refuse passwords containing the username, case insensitively
+	Breached   BreachChecker //refuse passwords known from data breaches, nil skips the check
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8, NoUsername: true}
+
+// BreachChecker reports whether a password appeared in known data breaches
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// classes counts the character classes in s: lower case, upper case, digits and anything else
+func classes(s string) int {
+	var lower, upper, digit, other int
+	for _, c := range s {
+		switch {
+		case unicode.IsLower(c):
+			lower = 1
+		case unicode.IsUpper(c):
+			upper = 1
+		case unicode.IsDigit(c):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+// Check returns the rules password breaks, reported against field. Only a failing breach
+// lookup is returned as an error, callers decide whether that blocks the change.
+func (p PasswordPolicy) Check(ctx context.Context, field, username, password string) ([]dh.FieldError, error) {
+	var errs []dh.FieldError
+	if utf8.RuneCountInString(password) < p.MinLength {
+		errs = append(errs, dh.FieldError{Field: field, Rule: dh.RulePasswordLength, Args: []any{p.MinLength}})
+	}
+	if n := classes(password); n < p.Classes {
+		errs = append(errs, dh.FieldError{Field: field, Rule: dh.RulePasswordClasses, Args: []any{p.Classes}})
+	}
+	if p.NoUsername && len(username) != 0 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		errs = append(errs, dh.FieldError{Field: field, Rule: dh.RulePasswordUsername})
+	}
+	if len(errs) != 0 || p.Breached == nil {
+		return errs, nil
+	}
+	breached, err := p.Breached.Breached(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+	if breached {
+		errs = append(errs, dh.FieldError{Field: field, Rule: dh.RulePasswordBreached})
+	}
+	return errs, nil
+}
+
+// PwnedPasswords checks the Have I Been Pwned range API with k-anonymity: only the first
+// five hex characters of the password's SHA-1 leave the server, the match is done locally
+type PwnedPasswords struct {
+	URL    string //range endpoint, the hash prefix is appended
+	Client *http.Client
+}
+
+func NewPwnedPasswords() *PwnedPasswords {
+	return &PwnedPasswords{URL: "https://api.pwnedpasswords.com/range/", Client: outboundHandler.Client(5 * time.Second)}
+}
+
+func (p *PwnedPasswords) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL+hash[:5], nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Add-Padding", "true") //hides the real number of matches from anyone watching response sizes
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("breached password check: %s", resp.Status)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		suffix, count, _ := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if suffix != hash[5:] {
+			continue
+		}
+		n, _ := strconv.Atoi(count)
+		return n > 0, nil //padding entries have a count of 0
+	}
+	return false, sc.Err()
+}
+
+// checkPassword fails with the policy violations when password can't be used.
+// An unreachable breach check is logged and let through, so an outage doesn't block signups.
+func (h *Handler) checkPassword(r *http.Request, field, username, password string) error {
+	policy := h.Policy
+	errs, err := policy.Check(r.Context(), field, username, password)
+	if err != nil {
+		h.logf(r, "password policy: %v", err)
+		policy.Breached = nil
+		errs, _ = policy.Check(r.Context(), field, username, password)
+	}
+	if len(errs) != 0 {
+		return eh.ValidationError(errs)
+	}
+	return nil
+}
+
+// setPassword hashes and stores a password that passed the policy
+func (h *Handler) setPassword(r *http.Request, username, password string) error {
+	hash, err := dh.HashPassword(password)
+	if err == nil {
+		err = h.Users.SetPasswordHash(r.Context(), username, hash)
+	}
+	if err != nil {
+		return fmt.Errorf("password: %w", err)
+	}
+	return nil
+}
+
+// endSessions deletes the user's server side sessions after their password changed. Their JWTs and
+// any session a store can't find are refused from now on as well, since they carry the account
+// generation of the old password.
+func (h *Handler) endSessions(r *http.Request, username string) {
+	us, ok := h.Sessions.(UserSessions)
+	if !ok {
+		return
+	}
+	if err := us.DeleteUserSessions(r.Context(), username); err != nil {
+		h.logf(r, "password: %v", err)
+	}
+}
+
+// revokeRefresh ends the user's remember-me logins except keep, after their password changed
+func (h *Handler) revokeRefresh(r *http.Request, username, keep string) {
+	tokens, err := h.Refresh.ListRefresh(r.Context(), username)
+	if err != nil {
+		h.logf(r, "password: %v", err)
+		return
+	}
+	for _, t := range tokens {
+		if t.ID == keep {
+			continue
+		}
+		if err := h.Refresh.DeleteRefresh(r.Context(), t.ID); err != nil {
+			h.logf(r, "password: %v", err)
+		}
+	}
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword lets a logged in user pick a new password, PUT /me/password.
+// Their other logins are revoked, the caller gets a fresh login cookie.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.changePassword)(w, r)
+}
+
+func (h *Handler) changePassword(w http.ResponseWriter, r *http.Request) error {
+	user, _ := UserFrom(r.Context())
+	var req ChangePasswordRequest
+	if err := readCredentials(w, r, &req); err != nil {
+		return err
+	}
+	ok, err := h.CheckCredentials(r.Context(), user, req.CurrentPassword)
+	if err != nil {
+		return Throttled(w, fmt.Errorf("password: %w", err))
+	}
+	if !ok {
+		return eh.New(http.StatusForbidden, eh.InvalidCredentials, i18n.InvalidCredentials)
+	}
+	if err := h.checkPassword(r, "new_password", user, req.NewPassword); err != nil {
+		return err
+	}
+	if err := h.setPassword(r, user, req.NewPassword); err != nil {
+		return err
+	}
+	current, _ := h.refreshToken(r) //read before the new password makes it stale
+	h.revokeRefresh(r, user, current.ID)
+	h.endSessions(r, user)
+	if err := h.startLogin(w, r, user); err != nil {
+		h.logf(r, "password: %v", err)
+	}
+	if len(current.ID) != 0 {
+		if current.Gen, err = h.generation(r.Context(), user); err == nil {
+			err = h.Refresh.SaveRefresh(r.Context(), current)
+		}
+		if err != nil {
+			h.logf(r, "password: %v", err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type ResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// ResetPassword sets another user's password, PUT /admin/users/{user}/password.
+// All of that user's logins are revoked.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.resetPassword)(w, r)
+}
+
+func (h *Handler) resetPassword(w http.ResponseWriter, r *http.Request) error {
+	user := chi.URLParam(r, "user")
+	var req ResetPasswordRequest
+	if err := readCredentials(w, r, &req); err != nil {
+		return err
+	}
+	if err := h.checkPassword(r, "password", user, req.Password); err != nil {
+		return err
+	}
+	if err := h.setPassword(r, user, req.Password); err != nil {
+		return err
+	}
+	h.revokeRefresh(r, user, "")
+	h.endSessions(r, user)
+	admin, _ := UserFrom(r.Context())
+	h.logf(r, "password of %q reset by %q", user, admin)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
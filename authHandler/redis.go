@@ -0,0 +1,247 @@
+package authHandler
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/redisHandler"
+	"github.com/Sabnaj-42/BookServer-API/secretHandler"
+)
+
+// RedisSessionStore keeps sessions in Redis as JSON with a TTL, so every replica sees the same sessions.
+type RedisSessionStore struct {
+	Client *redisHandler.Client
+	Prefix string
+	Keys   *secretHandler.Keyring //encrypts the stored JSON when set
+}
+
+func NewRedisSessionStore(addr string) *RedisSessionStore {
+	return &RedisSessionStore{Client: redisHandler.NewClient(addr), Prefix: "bookserver:session:"}
+}
+
+func (s *RedisSessionStore) Save(ctx context.Context, sess Session) error {
+	ttl := time.Until(sess.Expires).Milliseconds()
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	val := string(data)
+	if s.Keys != nil {
+		if val, err = s.Keys.Encrypt(val, sess.ID); err != nil {
+			return err
+		}
+	}
+	if _, err = s.Client.Do(ctx, "SET", s.Prefix+sess.ID, val, "PX", strconv.FormatInt(ttl, 10)); err != nil {
+		return err
+	}
+	//sessions all last TokenLifetime, so the newest one sets when the user's set of IDs can go
+	set := s.userKey(sess.Username)
+	if _, err = s.Client.Do(ctx, "SADD", set, sess.ID); err != nil {
+		return err
+	}
+	_, err = s.Client.Do(ctx, "PEXPIRE", set, strconv.FormatInt(ttl, 10))
+	return err
+}
+
+// userKey names the set of a user's session IDs, hashed so usernames don't appear in key names
+func (s *RedisSessionStore) userKey(username string) string {
+	return s.Prefix + "user:" + hashSecret(username)
+}
+
+func (s *RedisSessionStore) DeleteUserSessions(ctx context.Context, username string) error {
+	set := s.userKey(username)
+	reply, err := s.Client.Do(ctx, "SMEMBERS", set)
+	if err != nil {
+		return err
+	}
+	ids, _ := reply.([]any)
+	args := []string{"DEL", set}
+	for _, id := range ids {
+		if id, ok := id.(string); ok {
+			args = append(args, s.Prefix+id)
+		}
+	}
+	_, err = s.Client.Do(ctx, args...)
+	return err
+}
+
+func (s *RedisSessionStore) Get(ctx context.Context, id string) (Session, error) {
+	reply, err := s.Client.Do(ctx, "GET", s.Prefix+id)
+	if err != nil {
+		return Session{}, err
+	}
+	val, ok := reply.(string)
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	if s.Keys != nil {
+		if val, err = s.Keys.Decrypt(val, id); err != nil {
+			return Session{}, err
+		}
+	}
+	var sess Session
+	if err := json.Unmarshal([]byte(val), &sess); err != nil {
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+func (s *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.Client.Do(ctx, "DEL", s.Prefix+id)
+	return err
+}
+
+func (s *RedisSessionStore) Ping(ctx context.Context) error {
+	_, err := s.Client.Do(ctx, "PING")
+	return err
+}
+
+// RedisRefreshStore keeps remember-me tokens in Redis as JSON until they expire, so they outlive
+// restarts and work on every replica. Wrap it in an EncryptedRefreshStore to encrypt the secrets.
+type RedisRefreshStore struct {
+	Client *redisHandler.Client
+	Prefix string
+}
+
+func NewRedisRefreshStore(addr string) *RedisRefreshStore {
+	return &RedisRefreshStore{Client: redisHandler.NewClient(addr), Prefix: "bookserver:refresh:"}
+}
+
+type redisRefresh struct { //RefreshToken with the fields its JSON leaves out for clients
+	RefreshToken
+	Username string `json:"username"`
+	Gen      string `json:"gen"`
+	Hash     string `json:"hash"`
+}
+
+// userKey names the set of a user's token IDs, hashed so usernames don't appear in key names
+func (s *RedisRefreshStore) userKey(username string) string {
+	return s.Prefix + "user:" + hashSecret(username)
+}
+
+func (s *RedisRefreshStore) SaveRefresh(ctx context.Context, t RefreshToken) error {
+	ttl := time.Until(t.Expires).Milliseconds()
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(redisRefresh{RefreshToken: t, Username: t.Username, Gen: t.Gen, Hash: t.Hash})
+	if err != nil {
+		return err
+	}
+	if _, err = s.Client.Do(ctx, "SET", s.Prefix+t.ID, string(data), "PX", strconv.FormatInt(ttl, 10)); err != nil {
+		return err
+	}
+	//no token outlives RefreshLifetime from its last save, so the set can go then
+	set := s.userKey(t.Username)
+	if _, err = s.Client.Do(ctx, "SADD", set, t.ID); err != nil {
+		return err
+	}
+	_, err = s.Client.Do(ctx, "PEXPIRE", set, strconv.FormatInt(max(ttl, RefreshLifetime.Milliseconds()), 10))
+	return err
+}
+
+func (s *RedisRefreshStore) GetRefresh(ctx context.Context, id string) (RefreshToken, error) {
+	reply, err := s.Client.Do(ctx, "GET", s.Prefix+id)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	val, ok := reply.(string)
+	if !ok {
+		return RefreshToken{}, ErrRefreshNotFound
+	}
+	return decodeRefresh(val)
+}
+
+func decodeRefresh(val string) (RefreshToken, error) {
+	var rec redisRefresh
+	if err := json.Unmarshal([]byte(val), &rec); err != nil {
+		return RefreshToken{}, err
+	}
+	t := rec.RefreshToken
+	t.Username, t.Gen, t.Hash = rec.Username, rec.Gen, rec.Hash
+	return t, nil
+}
+
+// ListRefresh reads the tokens in the user's set, dropping the IDs of tokens that expired since
+func (s *RedisRefreshStore) ListRefresh(ctx context.Context, username string) ([]RefreshToken, error) {
+	set := s.userKey(username)
+	reply, err := s.Client.Do(ctx, "SMEMBERS", set)
+	if err != nil {
+		return nil, err
+	}
+	ids, _ := reply.([]any)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := []string{"MGET"}
+	for _, id := range ids {
+		if id, ok := id.(string); ok {
+			args = append(args, s.Prefix+id)
+		}
+	}
+	reply, err = s.Client.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	vals, _ := reply.([]any)
+	var list []RefreshToken
+	gone := []string{"SREM", set}
+	for i, val := range vals {
+		val, ok := val.(string)
+		if !ok {
+			gone = append(gone, strings.TrimPrefix(args[i+1], s.Prefix))
+			continue
+		}
+		t, err := decodeRefresh(val)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	if len(gone) > 2 {
+		s.Client.Do(ctx, gone...) //only tidies the set, the next list tries again
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+// DeleteRefresh drops the token, its ID leaves the user's set on the next ListRefresh
+func (s *RedisRefreshStore) DeleteRefresh(ctx context.Context, id string) error {
+	_, err := s.Client.Do(ctx, "DEL", s.Prefix+id)
+	return err
+}
+
+func (s *RedisRefreshStore) Ping(ctx context.Context) error {
+	_, err := s.Client.Do(ctx, "PING")
+	return err
+}
+
+// RedisNonceStore remembers nonces of signed requests in Redis, so a request replayed to another replica is refused too
+type RedisNonceStore struct {
+	Client *redisHandler.Client
+	Prefix string
+}
+
+func NewRedisNonceStore(addr string) *RedisNonceStore {
+	return &RedisNonceStore{Client: redisHandler.NewClient(addr), Prefix: "bookserver:nonce:"}
+}
+
+func (s *RedisNonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	reply, err := s.Client.Do(ctx, "SET", s.Prefix+nonce, "1", "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil //nil when the key already existed
+}
+
+func (s *RedisNonceStore) Ping(ctx context.Context) error {
+	_, err := s.Client.Do(ctx, "PING")
+	return err
+}
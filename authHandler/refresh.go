@@ -0,0 +1,241 @@
+package authHandler
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/Sabnaj-42/BookServer-API/ipHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	RefreshCookie   = "refresh"
+	RefreshLifetime = 30 * 24 * time.Hour //remember-me logins
+)
+
+var ErrRefreshNotFound = errors.New("refresh token not found")
+
+type RefreshToken struct { //long lived login from one device
+	ID        string    `json:"id"`
+	Username  string    `json:"-"`
+	Gen       string    `json:"-"` //account generation at login
+	Hash      string    `json:"-"` //sha256 of the secret half of the cookie
+	Device    string    `json:"device"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+	Expires   time.Time `json:"expires"`
+}
+
+// RefreshStore persists remember-me tokens so they can be listed and revoked
+type RefreshStore interface {
+	SaveRefresh(ctx context.Context, t RefreshToken) error
+	GetRefresh(ctx context.Context, id string) (RefreshToken, error)
+	ListRefresh(ctx context.Context, username string) ([]RefreshToken, error)
+	DeleteRefresh(ctx context.Context, id string) error
+}
+
+type MemRefreshStore struct {
+	mu     sync.RWMutex
+	tokens map[string]RefreshToken
+}
+
+func NewMemRefreshStore() *MemRefreshStore {
+	return &MemRefreshStore{tokens: make(map[string]RefreshToken)}
+}
+
+func (m *MemRefreshStore) SaveRefresh(_ context.Context, t RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[t.ID] = t
+	return nil
+}
+
+func (m *MemRefreshStore) GetRefresh(_ context.Context, id string) (RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.tokens[id]
+	if !ok {
+		return RefreshToken{}, ErrRefreshNotFound
+	}
+	return t, nil
+}
+
+func (m *MemRefreshStore) ListRefresh(_ context.Context, username string) ([]RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []RefreshToken
+	for _, t := range m.tokens {
+		if t.Username == username {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (m *MemRefreshStore) DeleteRefresh(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, id)
+	return nil
+}
+
+func (m *MemRefreshStore) Prune(_ context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, t := range m.tokens {
+		if now.After(t.Expires) {
+			delete(m.tokens, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueRefresh persists a remember-me token for username and sets its cookie as id.secret
+func (h *Handler) issueRefresh(w http.ResponseWriter, r *http.Request, username string) error {
+	gen, err := h.generation(r.Context(), username)
+	if err != nil {
+		return err
+	}
+	id, err := newSessionID()
+	if err != nil {
+		return err
+	}
+	secret, err := newSessionID()
+	if err != nil {
+		return err
+	}
+	now := h.Clock.Now()
+	t := RefreshToken{
+		ID:        id[:16],
+		Username:  username,
+		Gen:       gen,
+		Hash:      hashSecret(secret),
+		Device:    r.UserAgent(),
+		IP:        ipHandler.IP(r, nil).String(),
+		CreatedAt: now,
+		LastUsed:  now,
+		Expires:   now.Add(RefreshLifetime),
+	}
+	if err := h.Refresh.SaveRefresh(r.Context(), t); err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshCookie,
+		Value:    t.ID + "." + secret,
+		Path:     "/",
+		Expires:  t.Expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// refreshToken looks up and checks the remember-me cookie of r
+func (h *Handler) refreshToken(r *http.Request) (RefreshToken, bool) {
+	c, err := r.Cookie(RefreshCookie)
+	if err != nil {
+		return RefreshToken{}, false
+	}
+	id, secret, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return RefreshToken{}, false
+	}
+	t, err := h.Refresh.GetRefresh(r.Context(), id)
+	if err != nil {
+		return RefreshToken{}, false
+	}
+	if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hashSecret(secret))) != 1 {
+		return RefreshToken{}, false
+	}
+	if !h.Clock.Now().Before(t.Expires) || !h.active(r.Context(), t.Username, t.Gen) {
+		h.Refresh.DeleteRefresh(r.Context(), t.ID)
+		return RefreshToken{}, false
+	}
+	return t, true
+}
+
+// RefreshLogin trades a valid remember-me cookie for a fresh login cookie
+func (h *Handler) RefreshLogin(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.refreshLogin)(w, r)
+}
+
+func (h *Handler) refreshLogin(w http.ResponseWriter, r *http.Request) error {
+	t, ok := h.refreshToken(r)
+	if !ok {
+		return eh.New(http.StatusUnauthorized, eh.InvalidToken, i18n.Unauthorized)
+	}
+	t.LastUsed = h.Clock.Now()
+	if err := h.Refresh.SaveRefresh(r.Context(), t); err != nil {
+		h.logf(r, "refresh: %v", err)
+	}
+	if err := h.startLogin(w, r, t.Username); err != nil {
+		return eh.New(http.StatusInternalServerError, eh.Internal, i18n.InternalError).WithCause(fmt.Errorf("refresh: %w", err))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type sessionInfo struct {
+	RefreshToken
+	Current bool `json:"current"`
+}
+
+// ListSessions shows the caller's remember-me logins, GET /sessions
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.listSessions)(w, r)
+}
+
+func (h *Handler) listSessions(w http.ResponseWriter, r *http.Request) error {
+	user, _ := UserFrom(r.Context())
+	tokens, err := h.Refresh.ListRefresh(r.Context(), user)
+	if err != nil {
+		return fmt.Errorf("sessions: %w", err)
+	}
+	current, _ := h.refreshToken(r)
+	list := make([]sessionInfo, 0, len(tokens))
+	for _, t := range tokens {
+		list = append(list, sessionInfo{RefreshToken: t, Current: t.ID == current.ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	ch.Encode(w, list, ch.Of(r))
+	return nil
+}
+
+// RevokeSession deletes one of the caller's remember-me logins, DELETE /sessions/{id}
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	h.handle(h.revokeSession)(w, r)
+}
+
+func (h *Handler) revokeSession(w http.ResponseWriter, r *http.Request) error {
+	user, _ := UserFrom(r.Context())
+	t, err := h.Refresh.GetRefresh(r.Context(), chi.URLParam(r, "id"))
+	if err != nil || t.Username != user { //other users' sessions look the same as missing ones
+		return eh.New(http.StatusNotFound, eh.SessionNotFound, i18n.SessionNotFound)
+	}
+	if err := h.Refresh.DeleteRefresh(r.Context(), t.ID); err != nil {
+		return fmt.Errorf("sessions: %w", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
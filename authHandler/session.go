@@ -0,0 +1,121 @@
+package authHandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type Session struct { //server side login state, referenced by an opaque cookie
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	Gen      string    `json:"gen"` //account generation at login, see Handler.generation
+	Expires  time.Time `json:"expires"`
+}
+
+// SessionStore keeps sessions for the session auth mode, deleting one revokes it at once
+type SessionStore interface {
+	Save(ctx context.Context, s Session) error
+	Get(ctx context.Context, id string) (Session, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// UserSessions is implemented by session stores that can end all of a user's sessions at once.
+// Sessions of an older account generation are refused anyway, this only frees them early.
+type UserSessions interface {
+	DeleteUserSessions(ctx context.Context, username string) error
+}
+
+// Pruner is implemented by stores that keep expired sessions or tokens until told to drop them;
+// stores that expire entries themselves, such as Redis, don't need it
+type Pruner interface {
+	// Prune deletes what expired before now and reports how many it deleted
+	Prune(ctx context.Context, now time.Time) (int, error)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type MemSessionStore struct { //sessions kept in process memory
+	mu       sync.RWMutex
+	sessions map[string]Session
+}
+
+func NewMemSessionStore() *MemSessionStore {
+	return &MemSessionStore{sessions: make(map[string]Session)}
+}
+
+func (m *MemSessionStore) Save(_ context.Context, s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = s
+	return nil
+}
+
+func (m *MemSessionStore) Get(_ context.Context, id string) (Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+func (m *MemSessionStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemSessionStore) DeleteUserSessions(_ context.Context, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, s := range m.sessions {
+		if s.Username == username {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemSessionStore) Prune(_ context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, s := range m.sessions {
+		if now.After(s.Expires) {
+			delete(m.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PruneExpired drops expired sessions and remember-me tokens from the stores that keep them
+func (h *Handler) PruneExpired(ctx context.Context) (int, error) {
+	total := 0
+	for _, s := range []any{h.Sessions, h.Refresh} {
+		p, ok := s.(Pruner)
+		if !ok {
+			continue
+		}
+		n, err := p.Prune(ctx, h.Clock.Now())
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,121 @@
+package authHandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// wrong passwords an account may get in a row from one address before its logins from there are
+// slowed down, and the wait after the next one, doubling with every further failure up to maxLoginBackoff
+const (
+	freeLoginFailures = 5
+	minLoginBackoff   = time.Second
+	maxLoginBackoff   = 15 * time.Minute
+	maxThrottled      = 100000 //account and address pairs counted at most, the oldest give way
+)
+
+// ThrottledError is returned by CheckCredentials for an account that has to wait before its next
+// password is checked
+type ThrottledError struct {
+	Wait time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry in %s", e.Wait)
+}
+
+// loginThrottle counts failed logins per account name and client address, unknown names included so
+// the answers don't tell them apart. Counting by address too keeps others from locking an account out.
+type loginThrottle struct {
+	mu       sync.Mutex
+	accounts map[throttleKey]*loginFailures
+}
+
+type throttleKey struct {
+	username string
+	addr     netip.Addr //invalid when the address is unknown
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time //latest failure
+	until time.Time //no password is checked before then
+}
+
+// wait is how long username has to wait at addr before its next password is checked
+func (t *loginThrottle) wait(username string, addr netip.Addr, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if f, ok := t.accounts[throttleKey{username, addr}]; ok && now.Before(f.until) {
+		return f.until.Sub(now)
+	}
+	return 0
+}
+
+// failed counts a wrong password for username from addr
+func (t *loginThrottle) failed(username string, addr netip.Addr, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.accounts == nil {
+		t.accounts = make(map[throttleKey]*loginFailures)
+	}
+	key := throttleKey{username, addr}
+	f, ok := t.accounts[key]
+	if !ok || now.Sub(f.last) >= maxLoginBackoff && !now.Before(f.until) { //quiet for long enough to start over
+		if !ok && len(t.accounts) >= maxThrottled {
+			t.evict(now)
+		}
+		f = &loginFailures{}
+		t.accounts[key] = f
+	}
+	f.count++
+	f.last = now
+	if over := f.count - freeLoginFailures; over > 0 {
+		backoff := maxLoginBackoff
+		if over < 20 {
+			backoff = min(minLoginBackoff<<(over-1), maxLoginBackoff)
+		}
+		f.until = now.Add(backoff)
+	}
+}
+
+// evict drops the stale counts, and the one failed longest ago when none are stale, to stay under maxThrottled
+func (t *loginThrottle) evict(now time.Time) {
+	var oldest throttleKey
+	var oldestAt time.Time
+	for key, f := range t.accounts {
+		if now.Sub(f.last) >= maxLoginBackoff && !now.Before(f.until) {
+			delete(t.accounts, key)
+		} else if oldestAt.IsZero() || f.last.Before(oldestAt) {
+			oldest, oldestAt = key, f.last
+		}
+	}
+	if len(t.accounts) >= maxThrottled {
+		delete(t.accounts, oldest)
+	}
+}
+
+// succeeded forgets username's failures from addr
+func (t *loginThrottle) succeeded(username string, addr netip.Addr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.accounts, throttleKey{username, addr})
+}
+
+// Throttled turns a ThrottledError into a 429, setting Retry-After, other errors are returned as they are
+func Throttled(w http.ResponseWriter, err error) error {
+	var te *ThrottledError
+	if !errors.As(err, &te) {
+		return err
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(te.Wait.Seconds())+1))
+	return eh.New(http.StatusTooManyRequests, eh.RateLimited, i18n.TooManyRequests)
+}
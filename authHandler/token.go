@@ -0,0 +1,50 @@
+package authHandler
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// TokenIssuer creates signed tokens for logged in users
+type TokenIssuer interface {
+	Issue(username, gen string, issued, expiry time.Time) (string, error)
+	Verify(token string, now time.Time) (username, gen string, err error) //the user and account generation the token was issued to
+}
+
+const genClaim = "gen"
+
+type JWTIssuer struct { //HS256 signed JWTs
+	Secret   []byte
+	Audience []string
+}
+
+func (j JWTIssuer) Issue(username, gen string, issued, expiry time.Time) (string, error) {
+	token, err := jwt.NewBuilder().Subject(username).Claim(genClaim, gen).Audience(j.Audience).IssuedAt(issued).Expiration(expiry).Build()
+	if err != nil {
+		return "", err
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.HS256, j.Secret))
+	if err != nil {
+		return "", err
+	}
+	return string(signed), nil
+}
+
+func (j JWTIssuer) Verify(token string, now time.Time) (string, string, error) {
+	opts := []jwt.ParseOption{
+		jwt.WithKey(jwa.HS256, j.Secret),
+		jwt.WithValidate(true),
+		jwt.WithClock(jwt.ClockFunc(func() time.Time { return now })),
+	}
+	for _, aud := range j.Audience {
+		opts = append(opts, jwt.WithAudience(aud))
+	}
+	parsed, err := jwt.Parse([]byte(token), opts...)
+	if err != nil {
+		return "", "", err
+	}
+	gen, _ := parsed.PrivateClaims()[genClaim].(string)
+	return parsed.Subject(), gen, nil
+}
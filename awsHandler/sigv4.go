@@ -0,0 +1,134 @@
+package awsHandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	timeLayout      = "20060102T150405Z"
+)
+
+// Signer signs requests to AWS compatible services with Signature Version 4
+type Signer struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+	Service   string //e.g. "s3" or "kms"
+}
+
+// uriEncode escapes like SigV4 expects: everything but unreserved characters, optionally keeping "/"
+func uriEncode(s string, keepSlash bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			sb.WriteByte(b)
+		case b == '/' && keepSlash:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// SHA256Hex is the payload hash of a signed body
+func SHA256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s Signer) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+// signature signs a canonical request made at t
+func (s Signer) signature(t time.Time, canonical string) string {
+	toSign := "AWS4-HMAC-SHA256\n" + t.Format(timeLayout) + "\n" + s.scope(t) + "\n" + SHA256Hex([]byte(canonical))
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// Sign adds X-Amz-Date and the Authorization header to req made at t, payloadHash is
+// SHA256Hex of the body or UnsignedPayload where the service allows it
+func (s Signer) Sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(timeLayout))
+
+	names := []string{"host"}
+	for k := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") || lk == "content-type" || lk == "range" {
+			names = append(names, lk)
+		}
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, n := range names {
+		v := req.Host
+		if len(v) == 0 {
+			v = req.URL.Host
+		}
+		if n != "host" {
+			v = strings.TrimSpace(req.Header.Get(n))
+		}
+		headers.WriteString(n + ":" + v + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{req.Method, uriEncode(req.URL.Path, true), canonicalQuery(req.URL.Query()), headers.String(), signed, payloadHash}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKey, s.scope(t), signed, s.signature(t, canonical)))
+}
+
+// Presign returns u with a query signature valid for ttl from t, q holds extra parameters to sign
+func (s Signer) Presign(method string, u *url.URL, q url.Values, ttl time.Duration, t time.Time) string {
+	t = t.UTC()
+	if q == nil {
+		q = url.Values{}
+	}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(t))
+	q.Set("X-Amz-Date", t.Format(timeLayout))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := strings.Join([]string{method, uriEncode(u.Path, true), canonicalQuery(q), "host:" + u.Host + "\n", "host", UnsignedPayload}, "\n")
+	signed := *u
+	signed.RawQuery = canonicalQuery(q) + "&X-Amz-Signature=" + s.signature(t, canonical)
+	return signed.String()
+}
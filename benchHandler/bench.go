@@ -0,0 +1,302 @@
+package benchHandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// operations a workload is made of
+const (
+	OpList   = "list"   //GET /api/v1/books
+	OpGet    = "get"    //GET /api/v1/books/{id}
+	OpCreate = "create" //POST /api/v1/books
+	OpUpdate = "update" //PUT /api/v1/books/{id}, of a book the worker created
+	OpDelete = "delete" //DELETE /api/v1/books/{id}, of a book the worker created
+	OpLogin  = "login"  //POST /login
+)
+
+// Mix weighs reads, writes and logins against each other, e.g. 80/15/5
+type Mix struct {
+	Reads, Writes, Logins int
+}
+
+// ParseMix reads "reads,writes,logins" weights such as "80,15,5"
+func ParseMix(s string) (Mix, error) {
+	var m Mix
+	if _, err := fmt.Sscanf(strings.ReplaceAll(s, " ", ""), "%d,%d,%d", &m.Reads, &m.Writes, &m.Logins); err != nil {
+		return m, fmt.Errorf("mix %q: want reads,writes,logins", s)
+	}
+	if m.Reads < 0 || m.Writes < 0 || m.Logins < 0 || m.Reads+m.Writes+m.Logins == 0 {
+		return m, fmt.Errorf("mix %q: weights must be positive", s)
+	}
+	return m, nil
+}
+
+type Options struct {
+	Target      string //base URL of the server, e.g. http://127.0.0.1:8080
+	Concurrency int    //workers, each with its own login
+	Duration    time.Duration
+	Username    string
+	Password    string
+	Mix         Mix
+	Client      *http.Client //nil for a client with a 10s timeout
+}
+
+// OpStats holds the outcome of one kind of operation
+type OpStats struct {
+	Count    int
+	Errors   int         //transport errors and responses of 400 or more
+	Statuses map[int]int //0 counts transport errors
+	Latency  []time.Duration
+}
+
+// Percentile returns the latency p (0 to 100) of all calls fell under
+func (s *OpStats) Percentile(p float64) time.Duration {
+	if len(s.Latency) == 0 {
+		return 0
+	}
+	i := int(float64(len(s.Latency)-1) * p / 100)
+	return s.Latency[i]
+}
+
+type Report struct {
+	Elapsed time.Duration
+	Ops     map[string]*OpStats
+}
+
+// Names lists the operations that ran, in a stable order
+func (r *Report) Names() []string {
+	var names []string
+	for _, op := range []string{OpList, OpGet, OpCreate, OpUpdate, OpDelete, OpLogin} {
+		if _, ok := r.Ops[op]; ok {
+			names = append(names, op)
+		}
+	}
+	return names
+}
+
+type sample struct {
+	op      string
+	status  int
+	latency time.Duration
+}
+
+// Run drives the server at opts.Target with opts.Concurrency workers until opts.Duration passed or ctx ends.
+// Every worker logs in first, which fails the run when the credentials are refused; books the workers
+// create are deleted again at the end.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Concurrency < 1 {
+		return nil, errors.New("concurrency must be at least 1")
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	opts.Target = strings.TrimSuffix(opts.Target, "/")
+
+	workers := make([]*worker, opts.Concurrency)
+	for i := range workers {
+		w, err := newWorker(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		workers[i] = w
+	}
+	ids, err := workers[0].listIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(chan sample, 1024)
+	report := &Report{Ops: make(map[string]*OpStats)}
+	collected := make(chan struct{})
+	go func() {
+		for s := range samples {
+			st := report.Ops[s.op]
+			if st == nil {
+				st = &OpStats{Statuses: make(map[int]int)}
+				report.Ops[s.op] = st
+			}
+			st.Count++
+			st.Statuses[s.status]++
+			if s.status == 0 || s.status >= 400 {
+				st.Errors++
+			}
+			st.Latency = append(st.Latency, s.latency)
+		}
+		close(collected)
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(runCtx, ids, samples)
+		}()
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	close(samples)
+	<-collected
+
+	for _, w := range workers {
+		w.cleanup(ctx)
+	}
+	for _, st := range report.Ops {
+		sort.Slice(st.Latency, func(i, j int) bool { return st.Latency[i] < st.Latency[j] })
+	}
+	return report, nil
+}
+
+type worker struct {
+	opts    Options
+	client  *http.Client
+	created []string //IDs of books this worker created and didn't delete yet
+	rnd     *rand.Rand
+}
+
+func newWorker(ctx context.Context, opts Options) (*worker, error) {
+	jar, _ := cookiejar.New(nil)
+	client := *opts.Client
+	client.Jar = jar
+	w := &worker{opts: opts, client: &client, rnd: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+	status, err := w.login(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	if status != http.StatusOK && status != http.StatusNoContent {
+		return nil, fmt.Errorf("login: %d %s", status, http.StatusText(status))
+	}
+	return w, nil
+}
+
+// do sends a request and drains the answer, status is 0 on transport errors
+func (w *worker) do(ctx context.Context, method, path string, body any) (int, []byte, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, w.opts.Target+path, rd)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, b, err
+}
+
+func (w *worker) login(ctx context.Context) (int, error) {
+	status, _, err := w.do(ctx, http.MethodPost, "/login", map[string]string{"username": w.opts.Username, "password": w.opts.Password})
+	return status, err
+}
+
+func (w *worker) listIDs(ctx context.Context) ([]string, error) {
+	status, body, err := w.do(ctx, http.MethodGet, "/api/v1/books", nil)
+	if err != nil {
+		return nil, fmt.Errorf("list books: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("list books: %d %s", status, http.StatusText(status))
+	}
+	var books []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &books); err != nil {
+		return nil, fmt.Errorf("list books: %w", err)
+	}
+	ids := make([]string, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	return ids, nil
+}
+
+func (w *worker) book(n int) map[string]any {
+	return map[string]any{
+		"name":    fmt.Sprintf("Bench book %d", n),
+		"authors": []map[string]string{{"name": "Bench Author"}},
+		"genre":   "Benchmark",
+		"pub":     "BookServer bench",
+		"format":  "paperback",
+	}
+}
+
+func (w *worker) run(ctx context.Context, ids []string, samples chan<- sample) {
+	m := w.opts.Mix
+	for ctx.Err() == nil {
+		op, path, method, body := OpLogin, "/login", http.MethodPost, any(nil)
+		switch n := w.rnd.IntN(m.Reads + m.Writes + m.Logins); {
+		case n < m.Reads:
+			if len(ids) == 0 || w.rnd.IntN(2) == 0 {
+				op, method, path = OpList, http.MethodGet, "/api/v1/books"
+			} else {
+				op, method, path = OpGet, http.MethodGet, "/api/v1/books/"+url.PathEscape(ids[w.rnd.IntN(len(ids))])
+			}
+		case n < m.Reads+m.Writes:
+			switch {
+			case len(w.created) == 0:
+				op, method, path, body = OpCreate, http.MethodPost, "/api/v1/books", w.book(w.rnd.Int())
+			case w.rnd.IntN(2) == 0:
+				op, method, body = OpUpdate, http.MethodPut, w.book(w.rnd.Int())
+				path = "/api/v1/books/" + url.PathEscape(w.created[w.rnd.IntN(len(w.created))])
+			default:
+				op, method = OpDelete, http.MethodDelete
+				path = "/api/v1/books/" + url.PathEscape(w.created[len(w.created)-1])
+			}
+		default:
+			body = map[string]string{"username": w.opts.Username, "password": w.opts.Password}
+		}
+
+		start := time.Now()
+		status, resp, err := w.do(ctx, method, path, body)
+		if err != nil && ctx.Err() != nil {
+			return //cut off by the end of the run, not an error of the server
+		}
+		samples <- sample{op: op, status: status, latency: time.Since(start)}
+		switch {
+		case op == OpCreate && status == http.StatusCreated:
+			var b struct {
+				ID string `json:"id"`
+			}
+			if json.Unmarshal(resp, &b) == nil {
+				w.created = append(w.created, b.ID)
+			}
+		case op == OpDelete && (status == http.StatusNoContent || status == http.StatusNotFound):
+			w.created = w.created[:len(w.created)-1]
+		}
+	}
+}
+
+// cleanup deletes the books the worker left behind
+func (w *worker) cleanup(ctx context.Context) {
+	for _, id := range w.created {
+		w.do(ctx, http.MethodDelete, "/api/v1/books/"+url.PathEscape(id), nil)
+	}
+	w.created = nil
+}
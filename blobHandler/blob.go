@@ -0,0 +1,105 @@
+package blobHandler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+type Info struct { //what is known about a stored blob
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	SHA256      string    `json:"sha256,omitempty"` //hex, set by PutDeduped
+}
+
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// Store keeps binary files such as covers and e-books by key
+type Store interface {
+	Put(ctx context.Context, key, contentType string, r io.Reader) (Info, error)
+	Get(ctx context.Context, key string) (ReadSeekCloser, Info, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Lister is implemented by stores that can enumerate their blobs, e.g. to find the ones no book uses
+type Lister interface {
+	// List calls fn with every blob whose key starts with prefix, in no particular order, and stops
+	// at the first error fn returns. fn may delete the blob it is given.
+	List(ctx context.Context, prefix string, fn func(Info) error) error
+}
+
+type memBlob struct {
+	info Info
+	data []byte
+}
+
+// MemStore keeps blobs in memory, for a single instance and for trying things out
+type MemStore struct {
+	mu    sync.RWMutex
+	blobs map[string]memBlob
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{blobs: make(map[string]memBlob)}
+}
+
+func (s *MemStore) Put(_ context.Context, key, contentType string, r io.Reader) (Info, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Key: key, ContentType: contentType, Size: int64(len(data)), Modified: time.Now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = memBlob{info: info, data: data}
+	return info, nil
+}
+
+type nopCloser struct{ *bytes.Reader }
+
+func (nopCloser) Close() error { return nil }
+
+func (s *MemStore) Get(_ context.Context, key string) (ReadSeekCloser, Info, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, Info{}, ErrNotFound
+	}
+	return nopCloser{bytes.NewReader(b.data)}, b.info, nil
+}
+
+func (s *MemStore) List(_ context.Context, prefix string, fn func(Info) error) error {
+	s.mu.RLock()
+	var infos []Info
+	for key, b := range s.blobs {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, b.info)
+		}
+	}
+	s.mu.RUnlock()
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
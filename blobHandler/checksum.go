@@ -0,0 +1,96 @@
+package blobHandler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+)
+
+var ErrChecksum = errors.New("blob checksum mismatch")
+
+// PutDeduped stores r under prefix + its hex SHA-256, identical content uploaded twice is stored once
+func PutDeduped(ctx context.Context, s Store, prefix, contentType string, r io.Reader) (Info, error) {
+	body, ok := r.(io.ReadSeeker) //the key is only known once everything is read, so it is read twice
+	if !ok {
+		tmp, err := os.CreateTemp("", "bookserver-blob-*")
+		if err != nil {
+			return Info{}, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, r); err != nil {
+			return Info{}, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		body = tmp
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return Info{}, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	key := prefix + sum
+
+	if f, info, err := s.Get(ctx, key); err == nil {
+		f.Close()
+		info.SHA256 = sum
+		return info, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return Info{}, err
+	}
+
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+	info, err := s.Put(ctx, key, contentType, body)
+	info.SHA256 = sum
+	return info, err
+}
+
+// Verifier checks a blob against its SHA-256 while it is read from the start.
+// The read that would complete a corrupt blob fails with ErrChecksum, so the client gets a short body instead of bad data.
+type Verifier struct {
+	ReadSeekCloser
+	Size int64
+	Sum  string //hex
+
+	h        hash.Hash
+	off      int64
+	tracking bool //everything up to off went through h
+	Mismatch bool
+}
+
+func NewVerifier(f ReadSeekCloser, size int64, sum string) *Verifier {
+	return &Verifier{ReadSeekCloser: f, Size: size, Sum: sum, h: sha256.New(), tracking: true}
+}
+
+func (v *Verifier) Read(p []byte) (int, error) {
+	n, err := v.ReadSeekCloser.Read(p)
+	if v.tracking {
+		v.h.Write(p[:n])
+	}
+	v.off += int64(n)
+	if v.tracking && v.off == v.Size && hex.EncodeToString(v.h.Sum(nil)) != v.Sum {
+		v.Mismatch = true
+		return 0, ErrChecksum
+	}
+	return n, err
+}
+
+// Seek stops verifying unless it goes back to the start, ranges in the middle can't be checked
+func (v *Verifier) Seek(offset int64, whence int) (int64, error) {
+	pos, err := v.ReadSeekCloser.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	v.off, v.tracking = pos, pos == 0
+	v.h.Reset()
+	return pos, nil
+}
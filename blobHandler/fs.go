@@ -0,0 +1,156 @@
+package blobHandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FSStore keeps blobs as files under Dir, each with a .json sidecar holding its Info
+type FSStore struct {
+	Dir string
+}
+
+func NewFSStore(dir string) (*FSStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FSStore{Dir: dir}, nil
+}
+
+// path maps a key to a file under Dir, keys can't climb out of it
+func (s *FSStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "\\") {
+		return "", errors.New("blob: bad key " + key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *FSStore) Put(_ context.Context, key, contentType string, r io.Reader) (Info, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return Info{}, err
+	}
+	//write to a temp file and rename, so readers never see half a blob
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(tmp.Name())
+	size, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return Info{}, err
+	}
+	st, err := os.Stat(p)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Key: key, ContentType: contentType, Size: size, Modified: st.ModTime()}
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return Info{}, err
+	}
+	return info, os.WriteFile(p+".json", meta, 0o640)
+}
+
+func (s *FSStore) Get(_ context.Context, key string) (ReadSeekCloser, Info, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	var info Info
+	meta, err := os.ReadFile(p + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	if err := json.Unmarshal(meta, &info); err != nil {
+		return nil, Info{}, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return f, info, nil
+}
+
+func (s *FSStore) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{p, p + ".json"} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// List walks the directory of prefix, reading the sidecars. A sidecar of key is key.json and names
+// key, which tells it apart from a blob whose own key ends in .json.
+func (s *FSStore) List(ctx context.Context, prefix string, fn func(Info) error) error {
+	root := s.Dir
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir, err := s.path(prefix[:i])
+		if err != nil {
+			return err
+		}
+		root = dir
+	}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".json") || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		meta, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) { //deleted meanwhile
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		var info Info
+		if json.Unmarshal(meta, &info) != nil || info.Key != filepath.ToSlash(strings.TrimSuffix(rel, ".json")) {
+			return nil
+		}
+		if !strings.HasPrefix(info.Key, prefix) {
+			return nil
+		}
+		return fn(info)
+	})
+	if errors.Is(err, fs.ErrNotExist) { //nothing stored under prefix yet
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,283 @@
+package blobHandler
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/awsHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+)
+
+// Presigner is implemented by stores clients can download from directly
+type Presigner interface {
+	PresignGet(key, filename string, ttl time.Duration) (string, error)
+}
+
+// S3Store keeps blobs in an S3 compatible bucket (AWS, MinIO, ...), requests are signed with AWS Signature V4
+type S3Store struct {
+	Endpoint  string //e.g. https://s3.eu-west-1.amazonaws.com or http://127.0.0.1:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool //bucket in the path instead of the host name, MinIO needs it
+	Client    *http.Client
+	Now       func() time.Time
+}
+
+func NewS3Store(endpoint, region, bucket, accessKey, secretKey string) *S3Store {
+	return &S3Store{Endpoint: strings.TrimRight(endpoint, "/"), Region: region, Bucket: bucket, AccessKey: accessKey, SecretKey: secretKey, PathStyle: true, Client: outboundHandler.Client(0), Now: time.Now}
+}
+
+// objectURL is the URL of key, without query
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.PathStyle {
+		u.Path = strings.TrimSuffix("/"+s.Bucket+"/"+key, "/")
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u, nil
+}
+
+func (s *S3Store) signer() awsHandler.Signer {
+	return awsHandler.Signer{AccessKey: s.AccessKey, SecretKey: s.SecretKey, Region: s.Region, Service: "s3"}
+}
+
+// sign adds the Authorization header to req, the payload is left unsigned
+func (s *S3Store) sign(req *http.Request) {
+	req.Header.Set("X-Amz-Content-Sha256", awsHandler.UnsignedPayload)
+	s.signer().Sign(req, awsHandler.UnsignedPayload, s.Now())
+}
+
+func (s *S3Store) do(ctx context.Context, method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.send(ctx, method, key, u, body, size, header)
+}
+
+// send makes a signed request to u, which is about key, and turns error answers into errors
+func (s *S3Store) send(ctx context.Context, method, key string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req)
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+// Put needs the length up front, anything but a file is spooled to a temporary one first
+func (s *S3Store) Put(ctx context.Context, key, contentType string, r io.Reader) (Info, error) {
+	f, ok := r.(*os.File)
+	if !ok {
+		tmp, err := os.CreateTemp("", "bookserver-s3-*")
+		if err != nil {
+			return Info{}, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, r); err != nil {
+			return Info{}, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		f = tmp
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Info{}, err
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Info{}, err
+	}
+	if _, err := f.Seek(pos, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+	size := end - pos
+
+	resp, err := s.do(ctx, http.MethodPut, key, io.NopCloser(f), size, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+	return Info{Key: key, ContentType: contentType, Size: size, Modified: s.Now()}, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (ReadSeekCloser, Info, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	resp.Body.Close()
+	info := Info{Key: key, ContentType: resp.Header.Get("Content-Type"), Size: resp.ContentLength}
+	info.Modified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &s3Object{ctx: ctx, store: s, key: key, size: info.Size}, info, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3Store) Ping(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, 0, nil) //HEAD on the bucket
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("bucket %q does not exist", s.Bucket)
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through the bucket with ListObjectsV2; content types aren't listed, Info has none
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(Info) error) error {
+	u, err := s.objectURL("")
+	if err != nil {
+		return err
+	}
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if len(token) != 0 {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		resp, err := s.send(ctx, http.MethodGet, prefix, u, nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		var page s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3 list %s: %w", prefix, err)
+		}
+		for _, o := range page.Contents {
+			if err := fn(Info{Key: o.Key, Size: o.Size, Modified: o.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || len(page.NextContinuationToken) == 0 {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// PresignGet returns a URL anyone can GET key from until ttl passes, served as an attachment named filename
+func (s *S3Store) PresignGet(key, filename string, ttl time.Duration) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	if len(filename) != 0 {
+		q.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	return s.signer().Presign(http.MethodGet, u, q, ttl, s.Now()), nil
+}
+
+// s3Object reads an object with ranged GETs, so seeking (and HTTP Range requests on top) costs no extra transfer
+type s3Object struct {
+	ctx   context.Context
+	store *S3Store
+	key   string
+	size  int64
+	off   int64
+	body  io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.off >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		resp, err := o.store.do(o.ctx, http.MethodGet, o.key, nil, 0, http.Header{"Range": {fmt.Sprintf("bytes=%d-", o.off)}})
+		if err != nil {
+			return 0, err
+		}
+		o.body = resp.Body
+	}
+	n, err := o.body.Read(p)
+	o.off += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = o.off + offset
+	case io.SeekEnd:
+		abs = o.size + offset
+	}
+	if abs < 0 {
+		return 0, errors.New("s3: negative position")
+	}
+	if abs != o.off && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.off = abs
+	return abs, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body != nil {
+		return o.body.Close()
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package blobHandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Scanner checks an upload before it is stored, returning an *InfectedError for malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string { return "infected: " + e.Signature }
+
+// ClamdScanner streams files to a clamd daemon with its INSTREAM command
+type ClamdScanner struct {
+	Addr    string //host:port, or a unix socket path starting with /
+	Timeout time.Duration
+}
+
+func NewClamdScanner(addr string) *ClamdScanner {
+	return &ClamdScanner{Addr: addr, Timeout: time.Minute}
+}
+
+func (c *ClamdScanner) dial(ctx context.Context) (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(c.Addr, "/") {
+		network = "unix"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(c.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	return conn, nil
+}
+
+// command sends a null terminated command, writes the body if any and reads the one line reply
+func (c *ClamdScanner) command(ctx context.Context, cmd string, body func(w io.Writer) error) (string, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	w := bufio.NewWriter(conn)
+	w.WriteString("z" + cmd + "\x00")
+	if body != nil {
+		if err := body(w); err != nil {
+			return "", err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(reply, "\x00"), nil
+}
+
+func (c *ClamdScanner) Scan(ctx context.Context, r io.Reader) error {
+	reply, err := c.command(ctx, "INSTREAM", func(w io.Writer) error {
+		buf := make([]byte, 64<<10)
+		var size [4]byte
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				binary.BigEndian.PutUint32(size[:], uint32(n))
+				w.Write(size[:])
+				if _, err := w.Write(buf[:n]); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF {
+				_, err := w.Write([]byte{0, 0, 0, 0}) //end of stream
+				return err
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	//"stream: OK", "stream: Eicar-Test-Signature FOUND" or "INSTREAM size limit exceeded. ERROR"
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &InfectedError{Signature: strings.TrimSuffix(reply, " FOUND")}
+	}
+	return fmt.Errorf("clamd: %s", reply)
+}
+
+func (c *ClamdScanner) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package blobHandler
+
+import "bytes"
+
+const SniffLen = 512 //bytes Sniff needs at most
+
+// Sniff names the content type of a file from its first bytes, for the types the server accepts,
+// or returns "" when it is none of them
+func Sniff(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return "application/pdf"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")) && len(head) >= 58 && string(head[30:58]) == "mimetypeapplication/epub+zip":
+		return "application/epub+zip" //the spec puts an uncompressed mimetype entry first
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return "image/jpeg"
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return "image/webp"
+	}
+	return ""
+}
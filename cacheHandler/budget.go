@@ -0,0 +1,88 @@
+package cacheHandler
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+type member interface {
+	used() int64
+	evictOne() bool
+	Stats() Stats
+}
+
+// Budget caps the memory of every cache drawing on it together, so the server stays within its
+// container's limit however the load is spread. When a Set goes over, entries are evicted from
+// whichever cache holds the most bytes, by that cache's policy.
+type Budget struct {
+	Limit int64 //bytes, 0 or less for no limit
+
+	mu     sync.Mutex //serializes eviction, taken before any cache's lock
+	caches []member
+	used   atomic.Int64
+}
+
+// BudgetStats is the state of a budget and its caches
+type BudgetStats struct {
+	Limit  int64   `json:"limit"`
+	Used   int64   `json:"used"`
+	Caches []Stats `json:"caches"`
+}
+
+func NewBudget(limit int64) *Budget {
+	return &Budget{Limit: limit}
+}
+
+func (b *Budget) register(c member) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.caches = append(b.caches, c)
+}
+
+// fit evicts until the caches are back within Limit
+func (b *Budget) fit() {
+	if b.Limit <= 0 || b.used.Load() <= b.Limit {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used.Load() > b.Limit {
+		var largest member
+		for _, c := range b.caches {
+			if largest == nil || c.used() > largest.used() {
+				largest = c
+			}
+		}
+		if largest == nil || !largest.evictOne() {
+			return
+		}
+	}
+}
+
+func (b *Budget) Stats() BudgetStats {
+	b.mu.Lock()
+	caches := b.caches
+	b.mu.Unlock()
+	s := BudgetStats{Limit: b.Limit, Used: b.used.Load(), Caches: make([]Stats, 0, len(caches))}
+	for _, c := range caches {
+		s.Caches = append(s.Caches, c.Stats())
+	}
+	return s
+}
+
+var published atomic.Pointer[Budget]
+
+func init() {
+	expvar.Publish("caches", expvar.Func(func() any {
+		if b := published.Load(); b != nil {
+			return b.Stats()
+		}
+		return nil
+	}))
+}
+
+// Publish makes b the budget whose stats expvar reports as "caches"
+func (b *Budget) Publish() {
+	published.Store(b)
+}
@@ -0,0 +1,147 @@
+package cacheHandler
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stats of one cache, as served on /admin/caches and /admin/debug/vars
+type Stats struct {
+	Name      string `json:"name"`
+	Policy    string `json:"policy"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Evictions int64  `json:"evictions"` //entries dropped to stay within the budget
+}
+
+type entry[V any] struct {
+	value V
+	size  int64
+}
+
+// Cache keeps values by key within its Budget, evicting by its Policy. Size estimates the memory an
+// entry takes, it doesn't need to be exact but should grow with the value. Safe for concurrent use.
+type Cache[V any] struct {
+	name   string
+	size   func(V) int64
+	budget *Budget
+
+	mu      sync.Mutex
+	entries map[string]entry[V]
+	policy  Policy
+
+	bytes                   atomic.Int64
+	hits, misses, evictions atomic.Int64
+}
+
+// New makes a cache drawing on budget, a nil budget leaves it unbounded
+func New[V any](name string, policy Policy, budget *Budget, size func(V) int64) *Cache[V] {
+	c := &Cache[V]{name: name, size: size, budget: budget, entries: make(map[string]entry[V]), policy: policy}
+	if budget != nil {
+		budget.register(c)
+	}
+	return c
+}
+
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		c.policy.Hit(key)
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return e.value, false
+	}
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Set adds or replaces key, then evicts across the budget's caches until it fits again.
+// A value larger than the whole budget isn't kept.
+func (c *Cache[V]) Set(key string, v V) {
+	size := c.size(v) + int64(len(key))
+	if c.budget != nil && c.budget.Limit > 0 && size > c.budget.Limit {
+		c.Delete(key)
+		return
+	}
+	c.mu.Lock()
+	delta := size
+	if old, ok := c.entries[key]; ok {
+		delta -= old.size
+		c.policy.Hit(key)
+	} else {
+		c.policy.Add(key)
+	}
+	c.entries[key] = entry[V]{value: v, size: size}
+	c.mu.Unlock()
+
+	c.grow(delta)
+	if c.budget != nil {
+		c.budget.fit()
+	}
+}
+
+func (c *Cache[V]) Delete(key string) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		delete(c.entries, key)
+		c.policy.Remove(key)
+	}
+	c.mu.Unlock()
+	if ok {
+		c.grow(-e.size)
+	}
+}
+
+// Purge drops every entry
+func (c *Cache[V]) Purge() {
+	c.mu.Lock()
+	var freed int64
+	for key, e := range c.entries {
+		c.policy.Remove(key)
+		freed += e.size
+	}
+	c.entries = make(map[string]entry[V])
+	c.mu.Unlock()
+	c.grow(-freed)
+}
+
+func (c *Cache[V]) Stats() Stats {
+	c.mu.Lock()
+	n := len(c.entries)
+	c.mu.Unlock()
+	return Stats{Name: c.name, Policy: c.policy.Name(), Entries: n, Bytes: c.bytes.Load(),
+		Hits: c.hits.Load(), Misses: c.misses.Load(), Evictions: c.evictions.Load()}
+}
+
+func (c *Cache[V]) grow(delta int64) {
+	c.bytes.Add(delta)
+	if c.budget != nil {
+		c.budget.used.Add(delta)
+	}
+}
+
+func (c *Cache[V]) used() int64 { return c.bytes.Load() }
+
+// evictOne drops the policy's victim, false when the cache is empty
+func (c *Cache[V]) evictOne() bool {
+	c.mu.Lock()
+	key, ok := c.policy.Victim()
+	var e entry[V]
+	if ok {
+		e = c.entries[key]
+		delete(c.entries, key)
+		c.policy.Remove(key)
+	}
+	c.mu.Unlock()
+	if ok {
+		c.evictions.Add(1)
+		c.grow(-e.size)
+	}
+	return ok
+}
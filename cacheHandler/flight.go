@@ -0,0 +1,54 @@
+package cacheHandler
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrLoadPanicked is what callers waiting on a load get when it panicked, the loading caller re-panics
+var ErrLoadPanicked = errors.New("load panicked")
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Group runs one load per key at a time: callers asking for a key that is already being loaded
+// wait for that load and share its result instead of starting their own. Safe for concurrent use.
+type Group[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+// Do returns the result of load for key, shared reports whether it came from another caller's load
+func (g *Group[V]) Do(key string, load func() (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*call[V])
+	}
+	c := &call[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() { //also when load panics, so waiters aren't stuck
+		p := recover()
+		if p != nil {
+			c.err = ErrLoadPanicked
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+		if p != nil {
+			panic(p)
+		}
+	}()
+	c.value, c.err = load()
+	return c.value, c.err, false
+}
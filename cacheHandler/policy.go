@@ -0,0 +1,147 @@
+package cacheHandler
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+// Policy picks which entry a Cache evicts next, callers serialize access
+type Policy interface {
+	Name() string
+	Add(key string) //a new entry
+	Hit(key string) //an entry was read or replaced
+	Remove(key string)
+	Victim() (string, bool) //entry to evict next, false when empty
+}
+
+// NewPolicy returns "lru" or "lfu"
+func NewPolicy(name string) (Policy, error) {
+	switch name {
+	case "lru":
+		return NewLRU(), nil
+	case "lfu":
+		return NewLFU(), nil
+	}
+	return nil, fmt.Errorf("unknown cache policy %q, want lru or lfu", name)
+}
+
+// LRU evicts the entry read longest ago
+type LRU struct {
+	order *list.List //front is the most recently used
+	elems map[string]*list.Element
+}
+
+func NewLRU() *LRU {
+	return &LRU{order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+func (p *LRU) Name() string { return "lru" }
+
+func (p *LRU) Add(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *LRU) Hit(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *LRU) Remove(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *LRU) Victim() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// LFU evicts the entry read least often, the least recently used of those on a tie
+type LFU struct {
+	items lfuHeap
+	index map[string]*lfuItem
+	clock uint64 //orders uses for ties
+}
+
+type lfuItem struct {
+	key  string
+	hits uint64
+	last uint64
+	pos  int
+}
+
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].hits != h[j].hits {
+		return h[i].hits < h[j].hits
+	}
+	return h[i].last < h[j].last
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos, h[j].pos = i, j
+}
+func (h *lfuHeap) Push(x any) {
+	it := x.(*lfuItem)
+	it.pos = len(*h)
+	*h = append(*h, it)
+}
+func (h *lfuHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+func NewLFU() *LFU {
+	return &LFU{index: make(map[string]*lfuItem)}
+}
+
+func (p *LFU) Name() string { return "lfu" }
+
+func (p *LFU) Add(key string) {
+	if _, ok := p.index[key]; ok {
+		p.Hit(key)
+		return
+	}
+	p.clock++
+	it := &lfuItem{key: key, hits: 1, last: p.clock}
+	p.index[key] = it
+	heap.Push(&p.items, it)
+}
+
+func (p *LFU) Hit(key string) {
+	if it, ok := p.index[key]; ok {
+		p.clock++
+		it.hits++
+		it.last = p.clock
+		heap.Fix(&p.items, it.pos)
+	}
+}
+
+func (p *LFU) Remove(key string) {
+	if it, ok := p.index[key]; ok {
+		heap.Remove(&p.items, it.pos)
+		delete(p.index, key)
+	}
+}
+
+func (p *LFU) Victim() (string, bool) {
+	if len(p.items) == 0 {
+		return "", false
+	}
+	return p.items[0].key, true
+}
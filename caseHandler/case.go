@@ -0,0 +1,78 @@
+package caseHandler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Casing is how field names are spelled in JSON responses
+type Casing string
+
+const (
+	Snake Casing = "snake" //original_title, as declared in the struct tags and the API specification
+	Camel Casing = "camel" //originalTitle
+)
+
+// Parse reads a casing name, the empty string meaning Snake
+func Parse(s string) (Casing, error) {
+	switch c := Casing(strings.ToLower(strings.TrimSpace(s))); c {
+	case "":
+		return Snake, nil
+	case Snake, Camel:
+		return c, nil
+	}
+	return Snake, fmt.Errorf("unknown JSON casing %q, want snake or camel", s)
+}
+
+type ctxKey struct{}
+
+// WithCasing returns a copy of r whose responses spell field names in c
+func WithCasing(r *http.Request, c Casing) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), ctxKey{}, c))
+}
+
+// Of returns the casing picked for r, Snake unless WithCasing set another
+func Of(r *http.Request) Casing {
+	if c, ok := r.Context().Value(ctxKey{}).(Casing); ok {
+		return c
+	}
+	return Snake
+}
+
+// Name spells a snake_case field name, or a path of them such as authors[0].home_town, in c
+func (c Casing) Name(name string) string {
+	if c != Camel || !strings.Contains(name, "_") {
+		return name
+	}
+	var sb strings.Builder
+	sb.Grow(len(name))
+	upper := false
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		switch {
+		case ch == '_' && sb.Len() > 0 && i+1 < len(name):
+			upper = true
+		case upper && 'a' <= ch && ch <= 'z':
+			sb.WriteByte(ch - 'a' + 'A')
+			upper = false
+		default:
+			sb.WriteByte(ch)
+			upper = false
+		}
+	}
+	return sb.String()
+}
+
+// Encode writes v as JSON followed by a newline, like json.Encoder, with struct field names spelled in c.
+// Map keys are data and are written as they are.
+func Encode(w io.Writer, v any, c Casing) error {
+	b, err := Marshal(v, c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,239 @@
+package caseHandler
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Marshal is json.Marshal with struct field names spelled in c. It follows the json struct tags,
+// omitempty and embedded structs included; values with their own MarshalJSON are encoded by it unchanged.
+func Marshal(v any, c Casing) ([]byte, error) {
+	if c == Snake {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, reflect.ValueOf(v), c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func encode(buf *bytes.Buffer, v reflect.Value, c Casing) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(marshalerType)) {
+		return plain(buf, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encode(buf, v.Elem(), c)
+	case reflect.Struct:
+		buf.WriteByte('{')
+		first := true
+		for _, f := range fieldsOf(t) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmpty(fv)) {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			key, _ := json.Marshal(c.Name(f.name))
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := encode(buf, fv, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		type entry struct {
+			key string
+			v   reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			k, err := mapKey(it.Key())
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{k, it.Value()})
+		}
+		slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.key, b.key) })
+		buf.WriteByte('{')
+		for i, e := range entries {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(e.key)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := encode(buf, e.v, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 { //base64, like encoding/json
+			return plain(buf, v)
+		}
+		fallthrough
+	case reflect.Array:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(buf, v.Index(i), c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	return plain(buf, v)
+}
+
+// plain encodes v with encoding/json, for scalars and values that marshal themselves
+func plain(buf *bytes.Buffer, v reflect.Value) error {
+	if v.CanAddr() && v.Kind() != reflect.Pointer {
+		v = v.Addr() //MarshalJSON may be declared on the pointer
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// mapKey spells a map key the way encoding/json does
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("json: unsupported map key type %s", k.Type())
+}
+
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map //reflect.Type -> []field
+
+// fieldsOf lists the JSON fields of struct type t in encoding order, fields of untagged embedded
+// structs inlined; a name declared at a shallower depth hides deeper ones, like in encoding/json
+func fieldsOf(t reflect.Type) []field {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.([]field)
+	}
+	var fs []field
+	seen := make(map[string]bool)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			idx := append(slices.Clone(index), i)
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && len(name) == 0 && ft.Kind() == reflect.Struct {
+				walk(ft, idx)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if len(name) == 0 {
+				name = sf.Name
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			fs = append(fs, field{name: name, index: idx, omitEmpty: strings.Contains(","+opts+",", ",omitempty,")})
+		}
+	}
+	walk(t, nil)
+	fieldCache.Store(t, fs)
+	return fs
+}
+
+// fieldByIndex is v.FieldByIndex without panicking on nil embedded pointers, ok is false then
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmpty is encoding/json's notion of empty for omitempty
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
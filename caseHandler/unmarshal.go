@@ -0,0 +1,91 @@
+package caseHandler
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+var (
+	unmarshalerType     = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Unmarshal is json.Unmarshal for a body whose struct field names are spelled in c. Keys are matched
+// against the fields of the struct they decode into, so map keys, which are data, are kept as they are.
+// Names spelled as in the struct tags are accepted whatever c is.
+func Unmarshal(data []byte, v any, c Casing) error {
+	if c == Snake {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid character after top-level value")
+	}
+	b, err := json.Marshal(Keys(doc, reflect.TypeOf(v), c))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Decode reads the next JSON value from r into v like json.Decoder, with struct field names spelled in c
+func Decode(r io.Reader, v any, c Casing) error {
+	if c == Snake {
+		return json.NewDecoder(r).Decode(v)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return err
+	}
+	return Unmarshal(raw, v, c)
+}
+
+// Keys respells the object keys of a decoded JSON value from c to the names of the fields of t they
+// decode into. Objects decoding into maps or values that unmarshal themselves are left alone.
+func Keys(doc any, t reflect.Type, c Casing) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || c == Snake || reflect.PointerTo(t).Implements(unmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return doc
+	}
+	switch v := doc.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fs := fieldsOf(t)
+			out := make(map[string]any, len(v))
+			for key, val := range v {
+				name, ft := key, reflect.Type(nil)
+				for _, f := range fs {
+					if f.name == key || c.Name(f.name) == key {
+						name, ft = f.name, t.FieldByIndex(f.index).Type
+						break
+					}
+				}
+				out[name] = Keys(val, ft, c)
+			}
+			return out
+		case reflect.Map:
+			for key, val := range v {
+				v[key] = Keys(val, t.Elem(), c)
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i := range v {
+				v[i] = Keys(v[i], t.Elem(), c)
+			}
+		}
+	}
+	return doc
+}
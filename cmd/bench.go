@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/benchHandler"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchOpts = benchHandler.Options{Target: "http://127.0.0.1:8080", Concurrency: 8, Duration: 30 * time.Second, Username: "sabnaj", Password: "1234"}
+	benchMix  = "80,15,5"
+	benchP99  time.Duration
+	benchCmd  = &cobra.Command{
+		Use:   "bench",
+		Short: "load a running server with a mixed workload and report latencies",
+		Long: `bench logs every worker in and then sends a weighted mix of reads (listing and fetching books),
+writes (creating, updating and deleting its own books) and logins until --duration is up.
+It prints throughput, errors and latency percentiles per operation; with --fail-p99 it exits 1
+when an operation's p99 is slower, for catching performance regressions in CI.
+Point it at a test server: the writes go through the normal API, and rate limits and quotas apply.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			mix, err := benchHandler.ParseMix(benchMix)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			benchOpts.Mix = mix
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			report, err := benchHandler.Run(ctx, benchOpts)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "bench:", err)
+				os.Exit(1)
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintln(tw, "op\tcalls\terrors\treq/s\tp50\tp90\tp99\tmax\t")
+			slow := false
+			for _, name := range report.Names() {
+				st := report.Ops[name]
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%v\t%v\t%v\t%v\t\n", name, st.Count, st.Errors,
+					float64(st.Count)/report.Elapsed.Seconds(), round(st.Percentile(50)), round(st.Percentile(90)),
+					round(st.Percentile(99)), round(st.Percentile(100)))
+				slow = slow || benchP99 > 0 && st.Percentile(99) > benchP99
+			}
+			tw.Flush()
+			for _, name := range report.Names() {
+				if st := report.Ops[name]; st.Errors != 0 {
+					fmt.Printf("%s statuses: %v (0 is a transport error)\n", name, st.Statuses)
+				}
+			}
+			if slow {
+				fmt.Fprintf(os.Stderr, "bench: p99 above %v\n", benchP99)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+// round shortens latencies to a readable precision
+func round(d time.Duration) time.Duration {
+	if d > time.Millisecond {
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	fs := benchCmd.Flags()
+	fs.StringVar(&benchOpts.Target, "target", benchOpts.Target, "base URL of the server to load")
+	fs.IntVarP(&benchOpts.Concurrency, "concurrency", "c", benchOpts.Concurrency, "concurrent workers, each logged in on its own")
+	fs.DurationVarP(&benchOpts.Duration, "duration", "d", benchOpts.Duration, "how long to run")
+	fs.StringVar(&benchOpts.Username, "username", benchOpts.Username, "account the workers log in with")
+	fs.StringVar(&benchOpts.Password, "password", benchOpts.Password, "password of --username")
+	fs.StringVar(&benchMix, "mix", benchMix, "weights of reads, writes and logins")
+	fs.DurationVar(&benchP99, "fail-p99", 0, "exit 1 when any operation's p99 latency is above this, 0 never fails")
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	ap "github.com/Sabnaj-42/BookServer-API/apiHandler"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check the environment the server would start in",
+	Long: `doctor runs the startup self-check with the same flags as start:
+storage, data directories, secret strength, clock and port availability`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		h, err := ap.FromConfig(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "config:", err)
+			os.Exit(1)
+		}
+		failed := false
+		for _, c := range h.SelfCheck(context.Background()) {
+			fmt.Printf("[%-4s] %-15s %s\n", c.Status, c.Name, c.Detail)
+			failed = failed || c.Status == ap.CheckFail
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	serverFlags(doctorCmd.Flags())
+}
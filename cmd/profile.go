@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// profileTypes maps --type to the pprof endpoint under /admin/debug/pprof/
+var profileTypes = map[string]string{
+	"cpu":       "profile",
+	"heap":      "heap",
+	"allocs":    "allocs",
+	"goroutine": "goroutine",
+	"block":     "block",
+	"mutex":     "mutex",
+	"trace":     "trace",
+}
+
+var (
+	profileTarget   = "http://127.0.0.1:8080"
+	profileType     = "cpu"
+	profileDuration = 30 * time.Second
+	profileOut      = "profile.pb.gz"
+	profileUser     = "Admin"
+	profilePassword = os.Getenv("BOOKSERVER_ADMIN_PASSWORD")
+	profileCmd      = &cobra.Command{
+		Use:   "profile",
+		Short: "save a pprof profile of a running server",
+		Long: `profile logs in as an admin and downloads a profile from /admin/debug/pprof of a running server,
+e.g. BookServer profile --type cpu --duration 30s --out profile.pb.gz, then: go tool pprof profile.pb.gz
+--duration is how long CPU profiles and traces record, heap and allocs are a snapshot since startup.
+The admin IP rules apply, run it from an address allowed on /admin.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			endpoint, ok := profileTypes[profileType]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "unknown profile type %q\n", profileType)
+				os.Exit(1)
+			}
+			if err := saveProfile(endpoint); err != nil {
+				fmt.Fprintln(os.Stderr, "profile:", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s profile saved to %s\n", profileType, profileOut)
+		},
+	}
+)
+
+func saveProfile(endpoint string) error {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, Timeout: profileDuration + 30*time.Second}
+	target := strings.TrimSuffix(profileTarget, "/")
+
+	creds := fmt.Sprintf(`{"username":%q,"password":%q}`, profileUser, profilePassword)
+	resp, err := client.Post(target+"/login", "application/json", strings.NewReader(creds))
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("login: %s", resp.Status)
+	}
+
+	q := url.Values{}
+	if endpoint == "profile" || endpoint == "trace" {
+		q.Set("seconds", fmt.Sprint(int(profileDuration.Seconds())))
+	}
+	resp, err = client.Get(target + "/admin/debug/pprof/" + endpoint + "?" + q.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	f, err := os.Create(profileOut)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	fs := profileCmd.Flags()
+	fs.StringVar(&profileTarget, "target", profileTarget, "base URL of the running server")
+	fs.StringVar(&profileType, "type", profileType, "cpu, heap, allocs, goroutine, block, mutex or trace")
+	fs.DurationVar(&profileDuration, "duration", profileDuration, "how long to record cpu profiles and traces")
+	fs.StringVarP(&profileOut, "out", "o", profileOut, "file to save the profile to")
+	fs.StringVar(&profileUser, "username", profileUser, "admin account to log in with")
+	fs.StringVar(&profilePassword, "password", profilePassword, "password of --username, defaults to $BOOKSERVER_ADMIN_PASSWORD")
+}
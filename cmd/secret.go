@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Sabnaj-42/BookServer-API/pushHandler"
+	"github.com/Sabnaj-42/BookServer-API/secretHandler"
+	"github.com/spf13/cobra"
+)
+
+var (
+	secretAAD string
+	secretCmd = &cobra.Command{
+		Use:   "secret",
+		Short: "manage the keys secrets are encrypted with in Redis and the signing key file",
+	}
+	keygenCmd = &cobra.Command{
+		Use:   "keygen <id>",
+		Short: "print a new encryption key as id:base64",
+		Long: `keygen prints a random AES-256 key for BOOKSERVER_ENCRYPTION_KEYS.
+To rotate, put the new key in front of the old ones; the old keys can go once everything was rewritten.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s:%s\n", args[0], base64.StdEncoding.EncodeToString(key))
+		},
+	}
+	vapidCmd = &cobra.Command{
+		Use:   "vapid-keygen",
+		Short: "print a new Web Push key pair",
+		Long: `vapid-keygen prints a private key for BOOKSERVER_VAPID_KEY and the public key browsers
+subscribe with; changing the key invalidates every browser's push subscription.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			priv, pub, err := pushHandler.GenerateVAPIDKey()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("BOOKSERVER_VAPID_KEY=%s\npublic key: %s\n", priv, pub)
+		},
+	}
+	encryptCmd = &cobra.Command{
+		Use:   "encrypt",
+		Short: "encrypt stdin with the primary key of BOOKSERVER_ENCRYPTION_KEYS",
+		Long: `encrypt seals a secret read from stdin, e.g. a signing key secret for --signing-keys,
+with --aad set to the key's id`,
+		Run: func(cmd *cobra.Command, args []string) {
+			ring, err := secretHandler.ParseKeyring(os.Getenv("BOOKSERVER_ENCRYPTION_KEYS"))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "BOOKSERVER_ENCRYPTION_KEYS:", err)
+				os.Exit(1)
+			}
+			plain, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			sealed, err := ring.Encrypt(strings.TrimRight(string(plain), "\r\n"), secretAAD)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Println(sealed)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(keygenCmd, vapidCmd, encryptCmd)
+	encryptCmd.Flags().StringVar(&secretAAD, "aad", "", "what the value belongs to, e.g. the signing key id")
+}
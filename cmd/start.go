@@ -3,11 +3,12 @@ package cmd
 import (
 	ap "github.com/Sabnaj-42/BookServer-API/apiHandler"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // rootCmd represents the base command when called without any subcommands
 var (
-	port     int
+	cfg      = ap.DefaultConfig()
 	startCmd = &cobra.Command{
 		Use:   "start",
 		Short: "start cmd starts the sever on a port",
@@ -15,12 +16,78 @@ var (
                    post number will be given in the cmd`,
 
 		Run: func(cmd *cobra.Command, args []string) {
-			ap.RunServer(port)
+			ap.RunServer(cfg)
 		},
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(startCmd)
-	startCmd.PersistentFlags().IntVarP(&port, "port", "p", 8080, "port to listen on")
+	serverFlags(startCmd.PersistentFlags())
+}
+
+// serverFlags binds the server configuration flags, shared by start and doctor
+func serverFlags(fs *pflag.FlagSet) {
+	fs.IntVarP(&cfg.Port, "port", "p", cfg.Port, "port to listen on")
+	fs.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "scheme and host clients reach the server at, e.g. https://books.example.com; public shelf, notification and signed URLs are built on it")
+	fs.StringVar(&cfg.CSP, "csp", cfg.CSP, "Content-Security-Policy sent with every response, empty to disable")
+	fs.StringVar(&cfg.Store, "store", cfg.Store, "where books are kept: memory or a store a --plugin registers")
+	fs.StringVar(&cfg.UserStore, "user-store", cfg.UserStore, "where accounts are checked at login: a user store a --plugin registers, empty keeps them in --store")
+	fs.StringVar(&cfg.ReplicaStore, "replica-store", cfg.ReplicaStore, "a read replica of --store that book reads go to: a store a --plugin registers, empty reads from --store")
+	fs.DurationVar(&cfg.ReplicaMaxLag, "replica-max-lag", cfg.ReplicaMaxLag, "how long after a write of this instance book reads stay on --store, so a lagging --replica-store can't hide it")
+	fs.StringSliceVar(&cfg.Plugins, "plugin", cfg.Plugins, "Go plugin (.so built with -buildmode=plugin) to load at startup for more stores, repeatable")
+	fs.StringToStringVar(&cfg.BackendOptions, "backend-option", cfg.BackendOptions, "key=value setting for a plugin's backends, e.g. ldap.url=ldaps://dir.example.com, repeatable")
+	fs.StringVar((*string)(&cfg.AuthMode), "auth-mode", string(cfg.AuthMode), "how logins are kept: jwt or session")
+	fs.StringVar(&cfg.SessionStore, "session-store", cfg.SessionStore, "session store for --auth-mode=session: memory, redis or one a --plugin registers")
+	fs.StringVar(&cfg.RefreshStore, "refresh-store", cfg.RefreshStore, "where remember-me logins are kept: memory, redis or one a --plugin registers")
+	fs.BoolVar(&cfg.BasicAuth, "basic-auth", cfg.BasicAuth, "accept HTTP Basic credentials on protected endpoints")
+	fs.IntVar(&cfg.PasswordMinLength, "password-min-length", cfg.PasswordMinLength, "minimum length of new passwords")
+	fs.IntVar(&cfg.PasswordClasses, "password-classes", cfg.PasswordClasses, "how many of lower case, upper case, digits and symbols new passwords must mix, 0 to 4")
+	fs.BoolVar(&cfg.BreachCheck, "password-breach-check", cfg.BreachCheck, "refuse new passwords found in data breaches, asks api.pwnedpasswords.com with a 5 character hash prefix")
+	fs.StringVar(&cfg.SigningKeys, "signing-keys", cfg.SigningKeys, `JSON file of [{"id","secret","user"}] keys for HMAC-SHA256 signed requests`)
+	fs.StringVar(&cfg.NonceStore, "nonce-store", cfg.NonceStore, "where nonces of signed requests are kept: memory, redis or one a --plugin registers")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "PEM certificate to serve HTTPS with, needs --tls-key")
+	fs.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "PEM private key of --tls-cert")
+	fs.StringVar(&cfg.ClientCA, "client-ca", cfg.ClientCA, "PEM bundle to verify client certificates against, enables mTLS")
+	fs.StringVar(&cfg.ClientCerts, "client-certs", cfg.ClientCerts, "with --client-ca: optional or require a client certificate on every connection")
+	fs.StringVar(&cfg.CertUsers, "cert-users", cfg.CertUsers, `JSON file of [{"subject","user","admin"}] mapping client certificates to users`)
+	fs.StringVar(&cfg.JWTSecretFrom, "jwt-secret-from", cfg.JWTSecretFrom, "fetch the JWT key at startup from vault:<path>#<field>, kms:<base64 ciphertext>, env:<name> or file:<path>; Vault is reached via $VAULT_ADDR and $VAULT_TOKEN")
+	fs.StringVar(&cfg.RedisAuthFrom, "redis-credentials-from", cfg.RedisAuthFrom, "secret reference with redis username and password, e.g. vault:database/creds/bookserver; leases are renewed while running")
+	fs.StringVar(&cfg.KMSRegion, "kms-region", cfg.KMSRegion, "AWS region for kms: secret references, keys come from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	fs.StringVar(&cfg.RedisAddr, "redis-addr", cfg.RedisAddr, "redis address for --session-store, --nonce-store, --event-bus and --job-lock set to redis")
+	fs.StringVar(&cfg.EventBus, "event-bus", cfg.EventBus, "how book changes reach other replicas: local or redis")
+	fs.StringVar(&cfg.JobLock, "job-lock", cfg.JobLock, "how one replica is picked to run background jobs: local or redis")
+	fs.StringSliceVar(&cfg.Admins, "admins", cfg.Admins, "usernames allowed on /admin")
+	fs.StringSliceVar(&cfg.AdminAllow, "admin-allow", cfg.AdminAllow, "CIDRs allowed on /admin, empty allows all")
+	fs.StringSliceVar(&cfg.AdminDeny, "admin-deny", cfg.AdminDeny, "CIDRs refused on /admin")
+	fs.DurationVar(&cfg.StoreReadTimeout, "store-read-timeout", cfg.StoreReadTimeout, "how long a book storage read may take before the request fails with 504, 0 for no limit")
+	fs.DurationVar(&cfg.StoreWriteTimeout, "store-write-timeout", cfg.StoreWriteTimeout, "how long a book storage write may take before the request fails with 504, 0 for no limit")
+	fs.DurationVar(&cfg.SlowStoreCall, "slow-store-call", cfg.SlowStoreCall, "log and count book storage calls taking this long or longer, 0 for none")
+	fs.IntVar(&cfg.StoreAttempts, "store-attempts", cfg.StoreAttempts, "how often a storage call failing with a serialization failure or a dropped connection is tried, with jittered backoff")
+	fs.IntVar(&cfg.CacheMemoryMB, "cache-memory-mb", cfg.CacheMemoryMB, "megabytes the in-process caches may take together, 0 disables caching")
+	fs.StringVar(&cfg.CachePolicy, "cache-policy", cfg.CachePolicy, "what the caches evict first when full: lru (least recently used) or lfu (least frequently used)")
+	fs.StringVar(&cfg.Warmup, "warmup", cfg.Warmup, "load hot books into the cache at startup: off, sync (before listening) or async (/readyz answers 503 until done)")
+	fs.StringVar(&cfg.RuntimeFile, "runtime-config", cfg.RuntimeFile, "JSON file with log level, rate limits, CORS origins, feature flags, response field casing and banned words, reloaded on SIGHUP")
+	fs.StringVar(&cfg.BlobDir, "blob-dir", cfg.BlobDir, "directory for covers and e-book files, empty keeps them in memory")
+	fs.StringVar(&cfg.S3Bucket, "s3-bucket", cfg.S3Bucket, "S3 bucket for covers and e-book files, takes precedence over --blob-dir; keys come from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	fs.StringVar(&cfg.S3Endpoint, "s3-endpoint", cfg.S3Endpoint, "S3 endpoint, e.g. http://127.0.0.1:9000 for MinIO")
+	fs.StringVar(&cfg.S3Region, "s3-region", cfg.S3Region, "S3 region used for request signing")
+	fs.StringVar(&cfg.ClamdAddr, "clamd-addr", cfg.ClamdAddr, "clamd address to scan uploads with, host:port or a unix socket path; empty skips scanning")
+	fs.StringVar(&cfg.BookWebhook, "book-webhook", cfg.BookWebhook, "URL every book change is POSTed to in batches, updates with the fields they changed, signed with $BOOKSERVER_WEBHOOK_SECRET")
+	fs.IntVar(&cfg.OutboundAttempts, "outbound-attempts", cfg.OutboundAttempts, "how often a webhook, push, S3 or secret store call failing with a network error or 429, 502, 503 or 504 is tried, with exponential backoff")
+	fs.StringVar(&cfg.OutboundProxy, "outbound-proxy", cfg.OutboundProxy, "proxy URL for outbound HTTP calls, without it $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY apply")
+	fs.BoolVar(&cfg.AllowPrivateWebhooks, "allow-private-webhooks", cfg.AllowPrivateWebhooks, "let the book and moderation webhooks call loopback, private and link-local addresses")
+	fs.StringVar(&cfg.ModerationWebhook, "moderation-webhook", cfg.ModerationWebhook, "URL of a moderation service new reviews and comments are POSTed to after the banned word check, signed with $BOOKSERVER_MODERATION_SECRET")
+	fs.StringVar(&cfg.VAPIDSubject, "vapid-subject", cfg.VAPIDSubject, "mailto: or https: contact for Web Push services, needed with $BOOKSERVER_VAPID_KEY")
+	fs.StringVar(&cfg.FCMCredentials, "fcm-credentials", cfg.FCMCredentials, "Firebase service account key file to push notifications to Android and iOS apps with")
+	fs.StringVar(&cfg.SMTPAddr, "smtp-addr", cfg.SMTPAddr, "mail server to send notification emails through, host:port; credentials come from BOOKSERVER_SMTP_USERNAME and BOOKSERVER_SMTP_PASSWORD")
+	fs.StringVar(&cfg.MailFrom, "mail-from", cfg.MailFrom, "sender address of notification emails")
+	fs.StringSliceVar(&cfg.ReportEmail, "report-email", cfg.ReportEmail, "addresses to mail the weekly and monthly catalog reports to, needs --smtp-addr")
+	fs.DurationVar(&cfg.SnapshotInterval, "snapshot-interval", cfg.SnapshotInterval, "how often to back up the catalog to the blob store for /admin/diff, 0 takes snapshots only on POST /admin/snapshots")
+	fs.DurationVar(&cfg.ChangeRetention, "change-retention", cfg.ChangeRetention, "drop updates older than this from the change log behind book history, ?as_of and /changes once a newer version follows, e.g. 2160h for 90 days; creates and deletes stay; 0 keeps every change")
+	fs.IntVar(&cfg.ChangeLogMax, "change-log-max", cfg.ChangeLogMax, "compact all but this many latest changes, 0 for no limit")
+	fs.BoolVar(&cfg.ChangeArchive, "change-archive", cfg.ChangeArchive, "archive the updates compaction drops to the blob store under changes/ first")
+	fs.DurationVar(&cfg.GCInterval, "gc-interval", cfg.GCInterval, "how often to delete covers and files no book uses and expired logins, 0 for never")
+	fs.StringSliceVar(&cfg.Tenants, "tenants", cfg.Tenants, "host names billed as tenants of their own in /admin/usage and matched by tenant_casing, requests to others go to the default tenant")
+	fs.StringSliceVar(&cfg.TrustedProxies, "trusted-proxies", cfg.TrustedProxies, "CIDRs of proxies whose X-Forwarded-For, X-Real-IP, X-Forwarded-Proto and X-Request-Id are trusted")
 }
@@ -0,0 +1,114 @@
+package dataHandler
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// diacritics folds accented Latin letters to their base letter, enough for author names
+// without pulling in a full Unicode normalization table
+var diacritics = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a', 'ă': 'a', 'ą': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c', 'ď': 'd', 'đ': 'd',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ė': 'e', 'ę': 'e', 'ě': 'e',
+	'ğ': 'g', 'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'į': 'i', 'ı': 'i',
+	'ł': 'l', 'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o', 'ő': 'o',
+	'ř': 'r', 'ś': 's', 'š': 's', 'ş': 's', 'ș': 's', 'ť': 't', 'ţ': 't', 'ț': 't',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u', 'ű': 'u', 'ų': 'u',
+	'ý': 'y', 'ÿ': 'y', 'ź': 'z', 'ż': 'z', 'ž': 'z', 'ß': 's',
+}
+
+// AuthorKey canonicalizes an author name: case folded, diacritics removed, punctuation dropped
+// and runs of initials joined, so "J. R. R. Tolkien", "JRR Tolkien" and "j.r.r. tolkien" share a key
+func AuthorKey(name string) string {
+	var words []string
+	var initials string
+	for _, w := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '.' || r == ',' || r == '-'
+	}) {
+		w = strings.Map(func(r rune) rune {
+			if f, ok := diacritics[r]; ok {
+				return f
+			}
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, w)
+		switch {
+		case len(w) == 0:
+		case len([]rune(w)) == 1:
+			initials += w
+		default:
+			if len(initials) != 0 {
+				words, initials = append(words, initials), ""
+			}
+			words = append(words, w)
+		}
+	}
+	if len(initials) != 0 {
+		words = append(words, initials)
+	}
+	return strings.Join(words, " ")
+}
+
+// AuthorAliases maps the keys of alternative spellings to the key of the canonical name,
+// for variants AuthorKey can't fold on its own such as pen names or "Tolkien, J.R.R."
+type AuthorAliases struct {
+	mu sync.RWMutex
+	m  map[string]string //alias key -> canonical key
+}
+
+func NewAuthorAliases() *AuthorAliases {
+	return &AuthorAliases{m: make(map[string]string)}
+}
+
+// Resolve returns the canonical key for an author name
+func (a *AuthorAliases) Resolve(name string) string {
+	key := AuthorKey(name)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if c, ok := a.m[key]; ok {
+		return c
+	}
+	return key
+}
+
+// Set makes alias resolve to canonical, chains are collapsed so lookups take one step
+func (a *AuthorAliases) Set(alias, canonical string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ak, ck := AuthorKey(alias), AuthorKey(canonical)
+	if c, ok := a.m[ck]; ok {
+		ck = c
+	}
+	if ak == ck {
+		delete(a.m, ak)
+		return
+	}
+	for k, v := range a.m {
+		if v == ak {
+			a.m[k] = ck
+		}
+	}
+	a.m[ak] = ck
+}
+
+func (a *AuthorAliases) Delete(alias string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.m, AuthorKey(alias))
+}
+
+// All returns a copy of the alias table
+func (a *AuthorAliases) All() map[string]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	out := make(map[string]string, len(a.m))
+	for k, v := range a.m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package dataHandler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/cacheHandler"
+)
+
+// CacheInvalidator is implemented by whatever keeps book data between requests. Invalidate is
+// called for every book change, made here or by another replica, with the book's ID and ISBN.
+type CacheInvalidator interface {
+	Invalidate(id string, isbns ...string)
+}
+
+// CachedStore answers GetBook from memory. Books are cached by ID, ISBN lookups go through a second
+// cache of ISBN to ID and only count when the cached book still has that ISBN. Writes through the store
+// drop the book; writes by other replicas must be passed to Invalidate, e.g. from book events.
+type CachedStore struct {
+	Store Store
+	Books *cacheHandler.Cache[Book]
+	ISBNs *cacheHandler.Cache[string]
+
+	gen atomic.Uint64 //bumped by Invalidate, a read that raced with it isn't cached
+}
+
+// NewCachedStore caches store's books within budget, evicting by policy ("lru" or "lfu")
+func NewCachedStore(store Store, policy string, budget *cacheHandler.Budget) (*CachedStore, error) {
+	books, err := cacheHandler.NewPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	isbns, _ := cacheHandler.NewPolicy(policy)
+	return &CachedStore{
+		Store: store,
+		Books: cacheHandler.New("books", books, budget, Book.size),
+		ISBNs: cacheHandler.New("book_isbns", isbns, budget, func(id string) int64 { return int64(len(id)) + 16 }),
+	}, nil
+}
+
+// size estimates the memory b takes, strings and the fixed part of the struct
+func (b Book) size() int64 {
+	n := 256 + len(b.ID) + len(b.Name) + len(b.ISBN) + len(b.Genre) + len(b.Pub) + len(b.Published) +
+		len(b.Language) + len(b.OriginalTitle) + len(b.Translator) + len(b.WorkID) + len(b.Format)
+	for _, a := range b.Authors {
+		n += 32 + len(a.Name) + len(a.Home)
+	}
+	if b.File != nil {
+		n += 64 + len(b.File.Key) + len(b.File.ContentType) + len(b.File.SHA256)
+	}
+	if b.Cover != nil {
+		n += 64 + len(b.Cover.Key) + len(b.Cover.ContentType) + len(b.Cover.SHA256)
+	}
+	return int64(n)
+}
+
+// Invalidate drops the book id and the mapping of each ISBN
+func (s *CachedStore) Invalidate(id string, isbns ...string) {
+	s.gen.Add(1)
+	s.Books.Delete(id)
+	for _, isbn := range isbns {
+		s.ISBNs.Delete(isbn)
+	}
+}
+
+// Prime caches the books load reads, e.g. to warm up at startup. Nothing is cached when a book
+// changed while load ran, since what it read may already be stale.
+func (s *CachedStore) Prime(ctx context.Context, load func(context.Context) ([]Book, error)) error {
+	gen := s.gen.Load()
+	books, err := load(ctx)
+	if err != nil || s.gen.Load() != gen {
+		return err
+	}
+	for _, b := range books {
+		s.Books.Set(b.ID, b)
+		if len(b.ISBN) != 0 {
+			s.ISBNs.Set(b.ISBN, b.ID)
+		}
+	}
+	return nil
+}
+
+func (s *CachedStore) GetBook(ctx context.Context, ref string) (Book, error) {
+	if b, ok := s.Books.Get(ref); ok {
+		return b, nil
+	}
+	if id, ok := s.ISBNs.Get(ref); ok {
+		if b, ok := s.Books.Get(id); ok && b.ISBN == ref {
+			return b, nil
+		}
+	}
+	gen := s.gen.Load()
+	b, err := s.Store.GetBook(ctx, ref)
+	if err != nil || s.gen.Load() != gen {
+		return b, err
+	}
+	s.Books.Set(b.ID, b)
+	if len(b.ISBN) != 0 {
+		s.ISBNs.Set(b.ISBN, b.ID)
+	}
+	return b, nil
+}
+
+func (s *CachedStore) ListBooks(ctx context.Context) ([]Book, error) {
+	return s.Store.ListBooks(ctx)
+}
+
+func (s *CachedStore) GetBooksByISBNs(ctx context.Context, isbns []string) (map[string]Book, error) {
+	return s.Store.GetBooksByISBNs(ctx, isbns)
+}
+
+func (s *CachedStore) ListBooksUpdatedAfter(ctx context.Context, t time.Time) ([]Book, error) {
+	return s.Store.ListBooksUpdatedAfter(ctx, t)
+}
+
+func (s *CachedStore) CreateBook(ctx context.Context, book Book) error {
+	return s.Store.CreateBook(ctx, book) //a failed GetBook is never cached, nothing to drop
+}
+
+func (s *CachedStore) UpdateBook(ctx context.Context, book Book) error {
+	defer s.Invalidate(book.ID, book.ISBN)
+	return s.Store.UpdateBook(ctx, book)
+}
+
+func (s *CachedStore) DeleteBook(ctx context.Context, ref string) error {
+	if id, ok := s.ISBNs.Get(ref); ok {
+		defer s.Invalidate(id)
+	}
+	defer s.Invalidate(ref, ref)
+	return s.Store.DeleteBook(ctx, ref)
+}
+
+func (s *CachedStore) FindBooks(ctx context.Context, match func(Book) bool) ([]Book, error) {
+	return FindBooks(ctx, s.Store, match)
+}
+
+func (s *CachedStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	log, ok := s.Store.(ChangeLog)
+	if !ok {
+		return nil, ErrNoChangeLog
+	}
+	return log.Changes(ctx, after, limit)
+}
+
+func (s *CachedStore) CompactChanges(ctx context.Context, upTo int64) (int, error) {
+	return CompactChanges(ctx, s.Store, upTo)
+}
+
+// Tx runs fn on the uncached store, so no write it may undo gets cached, and drops every book it
+// wrote once it is over, committed or not
+func (s *CachedStore) Tx(ctx context.Context, fn func(tx Store) error) error {
+	var refs []string
+	defer func() {
+		for _, ref := range refs {
+			if id, ok := s.ISBNs.Get(ref); ok {
+				s.Invalidate(id)
+			}
+			s.Invalidate(ref, ref)
+		}
+	}()
+	return InTx(ctx, s.Store, func(tx Store) error {
+		return fn(&txRefs{Store: tx, refs: &refs})
+	})
+}
+
+// txRefs notes the IDs, ISBNs and refs written through Store
+type txRefs struct {
+	Store
+	refs *[]string
+}
+
+func (t *txRefs) CreateBook(ctx context.Context, book Book) error {
+	*t.refs = append(*t.refs, book.ID, book.ISBN)
+	return t.Store.CreateBook(ctx, book)
+}
+
+func (t *txRefs) UpdateBook(ctx context.Context, book Book) error {
+	*t.refs = append(*t.refs, book.ID, book.ISBN)
+	return t.Store.UpdateBook(ctx, book)
+}
+
+func (t *txRefs) DeleteBook(ctx context.Context, ref string) error {
+	*t.refs = append(*t.refs, ref)
+	return t.Store.DeleteBook(ctx, ref)
+}
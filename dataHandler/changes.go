@@ -0,0 +1,226 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// change operations recorded in the change log
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+type Change struct { //one write to the book store, Seq increases by one per write
+	Seq  int64
+	Op   string
+	ID   string
+	ISBN string
+	Book *Book         //state after the change, nil for deletes
+	Diff []FieldChange //what an update changed, nil for creates and deletes
+	At   time.Time     //when it was committed
+}
+
+// ChangeLog is implemented by stores that keep an ordered log of their writes,
+// so clients can replicate the catalog by replaying changes after the last Seq they saw
+type ChangeLog interface {
+	Changes(ctx context.Context, after int64, limit int) ([]Change, error)
+}
+
+// record appends a change, callers hold s.idx
+func (s *MemStore) record(op, id, isbn string, book *Book, diff []FieldChange) {
+	s.lastSeq++
+	s.changes = append(s.changes, Change{Seq: s.lastSeq, Op: op, ID: id, ISBN: isbn, Book: book, Diff: diff, At: s.Clock.Now()})
+}
+
+const changesPerRead = 1000 //changes EachChange asks the log for at once
+
+var errStop = errors.New("stop")
+
+// EachChange calls fn with every change of store's log after the sequence number after, oldest
+// first, and stops at the first error fn returns
+func EachChange(ctx context.Context, store Store, after int64, fn func(c Change) error) error {
+	log, ok := store.(ChangeLog)
+	if !ok {
+		return ErrNoChangeLog
+	}
+	for {
+		changes, err := log.Changes(ctx, after, changesPerRead)
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		for _, c := range changes {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		after = changes[len(changes)-1].Seq
+	}
+}
+
+// ChangesFrom finds where in store's log the changes committed at or after t start: the Seq to pass
+// EachChange as after, 0 when the whole log is. It asks the log for one change at a time, galloping
+// then bisecting over sequence numbers, so it takes a few dozen reads even for a long log.
+func ChangesFrom(ctx context.Context, store Store, t time.Time) (int64, error) {
+	log, ok := store.(ChangeLog)
+	if !ok {
+		return 0, ErrNoChangeLog
+	}
+	before := func(after int64) (int64, bool, error) { //the Seq of the first change after after, if it came before t
+		changes, err := log.Changes(ctx, after, 1)
+		if err != nil || len(changes) == 0 || !changes[0].At.Before(t) {
+			return 0, false, err
+		}
+		return changes[0].Seq, true, nil
+	}
+	var last int64 //the latest change known to come before t
+	step := int64(1)
+	for ; ; step *= 2 {
+		seq, ok, err := before(last + step - 1)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		last = seq
+	}
+	lo, hi := last, last+step-1 //every change after hi is at or after t
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		seq, ok, err := before(mid - 1)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = seq
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
+
+// History returns every change to the book ref names, oldest first: the book with that ID or ISBN,
+// or when there is none anymore, the last book that had it. ErrBookNotFound when the log has none.
+func History(ctx context.Context, store Store, ref string) ([]Change, error) {
+	var id string
+	if b, err := store.GetBook(ctx, ref); err == nil {
+		id = b.ID
+	} else if !errors.Is(err, ErrBookNotFound) {
+		return nil, err
+	} else {
+		//one scan finds the last book ref named and collects its changes from the first that
+		//named it; only a book that got ref after its first change needs a second scan
+		known := make(map[string]bool)
+		found := make(map[string][]Change)
+		complete := make(map[string]bool)
+		if err := EachChange(ctx, store, 0, func(c Change) error {
+			if _, ok := found[c.ID]; !ok && (c.ID == ref || c.ISBN == ref) {
+				found[c.ID], complete[c.ID] = nil, !known[c.ID]
+			}
+			if _, ok := found[c.ID]; ok {
+				found[c.ID] = append(found[c.ID], c)
+				if c.ID == ref || c.ISBN == ref {
+					id = c.ID
+				}
+			}
+			known[c.ID] = true
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if len(id) == 0 {
+			return nil, ErrBookNotFound
+		}
+		if complete[id] {
+			return found[id], nil
+		}
+	}
+	var history []Change
+	err := EachChange(ctx, store, 0, func(c Change) error {
+		if c.ID == id {
+			history = append(history, c)
+		}
+		return nil
+	})
+	if err == nil && len(history) == 0 {
+		err = ErrBookNotFound
+	}
+	return history, err
+}
+
+// BooksAsOf replays the change log up to t and returns the books that existed then, by ISBN like
+// ListBooks
+func BooksAsOf(ctx context.Context, store Store, t time.Time) ([]Book, error) {
+	books := make(map[string]Book)
+	err := EachChange(ctx, store, 0, func(c Change) error {
+		if c.At.After(t) {
+			return errStop //the log is in commit order
+		}
+		if c.Book == nil {
+			delete(books, c.ID)
+		} else {
+			books[c.ID] = *c.Book
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	list := make([]Book, 0, len(books))
+	for _, b := range books {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ISBN < list[j].ISBN })
+	return list, nil
+}
+
+// snapshotTries is how often a scan of every book runs while writes land during it, before it
+// holds commits back to get a consistent view
+const snapshotTries = 3
+
+// seq is the sequence number of the latest change
+func (s *MemStore) seq() int64 {
+	s.idx.Lock()
+	defer s.idx.Unlock()
+	return s.lastSeq
+}
+
+// consistently runs scan, which reads books without s.idx, until no write committed while it ran,
+// so it saw the catalog as of one change. Every commit records a change, see swap; scans that keep
+// racing writes run with commits held back. Callers hold s.mu.
+func (s *MemStore) consistently(scan func()) {
+	for range snapshotTries {
+		before := s.seq()
+		if scan(); s.seq() == before {
+			return
+		}
+	}
+	s.idx.Lock()
+	defer s.idx.Unlock()
+	scan()
+}
+
+// Changes holds s.mu for reading like CompactChanges, so it waits for transactions: their changes
+// aren't committed before they return, and a rollback gives their Seqs to later writes
+func (s *MemStore) Changes(_ context.Context, after int64, limit int) ([]Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.idx.Lock()
+	defer s.idx.Unlock()
+
+	start := sort.Search(len(s.changes), func(i int) bool { return s.changes[i].Seq > after })
+	end := min(start+max(limit, 0), len(s.changes))
+	return append([]Change{}, s.changes[start:end]...), nil
+}
+
+func (s *ReplicaStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	if log, ok := s.Primary.(ChangeLog); ok {
+		return log.Changes(ctx, after, limit) //sequence numbers are only meaningful on the primary
+	}
+	return nil, ErrNoChangeLog
+}
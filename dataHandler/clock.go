@@ -0,0 +1,65 @@
+package dataHandler
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the time source for token expiry and timestamps, swap it for a fixed clock to replay or test
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+type FixedClock struct { //clock that only moves when told to
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewFixedClock(t time.Time) *FixedClock {
+	return &FixedClock{t: t}
+}
+
+func (c *FixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *FixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// IDGenerator hands out unique IDs for requests, jobs and records
+type IDGenerator interface {
+	NewID() string
+}
+
+type UUIDGenerator struct{} //random version 4 UUIDs
+
+func (UUIDGenerator) NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err) //crypto/rand never fails on supported platforms
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+type SeqGenerator struct { //deterministic IDs: prefix-1, prefix-2, ...
+	Prefix string
+	n      atomic.Uint64
+}
+
+func (g *SeqGenerator) NewID() string {
+	return fmt.Sprintf("%s-%d", g.Prefix, g.n.Add(1))
+}
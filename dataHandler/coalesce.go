@@ -0,0 +1,77 @@
+package dataHandler
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/cacheHandler"
+)
+
+// CoalescingStore lets concurrent GetBook calls for the same ref share one call to Store, so a burst
+// of requests for a popular book reaches the database once. The shared call runs without the first
+// caller's cancellation, a client going away doesn't fail the others; bound it with a DeadlineStore below.
+type CoalescingStore struct {
+	Store  Store
+	Shared func() //called for every coalesced call, may be nil
+
+	books cacheHandler.Group[Book]
+}
+
+func NewCoalescingStore(store Store, shared func()) *CoalescingStore {
+	return &CoalescingStore{Store: store, Shared: shared}
+}
+
+func (s *CoalescingStore) GetBook(ctx context.Context, ref string) (Book, error) {
+	b, err, shared := s.books.Do(ref, func() (Book, error) {
+		return s.Store.GetBook(context.WithoutCancel(ctx), ref)
+	})
+	if shared && s.Shared != nil {
+		s.Shared()
+	}
+	return b, err
+}
+
+func (s *CoalescingStore) ListBooks(ctx context.Context) ([]Book, error) {
+	return s.Store.ListBooks(ctx) //callers filter the slice in place, it can't be shared
+}
+
+func (s *CoalescingStore) GetBooksByISBNs(ctx context.Context, isbns []string) (map[string]Book, error) {
+	return s.Store.GetBooksByISBNs(ctx, isbns)
+}
+
+func (s *CoalescingStore) ListBooksUpdatedAfter(ctx context.Context, t time.Time) ([]Book, error) {
+	return s.Store.ListBooksUpdatedAfter(ctx, t)
+}
+
+func (s *CoalescingStore) CreateBook(ctx context.Context, book Book) error {
+	return s.Store.CreateBook(ctx, book)
+}
+
+func (s *CoalescingStore) UpdateBook(ctx context.Context, book Book) error {
+	return s.Store.UpdateBook(ctx, book)
+}
+
+func (s *CoalescingStore) DeleteBook(ctx context.Context, ref string) error {
+	return s.Store.DeleteBook(ctx, ref)
+}
+
+func (s *CoalescingStore) FindBooks(ctx context.Context, match func(Book) bool) ([]Book, error) {
+	return FindBooks(ctx, s.Store, match)
+}
+
+func (s *CoalescingStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	log, ok := s.Store.(ChangeLog)
+	if !ok {
+		return nil, ErrNoChangeLog
+	}
+	return log.Changes(ctx, after, limit)
+}
+
+func (s *CoalescingStore) CompactChanges(ctx context.Context, upTo int64) (int, error) {
+	return CompactChanges(ctx, s.Store, upTo)
+}
+
+// Tx reads aren't coalesced, they see writes no other caller may share yet
+func (s *CoalescingStore) Tx(ctx context.Context, fn func(tx Store) error) error {
+	return InTx(ctx, s.Store, fn)
+}
@@ -0,0 +1,91 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// ErrNoCompaction is returned by change logs that can't drop old changes
+var ErrNoCompaction = errors.New("change log can't be compacted")
+
+// ChangeCompactor is implemented by change logs that can drop old changes, so the log doesn't grow
+// without bound
+type ChangeCompactor interface {
+	// CompactChanges drops the changes up to Seq upTo that Superseded returns and reports how many
+	// it dropped. Sequence numbers don't change, the log skips the dropped ones.
+	CompactChanges(ctx context.Context, upTo int64) (int, error)
+}
+
+// CompactChanges compacts store's change log up to upTo, ErrNoCompaction when it can't
+func CompactChanges(ctx context.Context, store Store, upTo int64) (int, error) {
+	c, ok := store.(ChangeCompactor)
+	if !ok {
+		return 0, ErrNoCompaction
+	}
+	return c.CompactChanges(ctx, upTo)
+}
+
+// superseded marks the changes, oldest first and all up to the compaction point, that compaction
+// drops: updates a later change of the same book follows. Creates and deletes stay, so consumers
+// of the log behind the compaction point still learn of every book and every deletion, and the
+// catalog reports still find their acquisitions. Every change carries the whole book, so replaying
+// the rest gives the catalog as it was and BooksAsOf stays right from that point on; History and
+// webhooks lose the versions and diffs in between.
+func superseded(changes []Change) []bool {
+	drop := make([]bool, len(changes))
+	seen := make(map[string]bool)
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		drop[i] = seen[c.ID] && c.Op == OpUpdate
+		seen[c.ID] = true
+	}
+	return drop
+}
+
+// Superseded returns the changes compacting store's log up to upTo would drop, oldest first, e.g.
+// to archive them beforehand
+func Superseded(ctx context.Context, store Store, upTo int64) ([]Change, error) {
+	var changes []Change
+	err := EachChange(ctx, store, 0, func(c Change) error {
+		if c.Seq > upTo {
+			return errStop
+		}
+		changes = append(changes, c)
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	var dropped []Change
+	for i, drop := range superseded(changes) {
+		if drop {
+			dropped = append(dropped, changes[i])
+		}
+	}
+	return dropped, nil
+}
+
+// CompactChanges holds s.mu for reading, which keeps transactions out: a rollback truncates the log
+func (s *MemStore) CompactChanges(_ context.Context, upTo int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.idx.Lock()
+	defer s.idx.Unlock()
+
+	n := sort.Search(len(s.changes), func(i int) bool { return s.changes[i].Seq > upTo })
+	drop := superseded(s.changes[:n])
+	kept := make([]Change, 0, len(s.changes))
+	for i, c := range s.changes {
+		if i >= n || !drop[i] {
+			kept = append(kept, c)
+		}
+	}
+	dropped := len(s.changes) - len(kept)
+	s.changes = kept
+	return dropped, nil
+}
+
+func (s *ReplicaStore) CompactChanges(ctx context.Context, upTo int64) (int, error) {
+	return CompactChanges(ctx, s.Primary, upTo)
+}
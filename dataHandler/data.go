@@ -1,6 +1,11 @@
 package dataHandler
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
+
+const DateLayout = "2006-01-02" //layout of publication dates
 
 type Author struct { //Hold common information of an Aurhor
 	Name string `json:"name"`
@@ -13,32 +18,74 @@ type Author struct { //Hold common information of an Aurhor
 }*/
 
 type Book struct { // Information about book
-	Name    string   `json:"name"`
-	Authors []Author `json:"authors"`
-	ISBN    string   `json:"isbn"`
-	Genre   string   `json:"genre"`
-	Pub     string   `json:"pub"`
+	ID        string   `json:"id"` // internal identifier, every book has one even without an ISBN
+	Name      string   `json:"name"`
+	Authors   []Author `json:"authors"`
+	ISBN      string   `json:"isbn,omitempty"` // optional, unique when set
+	Genre     string   `json:"genre"`
+	Pub       string   `json:"pub"`                 // publisher
+	Published string   `json:"published,omitempty"` // publication date, YYYY-MM-DD
+
+	Language      string `json:"language,omitempty"`       // ISO 639-1 code, e.g. "en", "bn"
+	OriginalTitle string `json:"original_title,omitempty"` // title in the original language for translated books
+	Translator    string `json:"translator,omitempty"`
+
+	WorkID string `json:"work_id,omitempty"` // editions of the same work share it, empty for a lone edition
+	Format string `json:"format,omitempty"`  // one of Formats
+
+	File  *BookFile  `json:"file,omitempty"`  // attached e-book
+	Cover *BookCover `json:"cover,omitempty"` // original cover image, resized variants are derived from its checksum
+
+	UpdatedAt time.Time `json:"updated_at"` // last create or update, set by the handlers
+	Version   int64     `json:"version"`    // 1 when created, one more on every update, see Touch
 }
 
 type Credentials struct { //Login credentials
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
+
+type BookCover struct { // cover image, the bytes live in the blob store
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	SHA256      string `json:"sha256"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+type BookFile struct { // e-book attached to a book, the bytes live in the blob store
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256,omitempty"` // hex, also part of the key since identical files share a blob
+}
+
+// formats an edition comes in
+const (
+	FormatHardcover = "hardcover"
+	FormatPaperback = "paperback"
+	FormatEbook     = "ebook"
+	FormatAudiobook = "audiobook"
+)
+
+var Formats = map[string]bool{FormatHardcover: true, FormatPaperback: true, FormatEbook: true, FormatAudiobook: true}
+
 type AuthorDB map[string]Author
-type BookDB map[string]Book
-type CredentialDB map[string]string
+type BookDB map[string]Book         // by ID
+type CredentialDB map[string]string // username -> password hash
 
-var BookList BookDB
-var CredentialList CredentialDB
 var authorList AuthorDB
 
-func Init() { //initializing data for book server
+func Init() *MemStore { //initializing data for book server
+	store := NewMemStore()
 
-	CredentialList = make(CredentialDB)
-	BookList = make(BookDB)
-
-	CredentialList["sabnaj"] = "1234"
-	CredentialList["Admin"] = "5678"
+	for username, password := range map[string]string{"sabnaj": "1234", "Admin": "5678"} {
+		hash, err := HashPassword(password)
+		if err != nil {
+			panic(err)
+		}
+		store.users[username] = hash
+	}
 
 	author1 := Author{
 		Name: "Sadia Sornaly",
@@ -50,27 +97,61 @@ func Init() { //initializing data for book server
 	}
 
 	book1 := Book{
-		Name:    "Book 1",
-		Authors: []Author{author1, author2},
-		ISBN:    "ISBN 1",
-		Genre:   "Thriller",
-		Pub:     "Unknown",
+		Name:      "Book 1",
+		Authors:   []Author{author1, author2},
+		ISBN:      "ISBN 1",
+		Genre:     "Thriller",
+		Pub:       "Unknown",
+		Language:  "en",
+		Published: "2019-03-14",
 	}
 	book2 := Book{
-		Name:    "Book 2",
-		Authors: []Author{author1},
-		ISBN:    "ISBN 2",
-		Genre:   "Science Fiction",
-		Pub:     "Tor Books",
+		Name:      "Book 2",
+		Authors:   []Author{author1},
+		ISBN:      "ISBN 2",
+		Genre:     "Science Fiction",
+		Pub:       "Tor Books",
+		Language:  "en",
+		Published: "2021-09-07",
 	}
 	//authorList[author1.Name] = author1
 	//authorList[author2.Name] = author2
 
-	BookList[book1.ISBN] = book1
-	BookList[book2.ISBN] = book2
+	now := time.Now()
+	for _, b := range []Book{book1, book2} {
+		b.ID = UUIDGenerator{}.NewID()
+		b.Touch(now)
+		store.swap(OpCreate, nil, &b)
+	}
+
+	return store
+}
 
+// Touch marks b as written at now and moves it to the next version, call it once before every
+// CreateBook or UpdateBook. Stores only accept an update whose version follows the stored one.
+func (b *Book) Touch(now time.Time) {
+	b.UpdatedAt = now
+	b.Version++
 }
 
 func SmStr(str string) string { //convert string into small letter
 	return strings.ToLower(str)
 }
+
+func ParseDate(str string) (time.Time, error) { //parse a YYYY-MM-DD date
+	return time.Parse(DateLayout, strings.TrimSpace(str))
+}
+
+func (b Book) Matches(search string) bool { //case insensitive match of a lower case search on title, authors and ISBN
+	if strings.Contains(SmStr(b.Name), search) ||
+		strings.Contains(SmStr(b.OriginalTitle), search) ||
+		strings.Contains(SmStr(b.ISBN), search) {
+		return true
+	}
+	for _, a := range b.Authors {
+		if strings.Contains(SmStr(a.Name), search) {
+			return true
+		}
+	}
+	return false
+}
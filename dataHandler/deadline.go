@@ -0,0 +1,90 @@
+package dataHandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeadlineStore bounds every call to the book Store, so a hung database fails the request with an
+// error wrapping context.DeadlineExceeded instead of holding the handler forever. The shelf, review
+// and notification stores aren't bounded. A write that timed out may still land once the database recovers.
+type DeadlineStore struct {
+	Store Store
+	Read  time.Duration //limit of ListBooks, GetBook, GetBooksByISBNs, ListBooksUpdatedAfter, FindBooks and Changes, 0 for none
+	Write time.Duration //limit of CreateBook, UpdateBook and DeleteBook, 0 for none
+}
+
+func NewDeadlineStore(store Store, read, write time.Duration) *DeadlineStore {
+	return &DeadlineStore{Store: store, Read: read, Write: write}
+}
+
+// bounded runs call with a context ending after d. The backends honor it, so a hung database gives
+// up when the deadline passes and no call is left running behind the caller; whatever error a call
+// cut short returns, e.g. a network timeout, is reported as the deadline.
+func bounded[T any](ctx context.Context, d time.Duration, call func(context.Context) (T, error)) (T, error) {
+	if d <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	v, err := call(ctx)
+	if err != nil && ctx.Err() != nil {
+		return v, fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return v, err
+}
+
+func (s *DeadlineStore) ListBooks(ctx context.Context) ([]Book, error) {
+	return bounded(ctx, s.Read, s.Store.ListBooks)
+}
+
+func (s *DeadlineStore) GetBook(ctx context.Context, ref string) (Book, error) {
+	return bounded(ctx, s.Read, func(ctx context.Context) (Book, error) { return s.Store.GetBook(ctx, ref) })
+}
+
+func (s *DeadlineStore) GetBooksByISBNs(ctx context.Context, isbns []string) (map[string]Book, error) {
+	return bounded(ctx, s.Read, func(ctx context.Context) (map[string]Book, error) { return s.Store.GetBooksByISBNs(ctx, isbns) })
+}
+
+func (s *DeadlineStore) ListBooksUpdatedAfter(ctx context.Context, t time.Time) ([]Book, error) {
+	return bounded(ctx, s.Read, func(ctx context.Context) ([]Book, error) { return s.Store.ListBooksUpdatedAfter(ctx, t) })
+}
+
+func (s *DeadlineStore) CreateBook(ctx context.Context, book Book) error {
+	_, err := bounded(ctx, s.Write, func(ctx context.Context) (struct{}, error) { return struct{}{}, s.Store.CreateBook(ctx, book) })
+	return err
+}
+
+func (s *DeadlineStore) UpdateBook(ctx context.Context, book Book) error {
+	_, err := bounded(ctx, s.Write, func(ctx context.Context) (struct{}, error) { return struct{}{}, s.Store.UpdateBook(ctx, book) })
+	return err
+}
+
+func (s *DeadlineStore) DeleteBook(ctx context.Context, ref string) error {
+	_, err := bounded(ctx, s.Write, func(ctx context.Context) (struct{}, error) { return struct{}{}, s.Store.DeleteBook(ctx, ref) })
+	return err
+}
+
+func (s *DeadlineStore) FindBooks(ctx context.Context, match func(Book) bool) ([]Book, error) {
+	return bounded(ctx, s.Read, func(ctx context.Context) ([]Book, error) { return FindBooks(ctx, s.Store, match) })
+}
+
+func (s *DeadlineStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	log, ok := s.Store.(ChangeLog)
+	if !ok {
+		return nil, ErrNoChangeLog
+	}
+	return bounded(ctx, s.Read, func(ctx context.Context) ([]Change, error) { return log.Changes(ctx, after, limit) })
+}
+
+func (s *DeadlineStore) CompactChanges(ctx context.Context, upTo int64) (int, error) {
+	return bounded(ctx, s.Write, func(ctx context.Context) (int, error) { return CompactChanges(ctx, s.Store, upTo) })
+}
+
+// Tx isn't bounded: giving up on a transaction can't stop its writes landing after the rollback.
+// Bound it through ctx, which the backend honors.
+func (s *DeadlineStore) Tx(ctx context.Context, fn func(tx Store) error) error {
+	return InTx(ctx, s.Store, fn)
+}
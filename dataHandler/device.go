@@ -0,0 +1,85 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// platforms devices receive pushes on
+const (
+	PlatformWebPush = "webpush" //a browser's push subscription
+	PlatformFCM     = "fcm"     //an Android or iOS app registered with Firebase Cloud Messaging
+)
+
+type Device struct { //where a user gets push notifications
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Platform  string    `json:"platform"`
+	Name      string    `json:"name,omitempty"`     //for the user to tell their devices apart, e.g. "Firefox on my laptop"
+	Endpoint  string    `json:"endpoint,omitempty"` //Web Push subscription endpoint
+	P256dh    string    `json:"p256dh,omitempty"`   //Web Push subscription keys, base64url
+	Auth      string    `json:"auth,omitempty"`
+	Token     string    `json:"token,omitempty"` //FCM registration token
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// address is what the push service knows the device by
+func (d Device) address() string {
+	if d.Platform == PlatformFCM {
+		return d.Token
+	}
+	return d.Endpoint
+}
+
+// DeviceStore keeps the devices users registered for push notifications
+type DeviceStore interface {
+	// PutDevice registers d. A device registered before under the same endpoint or token is
+	// replaced and keeps its ID, also when another user logged in on it since; the device in effect
+	// is returned.
+	PutDevice(ctx context.Context, d Device) (Device, error)
+	ListDevices(ctx context.Context, username string) ([]Device, error) //oldest first
+	DeleteDevice(ctx context.Context, username, id string) error        //ErrDeviceNotFound
+}
+
+func (s *MemStore) PutDevice(_ context.Context, d Device) (Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cur := range s.devices {
+		if cur.Platform == d.Platform && cur.address() == d.address() {
+			d.ID, d.CreatedAt = cur.ID, cur.CreatedAt
+			break
+		}
+	}
+	s.devices[d.ID] = d
+	return d, nil
+}
+
+func (s *MemStore) ListDevices(_ context.Context, username string) ([]Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	devices := []Device{}
+	for _, d := range s.devices {
+		if d.Username == username {
+			devices = append(devices, d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].CreatedAt.Before(devices[j].CreatedAt) })
+	return devices, nil
+}
+
+func (s *MemStore) DeleteDevice(_ context.Context, username, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.devices[id]; !ok || d.Username != username {
+		return ErrDeviceNotFound
+	}
+	delete(s.devices, id)
+	return nil
+}
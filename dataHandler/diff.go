@@ -0,0 +1,50 @@
+package dataHandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// FieldChange is one field an update changed, named as in the book's JSON, with its JSON values
+// before and after; null when the field was or became empty
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old"`
+	New   json.RawMessage `json:"new"`
+}
+
+var jsonNull = json.RawMessage("null")
+
+// Diff lists the fields that differ between old and b, by field name
+func Diff(old, b Book) []FieldChange {
+	before, after := bookFields(old), bookFields(b)
+	var diff []FieldChange
+	for f, v := range after {
+		if o, ok := before[f]; !ok || !bytes.Equal(o, v) {
+			diff = append(diff, FieldChange{Field: f, Old: orNull(o), New: v})
+		}
+	}
+	for f, o := range before {
+		if _, ok := after[f]; !ok {
+			diff = append(diff, FieldChange{Field: f, Old: o, New: jsonNull})
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Field < diff[j].Field })
+	return diff
+}
+
+// bookFields is b's JSON by field, fields left out of it are missing
+func bookFields(b Book) map[string]json.RawMessage {
+	raw, _ := json.Marshal(b) //only strings, numbers and times
+	var fields map[string]json.RawMessage
+	json.Unmarshal(raw, &fields)
+	return fields
+}
+
+func orNull(v json.RawMessage) json.RawMessage {
+	if v == nil {
+		return jsonNull
+	}
+	return v
+}
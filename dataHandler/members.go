@@ -0,0 +1,279 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrForbidden means the user is a member of the list but their role doesn't allow the change
+	ErrForbidden          = errors.New("not allowed on this list")
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+// ListRole is what a member may do with a shared shelf, each role allows what the ones below it do
+type ListRole string
+
+const (
+	RoleOwner  ListRole = "owner"  //the user whose shelf it is: invites and removes members
+	RoleEditor ListRole = "editor" //adds and removes books
+	RoleViewer ListRole = "viewer" //reads the list
+)
+
+var roleRanks = map[ListRole]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Allows reports whether r may do what need may, the empty role allows nothing
+func (r ListRole) Allows(need ListRole) bool {
+	return roleRanks[r] > 0 && roleRanks[r] >= roleRanks[need]
+}
+
+type ListMember struct { //someone invited to another user's shelf
+	Owner    string    `json:"owner"`
+	Shelf    string    `json:"shelf"`
+	Username string    `json:"username"`
+	Role     ListRole  `json:"role"`
+	Since    time.Time `json:"since"`
+}
+
+type Invitation struct { //a pending ListMember, until the invited user accepts
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Shelf     string    `json:"shelf"`
+	Username  string    `json:"username"` //who is invited
+	Role      ListRole  `json:"role"`     //editor or viewer
+	InvitedAt time.Time `json:"invited_at"`
+}
+
+// ListStore shares shelves with other users. Every call names the acting user and checks their
+// role itself: users without any role on the list get ErrShelfNotFound, so lists stay hidden from
+// strangers, members whose role is too low get ErrForbidden.
+type ListStore interface {
+	ListRole(ctx context.Context, actor, owner, shelf string) (ListRole, error)
+	ListEntries(ctx context.Context, actor, owner, shelf string) ([]ShelfEntry, error) //viewers and up
+	PutListEntry(ctx context.Context, actor, owner string, e ShelfEntry) error         //editors and up, of the shelf the book is on too; e.Shelf names the list
+	DeleteListEntry(ctx context.Context, actor, owner, shelf, isbn string) error       //editors and up
+	ListMembers(ctx context.Context, actor, owner, shelf string) ([]ListMember, error) //viewers and up
+	RemoveMember(ctx context.Context, actor, owner, shelf, username string) error      //the owner, or members leaving
+	Invite(ctx context.Context, actor string, inv Invitation) error                    //the owner; unknown users are invited to nothing, without an error
+	ListInvitations(ctx context.Context, username string) ([]Invitation, error)        //pending for username
+	AcceptInvitation(ctx context.Context, username, id string) (ListMember, error)     //ErrInvitationNotFound
+	DeclineInvitation(ctx context.Context, username, id string) error                  //ErrInvitationNotFound
+	ListMemberships(ctx context.Context, username string) ([]ListMember, error)        //other users' lists username is on
+}
+
+type listKey struct{ owner, shelf string }
+
+// role is actor's role on owner's shelf, the caller holds s.mu
+func (s *MemStore) role(actor, owner, shelf string) ListRole {
+	if !Shelves[shelf] {
+		return ""
+	}
+	if actor == owner {
+		return RoleOwner
+	}
+	return s.members[listKey{owner, shelf}][actor].Role
+}
+
+// allow checks actor may do what need may on owner's shelf, the caller holds s.mu
+func (s *MemStore) allow(actor, owner, shelf string, need ListRole) error {
+	switch r := s.role(actor, owner, shelf); {
+	case len(r) == 0:
+		return ErrShelfNotFound
+	case !r.Allows(need):
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *MemStore) ListRole(_ context.Context, actor, owner, shelf string) (ListRole, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.allow(actor, owner, shelf, RoleViewer); err != nil {
+		return "", err
+	}
+	return s.role(actor, owner, shelf), nil
+}
+
+func (s *MemStore) ListEntries(_ context.Context, actor, owner, shelf string) ([]ShelfEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.allow(actor, owner, shelf, RoleViewer); err != nil {
+		return nil, err
+	}
+	entries := []ShelfEntry{}
+	for _, e := range s.shelves[owner] {
+		if e.Shelf == shelf {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ISBN < entries[j].ISBN })
+	return entries, nil
+}
+
+func (s *MemStore) PutListEntry(_ context.Context, actor, owner string, e ShelfEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.allow(actor, owner, e.Shelf, RoleEditor); err != nil {
+		return err
+	}
+	if _, ok := s.isbnID(e.ISBN); !ok {
+		return ErrBookNotFound
+	}
+	if cur, ok := s.shelves[owner][e.ISBN]; ok && cur.Shelf != e.Shelf {
+		//shelves are exclusive, this moves the book off another one: an editor of this shelf
+		//alone mustn't take books off the owner's others
+		if err := s.allow(actor, owner, cur.Shelf, RoleEditor); err != nil {
+			return ErrForbidden
+		}
+	}
+	if s.shelves[owner] == nil {
+		s.shelves[owner] = make(map[string]ShelfEntry)
+	}
+	s.shelves[owner][e.ISBN] = e
+	return nil
+}
+
+func (s *MemStore) DeleteListEntry(_ context.Context, actor, owner, shelf, isbn string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.allow(actor, owner, shelf, RoleEditor); err != nil {
+		return err
+	}
+	if e, ok := s.shelves[owner][isbn]; !ok || e.Shelf != shelf {
+		return ErrBookNotFound
+	}
+	delete(s.shelves[owner], isbn)
+	return nil
+}
+
+func (s *MemStore) ListMembers(_ context.Context, actor, owner, shelf string) ([]ListMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.allow(actor, owner, shelf, RoleViewer); err != nil {
+		return nil, err
+	}
+	members := []ListMember{}
+	for _, m := range s.members[listKey{owner, shelf}] {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+	return members, nil
+}
+
+func (s *MemStore) RemoveMember(_ context.Context, actor, owner, shelf, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if actor != username { //anyone may leave, only the owner removes others
+		if err := s.allow(actor, owner, shelf, RoleOwner); err != nil {
+			return err
+		}
+	}
+	key := listKey{owner, shelf}
+	if _, ok := s.members[key][username]; !ok {
+		return ErrUserNotFound
+	}
+	delete(s.members[key], username)
+	if len(s.members[key]) == 0 {
+		delete(s.members, key)
+	}
+	return nil
+}
+
+func (s *MemStore) Invite(_ context.Context, actor string, inv Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.allow(actor, inv.Owner, inv.Shelf, RoleOwner); err != nil {
+		return err
+	}
+	if inv.Role != RoleEditor && inv.Role != RoleViewer { //there is one owner
+		return ErrForbidden
+	}
+	if inv.Username == inv.Owner {
+		return ErrUserExists
+	}
+	if _, ok := s.users[inv.Username]; !ok {
+		return nil //answered like an invitation, so owners can't probe for user names
+	}
+	for id, cur := range s.invites { //a new invitation replaces a pending one, e.g. to change the role
+		if cur.Owner == inv.Owner && cur.Shelf == inv.Shelf && cur.Username == inv.Username {
+			delete(s.invites, id)
+		}
+	}
+	s.invites[inv.ID] = inv
+	return nil
+}
+
+func (s *MemStore) ListInvitations(_ context.Context, username string) ([]Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	invites := []Invitation{}
+	for _, inv := range s.invites {
+		if inv.Username == username {
+			invites = append(invites, inv)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].InvitedAt.Before(invites[j].InvitedAt) })
+	return invites, nil
+}
+
+func (s *MemStore) AcceptInvitation(_ context.Context, username, id string) (ListMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv, ok := s.invites[id]
+	if !ok || inv.Username != username {
+		return ListMember{}, ErrInvitationNotFound
+	}
+	delete(s.invites, id)
+	key := listKey{inv.Owner, inv.Shelf}
+	if s.members[key] == nil {
+		s.members[key] = make(map[string]ListMember)
+	}
+	m := ListMember{Owner: inv.Owner, Shelf: inv.Shelf, Username: username, Role: inv.Role, Since: inv.InvitedAt}
+	s.members[key][username] = m
+	return m, nil
+}
+
+func (s *MemStore) DeclineInvitation(_ context.Context, username, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if inv, ok := s.invites[id]; !ok || inv.Username != username {
+		return ErrInvitationNotFound
+	}
+	delete(s.invites, id)
+	return nil
+}
+
+func (s *MemStore) ListMemberships(_ context.Context, username string) ([]ListMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	memberships := []ListMember{}
+	for _, members := range s.members {
+		if m, ok := members[username]; ok {
+			memberships = append(memberships, m)
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		if memberships[i].Owner != memberships[j].Owner {
+			return memberships[i].Owner < memberships[j].Owner
+		}
+		return memberships[i].Shelf < memberships[j].Shelf
+	})
+	return memberships, nil
+}
+
+// forgetMember drops username's memberships, invitations and the lists they own, the caller holds s.mu
+func (s *MemStore) forgetMember(username string) {
+	for key, members := range s.members {
+		delete(members, username)
+		if key.owner == username || len(members) == 0 {
+			delete(s.members, key)
+		}
+	}
+	for id, inv := range s.invites {
+		if inv.Username == username || inv.Owner == username {
+			delete(s.invites, id)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// what a Notification tells its user about
+const (
+	NotifyReviewReply  = "review.reply"  //someone commented on their review
+	NotifyCommentReply = "comment.reply" //someone replied to their comment
+)
+
+var NotifyKinds = map[string]bool{NotifyReviewReply: true, NotifyCommentReply: true}
+
+const MaxNotifications = 500 //kept per user, the oldest go first
+
+type Notification struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"` //who it is for
+	Kind      string    `json:"kind"`
+	Actor     string    `json:"actor,omitempty"` //who caused it, e.g. the user who replied
+	ReviewID  string    `json:"review_id,omitempty"`
+	CommentID string    `json:"comment_id,omitempty"`
+	URL       string    `json:"url,omitempty"` //where to read what it is about
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationStore keeps each user's inbox, the devices they get pushes on and what they want to hear about
+type NotificationStore interface {
+	// AddNotification delivers n to n.Username. The same kind of notification about the same
+	// comment is delivered once, so replicas hearing the same event don't repeat it.
+	AddNotification(ctx context.Context, n Notification) error
+	ListNotifications(ctx context.Context, username string, unread bool) ([]Notification, error) //newest first, unread ones only when unread
+	UnreadCount(ctx context.Context, username string) (int, error)
+	MarkRead(ctx context.Context, username, id string, read bool) error //ErrNotificationNotFound
+	MarkAllRead(ctx context.Context, username string) (int, error)      //returns how many were unread
+	DeleteNotifications(ctx context.Context, username string) error     //drops username's inbox, devices and preferences
+
+	DeviceStore
+	PreferenceStore
+}
+
+func (s *MemStore) AddNotification(_ context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inbox := s.inbox[n.Username]
+	for _, cur := range inbox {
+		if cur.ID == n.ID || (cur.Kind == n.Kind && cur.ReviewID == n.ReviewID && cur.CommentID == n.CommentID) {
+			return nil
+		}
+	}
+	inbox = append(inbox, n)
+	if len(inbox) > MaxNotifications {
+		inbox = append([]Notification(nil), inbox[len(inbox)-MaxNotifications:]...)
+	}
+	s.inbox[n.Username] = inbox
+	return nil
+}
+
+func (s *MemStore) ListNotifications(_ context.Context, username string, unread bool) ([]Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []Notification{}
+	inbox := s.inbox[username]
+	for i := len(inbox) - 1; i >= 0; i-- {
+		if !unread || !inbox[i].Read {
+			list = append(list, inbox[i])
+		}
+	}
+	return list, nil
+}
+
+func (s *MemStore) UnreadCount(_ context.Context, username string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, n := range s.inbox[username] {
+		if !n.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemStore) MarkRead(_ context.Context, username, id string, read bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, n := range s.inbox[username] {
+		if n.ID == id {
+			s.inbox[username][i].Read = read
+			return nil
+		}
+	}
+	return ErrNotificationNotFound
+}
+
+func (s *MemStore) MarkAllRead(_ context.Context, username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for i, n := range s.inbox[username] {
+		if !n.Read {
+			s.inbox[username][i].Read = true
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemStore) DeleteNotifications(_ context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inbox, username)
+	for id, d := range s.devices {
+		if d.Username == username {
+			delete(s.devices, id)
+		}
+	}
+	delete(s.prefs, username)
+	return nil
+}
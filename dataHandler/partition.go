@@ -0,0 +1,152 @@
+package dataHandler
+
+import (
+	"context"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Partitions is how many parts MemStore splits its books into by ID, the unit of a parallel scan
+const Partitions = 64
+
+// ParallelScanMin is the catalog size from which FindBooks scans partitions in parallel,
+// below it starting goroutines costs more than it saves
+const ParallelScanMin = 20000
+
+// Finder is implemented by stores that can filter books themselves, faster than
+// ListBooks followed by a filter in the caller. match may be called from several goroutines at once.
+type Finder interface {
+	FindBooks(ctx context.Context, match func(Book) bool) ([]Book, error) //in no particular order
+}
+
+// FindBooks filters store's books with match, in store when it is a Finder and through ListBooks otherwise
+func FindBooks(ctx context.Context, store Store, match func(Book) bool) ([]Book, error) {
+	if f, ok := store.(Finder); ok {
+		return f.FindBooks(ctx, match)
+	}
+	all, err := store.ListBooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	books := all[:0]
+	for _, b := range all {
+		if match(b) {
+			books = append(books, b)
+		}
+	}
+	return books, nil
+}
+
+// partitioned holds books by ID split over Partitions maps, each with its own lock
+type partitioned struct {
+	parts [Partitions]BookDB
+	mu    [Partitions]sync.RWMutex
+	n     atomic.Int64
+}
+
+func newPartitioned() *partitioned {
+	p := &partitioned{}
+	for i := range p.parts {
+		p.parts[i] = make(BookDB)
+	}
+	return p
+}
+
+func partitionOf(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % Partitions)
+}
+
+func (p *partitioned) get(id string) (Book, bool) {
+	i := partitionOf(id)
+	p.mu[i].RLock()
+	defer p.mu[i].RUnlock()
+	b, ok := p.parts[i][id]
+	return b, ok
+}
+
+func (p *partitioned) set(b Book) {
+	i := partitionOf(b.ID)
+	p.mu[i].Lock()
+	defer p.mu[i].Unlock()
+	if _, ok := p.parts[i][b.ID]; !ok {
+		p.n.Add(1)
+	}
+	p.parts[i][b.ID] = b
+}
+
+func (p *partitioned) delete(id string) {
+	i := partitionOf(id)
+	p.mu[i].Lock()
+	defer p.mu[i].Unlock()
+	if _, ok := p.parts[i][id]; ok {
+		p.n.Add(-1)
+		delete(p.parts[i], id)
+	}
+}
+
+func (p *partitioned) len() int { return int(p.n.Load()) }
+
+// all returns every book in no particular order
+func (p *partitioned) all() []Book {
+	books := make([]Book, 0, p.len())
+	for i := range p.parts {
+		p.mu[i].RLock()
+		for _, b := range p.parts[i] {
+			books = append(books, b)
+		}
+		p.mu[i].RUnlock()
+	}
+	return books
+}
+
+// find returns the books match accepts, scanning partitions on up to GOMAXPROCS goroutines
+// for large catalogs; it stops early when ctx ends
+func (p *partitioned) find(ctx context.Context, match func(Book) bool) ([]Book, error) {
+	workers := runtime.GOMAXPROCS(0)
+	if p.len() < ParallelScanMin || workers < 2 {
+		workers = 1
+	}
+	var found [Partitions][]Book
+	var next atomic.Int32
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1)) - 1; i < Partitions && ctx.Err() == nil; i = int(next.Add(1)) - 1 {
+				p.mu[i].RLock()
+				for _, b := range p.parts[i] {
+					if match(b) {
+						found[i] = append(found[i], b)
+					}
+				}
+				p.mu[i].RUnlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for _, f := range found {
+		n += len(f)
+	}
+	books := make([]Book, 0, n)
+	for _, f := range found {
+		books = append(books, f...)
+	}
+	return books, nil
+}
+
+func (s *MemStore) FindBooks(ctx context.Context, match func(Book) bool) (books []Book, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.consistently(func() { books, err = s.books.find(ctx, match) })
+	return books, err
+}
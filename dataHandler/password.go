@@ -0,0 +1,78 @@
+package dataHandler
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	hashScheme     = "pbkdf2-sha256"
+	hashIterations = 210000
+	hashKeyLength  = 32
+	hashSaltLength = 16
+)
+
+var ErrBadHash = errors.New("malformed password hash")
+
+// HashPassword returns a salted PBKDF2 hash encoded as pbkdf2-sha256$iterations$salt$key
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, hashSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, hashIterations, hashKeyLength)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, hashIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches hash, comparing keys in constant time
+func CheckPassword(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false, ErrBadHash
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return false, ErrBadHash
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrBadHash
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrBadHash
+	}
+
+	got, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// dummyHash is checked against when a user doesn't exist, so unknown users take as long as wrong
+// passwords. It is made on the first failed login rather than at startup.
+var dummyHash = sync.OnceValue(func() string {
+	h, err := HashPassword("not-a-real-password")
+	if err != nil {
+		panic(err)
+	}
+	return h
+})
+
+func DummyHash() string {
+	return dummyHash()
+}
@@ -0,0 +1,75 @@
+package dataHandler
+
+import "context"
+
+// channels notifications reach users on
+const (
+	ChannelInApp = "in_app" //their inbox, GET /me/notifications
+	ChannelPush  = "push"   //their registered devices
+	ChannelEmail = "email"
+)
+
+// Preferences are a user's choices about their notifications, per channel a notification kind ->
+// whether it is sent there. Kinds left out go to the inbox and are pushed, but are not emailed.
+type Preferences struct {
+	InApp        map[string]bool `json:"in_app"`
+	Push         map[string]bool `json:"push"`
+	Email        map[string]bool `json:"email"`
+	EmailAddress string          `json:"email_address,omitempty"` //where emails go, none are sent without one
+	Language     string          `json:"language,omitempty"`      //emails are written in
+
+	// EmailConfirmed is set once the user sent back the code mailed to EmailAddress, only
+	// confirmed addresses get notification emails. EmailCode is the SHA-256 of that code.
+	EmailConfirmed bool   `json:"email_confirmed"`
+	EmailCode      string `json:"-"`
+}
+
+// Wants reports whether notifications of kind are sent on channel
+func (p Preferences) Wants(channel, kind string) bool {
+	switch channel {
+	case ChannelInApp:
+		on, ok := p.InApp[kind]
+		return !ok || on
+	case ChannelPush:
+		on, ok := p.Push[kind]
+		return !ok || on
+	case ChannelEmail:
+		return p.Email[kind]
+	}
+	return false
+}
+
+func (p Preferences) clone() Preferences {
+	c := p
+	c.InApp, c.Push, c.Email = make(map[string]bool, len(p.InApp)), make(map[string]bool, len(p.Push)), make(map[string]bool, len(p.Email))
+	for kind, on := range p.InApp {
+		c.InApp[kind] = on
+	}
+	for kind, on := range p.Push {
+		c.Push[kind] = on
+	}
+	for kind, on := range p.Email {
+		c.Email[kind] = on
+	}
+	return c
+}
+
+type PreferenceStore interface {
+	GetPreferences(ctx context.Context, username string) (Preferences, error) //the defaults for users who never set any
+	PutPreferences(ctx context.Context, username string, p Preferences) error
+}
+
+func (s *MemStore) GetPreferences(_ context.Context, username string) (Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.prefs[username].clone(), nil
+}
+
+func (s *MemStore) PutPreferences(_ context.Context, username string, p Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prefs[username] = p.clone()
+	return nil
+}
@@ -0,0 +1,92 @@
+package dataHandler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaStore sends writes to Primary and reads to Replica.
+// Reads shortly after a write from this instance, and reads the replica can't answer,
+// go to Primary so a lagging replica never hides a change the client just made.
+type ReplicaStore struct {
+	Primary Store
+	Replica Store
+	MaxLag  time.Duration //how long after a write reads stay on the primary
+	Clock   Clock
+
+	lastWrite atomic.Int64 //unix nanoseconds
+}
+
+func NewReplicaStore(primary, replica Store, maxLag time.Duration) *ReplicaStore {
+	return &ReplicaStore{Primary: primary, Replica: replica, MaxLag: maxLag, Clock: SystemClock{}}
+}
+
+// reader picks the store for a read
+func (s *ReplicaStore) reader() Store {
+	if s.Clock.Now().Sub(time.Unix(0, s.lastWrite.Load())) < s.MaxLag {
+		return s.Primary
+	}
+	return s.Replica
+}
+
+func (s *ReplicaStore) wrote() {
+	s.lastWrite.Store(s.Clock.Now().UnixNano())
+}
+
+func (s *ReplicaStore) ListBooks(ctx context.Context) ([]Book, error) {
+	st := s.reader()
+	books, err := st.ListBooks(ctx)
+	if err != nil && st != s.Primary {
+		return s.Primary.ListBooks(ctx)
+	}
+	return books, err
+}
+
+func (s *ReplicaStore) GetBook(ctx context.Context, isbn string) (Book, error) {
+	st := s.reader()
+	book, err := st.GetBook(ctx, isbn)
+	if err != nil && st != s.Primary {
+		return s.Primary.GetBook(ctx, isbn) //missing on the replica may only mean it hasn't caught up
+	}
+	return book, err
+}
+
+func (s *ReplicaStore) GetBooksByISBNs(ctx context.Context, isbns []string) (map[string]Book, error) {
+	st := s.reader()
+	books, err := st.GetBooksByISBNs(ctx, isbns)
+	if st != s.Primary && (err != nil || len(books) < len(isbns)) {
+		return s.Primary.GetBooksByISBNs(ctx, isbns) //some may be missing only on the replica
+	}
+	return books, err
+}
+
+func (s *ReplicaStore) ListBooksUpdatedAfter(ctx context.Context, t time.Time) ([]Book, error) {
+	st := s.reader()
+	books, err := st.ListBooksUpdatedAfter(ctx, t)
+	if err != nil && st != s.Primary {
+		return s.Primary.ListBooksUpdatedAfter(ctx, t)
+	}
+	return books, err
+}
+
+func (s *ReplicaStore) CreateBook(ctx context.Context, book Book) error {
+	defer s.wrote()
+	return s.Primary.CreateBook(ctx, book)
+}
+
+func (s *ReplicaStore) UpdateBook(ctx context.Context, book Book) error {
+	defer s.wrote()
+	return s.Primary.UpdateBook(ctx, book)
+}
+
+func (s *ReplicaStore) DeleteBook(ctx context.Context, isbn string) error {
+	defer s.wrote()
+	return s.Primary.DeleteBook(ctx, isbn)
+}
+
+// Tx runs on Primary, reads inside it included
+func (s *ReplicaStore) Tx(ctx context.Context, fn func(tx Store) error) error {
+	defer s.wrote()
+	return InTx(ctx, s.Primary, fn)
+}
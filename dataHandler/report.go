@@ -0,0 +1,104 @@
+package dataHandler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// what a Report is about
+const (
+	ReportReview  = "review"
+	ReportComment = "comment"
+	ReportBook    = "book"
+	ReportUser    = "user"
+)
+
+var ReportKinds = map[string]bool{ReportReview: true, ReportComment: true, ReportBook: true, ReportUser: true}
+
+type Report struct { //a user flagging something to the moderators
+	Kind     string    `json:"kind"` //one of ReportKinds
+	ID       string    `json:"id"`   //of the review, comment or book, or the reported username
+	Username string    `json:"username"`
+	Reason   string    `json:"reason,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// ReportStore keeps the moderators' queue of abuse reports, one per user and reported item
+type ReportStore interface {
+	// AddReport files r and returns how many users reported the same item so far; a user
+	// reporting it again replaces their reason. ErrReviewNotFound, ErrCommentNotFound,
+	// ErrBookNotFound or ErrUserNotFound when the item doesn't exist.
+	AddReport(ctx context.Context, r Report) (int, error)
+	ListReports(ctx context.Context) ([]Report, error)         //oldest first
+	ResolveReports(ctx context.Context, kind, id string) error //drops the reports on an item, once a moderator dealt with it
+}
+
+type reportKey struct{ kind, id, username string }
+
+func (s *MemStore) AddReport(_ context.Context, r Report) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	switch r.Kind {
+	case ReportReview:
+		if _, ok := s.reviews[r.ID]; !ok {
+			err = ErrReviewNotFound
+		}
+	case ReportComment:
+		if _, ok := s.comments[r.ID]; !ok {
+			err = ErrCommentNotFound
+		}
+	case ReportBook:
+		if _, ok := s.books.get(r.ID); !ok {
+			err = ErrBookNotFound
+		}
+	case ReportUser:
+		if _, ok := s.users[r.ID]; !ok {
+			err = ErrUserNotFound
+		}
+	default:
+		err = fmt.Errorf("unknown report kind %q", r.Kind)
+	}
+	if err != nil {
+		return 0, err
+	}
+	key := reportKey{r.Kind, r.ID, r.Username}
+	if cur, ok := s.reports[key]; ok {
+		r.At = cur.At //stays in its place in the queue
+	}
+	s.reports[key] = r
+	count := 0
+	for k := range s.reports {
+		if k.kind == r.Kind && k.id == r.ID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemStore) ListReports(_ context.Context) ([]Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reports := make([]Report, 0, len(s.reports))
+	for _, r := range s.reports {
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].At.Before(reports[j].At) })
+	return reports, nil
+}
+
+func (s *MemStore) ResolveReports(_ context.Context, kind, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.reports {
+		if key.kind == kind && key.id == id {
+			delete(s.reports, key)
+		}
+	}
+	return nil
+}
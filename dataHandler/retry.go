@@ -0,0 +1,139 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrSerialization is what backends wrap a failed serializable transaction in (SQLSTATE 40001),
+// the write didn't happen and may succeed when tried again
+var ErrSerialization = errors.New("serialization failure")
+
+// Transient reports whether err is a backend hiccup worth retrying:
+// a serialization failure, a reset or dropped connection, or a network timeout
+func Transient(err error) bool {
+	if errors.Is(err, ErrSerialization) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// RetryStore repeats calls to Store that fail with a Transient error, at most Attempts times in all,
+// sleeping a random time up to Backoff, 2*Backoff, 4*Backoff... in between so retrying replicas spread out.
+// It relies on Store writes being idempotent: a retried delete that finds the book gone succeeded before.
+type RetryStore struct {
+	Store    Store
+	Attempts int
+	Backoff  time.Duration
+}
+
+func NewRetryStore(store Store, attempts int) *RetryStore {
+	return &RetryStore{Store: store, Attempts: attempts, Backoff: 50 * time.Millisecond}
+}
+
+// do runs call until it succeeds, fails for good, runs out of attempts or ctx ends
+func (s *RetryStore) do(ctx context.Context, call func(attempt int) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = call(attempt); err == nil || !Transient(err) || attempt+1 >= s.Attempts {
+			return err
+		}
+		wait := time.Duration(rand.Int64N(int64(s.Backoff<<attempt) + 1))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *RetryStore) ListBooks(ctx context.Context) (books []Book, err error) {
+	err = s.do(ctx, func(int) error {
+		books, err = s.Store.ListBooks(ctx)
+		return err
+	})
+	return books, err
+}
+
+func (s *RetryStore) GetBook(ctx context.Context, ref string) (book Book, err error) {
+	err = s.do(ctx, func(int) error {
+		book, err = s.Store.GetBook(ctx, ref)
+		return err
+	})
+	return book, err
+}
+
+func (s *RetryStore) GetBooksByISBNs(ctx context.Context, isbns []string) (books map[string]Book, err error) {
+	err = s.do(ctx, func(int) error {
+		books, err = s.Store.GetBooksByISBNs(ctx, isbns)
+		return err
+	})
+	return books, err
+}
+
+func (s *RetryStore) ListBooksUpdatedAfter(ctx context.Context, t time.Time) (books []Book, err error) {
+	err = s.do(ctx, func(int) error {
+		books, err = s.Store.ListBooksUpdatedAfter(ctx, t)
+		return err
+	})
+	return books, err
+}
+
+func (s *RetryStore) CreateBook(ctx context.Context, book Book) error {
+	return s.do(ctx, func(int) error { return s.Store.CreateBook(ctx, book) })
+}
+
+func (s *RetryStore) UpdateBook(ctx context.Context, book Book) error {
+	return s.do(ctx, func(int) error { return s.Store.UpdateBook(ctx, book) })
+}
+
+func (s *RetryStore) DeleteBook(ctx context.Context, ref string) error {
+	return s.do(ctx, func(attempt int) error {
+		err := s.Store.DeleteBook(ctx, ref)
+		if attempt > 0 && errors.Is(err, ErrBookNotFound) {
+			return nil //an earlier attempt deleted it before its answer was lost
+		}
+		return err
+	})
+}
+
+func (s *RetryStore) FindBooks(ctx context.Context, match func(Book) bool) (books []Book, err error) {
+	err = s.do(ctx, func(int) error {
+		books, err = FindBooks(ctx, s.Store, match)
+		return err
+	})
+	return books, err
+}
+
+func (s *RetryStore) Changes(ctx context.Context, after int64, limit int) (changes []Change, err error) {
+	log, ok := s.Store.(ChangeLog)
+	if !ok {
+		return nil, ErrNoChangeLog
+	}
+	err = s.do(ctx, func(int) error {
+		changes, err = log.Changes(ctx, after, limit)
+		return err
+	})
+	return changes, err
+}
+
+func (s *RetryStore) CompactChanges(ctx context.Context, upTo int64) (n int, err error) {
+	err = s.do(ctx, func(int) error {
+		n, err = CompactChanges(ctx, s.Store, upTo)
+		return err
+	})
+	return n, err
+}
+
+// Tx runs the whole transaction again on a Transient error, so fn may be called more than once
+// and must not keep state from an earlier call
+func (s *RetryStore) Tx(ctx context.Context, fn func(tx Store) error) error {
+	return s.do(ctx, func(int) error { return InTx(ctx, s.Store, fn) })
+}
@@ -0,0 +1,267 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrCommentNotFound = errors.New("comment not found")
+)
+
+// PostStatus says who gets to read a review or comment
+type PostStatus string
+
+const (
+	StatusVisible PostStatus = "visible"
+	StatusHidden  PostStatus = "hidden"  //by a moderator, its text is shown to moderators only
+	StatusDeleted PostStatus = "deleted" //by its author or a moderator, its text is gone
+	// held back by moderation when it was written, only moderators see it until one approves it
+	StatusQuarantined PostStatus = "quarantined"
+)
+
+type Review struct { //one per user and book, written again it is replaced
+	ID        string     `json:"id"`
+	BookID    string     `json:"book_id"`
+	Username  string     `json:"username"`
+	Rating    int        `json:"rating,omitempty"` //1-5, 0 when unrated
+	Body      string     `json:"body"`
+	Status    PostStatus `json:"status"`
+	Held      string     `json:"held,omitempty"` //why moderation quarantined it
+	Comments  int        `json:"comments"`       //in its thread, whatever their status
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type Comment struct { //a reply in a review's thread
+	ID        string     `json:"id"`
+	ReviewID  string     `json:"review_id"`
+	ParentID  string     `json:"parent_id,omitempty"` //the comment replied to, empty for replies to the review itself
+	Username  string     `json:"username"`
+	Body      string     `json:"body"`
+	Status    PostStatus `json:"status"`
+	Held      string     `json:"held,omitempty"` //why moderation quarantined it
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ReviewStore keeps book reviews and the comment threads below them. Hidden and deleted posts
+// stay in place so replies to them keep their thread, callers decide what to show of them.
+type ReviewStore interface {
+	ListReviews(ctx context.Context, bookID string) ([]Review, error) //newest first, every status
+	GetReview(ctx context.Context, id string) (Review, error)
+	// PutReview creates or replaces r.Username's review of r.BookID. A replaced review keeps its ID,
+	// creation time and a moderator's hiding; the review in effect is returned.
+	PutReview(ctx context.Context, r Review) (Review, error)
+	ListComments(ctx context.Context, reviewID string) ([]Comment, error) //oldest first; ErrReviewNotFound
+	GetComment(ctx context.Context, id string) (Comment, error)
+	AddComment(ctx context.Context, c Comment) error //ErrReviewNotFound, ErrCommentNotFound for a parent on another review
+	// SetReviewStatus and SetCommentStatus hide, restore or delete a post, deleting drops its text.
+	// A post leaving quarantine forgets why it was held.
+	SetReviewStatus(ctx context.Context, id string, status PostStatus) error
+	SetCommentStatus(ctx context.Context, id string, status PostStatus) error
+	ListQuarantined(ctx context.Context) ([]Review, []Comment, error) //oldest first
+	UserPosts(ctx context.Context, username string) ([]Review, []Comment, error)
+	DeletePosts(ctx context.Context, username string) error //deletes username's reviews and comments, drops their reports and the ones about them
+
+	ReportStore //users flagging posts, books and other users
+}
+
+func (s *MemStore) ListReviews(_ context.Context, bookID string) ([]Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews := []Review{}
+	for _, rv := range s.reviews {
+		if rv.BookID == bookID {
+			reviews = append(reviews, rv)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].CreatedAt.After(reviews[j].CreatedAt) })
+	return reviews, nil
+}
+
+func (s *MemStore) GetReview(_ context.Context, id string) (Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rv, ok := s.reviews[id]
+	if !ok {
+		return Review{}, ErrReviewNotFound
+	}
+	return rv, nil
+}
+
+func (s *MemStore) PutReview(_ context.Context, r Review) (Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.books.get(r.BookID); !ok {
+		return Review{}, ErrBookNotFound
+	}
+	for _, cur := range s.reviews {
+		if cur.BookID == r.BookID && cur.Username == r.Username {
+			r.ID, r.CreatedAt, r.Comments = cur.ID, cur.CreatedAt, cur.Comments
+			if cur.Status == StatusHidden {
+				r.Status, r.Held = StatusHidden, ""
+			}
+			break
+		}
+	}
+	s.reviews[r.ID] = r
+	return r, nil
+}
+
+func (s *MemStore) ListComments(_ context.Context, reviewID string) ([]Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.reviews[reviewID]; !ok {
+		return nil, ErrReviewNotFound
+	}
+	comments := []Comment{}
+	for _, c := range s.comments {
+		if c.ReviewID == reviewID {
+			comments = append(comments, c)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+	return comments, nil
+}
+
+func (s *MemStore) GetComment(_ context.Context, id string) (Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.comments[id]
+	if !ok {
+		return Comment{}, ErrCommentNotFound
+	}
+	return c, nil
+}
+
+func (s *MemStore) AddComment(_ context.Context, c Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rv, ok := s.reviews[c.ReviewID]
+	if !ok {
+		return ErrReviewNotFound
+	}
+	if len(c.ParentID) != 0 {
+		if parent, ok := s.comments[c.ParentID]; !ok || parent.ReviewID != c.ReviewID {
+			return ErrCommentNotFound
+		}
+	}
+	if _, ok := s.comments[c.ID]; ok { //a retry of a comment that landed
+		return nil
+	}
+	s.comments[c.ID] = c
+	rv.Comments++
+	s.reviews[rv.ID] = rv
+	return nil
+}
+
+func (s *MemStore) SetReviewStatus(_ context.Context, id string, status PostStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rv, ok := s.reviews[id]
+	if !ok {
+		return ErrReviewNotFound
+	}
+	rv.Status = status
+	if status != StatusQuarantined {
+		rv.Held = ""
+	}
+	if status == StatusDeleted {
+		rv.Body, rv.Rating = "", 0
+	}
+	s.reviews[id] = rv
+	return nil
+}
+
+func (s *MemStore) SetCommentStatus(_ context.Context, id string, status PostStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.comments[id]
+	if !ok {
+		return ErrCommentNotFound
+	}
+	c.Status = status
+	if status != StatusQuarantined {
+		c.Held = ""
+	}
+	if status == StatusDeleted {
+		c.Body = ""
+	}
+	s.comments[id] = c
+	return nil
+}
+
+func (s *MemStore) ListQuarantined(_ context.Context) ([]Review, []Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews, comments := []Review{}, []Comment{}
+	for _, rv := range s.reviews {
+		if rv.Status == StatusQuarantined {
+			reviews = append(reviews, rv)
+		}
+	}
+	for _, c := range s.comments {
+		if c.Status == StatusQuarantined {
+			comments = append(comments, c)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].UpdatedAt.Before(reviews[j].UpdatedAt) })
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+	return reviews, comments, nil
+}
+
+func (s *MemStore) UserPosts(_ context.Context, username string) ([]Review, []Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews, comments := []Review{}, []Comment{}
+	for _, rv := range s.reviews {
+		if rv.Username == username {
+			reviews = append(reviews, rv)
+		}
+	}
+	for _, c := range s.comments {
+		if c.Username == username {
+			comments = append(comments, c)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].CreatedAt.Before(reviews[j].CreatedAt) })
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+	return reviews, comments, nil
+}
+
+func (s *MemStore) DeletePosts(_ context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, rv := range s.reviews {
+		if rv.Username == username {
+			rv.Status, rv.Body, rv.Rating, rv.Held = StatusDeleted, "", 0, ""
+			s.reviews[id] = rv
+		}
+	}
+	for id, c := range s.comments {
+		if c.Username == username {
+			c.Status, c.Body, c.Held = StatusDeleted, "", ""
+			s.comments[id] = c
+		}
+	}
+	for key := range s.reports {
+		if key.username == username || (key.kind == ReportUser && key.id == username) {
+			delete(s.reports, key)
+		}
+	}
+	return nil
+}
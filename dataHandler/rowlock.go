@@ -0,0 +1,41 @@
+package dataHandler
+
+import (
+	"hash/fnv"
+	"slices"
+	"sync"
+)
+
+// RowStripes is how many mutexes MemStore spreads its row locks over, keys sharing one only
+// wait on each other
+const RowStripes = 256
+
+// rowLocks serializes writes by key, a book's ID or ISBN
+type rowLocks [RowStripes]sync.Mutex
+
+func stripeOf(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % RowStripes)
+}
+
+// lock takes the rows of keys, empty ones left out, and returns the function releasing them.
+// Stripes are taken in order, so writers locking several rows never wait on each other in a circle.
+func (l *rowLocks) lock(keys ...string) (unlock func()) {
+	stripes := make([]int, 0, len(keys))
+	for _, k := range keys {
+		if len(k) != 0 {
+			stripes = append(stripes, stripeOf(k))
+		}
+	}
+	slices.Sort(stripes)
+	stripes = slices.Compact(stripes)
+	for _, i := range stripes {
+		l[i].Lock()
+	}
+	return func() {
+		for _, i := range slices.Backward(stripes) {
+			l[i].Unlock()
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package dataHandler
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Clean returns s in Unicode normalization form C with control characters removed, so that the same
+// title typed on different clients is stored with the same bytes and searches, dedupes and sorts alike.
+// Format characters stay: zero width joiners are part of how Bengali and other scripts are spelled.
+func Clean(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return norm.NFC.String(s)
+}
+
+// Normalize cleans every text field of b clients can set, call it before Validate
+func (b *Book) Normalize() {
+	for _, f := range []*string{&b.Name, &b.ISBN, &b.Genre, &b.Pub, &b.Published, &b.Language, &b.OriginalTitle, &b.Translator, &b.WorkID, &b.Format} {
+		*f = Clean(*f)
+	}
+	authors := make([]Author, len(b.Authors)) //the slice may be shared with the stored book
+	for i, a := range b.Authors {
+		authors[i] = Author{Name: Clean(a.Name), Home: Clean(a.Home)}
+	}
+	if b.Authors != nil {
+		b.Authors = authors
+	}
+}
@@ -0,0 +1,139 @@
+package dataHandler
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// reading shelves, named like Goodreads' exclusive shelves
+const (
+	ShelfToRead  = "to-read"
+	ShelfReading = "currently-reading"
+	ShelfRead    = "read"
+)
+
+var Shelves = map[string]bool{ShelfToRead: true, ShelfReading: true, ShelfRead: true}
+
+type ShelfEntry struct { //a book on a user's reading list
+	ISBN      string `json:"isbn"`
+	Shelf     string `json:"shelf"`
+	Rating    int    `json:"rating,omitempty"`     // 1-5, 0 when unrated
+	DateRead  string `json:"date_read,omitempty"`  // YYYY-MM-DD
+	DateAdded string `json:"date_added,omitempty"` // YYYY-MM-DD
+}
+
+type PublicShelf struct { //a shelf its owner shared, readable by anyone knowing the slug
+	Slug     string    `json:"slug"` //unguessable, the shelf's public URL is built from it
+	Username string    `json:"username"`
+	Shelf    string    `json:"shelf"`
+	Since    time.Time `json:"since"`
+}
+
+// ShelfStore keeps every user's reading lists and read history
+type ShelfStore interface {
+	ListShelf(ctx context.Context, username string) ([]ShelfEntry, error)
+	PutShelfEntry(ctx context.Context, username string, e ShelfEntry) error
+	DeleteShelf(ctx context.Context, username string) error //drops all of the user's entries, publications and memberships
+
+	// PublishShelf makes p.Shelf of p.Username public under p.Slug. A shelf that is public already keeps
+	// its slug, so links shared before stay valid; the publication in effect is returned.
+	PublishShelf(ctx context.Context, p PublicShelf) (PublicShelf, error)
+	UnpublishShelf(ctx context.Context, username, shelf string) error //ErrShelfNotFound when it isn't public
+	GetPublicShelf(ctx context.Context, slug string) (PublicShelf, error)
+	ListPublicShelves(ctx context.Context, username string) ([]PublicShelf, error)
+
+	ListStore //sharing shelves with other users
+}
+
+func (s *MemStore) ListShelf(_ context.Context, username string) ([]ShelfEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]ShelfEntry, 0, len(s.shelves[username]))
+	for _, e := range s.shelves[username] {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ISBN < entries[j].ISBN })
+	return entries, nil
+}
+
+// PutShelfEntry adds or replaces the entry for e.ISBN, a book with that ISBN must exist
+func (s *MemStore) PutShelfEntry(_ context.Context, username string, e ShelfEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.isbnID(e.ISBN); !ok {
+		return ErrBookNotFound
+	}
+	if s.shelves[username] == nil {
+		s.shelves[username] = make(map[string]ShelfEntry)
+	}
+	s.shelves[username][e.ISBN] = e
+	return nil
+}
+
+func (s *MemStore) DeleteShelf(_ context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.shelves, username)
+	for slug, p := range s.public {
+		if p.Username == username {
+			delete(s.public, slug)
+		}
+	}
+	s.forgetMember(username)
+	return nil
+}
+
+func (s *MemStore) PublishShelf(_ context.Context, p PublicShelf) (PublicShelf, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cur := range s.public {
+		if cur.Username == p.Username && cur.Shelf == p.Shelf {
+			return cur, nil
+		}
+	}
+	s.public[p.Slug] = p
+	return p, nil
+}
+
+func (s *MemStore) UnpublishShelf(_ context.Context, username, shelf string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for slug, p := range s.public {
+		if p.Username == username && p.Shelf == shelf {
+			delete(s.public, slug)
+			return nil
+		}
+	}
+	return ErrShelfNotFound
+}
+
+func (s *MemStore) GetPublicShelf(_ context.Context, slug string) (PublicShelf, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.public[slug]
+	if !ok {
+		return PublicShelf{}, ErrShelfNotFound
+	}
+	return p, nil
+}
+
+func (s *MemStore) ListPublicShelves(_ context.Context, username string) ([]PublicShelf, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shelves := []PublicShelf{}
+	for _, p := range s.public {
+		if p.Username == username {
+			shelves = append(shelves, p)
+		}
+	}
+	sort.Slice(shelves, func(i, j int) bool { return shelves[i].Shelf < shelves[j].Shelf })
+	return shelves, nil
+}
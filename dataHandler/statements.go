@@ -0,0 +1,9 @@
+package dataHandler
+
+// StatementCache is implemented by stores that prepare their statements once and reuse them, such as
+// a SQL store registered as a plugin. The server reports the counts at /metrics.
+type StatementCache interface {
+	// StatementStats returns the statements prepared and the executions of prepared statements
+	// since the store opened, and the statements it holds now
+	StatementStats() (prepared, executed, cached int64)
+}
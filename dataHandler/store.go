@@ -0,0 +1,394 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	trace "github.com/Sabnaj-42/BookServer-API/traceHandler"
+)
+
+var (
+	ErrBookNotFound  = errors.New("book not found")
+	ErrBookExists    = errors.New("book already exists")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUserExists    = errors.New("user already exists")
+	ErrNoChangeLog   = errors.New("store keeps no change log")
+	ErrShelfNotFound = errors.New("shelf not found")
+	// ErrVersionConflict means the book changed since the caller read it
+	ErrVersionConflict = errors.New("book version conflict")
+)
+
+// Store is the storage layer for books, every call takes the request context.
+// Books are keyed by their ID, ref arguments accept either the ID or the ISBN;
+// an ISBN is optional but unique, a clash returns ErrBookExists.
+// Writes are idempotent so they can be retried: repeating a create or update that already
+// landed succeeds without another change.
+type Store interface {
+	// ListBooks, ListBooksUpdatedAfter and FindBooks of a Finder see the catalog as of one moment,
+	// a book written meanwhile is in its old or its new state, never both or neither
+	ListBooks(ctx context.Context) ([]Book, error)
+	GetBook(ctx context.Context, ref string) (Book, error)
+	// GetBooksByISBNs looks many books up in one call, keyed by ISBN; unknown ISBNs are left out
+	GetBooksByISBNs(ctx context.Context, isbns []string) (map[string]Book, error)
+	CreateBook(ctx context.Context, book Book) error
+	// UpdateBook is a compare-and-set matched by book.ID: it applies only while the stored
+	// version is book.Version-1, otherwise ErrVersionConflict
+	UpdateBook(ctx context.Context, book Book) error
+	DeleteBook(ctx context.Context, ref string) error
+	// ListBooksUpdatedAfter returns books whose UpdatedAt is after t, oldest change first
+	ListBooksUpdatedAfter(ctx context.Context, t time.Time) ([]Book, error)
+}
+
+// UserStore keeps login credentials, passwords are only stored as hashes (see HashPassword)
+type UserStore interface {
+	GetPasswordHash(ctx context.Context, username string) (string, error)
+	CreateUser(ctx context.Context, username, passwordHash string) error
+	SetPasswordHash(ctx context.Context, username, passwordHash string) error //ErrUserNotFound for unknown users
+	DeleteUser(ctx context.Context, username string) error                    //ErrUserNotFound for unknown users
+	ListUsers(ctx context.Context) ([]string, error)
+}
+
+// MemStore is the in memory Store and UserStore, safe for concurrent use. Book calls share mu and
+// lock only the rows they touch: writes to a book hold the rows of its ID and ISBNs, so writes to
+// different books run side by side while two to the same book take turns and see each other's
+// version. Everything else takes mu alone, as does a transaction. Reads of many books see the
+// catalog as of one change, never a write half done.
+type MemStore struct {
+	mu       sync.RWMutex
+	rows     rowLocks
+	books    *partitioned      //by ID, locks its partitions itself
+	idx      sync.Mutex        //guards isbns, updated and changes
+	isbns    map[string]string //ISBN -> ID
+	updated  []stamp           //ordered by UpdatedAt, then ID
+	changes  []Change          //every write oldest first, but those compaction dropped
+	lastSeq  int64             //Seq of the latest write, compaction may have dropped it
+	users    CredentialDB
+	shelves  map[string]map[string]ShelfEntry  //username -> ISBN -> entry
+	public   map[string]PublicShelf            //slug -> published shelf
+	members  map[listKey]map[string]ListMember //shared shelf -> username -> member
+	invites  map[string]Invitation             //by ID
+	reviews  map[string]Review                 //by ID
+	comments map[string]Comment                //by ID
+	reports  map[reportKey]Report
+	inbox    map[string][]Notification //username -> notifications, oldest first
+	devices  map[string]Device         //by ID
+	prefs    map[string]Preferences    //by username
+
+	Clock Clock //stamps the change log
+}
+
+// stamp is an entry of the UpdatedAt index
+type stamp struct {
+	at time.Time
+	id string
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{books: newPartitioned(), isbns: make(map[string]string), users: make(CredentialDB), shelves: make(map[string]map[string]ShelfEntry), public: make(map[string]PublicShelf), members: make(map[listKey]map[string]ListMember), invites: make(map[string]Invitation), reviews: make(map[string]Review), comments: make(map[string]Comment), reports: make(map[reportKey]Report), inbox: make(map[string][]Notification), devices: make(map[string]Device), prefs: make(map[string]Preferences), Clock: SystemClock{}}
+}
+
+func (s *MemStore) ListBooks(_ context.Context) ([]Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listBooks(), nil
+}
+
+// listBooks returns every book by ISBN as of one change, callers hold s.mu
+func (s *MemStore) listBooks() []Book {
+	var books []Book
+	s.consistently(func() { books = s.books.all() })
+	sort.Slice(books, func(i, j int) bool { return books[i].ISBN < books[j].ISBN })
+	return books
+}
+
+// isbnID returns the ID of the book with isbn
+func (s *MemStore) isbnID(isbn string) (string, bool) {
+	s.idx.Lock()
+	defer s.idx.Unlock()
+	id, ok := s.isbns[isbn]
+	return id, ok
+}
+
+// lookup finds a book by ID or ISBN, callers hold s.mu
+func (s *MemStore) lookup(ref string) (Book, bool) {
+	if b, ok := s.books.get(ref); ok {
+		return b, true
+	}
+	if id, ok := s.isbnID(ref); ok {
+		if b, ok := s.books.get(id); ok && b.ISBN == ref { //not mid-way through a change of ISBN
+			return b, true
+		}
+	}
+	return Book{}, false
+}
+
+// isbnTaken reports whether another book than id has isbn, callers hold the row of isbn
+func (s *MemStore) isbnTaken(isbn, id string) bool {
+	other, ok := s.isbnID(isbn)
+	return len(isbn) != 0 && ok && other != id
+}
+
+// lockRows locks the rows of book id, its ISBN and keys and returns the book as it is under them,
+// ok is false when there is none. Callers hold s.mu and call unlock when done.
+func (s *MemStore) lockRows(id string, keys ...string) (b Book, unlock func(), ok bool) {
+	for {
+		seen, had := s.books.get(id)
+		unlock = s.rows.lock(append(keys, id, seen.ISBN)...)
+		if b, ok = s.books.get(id); ok == had && b.ISBN == seen.ISBN {
+			return b, unlock, ok
+		}
+		unlock() //its ISBN changed before we got the rows, lock the new one
+	}
+}
+
+// swap replaces the book old with b, either may be nil, and updates the indexes. A non-empty op
+// is recorded in the change log. Callers hold the rows of both books. It all happens under s.idx,
+// so a scan that saw the change log stand still saw no write half done, see consistently.
+func (s *MemStore) swap(op string, old, b *Book) {
+	var diff []FieldChange
+	if op == OpUpdate && old != nil && b != nil {
+		diff = Diff(*old, *b) //before the lock, commits wait on it
+	}
+	s.idx.Lock()
+	defer s.idx.Unlock()
+
+	if b != nil {
+		s.books.set(*b) //in place, readers by ID never miss it
+	} else if old != nil {
+		s.books.delete(old.ID)
+	}
+	if old != nil {
+		s.unindex(*old)
+		if id, ok := s.isbns[old.ISBN]; ok && id == old.ID {
+			delete(s.isbns, old.ISBN)
+		}
+	}
+	if b != nil {
+		if len(b.ISBN) != 0 {
+			s.isbns[b.ISBN] = b.ID
+		}
+		s.index(*b)
+	}
+	switch {
+	case len(op) == 0:
+	case b != nil:
+		s.record(op, b.ID, b.ISBN, b, diff)
+	default:
+		s.record(op, old.ID, old.ISBN, nil, nil)
+	}
+}
+
+func (s *MemStore) GetBook(_ context.Context, ref string) (Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.lookup(ref)
+	if !ok {
+		return Book{}, ErrBookNotFound
+	}
+	return b, nil
+}
+
+func (s *MemStore) GetBooksByISBNs(_ context.Context, isbns []string) (map[string]Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.booksByISBNs(isbns), nil
+}
+
+// booksByISBNs looks books up by ISBN, callers hold s.mu
+func (s *MemStore) booksByISBNs(isbns []string) map[string]Book {
+	books := make(map[string]Book, len(isbns))
+	for _, isbn := range isbns {
+		if id, ok := s.isbnID(isbn); ok {
+			if b, ok := s.books.get(id); ok && b.ISBN == isbn {
+				books[isbn] = b
+			}
+		}
+	}
+	return books
+}
+
+func (s *MemStore) CreateBook(ctx context.Context, book Book) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.createBook(ctx, book)
+}
+
+// createBook is CreateBook for callers holding s.mu
+func (s *MemStore) createBook(ctx context.Context, book Book) error {
+	old, unlock, ok := s.lockRows(book.ID, book.ISBN)
+	defer unlock()
+
+	if ok && reflect.DeepEqual(old, book) {
+		return nil //a retry of a create that landed
+	}
+	if ok || len(book.ID) == 0 || s.isbnTaken(book.ISBN, book.ID) {
+		return ErrBookExists
+	}
+	s.swap(OpCreate, nil, &book)
+	trace.Logf(ctx, "store: created book %s", book.ID)
+	return nil
+}
+
+func (s *MemStore) UpdateBook(ctx context.Context, book Book) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updateBook(ctx, book)
+}
+
+// updateBook is UpdateBook for callers holding s.mu
+func (s *MemStore) updateBook(ctx context.Context, book Book) error {
+	old, unlock, ok := s.lockRows(book.ID, book.ISBN)
+	defer unlock()
+
+	if !ok {
+		return ErrBookNotFound
+	}
+	if reflect.DeepEqual(old, book) {
+		return nil //a retry of an update that landed
+	}
+	if old.Version+1 != book.Version {
+		return ErrVersionConflict
+	}
+	if s.isbnTaken(book.ISBN, book.ID) {
+		return ErrBookExists
+	}
+	s.swap(OpUpdate, &old, &book)
+	trace.Logf(ctx, "store: updated book %s", book.ID)
+	return nil
+}
+
+func (s *MemStore) DeleteBook(ctx context.Context, ref string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deleteBook(ctx, ref)
+}
+
+// deleteBook is DeleteBook for callers holding s.mu
+func (s *MemStore) deleteBook(ctx context.Context, ref string) error {
+	for {
+		found, ok := s.lookup(ref)
+		if !ok {
+			return ErrBookNotFound
+		}
+		old, unlock, ok := s.lockRows(found.ID, ref)
+		if ok && (old.ID == ref || old.ISBN == ref) {
+			s.swap(OpDelete, &old, nil)
+			unlock()
+			trace.Logf(ctx, "store: deleted book %s", old.ID)
+			return nil
+		}
+		unlock() //deleted or given another ISBN meanwhile, look ref up again
+	}
+}
+
+func (s *MemStore) ListBooksUpdatedAfter(_ context.Context, t time.Time) ([]Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updatedAfter(t), nil
+}
+
+// updatedAfter returns books whose UpdatedAt is after t, oldest change first, callers hold s.mu.
+// Holding s.idx no write lands while it reads, the result is as of one change.
+func (s *MemStore) updatedAfter(t time.Time) []Book {
+	s.idx.Lock()
+	defer s.idx.Unlock()
+
+	i := sort.Search(len(s.updated), func(i int) bool { return s.updated[i].at.After(t) })
+	books := make([]Book, 0, len(s.updated)-i)
+	for _, st := range s.updated[i:] {
+		if b, ok := s.books.get(st.id); ok {
+			books = append(books, b)
+		}
+	}
+	return books
+}
+
+// position of b in the UpdatedAt index, callers hold s.idx
+func (s *MemStore) position(b Book) int {
+	return sort.Search(len(s.updated), func(i int) bool {
+		o := s.updated[i]
+		if !o.at.Equal(b.UpdatedAt) {
+			return o.at.After(b.UpdatedAt)
+		}
+		return o.id >= b.ID
+	})
+}
+
+// index adds b to the UpdatedAt index, callers hold s.idx
+func (s *MemStore) index(b Book) {
+	i := s.position(b)
+	s.updated = append(s.updated, stamp{})
+	copy(s.updated[i+1:], s.updated[i:])
+	s.updated[i] = stamp{at: b.UpdatedAt, id: b.ID}
+}
+
+// unindex removes b, as it was indexed, from the UpdatedAt index, callers hold s.idx
+func (s *MemStore) unindex(b Book) {
+	if i := s.position(b); i < len(s.updated) && s.updated[i].id == b.ID {
+		s.updated = append(s.updated[:i], s.updated[i+1:]...)
+	}
+}
+
+func (s *MemStore) GetPasswordHash(_ context.Context, username string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, ok := s.users[username]
+	if !ok {
+		return "", ErrUserNotFound
+	}
+	return hash, nil
+}
+
+func (s *MemStore) CreateUser(ctx context.Context, username, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; ok {
+		return ErrUserExists
+	}
+	s.users[username] = passwordHash
+	trace.Logf(ctx, "store: created user %q", username)
+	return nil
+}
+
+func (s *MemStore) SetPasswordHash(ctx context.Context, username, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; !ok {
+		return ErrUserNotFound
+	}
+	s.users[username] = passwordHash
+	trace.Logf(ctx, "store: changed password of %q", username)
+	return nil
+}
+
+func (s *MemStore) DeleteUser(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; !ok {
+		return ErrUserNotFound
+	}
+	delete(s.users, username)
+	trace.Logf(ctx, "store: deleted user %q", username)
+	return nil
+}
+
+func (s *MemStore) ListUsers(_ context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]string, 0, len(s.users))
+	for u := range s.users {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	return users, nil
+}
@@ -0,0 +1,25 @@
+package storetest_test
+
+import (
+	"testing"
+
+	"github.com/Sabnaj-42/BookServer-API/cacheHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/dataHandler/storetest"
+)
+
+func TestMemStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) dh.Store {
+		return dh.NewMemStore()
+	})
+}
+
+func TestCachedStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) dh.Store {
+		s, err := dh.NewCachedStore(dh.NewMemStore(), "lru", cacheHandler.NewBudget(1<<20))
+		if err != nil {
+			t.Fatalf("NewCachedStore: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,681 @@
+// Package storetest checks that a book store behaves the way the rest of the server relies on:
+// the semantics documented on dataHandler.Store, the optional interfaces it implements, and its
+// behavior under concurrent calls. A new backend runs it from its own tests:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) dh.Store {
+//			s := mystore.Open(t.TempDir())
+//			t.Cleanup(func() { s.Close() })
+//			return s
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// Factory returns an empty store for one test, cleaning it up through t
+type Factory func(t *testing.T) dh.Store
+
+// Concurrency is how many goroutines the concurrency checks run at once
+var Concurrency = 16
+
+// Run checks stores from newStore, every check in a subtest with a store of its own. ChangeLog,
+// Transactor and ChangeCompactor are checked when the store implements them.
+func Run(t *testing.T, newStore Factory) {
+	checks := []struct {
+		name string
+		run  func(t *testing.T, s dh.Store)
+	}{
+		{"CRUD", testCRUD},
+		{"ISBNOptional", testISBNOptional},
+		{"ISBNUnique", testISBNUnique},
+		{"Idempotent", testIdempotent},
+		{"VersionConflict", testVersionConflict},
+		{"GetBooksByISBNs", testGetBooksByISBNs},
+		{"UpdatedAfter", testUpdatedAfter},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ConsistentList", testConsistentList},
+		{"ChangeLog", testChangeLog},
+		{"Tx", testTx},
+		{"Compaction", testCompaction},
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) { c.run(t, newStore(t)) })
+	}
+}
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// book makes version 1 of a book as the handlers create it, updated n minutes after epoch
+func book(id, isbn string, n int) dh.Book {
+	return dh.Book{
+		ID: id, ISBN: isbn, Name: "Book " + id, Genre: "test",
+		Authors:   []dh.Author{{Name: "Author " + id}},
+		UpdatedAt: epoch.Add(time.Duration(n) * time.Minute),
+		Version:   1,
+	}
+}
+
+// touched returns b as the next update writes it
+func touched(b dh.Book, name string) dh.Book {
+	b.Name = name
+	b.Touch(b.UpdatedAt.Add(time.Minute))
+	return b
+}
+
+func mustCreate(t *testing.T, s dh.Store, b dh.Book) {
+	t.Helper()
+	if err := s.CreateBook(context.Background(), b); err != nil {
+		t.Fatalf("CreateBook(%s): %v", b.ID, err)
+	}
+}
+
+func mustUpdate(t *testing.T, s dh.Store, b dh.Book) {
+	t.Helper()
+	if err := s.UpdateBook(context.Background(), b); err != nil {
+		t.Fatalf("UpdateBook(%s): %v", b.ID, err)
+	}
+}
+
+// wantBook fails unless ref finds want
+func wantBook(t *testing.T, s dh.Store, ref string, want dh.Book) {
+	t.Helper()
+	got, err := s.GetBook(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("GetBook(%q): %v", ref, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetBook(%q) = %+v, want %+v", ref, got, want)
+	}
+}
+
+// wantErr fails unless err is target
+func wantErr(t *testing.T, call string, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("%s: got error %v, want %v", call, err, target)
+	}
+}
+
+func ids(books []dh.Book) []string {
+	list := make([]string, len(books))
+	for i, b := range books {
+		list[i] = b.ID
+	}
+	return list
+}
+
+func sortedIDs(books []dh.Book) []string {
+	list := ids(books)
+	sort.Strings(list)
+	return list
+}
+
+func testCRUD(t *testing.T, s dh.Store) {
+	ctx := context.Background()
+	a, b := book("a", "978-0-00-000001-1", 1), book("b", "978-0-00-000002-2", 2)
+	mustCreate(t, s, a)
+	mustCreate(t, s, b)
+	wantBook(t, s, "a", a)
+	wantBook(t, s, a.ISBN, a)
+
+	books, err := s.ListBooks(ctx)
+	if err != nil {
+		t.Fatalf("ListBooks: %v", err)
+	}
+	if got := sortedIDs(books); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("ListBooks = %v, want [a b]", got)
+	}
+
+	a2 := touched(a, "Renamed")
+	mustUpdate(t, s, a2)
+	wantBook(t, s, "a", a2)
+
+	if err := s.DeleteBook(ctx, a.ISBN); err != nil {
+		t.Fatalf("DeleteBook by ISBN: %v", err)
+	}
+	if err := s.DeleteBook(ctx, "b"); err != nil {
+		t.Fatalf("DeleteBook by ID: %v", err)
+	}
+	for _, ref := range []string{"a", a.ISBN, "b", "missing"} {
+		_, err := s.GetBook(ctx, ref)
+		wantErr(t, "GetBook("+ref+")", err, dh.ErrBookNotFound)
+	}
+	wantErr(t, "DeleteBook(missing)", s.DeleteBook(ctx, "a"), dh.ErrBookNotFound)
+	wantErr(t, "UpdateBook(missing)", s.UpdateBook(ctx, touched(a2, "Again")), dh.ErrBookNotFound)
+	if books, err := s.ListBooks(ctx); err != nil || len(books) != 0 {
+		t.Fatalf("ListBooks after deleting all = %v, %v, want none", ids(books), err)
+	}
+}
+
+func testISBNOptional(t *testing.T, s dh.Store) {
+	a, b := book("a", "", 1), book("b", "", 2)
+	mustCreate(t, s, a)
+	mustCreate(t, s, b) //no ISBN clashes with another missing one
+	wantBook(t, s, "a", a)
+	wantBook(t, s, "b", b)
+	_, err := s.GetBook(context.Background(), "")
+	wantErr(t, `GetBook("")`, err, dh.ErrBookNotFound)
+}
+
+func testISBNUnique(t *testing.T, s dh.Store) {
+	ctx := context.Background()
+	const isbn, other = "978-0-00-000001-1", "978-0-00-000002-2"
+	a := book("a", isbn, 1)
+	mustCreate(t, s, a)
+	wantErr(t, "CreateBook with the same ID", s.CreateBook(ctx, book("a", other, 2)), dh.ErrBookExists)
+	wantErr(t, "CreateBook with a taken ISBN", s.CreateBook(ctx, book("b", isbn, 2)), dh.ErrBookExists)
+
+	b := book("b", other, 2)
+	mustCreate(t, s, b)
+	taken := touched(b, b.Name)
+	taken.ISBN = isbn
+	wantErr(t, "UpdateBook to a taken ISBN", s.UpdateBook(ctx, taken), dh.ErrBookExists)
+	wantBook(t, s, other, b)
+
+	//an ISBN given up is free again and no longer finds its old book
+	moved := touched(a, a.Name)
+	moved.ISBN = "978-0-00-000003-3"
+	mustUpdate(t, s, moved)
+	wantBook(t, s, moved.ISBN, moved)
+	if _, err := s.GetBook(ctx, isbn); !errors.Is(err, dh.ErrBookNotFound) {
+		t.Fatalf("GetBook(old ISBN): got %v, want ErrBookNotFound", err)
+	}
+	c := book("c", isbn, 3)
+	mustCreate(t, s, c)
+	wantBook(t, s, isbn, c)
+
+	if err := s.DeleteBook(ctx, "c"); err != nil {
+		t.Fatalf("DeleteBook: %v", err)
+	}
+	mustCreate(t, s, book("d", isbn, 4)) //and after a delete
+}
+
+func testIdempotent(t *testing.T, s dh.Store) {
+	a := book("a", "978-0-00-000001-1", 1)
+	mustCreate(t, s, a)
+	mustCreate(t, s, a) //a retried create that landed
+	a2 := touched(a, "Renamed")
+	mustUpdate(t, s, a2)
+	mustUpdate(t, s, a2) //a retried update that landed
+	wantBook(t, s, "a", a2)
+
+	if log, ok := s.(dh.ChangeLog); ok {
+		changes, err := log.Changes(context.Background(), 0, 10)
+		if err != nil {
+			t.Fatalf("Changes: %v", err)
+		}
+		if len(changes) != 2 {
+			t.Fatalf("retried writes logged %d changes, want 2", len(changes))
+		}
+	}
+}
+
+func testVersionConflict(t *testing.T, s dh.Store) {
+	ctx := context.Background()
+	a := book("a", "", 1)
+	mustCreate(t, s, a)
+	a2 := touched(a, "Second")
+	mustUpdate(t, s, a2)
+
+	stale := touched(a, "Stale") //read before a2 landed
+	wantErr(t, "UpdateBook from a stale read", s.UpdateBook(ctx, stale), dh.ErrVersionConflict)
+	skipped := a2
+	skipped.Name, skipped.Version = "Skipped", a2.Version+2
+	wantErr(t, "UpdateBook skipping a version", s.UpdateBook(ctx, skipped), dh.ErrVersionConflict)
+	wantBook(t, s, "a", a2)
+}
+
+func testGetBooksByISBNs(t *testing.T, s dh.Store) {
+	a, b := book("a", "978-0-00-000001-1", 1), book("b", "978-0-00-000002-2", 2)
+	mustCreate(t, s, a)
+	mustCreate(t, s, b)
+	mustCreate(t, s, book("c", "", 3))
+	got, err := s.GetBooksByISBNs(context.Background(), []string{a.ISBN, "unknown", b.ISBN, a.ISBN})
+	if err != nil {
+		t.Fatalf("GetBooksByISBNs: %v", err)
+	}
+	want := map[string]dh.Book{a.ISBN: a, b.ISBN: b}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetBooksByISBNs = %v, want %v", got, want)
+	}
+}
+
+func testUpdatedAfter(t *testing.T, s dh.Store) {
+	ctx := context.Background()
+	a, b, c := book("a", "", 3), book("b", "", 1), book("c", "", 2)
+	for _, x := range []dh.Book{a, b, c} {
+		mustCreate(t, s, x)
+	}
+	b2 := touched(b, "Later")
+	b2.UpdatedAt = epoch.Add(4 * time.Minute) //moves it last
+	mustUpdate(t, s, b2)
+
+	check := func(after time.Time, want ...string) {
+		t.Helper()
+		books, err := s.ListBooksUpdatedAfter(ctx, after)
+		if err != nil {
+			t.Fatalf("ListBooksUpdatedAfter: %v", err)
+		}
+		if got := ids(books); !reflect.DeepEqual(got, want) && len(got)+len(want) != 0 {
+			t.Fatalf("ListBooksUpdatedAfter(%v) = %v, want %v", after, got, want)
+		}
+	}
+	check(time.Time{}, "c", "a", "b")
+	check(epoch.Add(2*time.Minute), "a", "b") //strictly after
+	check(epoch.Add(3*time.Minute), "b")
+	check(epoch.Add(4 * time.Minute))
+}
+
+// parallel runs fn(i) for i below n on Concurrency goroutines and returns their errors
+func parallel(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return errs
+}
+
+func testConcurrentCreates(t *testing.T, s dh.Store) {
+	ctx := context.Background()
+	const n = 200
+	for i, err := range parallel(n, func(i int) error {
+		return s.CreateBook(ctx, book(fmt.Sprint("book", i), fmt.Sprintf("isbn-%d", i), i))
+	}) {
+		if err != nil {
+			t.Fatalf("CreateBook(book%d): %v", i, err)
+		}
+	}
+	books, err := s.ListBooks(ctx)
+	if err != nil || len(books) != n {
+		t.Fatalf("ListBooks found %d books, %v, want %d", len(books), err, n)
+	}
+
+	//the same ISBN for many books: exactly one gets it
+	won := 0
+	for _, err := range parallel(Concurrency, func(i int) error {
+		return s.CreateBook(ctx, book(fmt.Sprint("dup", i), "isbn-shared", i))
+	}) {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, dh.ErrBookExists):
+			t.Fatalf("CreateBook with a contested ISBN: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d books were created with the same ISBN, want 1", won)
+	}
+}
+
+func testConcurrentUpdates(t *testing.T, s dh.Store) {
+	ctx := context.Background()
+	a := book("a", "", 1)
+	mustCreate(t, s, a)
+
+	//every writer read version 1, exactly one may write version 2
+	won := 0
+	for _, err := range parallel(Concurrency, func(i int) error {
+		return s.UpdateBook(ctx, touched(a, fmt.Sprint("writer ", i)))
+	}) {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, dh.ErrVersionConflict):
+			t.Fatalf("contested UpdateBook: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d updates of the same version landed, want 1", won)
+	}
+
+	//writers retrying read, modify, write never lose an update
+	const rounds = 10
+	for i, err := range parallel(Concurrency, func(int) error {
+		for range rounds {
+			for {
+				cur, err := s.GetBook(ctx, "a")
+				if err != nil {
+					return err
+				}
+				err = s.UpdateBook(ctx, touched(cur, cur.Name))
+				if !errors.Is(err, dh.ErrVersionConflict) {
+					if err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+		return nil
+	}) {
+		if err != nil {
+			t.Fatalf("writer %d: %v", i, err)
+		}
+	}
+	got, err := s.GetBook(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetBook: %v", err)
+	}
+	if want := int64(2 + Concurrency*rounds); got.Version != want {
+		t.Fatalf("version after every update = %d, want %d", got.Version, want)
+	}
+}
+
+// testConsistentList moves a book between two ISBNs while others list the catalog, which must see
+// it exactly once every time
+func testConsistentList(t *testing.T, s dh.Store) {
+	ctx := context.Background()
+	for i := range 20 {
+		mustCreate(t, s, book(fmt.Sprint("b", i), fmt.Sprint("isbn-", i), i))
+	}
+	moving := book("moving", "isbn-a", 0)
+	mustCreate(t, s, moving)
+
+	done := make(chan struct{})
+	var writeErr error
+	go func() {
+		defer close(done)
+		b := moving
+		for i := range 200 {
+			b = touched(b, b.Name)
+			b.ISBN = []string{"isbn-a", "isbn-b"}[i%2]
+			if writeErr = s.UpdateBook(ctx, b); writeErr != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if writeErr != nil {
+				t.Fatalf("UpdateBook: %v", writeErr)
+			}
+			return
+		default:
+		}
+		books, err := s.ListBooks(ctx)
+		if err != nil {
+			t.Fatalf("ListBooks: %v", err)
+		}
+		seen := 0
+		for _, b := range books {
+			if b.ID == "moving" {
+				seen++
+			}
+		}
+		if len(books) != 21 || seen != 1 {
+			t.Fatalf("ListBooks during writes: %d books, the moving one %d times; want 21 and once", len(books), seen)
+		}
+	}
+}
+
+func testChangeLog(t *testing.T, s dh.Store) {
+	log, ok := s.(dh.ChangeLog)
+	if !ok {
+		t.Skip("no change log")
+	}
+	ctx := context.Background()
+	a := book("a", "978-0-00-000001-1", 1)
+	mustCreate(t, s, a)
+	a2 := touched(a, "Renamed")
+	mustUpdate(t, s, a2)
+	mustCreate(t, s, book("b", "", 2))
+	if err := s.DeleteBook(ctx, a.ISBN); err != nil {
+		t.Fatalf("DeleteBook: %v", err)
+	}
+	wantOps := []string{dh.OpCreate, dh.OpUpdate, dh.OpCreate, dh.OpDelete}
+	wantIDs := []string{"a", "a", "b", "a"}
+
+	//pages of two, each after the last Seq of the one before
+	var all []dh.Change
+	var after int64
+	for {
+		page, err := log.Changes(ctx, after, 2)
+		if err != nil {
+			t.Fatalf("Changes(%d, 2): %v", after, err)
+		}
+		if len(page) > 2 {
+			t.Fatalf("Changes(%d, 2) returned %d changes", after, len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+		after = page[len(page)-1].Seq
+	}
+	if len(all) != len(wantOps) {
+		t.Fatalf("change log has %d changes, want %d", len(all), len(wantOps))
+	}
+	for i, c := range all {
+		if c.Op != wantOps[i] || c.ID != wantIDs[i] {
+			t.Fatalf("change %d is %s %s, want %s %s", i, c.Op, c.ID, wantOps[i], wantIDs[i])
+		}
+		if i > 0 && c.Seq <= all[i-1].Seq {
+			t.Fatalf("change %d has Seq %d after %d, want increasing", i, c.Seq, all[i-1].Seq)
+		}
+	}
+	if b := all[1].Book; b == nil || !reflect.DeepEqual(*b, a2) {
+		t.Fatalf("update logged %+v, want the book after it %+v", b, a2)
+	}
+	if all[3].Book != nil {
+		t.Fatalf("delete logged a book")
+	}
+
+	//ChangesFrom points just before the first change committed at or after a time
+	for _, c := range all {
+		after, err := dh.ChangesFrom(ctx, s, c.At)
+		if err != nil {
+			t.Fatalf("ChangesFrom: %v", err)
+		}
+		var want int64
+		for _, prev := range all {
+			if prev.At.Before(c.At) {
+				want = prev.Seq
+			}
+		}
+		if after != want {
+			t.Fatalf("ChangesFrom(change %d's time) is %d, want %d", c.Seq, after, want)
+		}
+	}
+	if after, err := dh.ChangesFrom(ctx, s, time.Now()); err != nil || after != all[len(all)-1].Seq {
+		t.Fatalf("ChangesFrom(now) is %d, %v, want %d", after, err, all[len(all)-1].Seq)
+	}
+
+	books, err := dh.BooksAsOf(ctx, s, time.Now())
+	if err != nil {
+		t.Fatalf("BooksAsOf: %v", err)
+	}
+	if got := ids(books); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("replaying the log gives %v, want [b]", got)
+	}
+}
+
+var errAbort = errors.New("abort")
+
+func testTx(t *testing.T, s dh.Store) {
+	if _, ok := s.(dh.Transactor); !ok {
+		t.Skip("no transactions")
+	}
+	ctx := context.Background()
+	a := book("a", "isbn-a", 1)
+	mustCreate(t, s, a)
+
+	err := dh.InTx(ctx, s, func(tx dh.Store) error {
+		if err := tx.CreateBook(ctx, book("b", "isbn-b", 2)); err != nil {
+			return err
+		}
+		return tx.UpdateBook(ctx, touched(a, "In tx"))
+	})
+	if err != nil {
+		t.Fatalf("Tx: %v", err)
+	}
+	committed := touched(a, "In tx")
+	wantBook(t, s, "a", committed)
+
+	seq := lastSeq(t, s)
+	err = dh.InTx(ctx, s, func(tx dh.Store) error {
+		if err := tx.CreateBook(ctx, book("c", "isbn-c", 3)); err != nil {
+			return err
+		}
+		if err := tx.DeleteBook(ctx, "a"); err != nil {
+			return err
+		}
+		if _, err := tx.GetBook(ctx, "c"); err != nil { //its own writes are visible inside
+			return err
+		}
+		return errAbort
+	})
+	wantErr(t, "Tx", err, errAbort)
+	wantBook(t, s, "a", committed)
+	_, err = s.GetBook(ctx, "c")
+	wantErr(t, "GetBook of a rolled back create", err, dh.ErrBookNotFound)
+	if got := lastSeq(t, s); got != seq {
+		t.Fatalf("rolled back writes are in the change log, last Seq %d, want %d", got, seq)
+	}
+	testTxChanges(t, s, seq)
+}
+
+// testTxChanges reads the change log while a transaction that rolls back is running: the read
+// must not see its writes, they never happened and their Seqs go to the writes after it
+func testTxChanges(t *testing.T, s dh.Store, seq int64) {
+	log, ok := s.(dh.ChangeLog)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+	read := make(chan []dh.Change, 1)
+	var early []dh.Change
+	err := dh.InTx(ctx, s, func(tx dh.Store) error {
+		if err := tx.CreateBook(ctx, book("x", "isbn-x", 5)); err != nil {
+			return err
+		}
+		go func() {
+			changes, err := log.Changes(ctx, seq, 10)
+			if err != nil {
+				t.Errorf("Changes(%d, 10): %v", seq, err)
+			}
+			read <- changes
+		}()
+		select {
+		case early = <-read: //a store that waits for the transaction doesn't get here
+		case <-time.After(20 * time.Millisecond):
+		}
+		return errAbort
+	})
+	wantErr(t, "Tx", err, errAbort)
+	if early == nil {
+		early = <-read
+	}
+	for _, c := range early {
+		if c.ID == "x" {
+			t.Fatalf("Changes during a transaction returned its uncommitted write %d %s %s", c.Seq, c.Op, c.ID)
+		}
+	}
+	mustCreate(t, s, book("y", "isbn-y", 6))
+	changes, err := log.Changes(ctx, seq, 10)
+	if err != nil {
+		t.Fatalf("Changes(%d, 10): %v", seq, err)
+	}
+	if len(changes) != 1 || changes[0].ID != "y" {
+		t.Fatalf("change log after a rolled back transaction has %d changes after %d, want the create of y", len(changes), seq)
+	}
+}
+
+// lastSeq is the Seq of the latest change, 0 without a change log
+func lastSeq(t *testing.T, s dh.Store) int64 {
+	t.Helper()
+	var seq int64
+	err := dh.EachChange(context.Background(), s, 0, func(c dh.Change) error {
+		seq = c.Seq
+		return nil
+	})
+	if err != nil && !errors.Is(err, dh.ErrNoChangeLog) {
+		t.Fatalf("Changes: %v", err)
+	}
+	return seq
+}
+
+func testCompaction(t *testing.T, s dh.Store) {
+	if _, ok := s.(dh.ChangeCompactor); !ok {
+		t.Skip("no compaction")
+	}
+	ctx := context.Background()
+	a := book("a", "", 1)
+	mustCreate(t, s, a)
+	a2 := touched(a, "Second")
+	mustUpdate(t, s, a2)
+	mustUpdate(t, s, touched(a2, "Third"))
+	b := book("b", "", 2)
+	mustCreate(t, s, b)
+	mustUpdate(t, s, touched(b, "Second"))
+	if err := s.DeleteBook(ctx, "b"); err != nil {
+		t.Fatalf("DeleteBook: %v", err)
+	}
+	mustCreate(t, s, book("c", "", 3))
+	upTo := lastSeq(t, s)
+	mustCreate(t, s, book("d", "", 4))
+
+	dropped, err := dh.Superseded(ctx, s, upTo)
+	if err != nil {
+		t.Fatalf("Superseded: %v", err)
+	}
+	n, err := dh.CompactChanges(ctx, s, upTo)
+	if err != nil {
+		t.Fatalf("CompactChanges: %v", err)
+	}
+	if n != len(dropped) || n != 2 { //the second version of a and of b
+		t.Fatalf("CompactChanges dropped %d changes, Superseded listed %d, want 2", n, len(dropped))
+	}
+	var ops []string
+	err = dh.EachChange(ctx, s, 0, func(c dh.Change) error {
+		ops = append(ops, c.Op+" "+c.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	want := []string{"create a", "update a", "create b", "delete b", "create c", "create d"}
+	if !reflect.DeepEqual(ops, want) { //consumers behind the compaction point still see b come and go
+		t.Fatalf("compacted log is %v, want %v", ops, want)
+	}
+	books, err := dh.BooksAsOf(ctx, s, time.Now())
+	if err != nil {
+		t.Fatalf("BooksAsOf: %v", err)
+	}
+	list, err := s.ListBooks(ctx)
+	if err != nil {
+		t.Fatalf("ListBooks: %v", err)
+	}
+	if got, want := sortedIDs(books), sortedIDs(list); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replaying the compacted log gives %v, the store has %v", got, want)
+	}
+	if got := lastSeq(t, s); got != upTo+1 {
+		t.Fatalf("last Seq after compaction = %d, want %d", got, upTo+1)
+	}
+}
@@ -0,0 +1,119 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Call is one timed call to a Store
+type Call struct {
+	Op     string //the method, e.g. "GetBook"
+	Key    string //what it was about, a ref, an ID or a count, for logging
+	At     time.Time
+	Took   time.Duration
+	Err    error
+	Failed bool //Err is more than a not found or conflict answer
+}
+
+// TimedStore reports every call to the book Store to Observe, e.g. for the admin dashboard, /metrics
+// and the slow call log. The shelf, review and notification stores aren't timed. Not found and conflict errors, version conflicts included, are answers, only other
+// errors count as failures.
+type TimedStore struct {
+	Store   Store
+	Observe func(ctx context.Context, c Call)
+	Clock   Clock
+}
+
+func NewTimedStore(store Store, observe func(ctx context.Context, c Call)) *TimedStore {
+	return &TimedStore{Store: store, Observe: observe, Clock: SystemClock{}}
+}
+
+func (s *TimedStore) done(ctx context.Context, op, key string, start time.Time, err error) {
+	now := s.Clock.Now()
+	failed := err != nil && !errors.Is(err, ErrBookNotFound) && !errors.Is(err, ErrBookExists) && !errors.Is(err, ErrVersionConflict)
+	s.Observe(ctx, Call{Op: op, Key: key, At: now, Took: now.Sub(start), Err: err, Failed: failed})
+}
+
+func (s *TimedStore) ListBooks(ctx context.Context) ([]Book, error) {
+	start := s.Clock.Now()
+	books, err := s.Store.ListBooks(ctx)
+	s.done(ctx, "ListBooks", "", start, err)
+	return books, err
+}
+
+func (s *TimedStore) GetBook(ctx context.Context, ref string) (Book, error) {
+	start := s.Clock.Now()
+	book, err := s.Store.GetBook(ctx, ref)
+	s.done(ctx, "GetBook", ref, start, err)
+	return book, err
+}
+
+func (s *TimedStore) GetBooksByISBNs(ctx context.Context, isbns []string) (map[string]Book, error) {
+	start := s.Clock.Now()
+	books, err := s.Store.GetBooksByISBNs(ctx, isbns)
+	s.done(ctx, "GetBooksByISBNs", strconv.Itoa(len(isbns))+" ISBNs", start, err)
+	return books, err
+}
+
+func (s *TimedStore) ListBooksUpdatedAfter(ctx context.Context, t time.Time) ([]Book, error) {
+	start := s.Clock.Now()
+	books, err := s.Store.ListBooksUpdatedAfter(ctx, t)
+	s.done(ctx, "ListBooksUpdatedAfter", t.Format(time.RFC3339), start, err)
+	return books, err
+}
+
+func (s *TimedStore) CreateBook(ctx context.Context, book Book) error {
+	start := s.Clock.Now()
+	err := s.Store.CreateBook(ctx, book)
+	s.done(ctx, "CreateBook", book.ID, start, err)
+	return err
+}
+
+func (s *TimedStore) UpdateBook(ctx context.Context, book Book) error {
+	start := s.Clock.Now()
+	err := s.Store.UpdateBook(ctx, book)
+	s.done(ctx, "UpdateBook", book.ID, start, err)
+	return err
+}
+
+func (s *TimedStore) DeleteBook(ctx context.Context, ref string) error {
+	start := s.Clock.Now()
+	err := s.Store.DeleteBook(ctx, ref)
+	s.done(ctx, "DeleteBook", ref, start, err)
+	return err
+}
+
+func (s *TimedStore) FindBooks(ctx context.Context, match func(Book) bool) ([]Book, error) {
+	start := s.Clock.Now()
+	books, err := FindBooks(ctx, s.Store, match)
+	s.done(ctx, "FindBooks", "", start, err)
+	return books, err
+}
+
+func (s *TimedStore) Changes(ctx context.Context, after int64, limit int) ([]Change, error) {
+	log, ok := s.Store.(ChangeLog)
+	if !ok {
+		return nil, ErrNoChangeLog
+	}
+	start := s.Clock.Now()
+	changes, err := log.Changes(ctx, after, limit)
+	s.done(ctx, "Changes", strconv.FormatInt(after, 10), start, err)
+	return changes, err
+}
+
+func (s *TimedStore) CompactChanges(ctx context.Context, upTo int64) (int, error) {
+	start := s.Clock.Now()
+	n, err := CompactChanges(ctx, s.Store, upTo)
+	s.done(ctx, "CompactChanges", strconv.FormatInt(upTo, 10), start, err)
+	return n, err
+}
+
+// Tx is observed as one call, however many writes fn makes
+func (s *TimedStore) Tx(ctx context.Context, fn func(tx Store) error) error {
+	start := s.Clock.Now()
+	err := InTx(ctx, s.Store, fn)
+	s.done(ctx, "Tx", "", start, err)
+	return err
+}
@@ -0,0 +1,105 @@
+package dataHandler
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Sabnaj-42/BookServer-API/cacheHandler"
+)
+
+// Transliterator romanizes text, so titles and names written in another script can be found
+// by their Latin spelling and the other way round. Implementations must be safe for concurrent use.
+type Transliterator interface {
+	Romanize(s string) string //lower case, comparable with strings.Contains
+}
+
+// TransliteratorFunc adapts a function to Transliterator
+type TransliteratorFunc func(s string) string
+
+func (f TransliteratorFunc) Romanize(s string) string { return f(s) }
+
+// ScriptTable is a Transliterator spelling letters of other scripts by table. Accented Latin letters
+// are folded like AuthorKey does, letters the table leaves out are kept as they are.
+type ScriptTable map[rune]string
+
+// Romanizer covers Cyrillic (BGN/PCGN-like) and Greek
+var Romanizer = ScriptTable{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o", 'ϊ': "i", 'ϋ': "y",
+}
+
+// spellings that romanizations of the same name disagree on, folded on both sides of a search
+// so "Dostoevsky", "Dostoevskiy" and "Достоевский" meet
+var romanFolds = strings.NewReplacer("iy", "y", "ij", "y", "yy", "y")
+
+func (t ScriptTable) Romanize(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := t[r]; ok {
+			sb.WriteString(latin)
+		} else if f, ok := diacritics[r]; ok {
+			sb.WriteRune(f)
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	return romanFolds.Replace(strings.Join(strings.Fields(sb.String()), " "))
+}
+
+// TranslitIndex keeps the romanized title, original title and author names of the books searched,
+// computed once per book version and kept within the cache budget. Safe for concurrent use.
+type TranslitIndex struct {
+	T    Transliterator
+	keys *cacheHandler.Cache[translitKey] //by book ID
+}
+
+type translitKey struct {
+	version int64
+	key     string
+}
+
+// NewTranslitIndex romanizes with t and keeps the results within budget, evicting the least recently used
+func NewTranslitIndex(t Transliterator, budget *cacheHandler.Budget) *TranslitIndex {
+	size := func(k translitKey) int64 { return int64(len(k.key)) + 48 }
+	return &TranslitIndex{T: t, keys: cacheHandler.New("translit", cacheHandler.NewLRU(), budget, size)}
+}
+
+// Key returns b's romanized text, its fields separated by newlines a query can't contain
+func (x *TranslitIndex) Key(b Book) string {
+	if k, ok := x.keys.Get(b.ID); ok && k.version == b.Version {
+		return k.key
+	}
+	parts := make([]string, 0, 2+len(b.Authors))
+	parts = append(parts, x.T.Romanize(b.Name), x.T.Romanize(b.OriginalTitle))
+	for _, a := range b.Authors {
+		parts = append(parts, x.T.Romanize(a.Name))
+	}
+	key := strings.Join(parts, "\n")
+	x.keys.Set(b.ID, translitKey{version: b.Version, key: key})
+	return key
+}
+
+// Matches reports whether b's romanized text contains query, romanized with Query
+func (x *TranslitIndex) Matches(b Book, query string) bool {
+	return len(query) != 0 && strings.Contains(x.Key(b), query)
+}
+
+// Query romanizes a search the way Key romanizes books
+func (x *TranslitIndex) Query(q string) string {
+	return x.T.Romanize(q)
+}
+
+// Invalidate drops book id, its next search romanizes it again
+func (x *TranslitIndex) Invalidate(id string, _ ...string) {
+	x.keys.Delete(id)
+}
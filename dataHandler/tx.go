@@ -0,0 +1,121 @@
+package dataHandler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoTx is returned by stores that can't apply writes all or nothing
+var ErrNoTx = errors.New("store has no transactions")
+
+// Transactor is implemented by stores that can apply several writes all or nothing
+type Transactor interface {
+	// Tx runs fn with a Store whose writes all land when fn returns nil, and are all undone when it
+	// returns an error or panics; the error is returned as is. Other callers don't see the writes
+	// before fn returns. The Store is only valid inside fn, fn must not use the outer store.
+	Tx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// InTx runs fn in a transaction of store, ErrNoTx when store isn't a Transactor
+func InTx(ctx context.Context, store Store, fn func(tx Store) error) error {
+	t, ok := store.(Transactor)
+	if !ok {
+		return ErrNoTx
+	}
+	return t.Tx(ctx, fn)
+}
+
+// Tx holds s.mu alone for the whole transaction, so no other call sees its writes early, and
+// journals the books it writes; a rollback puts them back and truncates the change log
+func (s *MemStore) Tx(ctx context.Context, fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memTx{s: s, changes: len(s.changes), lastSeq: s.lastSeq}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// memTx is the Store a MemStore transaction runs on, s.mu is held by Tx
+type memTx struct {
+	s       *MemStore
+	journal []undo //oldest write first
+	changes int    //length of the change log before the transaction
+	lastSeq int64
+}
+
+type undo struct {
+	id  string
+	old *Book //nil when the book didn't exist
+}
+
+// note journals the state of book id before a write
+func (t *memTx) note(id string) {
+	u := undo{id: id}
+	if b, ok := t.s.books.get(id); ok {
+		u.old = &b
+	}
+	t.journal = append(t.journal, u)
+}
+
+// rollback undoes the journal newest first
+func (t *memTx) rollback() {
+	for i := len(t.journal) - 1; i >= 0; i-- {
+		u := t.journal[i]
+		var cur *Book
+		if b, ok := t.s.books.get(u.id); ok {
+			cur = &b
+		}
+		if cur != nil || u.old != nil {
+			t.s.swap("", cur, u.old)
+		}
+	}
+	t.s.changes, t.s.lastSeq = t.s.changes[:t.changes], t.lastSeq
+}
+
+func (t *memTx) ListBooks(_ context.Context) ([]Book, error) {
+	return t.s.listBooks(), nil
+}
+
+func (t *memTx) GetBook(_ context.Context, ref string) (Book, error) {
+	b, ok := t.s.lookup(ref)
+	if !ok {
+		return Book{}, ErrBookNotFound
+	}
+	return b, nil
+}
+
+func (t *memTx) GetBooksByISBNs(_ context.Context, isbns []string) (map[string]Book, error) {
+	return t.s.booksByISBNs(isbns), nil
+}
+
+func (t *memTx) ListBooksUpdatedAfter(_ context.Context, after time.Time) ([]Book, error) {
+	return t.s.updatedAfter(after), nil
+}
+
+func (t *memTx) CreateBook(ctx context.Context, book Book) error {
+	t.note(book.ID)
+	return t.s.createBook(ctx, book)
+}
+
+func (t *memTx) UpdateBook(ctx context.Context, book Book) error {
+	t.note(book.ID)
+	return t.s.updateBook(ctx, book)
+}
+
+func (t *memTx) DeleteBook(ctx context.Context, ref string) error {
+	if b, ok := t.s.lookup(ref); ok {
+		t.note(b.ID)
+	}
+	return t.s.deleteBook(ctx, ref)
+}
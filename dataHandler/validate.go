@@ -0,0 +1,88 @@
+package dataHandler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validation rule names reported back to clients
+const (
+	RuleRequired = "required"
+	RuleLanguage = "iso639_1"
+	RuleDate     = "date"
+	RuleFormat   = "format"
+	RuleBookID   = "book_id"
+
+	// password policy rules, see authHandler.PasswordPolicy
+	RulePasswordLength   = "password_length"
+	RulePasswordClasses  = "password_classes"
+	RulePasswordUsername = "password_username"
+	RulePasswordBreached = "password_breached"
+)
+
+type FieldError struct { //a single field violation found by Validate
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Args  []any  `json:"-"` //extra message arguments after the field name, e.g. a minimum length
+}
+
+type Validator interface { //implemented by every model accepted from clients
+	Validate() []FieldError
+}
+
+func required(errs []FieldError, field, value string) []FieldError {
+	if len(strings.TrimSpace(value)) == 0 {
+		errs = append(errs, FieldError{Field: field, Rule: RuleRequired})
+	}
+	return errs
+}
+
+func (a Author) validate(prefix string) []FieldError {
+	return required(nil, prefix+"name", a.Name)
+}
+
+// LooksLikeISBN reports whether s is 10 or 13 digits, the last of 10 may be an X, once hyphens and
+// spaces are dropped. Book routes take an ID or an ISBN, so IDs must never read as one.
+func LooksLikeISBN(s string) bool {
+	s = strings.NewReplacer("-", "", " ", "").Replace(s)
+	if len(s) != 10 && len(s) != 13 {
+		return false
+	}
+	for i, c := range s {
+		if (c < '0' || c > '9') && !(len(s) == 10 && i == 9 && (c == 'X' || c == 'x')) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b Book) Validate() []FieldError {
+	var errs []FieldError
+	if LooksLikeISBN(b.ID) {
+		errs = append(errs, FieldError{Field: "id", Rule: RuleBookID})
+	}
+	errs = required(errs, "name", b.Name)
+	if len(b.Authors) == 0 {
+		errs = append(errs, FieldError{Field: "authors", Rule: RuleRequired})
+	}
+	for i, a := range b.Authors {
+		errs = append(errs, a.validate(fmt.Sprintf("authors[%d].", i))...)
+	}
+	if len(b.Language) != 0 && !ValidLanguage(b.Language) {
+		errs = append(errs, FieldError{Field: "language", Rule: RuleLanguage})
+	}
+	if _, err := ParseDate(b.Published); len(b.Published) != 0 && err != nil {
+		errs = append(errs, FieldError{Field: "published", Rule: RuleDate})
+	}
+	if len(b.Format) != 0 && !Formats[b.Format] {
+		errs = append(errs, FieldError{Field: "format", Rule: RuleFormat})
+	}
+	return errs
+}
+
+func (c Credentials) Validate() []FieldError {
+	var errs []FieldError
+	errs = required(errs, "username", c.Username)
+	errs = required(errs, "password", c.Password)
+	return errs
+}
@@ -0,0 +1,83 @@
+package errHandler
+
+// Code names an error in every problem response, clients branch on it rather than on the
+// localized detail. Codes are part of the API: add new ones, never rename or reuse them.
+type Code string
+
+const (
+	InvalidBody          Code = "INVALID_BODY"          //unreadable, not JSON or the wrong shape
+	InvalidEncoding      Code = "INVALID_ENCODING"      //the body isn't valid UTF-8
+	InvalidData          Code = "INVALID_DATA"          //a parameter or field with a bad value
+	ValidationFailed     Code = "VALIDATION_FAILED"     //fields break catalog rules, see errors
+	NonconformingRequest Code = "NONCONFORMING_REQUEST" //the request doesn't match the OpenAPI document, see errors
+	InvalidISBN          Code = "INVALID_ISBN"
+	InvalidDate          Code = "INVALID_DATE"
+	InvalidTimestamp     Code = "INVALID_TIMESTAMP"
+	InvalidMonth         Code = "INVALID_MONTH"
+	InvalidSort          Code = "INVALID_SORT"
+	InvalidLocale        Code = "INVALID_LOCALE"
+	InvalidCursor        Code = "INVALID_CURSOR"
+	InvalidFormat        Code = "INVALID_FORMAT"
+	InvalidSize          Code = "INVALID_SIZE"
+	InvalidImage         Code = "INVALID_IMAGE"
+
+	BookNotFound         Code = "BOOK_NOT_FOUND"
+	DuplicateISBN        Code = "DUPLICATE_ISBN"
+	VersionConflict      Code = "VERSION_CONFLICT"    //the book changed since it was read
+	PreconditionFailed   Code = "PRECONDITION_FAILED" //If-Match named another version of the book
+	ReindexRunning       Code = "REINDEX_RUNNING"     //a reindex is queued or running already
+	UserNotFound         Code = "USER_NOT_FOUND"
+	UserExists           Code = "USER_EXISTS"
+	SessionNotFound      Code = "SESSION_NOT_FOUND"
+	ImportNotFound       Code = "IMPORT_NOT_FOUND"
+	ShelfNotFound        Code = "SHELF_NOT_FOUND" //not a shelf name, not public or not shared with the caller
+	InvitationNotFound   Code = "INVITATION_NOT_FOUND"
+	ReviewNotFound       Code = "REVIEW_NOT_FOUND"  //unknown, hidden or deleted
+	CommentNotFound      Code = "COMMENT_NOT_FOUND" //unknown, hidden or deleted
+	NotificationNotFound Code = "NOTIFICATION_NOT_FOUND"
+	DeviceNotFound       Code = "DEVICE_NOT_FOUND"
+	SnapshotNotFound     Code = "SNAPSHOT_NOT_FOUND"
+	FileNotFound         Code = "FILE_NOT_FOUND"
+	CoverNotFound        Code = "COVER_NOT_FOUND"
+	RouteNotFound        Code = "ROUTE_NOT_FOUND"
+
+	Unauthenticated    Code = "UNAUTHENTICATED"     //no valid login
+	InvalidToken       Code = "INVALID_TOKEN"       //a refresh token that is unknown, expired or revoked
+	InvalidCredentials Code = "INVALID_CREDENTIALS" //wrong username or password
+	Forbidden          Code = "FORBIDDEN"
+	AdminAccount       Code = "ADMIN_ACCOUNT"     //admins can't delete their own account
+	InvalidSignature   Code = "INVALID_SIGNATURE" //a signed URL that expired or was altered
+	SigningDisabled    Code = "SIGNING_DISABLED"  //signed URLs are off while the built-in JWT secret is used
+	InvalidCode        Code = "INVALID_CODE"      //a wrong email confirmation code
+
+	MethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	UnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	ContentMismatch      Code = "CONTENT_MISMATCH" //the bytes aren't of the declared content type
+	FileTooLarge         Code = "FILE_TOO_LARGE"
+	BodyTooLarge         Code = "BODY_TOO_LARGE" //a JSON body over the endpoint's limit
+	ImageTooLarge        Code = "IMAGE_TOO_LARGE"
+	FileInfected         Code = "FILE_INFECTED"
+	ContentRejected      Code = "CONTENT_REJECTED" //moderation refused a review or comment
+
+	RateLimited   Code = "RATE_LIMITED"
+	QuotaExceeded Code = "QUOTA_EXCEEDED"
+	Maintenance   Code = "MAINTENANCE"
+	QueueFull     Code = "QUEUE_FULL" //too much background work is waiting
+
+	StorageError    Code = "STORAGE_ERROR"
+	StorageTimeout  Code = "STORAGE_TIMEOUT" //the storage didn't answer within its deadline
+	NoTransactions  Code = "NO_TRANSACTIONS" //the storage can't apply an atomic request all or nothing
+	ScanUnavailable Code = "SCAN_UNAVAILABLE"
+	Internal        Code = "INTERNAL_ERROR"
+)
+
+// Codes lists every code, for documentation and client generators
+var Codes = []Code{
+	InvalidBody, InvalidEncoding, InvalidData, ValidationFailed, NonconformingRequest, InvalidISBN, InvalidDate, InvalidTimestamp,
+	InvalidMonth, InvalidSort, InvalidLocale, InvalidCursor, InvalidFormat, InvalidSize, InvalidImage,
+	BookNotFound, DuplicateISBN, VersionConflict, PreconditionFailed, ReindexRunning, UserNotFound, UserExists, SessionNotFound, ImportNotFound, ShelfNotFound, InvitationNotFound, ReviewNotFound, CommentNotFound, NotificationNotFound, DeviceNotFound, SnapshotNotFound, FileNotFound, CoverNotFound, RouteNotFound,
+	Unauthenticated, InvalidToken, InvalidCredentials, Forbidden, AdminAccount, InvalidSignature, SigningDisabled, InvalidCode,
+	MethodNotAllowed, UnsupportedMediaType, ContentMismatch, FileTooLarge, BodyTooLarge, ImageTooLarge, FileInfected, ContentRejected,
+	RateLimited, QuotaExceeded, Maintenance, QueueFull,
+	StorageError, StorageTimeout, NoTransactions, ScanUnavailable, Internal,
+}
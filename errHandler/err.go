@@ -0,0 +1,77 @@
+package errHandler
+
+import (
+	"net/http"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+type Violation struct { //field violation as sent to the client
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type ValidationResponse struct {
+	Code    Code        `json:"code"`
+	Message string      `json:"message"`
+	Errors  []Violation `json:"errors"`
+}
+
+// Validation writes a 422 with the localized list of field violations
+func Validation(w http.ResponseWriter, r *http.Request, errs []dh.FieldError) {
+	violations(w, r, http.StatusUnprocessableEntity, ValidationFailed, i18n.InvalidData, errs)
+}
+
+// Nonconforming writes a 400 listing where a request departs from the API specification
+func Nonconforming(w http.ResponseWriter, r *http.Request, errs []dh.FieldError) {
+	violations(w, r, http.StatusBadRequest, NonconformingRequest, i18n.NonconformingRequest, errs)
+}
+
+func violations(w http.ResponseWriter, r *http.Request, status int, code Code, message string, errs []dh.FieldError) {
+	resp := ValidationResponse{
+		Code:    code,
+		Message: i18n.T(r, message),
+		Errors:  make([]Violation, 0, len(errs)),
+	}
+	c := ch.Of(r)
+	for _, e := range errs {
+		field := c.Name(e.Field) //spelled like the request spelled it
+		resp.Errors = append(resp.Errors, Violation{
+			Field:   field,
+			Rule:    e.Rule,
+			Message: i18n.T(r, "rule_"+e.Rule, append([]any{field}, e.Args...)...),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	ch.Encode(w, resp, c)
+}
+
+type Problem struct { //RFC 7807 problem details
+	Code     Code   `json:"code"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// WriteProblem writes an application/problem+json response for status
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code Code, detail string) {
+	p := Problem{
+		Code:     code,
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	ch.Encode(w, p, ch.Of(r))
+}
@@ -0,0 +1,98 @@
+package errHandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// HandlerFunc is a handler that returns its failure instead of answering it, a Responder turns the
+// error into the response so every handler fails the same way. Middleware, which has no result to
+// return, answers an Error with its Write.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Error fails a request with a problem document, its detail localized from key
+type Error struct {
+	status int
+	code   Code
+	key    string
+	args   []any
+	cause  error //logged, never sent
+}
+
+func New(status int, code Code, key string, args ...any) *Error {
+	return &Error{status: status, code: code, key: key, args: args}
+}
+
+// WithCause keeps the error behind e for the log, clients only get e
+func (e *Error) WithCause(cause error) *Error {
+	e.cause = cause
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%d %s: %v", e.status, e.code, e.cause)
+	}
+	return fmt.Sprintf("%d %s", e.status, e.code)
+}
+
+func (e *Error) Unwrap() error { return e.cause }
+
+func (e *Error) Code() Code { return e.code }
+
+func (e *Error) Write(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, e.status, e.code, i18n.T(r, e.key, e.args...))
+}
+
+// ValidationError fails a request with 422 listing the field violations
+type ValidationError []dh.FieldError
+
+func (v ValidationError) Error() string { return fmt.Sprintf("%d invalid fields", len(v)) }
+
+// Responder answers the errors HandlerFuncs return
+type Responder struct {
+	Logf  func(r *http.Request, format string, args ...any)
+	Other func(w http.ResponseWriter, r *http.Request, err error) //answers errors that are neither *Error nor ValidationError
+}
+
+// Handle adapts fn to http.HandlerFunc. The error fn returns is answered by Write, unless fn started
+// the response already: the status is out by then, so the error is only logged.
+func (re Responder) Handle(fn HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		err := fn(ww, r)
+		switch {
+		case err == nil:
+		case ww.Status() != 0:
+			re.Logf(r, "failed after answering %d: %v", ww.Status(), err)
+		default:
+			re.Write(w, r, err)
+		}
+	}
+}
+
+// Write answers err: *Error and ValidationError as they say, others with Other. Nothing is written
+// for a client that went away.
+func (re Responder) Write(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	var v ValidationError
+	switch {
+	case errors.As(err, &e):
+		if e.cause != nil {
+			re.Logf(r, "%v", e.cause)
+		}
+		e.Write(w, r)
+	case errors.As(err, &v):
+		Validation(w, r, v)
+	case errors.Is(err, context.Canceled) && r.Context().Err() != nil:
+		re.Logf(r, "canceled: %v", err)
+	default:
+		re.Other(w, r, err)
+	}
+}
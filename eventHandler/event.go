@@ -0,0 +1,164 @@
+package eventHandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/redisHandler"
+	trace "github.com/Sabnaj-42/BookServer-API/traceHandler"
+)
+
+// event types published after a book changes
+const (
+	BookCreated = "book.created"
+	BookUpdated = "book.updated"
+	BookDeleted = "book.deleted"
+)
+
+// event types published when someone replies to a user's post, one per user to notify
+const (
+	ReviewReplied  = "review.replied"
+	CommentReplied = "comment.replied"
+)
+
+// event type published when an admin switches maintenance mode, so every replica follows
+const MaintenanceChanged = "maintenance.changed"
+
+type Event struct { //a change other replicas need to hear about
+	Type string    `json:"type"`
+	ID   string    `json:"id,omitempty"` //book ID
+	ISBN string    `json:"isbn,omitempty"`
+	Node string    `json:"node"` //instance that published it
+	At   time.Time `json:"at"`
+	Key  string    `json:"key"` //tells apart publications, a message redelivered under the same key is dropped
+
+	// set on reply events
+	User    string `json:"user,omitempty"`  //who is told about it
+	Actor   string `json:"actor,omitempty"` //who replied
+	Review  string `json:"review,omitempty"`
+	Comment string `json:"comment,omitempty"` //the reply
+	URL     string `json:"url,omitempty"`     //where to read it
+
+	// set on maintenance events
+	Maintenance json.RawMessage `json:"maintenance,omitempty"` //the new setting, as /admin/maintenance shows it
+}
+
+// Bus fans events out to subscribers, on this instance or, for shared buses, on every replica.
+// Subscribers use it to drop cached data, forward events to connected clients and fill users' inboxes.
+type Bus interface {
+	Publish(ctx context.Context, e Event) error
+	Subscribe(fn func(Event))
+}
+
+// LocalBus delivers events inside one process, the default for a single instance
+type LocalBus struct {
+	mu   sync.RWMutex
+	subs []func(Event)
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+func (b *LocalBus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, fn)
+}
+
+func (b *LocalBus) Publish(_ context.Context, e Event) error {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+	return nil
+}
+
+// recentKeys is how many event keys RedisBus remembers to drop repeats. The client resends a
+// PUBLISH once when the connection breaks, so a repeat follows its first delivery closely.
+const recentKeys = 1024
+
+// RedisBus shares events between replicas over a Redis pub/sub channel.
+// Local subscribers hear their own events right away, events from other nodes arrive through Listen.
+type RedisBus struct {
+	Client  *redisHandler.Client
+	Channel string
+	Node    string
+
+	local LocalBus
+	mu    sync.Mutex
+	seen  map[string]bool
+	keys  []string //seen's keys, oldest first
+}
+
+func NewRedisBus(addr, node string) *RedisBus {
+	return &RedisBus{Client: redisHandler.NewClient(addr), Channel: "bookserver:events", Node: node}
+}
+
+func (b *RedisBus) Subscribe(fn func(Event)) {
+	b.local.Subscribe(fn)
+}
+
+func (b *RedisBus) Publish(ctx context.Context, e Event) error {
+	e.Node = b.Node
+	if len(e.Key) == 0 {
+		e.Key = rand.Text()
+	}
+	b.local.Publish(ctx, e)
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = b.Client.Do(ctx, "PUBLISH", b.Channel, string(payload))
+	return err
+}
+
+func (b *RedisBus) Ping(ctx context.Context) error {
+	return b.Client.Ping(ctx)
+}
+
+// Listen delivers events published by other nodes until ctx is done
+func (b *RedisBus) Listen(ctx context.Context) error {
+	return b.Client.Subscribe(ctx, b.Channel, func(payload string) {
+		var e Event
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			trace.Logf(ctx, "events: bad message on %s: %v", b.Channel, err)
+			return
+		}
+		if e.Node == b.Node {
+			return //already delivered locally
+		}
+		if b.repeated(e.Key) {
+			trace.Logf(ctx, "events: dropped %s %s from %s, delivered before", e.Type, e.ID, e.Node)
+			return
+		}
+		b.local.Publish(ctx, e)
+	})
+}
+
+// repeated reports whether an event with key was delivered before, remembering the recentKeys latest
+func (b *RedisBus) repeated(key string) bool {
+	if len(key) == 0 { //from a node that sends no keys
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.seen[key] {
+		return true
+	}
+	if b.seen == nil {
+		b.seen = make(map[string]bool, recentKeys)
+	}
+	if len(b.keys) == recentKeys {
+		delete(b.seen, b.keys[0])
+		b.keys = b.keys[1:]
+	}
+	b.seen[key] = true
+	b.keys = append(b.keys, key)
+	return false
+}
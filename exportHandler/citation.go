@@ -0,0 +1,171 @@
+package exportHandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+var ErrUnknownStyle = errors.New("unknown citation style")
+
+// CitationStyles lists the styles Citation renders, with the content type of each
+var CitationStyles = map[string]string{
+	"bibtex": "application/x-bibtex; charset=utf-8",
+	"apa":    "text/plain; charset=utf-8",
+	"mla":    "text/plain; charset=utf-8",
+}
+
+// Citation renders a book in one of CitationStyles
+func Citation(b dh.Book, style string) (string, error) {
+	switch style {
+	case "bibtex":
+		return bibtex(b), nil
+	case "apa":
+		return apa(b), nil
+	case "mla":
+		return mla(b), nil
+	}
+	return "", ErrUnknownStyle
+}
+
+// splitName splits "Given Names Family" on its last space, single names have no given part
+func splitName(name string) (given, family string) {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, " "); i > 0 {
+		return strings.TrimSpace(name[:i]), name[i+1:]
+	}
+	return "", name
+}
+
+func citationYear(b dh.Book) string {
+	if len(b.Published) >= 4 {
+		return b.Published[:4]
+	}
+	return ""
+}
+
+// bibtexEscaper keeps braces and backslashes in values from closing a field early or running TeX
+// commands. BibTeX counts \{ and \} as braces too, so they are spelled as commands.
+var bibtexEscaper = strings.NewReplacer(`\`, `\textbackslash{}`, "{", `\textbraceleft{}`, "}", `\textbraceright{}`)
+
+func bibtex(b dh.Book) string {
+	key := "book"
+	if len(b.Authors) != 0 {
+		_, key = splitName(b.Authors[0].Name)
+	}
+	key = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, key+citationYear(b))
+
+	names := make([]string, 0, len(b.Authors))
+	for _, a := range b.Authors {
+		names = append(names, a.Name)
+	}
+	fields := [][2]string{
+		{"author", strings.Join(names, " and ")},
+		{"title", b.Name},
+		{"publisher", b.Pub},
+		{"year", citationYear(b)},
+		{"isbn", b.ISBN},
+		{"language", b.Language},
+		{"translator", b.Translator},
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@book{%s", key)
+	for _, f := range fields {
+		if len(f[1]) != 0 {
+			fmt.Fprintf(&sb, ",\n  %s = {%s}", f[0], bibtexEscaper.Replace(f[1]))
+		}
+	}
+	sb.WriteString("\n}\n")
+	return sb.String()
+}
+
+// apa renders "Family, G. N., & Family, G. (Year). Title. Publisher."
+func apa(b dh.Book) string {
+	names := make([]string, 0, len(b.Authors))
+	for _, a := range b.Authors {
+		given, family := splitName(a.Name)
+		var initials []string
+		for _, g := range strings.Fields(given) {
+			initials = append(initials, string([]rune(g)[0])+".")
+		}
+		if len(initials) == 0 {
+			names = append(names, family)
+		} else {
+			names = append(names, family+", "+strings.Join(initials, " "))
+		}
+	}
+	var authors string
+	switch n := len(names); {
+	case n == 1:
+		authors = names[0]
+	case n > 1:
+		authors = strings.Join(names[:n-1], ", ") + ", & " + names[n-1]
+	}
+
+	year := citationYear(b)
+	if len(year) == 0 {
+		year = "n.d."
+	}
+	parts := []string{fmt.Sprintf("(%s).", year), sentence(b.Name)}
+	if len(authors) != 0 {
+		parts = append([]string{sentence(authors)}, parts...)
+	}
+	if len(b.Pub) != 0 {
+		parts = append(parts, sentence(b.Pub))
+	}
+	return strings.Join(parts, " ") + "\n"
+}
+
+// mla renders "Family, Given, and Given Family. Title. Publisher, Year."
+func mla(b dh.Book) string {
+	var authors string
+	if len(b.Authors) != 0 {
+		given, family := splitName(b.Authors[0].Name)
+		authors = family
+		if len(given) != 0 {
+			authors += ", " + given
+		}
+		switch len(b.Authors) {
+		case 1:
+		case 2:
+			authors += ", and " + b.Authors[1].Name
+		default:
+			authors += ", et al"
+		}
+	}
+
+	var parts []string
+	if len(authors) != 0 {
+		parts = append(parts, sentence(authors))
+	}
+	parts = append(parts, sentence(b.Name))
+	if len(b.Translator) != 0 {
+		parts = append(parts, sentence("Translated by "+b.Translator))
+	}
+	var pub []string
+	for _, p := range []string{b.Pub, citationYear(b)} {
+		if len(p) != 0 {
+			pub = append(pub, p)
+		}
+	}
+	if len(pub) != 0 {
+		parts = append(parts, sentence(strings.Join(pub, ", ")))
+	}
+	return strings.Join(parts, " ") + "\n"
+}
+
+// sentence ends s with a period unless it already has one
+func sentence(s string) string {
+	if strings.HasSuffix(s, ".") {
+		return s
+	}
+	return s + "."
+}
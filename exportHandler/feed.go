@@ -0,0 +1,156 @@
+package exportHandler
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+const (
+	RSSType  = "application/rss+xml"
+	OPDSType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+)
+
+// Feed is a list of books to render as RSS or OPDS, newest first
+type Feed struct {
+	ID      string //stable identifier of the list, e.g. its slug
+	Title   string
+	Link    string //absolute URL of the list
+	Updated time.Time
+	Books   []dh.Book
+	BookURL func(dh.Book) string //absolute URL of a book, its /file and /cover hang below it
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// authorNames joins a book's authors for display
+func authorNames(b dh.Book) string {
+	names := make([]string, 0, len(b.Authors))
+	for _, a := range b.Authors {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// RSS writes f as an RSS 2.0 channel
+func RSS(w io.Writer, f Feed) error {
+	doc := rss{Version: "2.0", Channel: rssChannel{Title: f.Title, Link: f.Link, Description: f.Title}}
+	for _, b := range f.Books {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       b.Name,
+			Link:        f.BookURL(b),
+			Description: authorNames(b),
+			GUID:        rssGUID{Value: "urn:uuid:" + b.ID},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	DC      string      `xml:"xmlns:dc,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string       `xml:"id"`
+	Title      string       `xml:"title"`
+	Updated    string       `xml:"updated"`
+	Authors    []atomAuthor `xml:"author"`
+	Language   string       `xml:"dc:language,omitempty"`
+	Identifier string       `xml:"dc:identifier,omitempty"`
+	Issued     string       `xml:"dc:issued,omitempty"`
+	Publisher  string       `xml:"dc:publisher,omitempty"`
+	Links      []atomLink   `xml:"link"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// OPDS writes f as an OPDS 1.2 acquisition feed, so e-reader apps can browse the list and
+// download the attached e-books
+func OPDS(w io.Writer, f Feed) error {
+	doc := atomFeed{
+		DC:      "http://purl.org/dc/terms/",
+		ID:      "urn:bookserver:list:" + f.ID,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Rel: "self", Href: f.Link, Type: OPDSType}},
+	}
+	for _, b := range f.Books {
+		url := f.BookURL(b)
+		e := atomEntry{
+			ID:        "urn:uuid:" + b.ID,
+			Title:     b.Name,
+			Updated:   b.UpdatedAt.UTC().Format(time.RFC3339),
+			Language:  b.Language,
+			Issued:    b.Published,
+			Publisher: b.Pub,
+			Links:     []atomLink{{Rel: "alternate", Href: url, Type: "application/json"}},
+		}
+		if len(b.ISBN) != 0 {
+			e.Identifier = "urn:isbn:" + b.ISBN
+		}
+		for _, a := range b.Authors {
+			e.Authors = append(e.Authors, atomAuthor{Name: a.Name})
+		}
+		if b.Cover != nil {
+			e.Links = append(e.Links, atomLink{Rel: "http://opds-spec.org/image", Href: url + "/cover", Type: b.Cover.ContentType})
+		}
+		if b.File != nil {
+			e.Links = append(e.Links, atomLink{Rel: "http://opds-spec.org/acquisition", Href: url + "/file", Type: b.File.ContentType})
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,137 @@
+package exportHandler
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+const MARCXMLType = "application/marcxml+xml"
+
+// MARC21 language codes for the ISO 639-1 codes books are stored with, others export as "und"
+var marcLanguages = map[string]string{
+	"ar": "ara", "bn": "ben", "de": "ger", "el": "gre", "en": "eng", "es": "spa", "fa": "per",
+	"fr": "fre", "he": "heb", "hi": "hin", "it": "ita", "ja": "jpn", "ko": "kor", "nl": "dut",
+	"pl": "pol", "pt": "por", "ru": "rus", "sv": "swe", "tr": "tur", "ur": "urd", "zh": "chi",
+}
+
+func marcLanguage(code string) string {
+	if l, ok := marcLanguages[dh.SmStr(code)]; ok {
+		return l
+	}
+	return "und"
+}
+
+type marcCollection struct {
+	XMLName xml.Name     `xml:"http://www.loc.gov/MARC21/slim collection"`
+	Records []marcRecord `xml:"record"`
+}
+
+type marcRecord struct {
+	Leader        string         `xml:"leader"`
+	ControlFields []controlField `xml:"controlfield"`
+	DataFields    []dataField    `xml:"datafield"`
+}
+
+type controlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dataField struct {
+	Tag       string     `xml:"tag,attr"`
+	Ind1      string     `xml:"ind1,attr"`
+	Ind2      string     `xml:"ind2,attr"`
+	Subfields []subfield `xml:"subfield"`
+}
+
+type subfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+func field(tag, ind1, ind2 string, codeValues ...string) dataField {
+	f := dataField{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for i := 0; i+1 < len(codeValues); i += 2 {
+		f.Subfields = append(f.Subfields, subfield{Code: codeValues[i], Value: codeValues[i+1]})
+	}
+	return f
+}
+
+// year returns the four digit publication year, or "uuuu" when unknown as MARC spells it
+func year(b dh.Book) string {
+	if len(b.Published) >= 4 {
+		return b.Published[:4]
+	}
+	return "uuuu"
+}
+
+// fixedFields builds the 40 character 008 field: entry date, single known date, place, language
+func fixedFields(b dh.Book) string {
+	entered := "000000"
+	if !b.UpdatedAt.IsZero() {
+		entered = b.UpdatedAt.UTC().Format("060102")
+	}
+	return entered + "s" + year(b) + "    " + "xx " + strings.Repeat(" ", 17) + marcLanguage(b.Language) + " d"
+}
+
+// toMARC maps a book to a MARC21 bibliographic record
+func toMARC(b dh.Book) marcRecord {
+	rec := marcRecord{
+		Leader:        "00000nam a2200000 i 4500",
+		ControlFields: []controlField{{Tag: "001", Value: b.ID}},
+	}
+	if !b.UpdatedAt.IsZero() {
+		rec.ControlFields = append(rec.ControlFields, controlField{Tag: "005", Value: b.UpdatedAt.UTC().Format("20060102150405.0")})
+	}
+	rec.ControlFields = append(rec.ControlFields, controlField{Tag: "008", Value: fixedFields(b)})
+
+	//fields are kept in tag order, added authors and the translator go last as 700s
+	if len(b.ISBN) != 0 {
+		rec.DataFields = append(rec.DataFields, field("020", " ", " ", "a", b.ISBN))
+	}
+	if len(b.Language) != 0 {
+		rec.DataFields = append(rec.DataFields, field("041", "0", " ", "a", marcLanguage(b.Language)))
+	}
+	var added []dataField
+	for i, a := range b.Authors {
+		if i == 0 {
+			rec.DataFields = append(rec.DataFields, field("100", "1", " ", "a", a.Name, "e", "author")) //main entry
+			continue
+		}
+		added = append(added, field("700", "1", " ", "a", a.Name, "e", "author"))
+	}
+	if len(b.Translator) != 0 {
+		added = append(added, field("700", "1", " ", "a", b.Translator, "e", "translator"))
+	}
+	if len(b.OriginalTitle) != 0 {
+		rec.DataFields = append(rec.DataFields, field("240", "1", "0", "a", b.OriginalTitle))
+	}
+	rec.DataFields = append(rec.DataFields, field("245", "1", "0", "a", b.Name))
+	rec.DataFields = append(rec.DataFields, field("264", " ", "1", "b", b.Pub, "c", year(b)))
+	if len(b.Genre) != 0 {
+		rec.DataFields = append(rec.DataFields, field("655", " ", "7", "a", b.Genre, "2", "local"))
+	}
+	rec.DataFields = append(rec.DataFields, added...)
+	return rec
+}
+
+// MARCXML writes books as a MARC21 slim XML collection, the format library systems and union catalogs import
+func MARCXML(w io.Writer, books []dh.Book) error {
+	c := marcCollection{Records: make([]marcRecord, 0, len(books))}
+	for _, b := range books {
+		c.Records = append(c.Records, toMARC(b))
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
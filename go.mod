@@ -6,6 +6,8 @@ require (
 	github.com/go-chi/chi/v5 v5.2.1
 	github.com/lestrrat-go/jwx/v2 v2.1.6
 	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
+	golang.org/x/text v0.21.0
 )
 
 require (
@@ -18,7 +20,6 @@ require (
 	github.com/lestrrat-go/iter v1.0.2 // indirect
 	github.com/lestrrat-go/option v1.0.1 // indirect
 	github.com/segmentio/asm v1.2.0 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
 	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 )
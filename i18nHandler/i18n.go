@@ -0,0 +1,392 @@
+package i18nHandler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultLang = "en" //fallback language when nothing else matches
+
+// message keys used by the handlers
+const (
+	CannotDecode         = "cannot_decode"
+	InvalidUTF8          = "invalid_utf8"
+	CannotEncode         = "cannot_encode"
+	InvalidData          = "invalid_data"
+	InvalidDataDetail    = "invalid_data_detail"
+	NonconformingRequest = "nonconforming_request"
+	InvalidISBN          = "invalid_isbn"
+	BookExists           = "book_exists"
+	BookNotFound         = "book_not_found"
+	InvalidCredentials   = "invalid_credentials"
+	UserExists           = "user_exists"
+	UserNotFound         = "user_not_found"
+	AdminAccount         = "admin_account"
+	UserRegistered       = "user_registered"
+	LoginSuccessful      = "login_successful"
+	CannotSignToken      = "cannot_sign_token"
+	InvalidMethod        = "invalid_method"
+	CannotReadBody       = "cannot_read_body"
+	InvalidJSON          = "invalid_json"
+	InvalidDate          = "invalid_date"
+	InvalidTimestamp     = "invalid_timestamp"
+	InvalidMonth         = "invalid_month"
+	InvalidSort          = "invalid_sort"
+	InvalidLocale        = "invalid_locale"
+	InvalidLimit         = "invalid_limit"
+	QueryTooLong         = "query_too_long"
+	InvalidCursor        = "invalid_cursor"
+	InvalidFormat        = "invalid_format"
+	RouteNotFound        = "route_not_found"
+	MethodNotAllowed     = "method_not_allowed"
+	StorageError         = "storage_error"
+	StorageTimeout       = "storage_timeout"
+	NoTransactions       = "no_transactions"
+	VersionConflict      = "version_conflict"
+	PreconditionFailed   = "precondition_failed"
+	ReindexRunning       = "reindex_running"
+	QueueFull            = "queue_full"
+	InternalError        = "internal_error"
+	Unauthorized         = "unauthorized"
+	Forbidden            = "forbidden"
+	Maintenance          = "maintenance"
+	TooManyRequests      = "too_many_requests"
+	QuotaExceeded        = "quota_exceeded"
+	SessionNotFound      = "session_not_found"
+	ImportNotFound       = "import_not_found"
+	ShelfNotFound        = "shelf_not_found"
+	InvitationNotFound   = "invitation_not_found"
+	PublicListTitle      = "public_list_title"
+	ReviewNotFound       = "review_not_found"
+	CommentNotFound      = "comment_not_found"
+	NotificationNotFound = "notification_not_found"
+	DeviceNotFound       = "device_not_found"
+	SnapshotNotFound     = "snapshot_not_found"
+	InvalidSignature     = "invalid_signature"
+	SigningDisabled      = "signing_disabled"
+
+	// notification emails
+	MailReviewReply      = "mail_review_reply"
+	MailCommentReply     = "mail_comment_reply"
+	MailFooter           = "mail_footer"
+	MailConfirm          = "mail_confirm"
+	MailConfirmBody      = "mail_confirm_body"
+	InvalidCode          = "invalid_code"
+	TooManyPosts         = "too_many_posts"
+	ContentRejected      = "content_rejected"
+	FileNotFound         = "file_not_found"
+	FileTooLarge         = "file_too_large"
+	BodyTooLarge         = "body_too_large"
+	ContentMismatch      = "content_mismatch"
+	FileInfected         = "file_infected"
+	ScanUnavailable      = "scan_unavailable"
+	CoverNotFound        = "cover_not_found"
+	InvalidImage         = "invalid_image"
+	ImageTooLarge        = "image_too_large"
+	InvalidSize          = "invalid_size"
+	UnsupportedMediaType = "unsupported_media_type"
+
+	// field validation rules, formatted with the field name
+	RuleRequired         = "rule_required"
+	RuleLanguage         = "rule_iso639_1"
+	RuleDate             = "rule_date"
+	RuleFormat           = "rule_format"
+	RuleBookID           = "rule_book_id"
+	RulePasswordLength   = "rule_password_length"
+	RulePasswordClasses  = "rule_password_classes"
+	RulePasswordUsername = "rule_password_username"
+	RulePasswordBreached = "rule_password_breached"
+	RuleType             = "rule_type"
+	RuleEnum             = "rule_enum"
+	RulePattern          = "rule_pattern"
+	RuleSpecFormat       = "rule_spec_format"
+	RuleMinLength        = "rule_min_length"
+	RuleMaxLength        = "rule_max_length"
+	RuleMinItems         = "rule_min_items"
+	RuleMinimum          = "rule_minimum"
+	RuleMaximum          = "rule_maximum"
+	RuleUnknownField     = "rule_unknown_field"
+)
+
+type Catalog map[string]map[string]string // language -> message key -> message
+
+var (
+	mu       sync.RWMutex
+	messages = Catalog{
+		"en": {
+			CannotDecode:         "Cannot decode data",
+			InvalidUTF8:          "The request body must be encoded in UTF-8",
+			CannotEncode:         "Cannot encode data",
+			InvalidData:          "Invalid Data Entry",
+			InvalidDataDetail:    "Invalid Data Entry: %s",
+			NonconformingRequest: "The request does not match the API specification, see /api/v1/openapi.json",
+			InvalidISBN:          "Invalid ISBN",
+			BookExists:           "Book already exists",
+			BookNotFound:         "Book does not exist",
+			InvalidCredentials:   "Invalid username or password",
+			UserExists:           "User already exists",
+			UserNotFound:         "User does not exist",
+			AdminAccount:         "Admin accounts can not delete themselves, ask to be removed from the admins first",
+			UserRegistered:       "User %s registered successfully",
+			LoginSuccessful:      "Login successful",
+			CannotSignToken:      "Cannot sign token",
+			InvalidMethod:        "Invalid request method",
+			CannotReadBody:       "Unable to read request body",
+			InvalidJSON:          "Invalid JSON format",
+			InvalidDate:          "Date must be in YYYY-MM-DD format",
+			InvalidTimestamp:     "Time must be in RFC 3339 format, e.g. 2024-05-01T10:00:00Z",
+			InvalidMonth:         "Month must be in YYYY-MM format",
+			InvalidSort:          "Unknown sort field",
+			InvalidLocale:        "Unknown locale, use a language tag such as en, de or bn",
+			InvalidLimit:         "limit must be a number from 1 to %d",
+			QueryTooLong:         "Search for at most %d characters and %d words",
+			InvalidCursor:        "Invalid cursor",
+			InvalidFormat:        "Unknown format %q",
+			RouteNotFound:        "The requested resource does not exist",
+			MethodNotAllowed:     "Method not allowed for this resource",
+			StorageError:         "Storage error, please retry later",
+			StorageTimeout:       "Storage did not answer in time, please retry later",
+			NoTransactions:       "This storage cannot apply requests all or nothing, leave out atomic",
+			VersionConflict:      "The book was changed by someone else meanwhile, reload it and try again",
+			PreconditionFailed:   "The book is no longer at the version in If-Match, reload it and try again",
+			ReindexRunning:       "A reindex is already running, cancel it or wait for it to finish",
+			QueueFull:            "Too much background work is waiting, please retry later",
+			InternalError:        "Something went wrong on our side",
+			Unauthorized:         "Please log in to do this",
+			Forbidden:            "You are not allowed to do this",
+			Maintenance:          "The server is under maintenance, please retry later",
+			TooManyRequests:      "Too many requests, please slow down",
+			QuotaExceeded:        "Daily quota of %d requests used up, it resets at %s",
+			SessionNotFound:      "Session not found",
+			ImportNotFound:       "Import not found or expired, upload the file again",
+			ShelfNotFound:        "Shelf not found or not shared with you",
+			InvitationNotFound:   "Invitation not found, it may have been withdrawn or answered already",
+			PublicListTitle:      "%s's %s shelf",
+			ReviewNotFound:       "Review not found or removed by a moderator",
+			CommentNotFound:      "Comment not found or removed by a moderator",
+			NotificationNotFound: "Notification not found",
+			DeviceNotFound:       "Device not found",
+			SnapshotNotFound:     "Snapshot not found, GET /admin/snapshots lists them",
+			InvalidSignature:     "This link has expired or is not valid",
+			SigningDisabled:      "Signed links are turned off until the server has a secret of its own",
+			MailReviewReply:      "%s commented on your review",
+			MailCommentReply:     "%s replied to your comment",
+			MailFooter:           "You get this email because of your notification preferences, change them with PUT /me/preferences.",
+			MailConfirm:          "Confirm your email address",
+			MailConfirmBody:      "Someone, hopefully you, asked for notification emails to %s. Confirm with POST /me/preferences/email/confirm and the code %s, or ignore this email and none will follow.",
+			InvalidCode:          "The confirmation code is wrong or was replaced by a newer one",
+			TooManyPosts:         "You are posting too fast, at most %d reviews, comments and reports a minute",
+			ContentRejected:      "Your post was not accepted: %s",
+			FileNotFound:         "This book has no file attached",
+			FileTooLarge:         "The file is too large, the limit is %d MB",
+			BodyTooLarge:         "The request body is too large, the limit is %d KB",
+			ContentMismatch:      "The file content is not %s as declared",
+			FileInfected:         "The file was rejected by the virus scanner",
+			ScanUnavailable:      "The virus scanner is unavailable, please retry later",
+			CoverNotFound:        "This book has no cover",
+			InvalidImage:         "The image could not be read, send a JPEG or PNG",
+			ImageTooLarge:        "The image is too large, the limit is %d megapixels",
+			InvalidSize:          "Unknown size %q, use thumbnail, medium or original",
+			UnsupportedMediaType: "Unsupported content type, send %s",
+			RuleRequired:         "%s is required",
+			RuleLanguage:         "%s must be an ISO 639-1 language code",
+			RuleDate:             "%s must be a date in YYYY-MM-DD format",
+			RuleFormat:           "%s must be one of hardcover, paperback, ebook or audiobook",
+			RuleBookID:           "%s must not look like an ISBN",
+			RulePasswordLength:   "%s must be at least %d characters long",
+			RulePasswordClasses:  "%s must mix at least %d of lower case letters, upper case letters, digits and symbols",
+			RulePasswordUsername: "%s must not contain the username",
+			RulePasswordBreached: "%s appeared in a known data breach, choose another one",
+			RuleType:             "%s must be of type %s",
+			RuleEnum:             "%s must be one of %s",
+			RulePattern:          "%s must match %s",
+			RuleSpecFormat:       "%s must be a valid %s",
+			RuleMinLength:        "%s must be at least %d characters long",
+			RuleMaxLength:        "%s must be at most %d characters long",
+			RuleMinItems:         "%s needs at least %d entries",
+			RuleMinimum:          "%s must be at least %v",
+			RuleMaximum:          "%s must be at most %v",
+			RuleUnknownField:     "%s is not a known field",
+		},
+		"bn": {
+			CannotDecode:         "ডেটা ডিকোড করা যায়নি",
+			InvalidUTF8:          "অনুরোধের বডি অবশ্যই UTF-8 এ এনকোড করা থাকতে হবে",
+			CannotEncode:         "ডেটা এনকোড করা যায়নি",
+			InvalidData:          "অবৈধ ডেটা",
+			InvalidDataDetail:    "অবৈধ ডেটা: %s",
+			NonconformingRequest: "অনুরোধটি API স্পেসিফিকেশনের সাথে মেলে না, /api/v1/openapi.json দেখুন",
+			InvalidISBN:          "অবৈধ ISBN",
+			BookExists:           "বইটি আগে থেকেই আছে",
+			BookNotFound:         "বইটি পাওয়া যায়নি",
+			InvalidCredentials:   "ব্যবহারকারীর নাম বা পাসওয়ার্ড ভুল",
+			UserExists:           "ব্যবহারকারী আগে থেকেই আছে",
+			UserNotFound:         "ব্যবহারকারী পাওয়া যায়নি",
+			AdminAccount:         "অ্যাডমিন অ্যাকাউন্ট নিজেকে মুছতে পারে না, আগে অ্যাডমিন তালিকা থেকে সরানোর অনুরোধ করুন",
+			UserRegistered:       "ব্যবহারকারী %s সফলভাবে নিবন্ধিত হয়েছে",
+			LoginSuccessful:      "লগইন সফল হয়েছে",
+			CannotSignToken:      "টোকেন স্বাক্ষর করা যায়নি",
+			InvalidMethod:        "অবৈধ অনুরোধ পদ্ধতি",
+			CannotReadBody:       "অনুরোধের বডি পড়া যায়নি",
+			InvalidJSON:          "অবৈধ JSON ফরম্যাট",
+			InvalidDate:          "তারিখ অবশ্যই YYYY-MM-DD ফরম্যাটে হতে হবে",
+			InvalidTimestamp:     "সময় অবশ্যই RFC 3339 ফরম্যাটে হতে হবে, যেমন 2024-05-01T10:00:00Z",
+			InvalidMonth:         "মাস অবশ্যই YYYY-MM ফরম্যাটে হতে হবে",
+			InvalidSort:          "অজানা সাজানোর ক্ষেত্র",
+			InvalidLocale:        "অজানা লোকেল, en, de বা bn এর মতো ভাষা ট্যাগ ব্যবহার করুন",
+			InvalidLimit:         "limit অবশ্যই ১ থেকে %d এর মধ্যে একটি সংখ্যা হতে হবে",
+			QueryTooLong:         "সর্বোচ্চ %d অক্ষর ও %d শব্দ দিয়ে খুঁজুন",
+			InvalidCursor:        "অবৈধ কার্সর",
+			InvalidFormat:        "অজানা ফরম্যাট %q",
+			RouteNotFound:        "অনুরোধকৃত রিসোর্সটি নেই",
+			MethodNotAllowed:     "এই রিসোর্সের জন্য পদ্ধতিটি অনুমোদিত নয়",
+			StorageError:         "সংরক্ষণে সমস্যা, পরে আবার চেষ্টা করুন",
+			StorageTimeout:       "সংরক্ষণ সময়মতো সাড়া দেয়নি, পরে আবার চেষ্টা করুন",
+			NoTransactions:       "এই সংরক্ষণ অনুরোধ সম্পূর্ণ বা একেবারেই না প্রয়োগ করতে পারে না, atomic বাদ দিন",
+			VersionConflict:      "এর মধ্যে অন্য কেউ বইটি বদলেছে, আবার লোড করে চেষ্টা করুন",
+			PreconditionFailed:   "বইটি আর If-Match-এ দেওয়া সংস্করণে নেই, আবার লোড করে চেষ্টা করুন",
+			ReindexRunning:       "একটি রিইনডেক্স ইতিমধ্যে চলছে, এটি বাতিল করুন অথবা শেষ হওয়া পর্যন্ত অপেক্ষা করুন",
+			QueueFull:            "অনেক কাজ অপেক্ষায় আছে, পরে আবার চেষ্টা করুন",
+			InternalError:        "আমাদের দিকে কিছু একটা ভুল হয়েছে",
+			Unauthorized:         "এটি করতে লগইন করুন",
+			Forbidden:            "আপনার এটি করার অনুমতি নেই",
+			Maintenance:          "সার্ভারে রক্ষণাবেক্ষণ চলছে, পরে আবার চেষ্টা করুন",
+			TooManyRequests:      "অনেক বেশি অনুরোধ, একটু ধীরে করুন",
+			QuotaExceeded:        "দৈনিক %d অনুরোধের কোটা শেষ, এটি %s এ আবার শুরু হবে",
+			SessionNotFound:      "সেশন পাওয়া যায়নি",
+			ImportNotFound:       "ইমপোর্ট পাওয়া যায়নি বা মেয়াদ শেষ, ফাইলটি আবার আপলোড করুন",
+			ShelfNotFound:        "তাক পাওয়া যায়নি বা আপনার সাথে শেয়ার করা হয়নি",
+			InvitationNotFound:   "আমন্ত্রণ পাওয়া যায়নি, এটি হয়তো প্রত্যাহার করা হয়েছে বা আগেই উত্তর দেওয়া হয়েছে",
+			PublicListTitle:      "%s এর %s তাক",
+			ReviewNotFound:       "রিভিউ পাওয়া যায়নি বা মডারেটর সরিয়ে দিয়েছেন",
+			CommentNotFound:      "মন্তব্য পাওয়া যায়নি বা মডারেটর সরিয়ে দিয়েছেন",
+			NotificationNotFound: "বিজ্ঞপ্তি পাওয়া যায়নি",
+			DeviceNotFound:       "ডিভাইস পাওয়া যায়নি",
+			SnapshotNotFound:     "স্ন্যাপশট পাওয়া যায়নি, GET /admin/snapshots এ তালিকা আছে",
+			InvalidSignature:     "এই লিঙ্কের মেয়াদ শেষ অথবা এটি বৈধ নয়",
+			SigningDisabled:      "সার্ভারের নিজস্ব সিক্রেট না থাকা পর্যন্ত সাইন করা লিঙ্ক বন্ধ আছে",
+			MailReviewReply:      "%s আপনার রিভিউতে মন্তব্য করেছেন",
+			MailCommentReply:     "%s আপনার মন্তব্যের উত্তর দিয়েছেন",
+			MailFooter:           "আপনার বিজ্ঞপ্তি পছন্দের কারণে আপনি এই ইমেইল পাচ্ছেন, PUT /me/preferences দিয়ে সেগুলো বদলান।",
+			MailConfirm:          "আপনার ইমেইল ঠিকানা নিশ্চিত করুন",
+			MailConfirmBody:      "কেউ, আশা করি আপনি, %s এ বিজ্ঞপ্তি ইমেইল চেয়েছেন। POST /me/preferences/email/confirm ও কোড %s দিয়ে নিশ্চিত করুন, অথবা এই ইমেইল উপেক্ষা করুন, আর কোনো ইমেইল আসবে না।",
+			InvalidCode:          "নিশ্চিতকরণ কোডটি ভুল বা নতুন কোড দিয়ে বদলে গেছে",
+			TooManyPosts:         "আপনি খুব দ্রুত লিখছেন, মিনিটে সর্বোচ্চ %dটি রিভিউ, মন্তব্য ও রিপোর্ট",
+			ContentRejected:      "আপনার লেখাটি গ্রহণ করা হয়নি: %s",
+			FileNotFound:         "এই বইয়ের সাথে কোনো ফাইল যুক্ত নেই",
+			FileTooLarge:         "ফাইলটি অনেক বড়, সীমা %d MB",
+			BodyTooLarge:         "অনুরোধের বডি অনেক বড়, সীমা %d KB",
+			ContentMismatch:      "ফাইলের বিষয়বস্তু ঘোষিত %s নয়",
+			FileInfected:         "ভাইরাস স্ক্যানার ফাইলটি প্রত্যাখ্যান করেছে",
+			ScanUnavailable:      "ভাইরাস স্ক্যানার এখন পাওয়া যাচ্ছে না, পরে আবার চেষ্টা করুন",
+			CoverNotFound:        "এই বইয়ের কোনো প্রচ্ছদ নেই",
+			InvalidImage:         "ছবিটি পড়া যায়নি, JPEG বা PNG পাঠান",
+			ImageTooLarge:        "ছবিটি অনেক বড়, সীমা %d মেগাপিক্সেল",
+			InvalidSize:          "অজানা আকার %q, thumbnail, medium বা original ব্যবহার করুন",
+			UnsupportedMediaType: "অসমর্থিত কনটেন্ট টাইপ, %s পাঠান",
+			RuleRequired:         "%s আবশ্যক",
+			RuleLanguage:         "%s অবশ্যই ISO 639-1 ভাষা কোড হতে হবে",
+			RuleDate:             "%s অবশ্যই YYYY-MM-DD ফরম্যাটে তারিখ হতে হবে",
+			RuleFormat:           "%s অবশ্যই hardcover, paperback, ebook বা audiobook হতে হবে",
+			RuleBookID:           "%s ISBN-এর মতো দেখতে হওয়া চলবে না",
+			RulePasswordLength:   "%s কমপক্ষে %d অক্ষরের হতে হবে",
+			RulePasswordClasses:  "%s এ ছোট হাতের অক্ষর, বড় হাতের অক্ষর, সংখ্যা ও চিহ্নের মধ্যে কমপক্ষে %d ধরনের থাকতে হবে",
+			RulePasswordUsername: "%s এ ব্যবহারকারীর নাম থাকতে পারবে না",
+			RulePasswordBreached: "%s একটি পরিচিত ডেটা ফাঁসে পাওয়া গেছে, অন্য একটি বেছে নিন",
+			RuleType:             "%s অবশ্যই %s ধরনের হতে হবে",
+			RuleEnum:             "%s অবশ্যই %s এর একটি হতে হবে",
+			RulePattern:          "%s অবশ্যই %s এর সাথে মিলতে হবে",
+			RuleSpecFormat:       "%s অবশ্যই একটি বৈধ %s হতে হবে",
+			RuleMinLength:        "%s কমপক্ষে %d অক্ষরের হতে হবে",
+			RuleMaxLength:        "%s সর্বোচ্চ %d অক্ষরের হতে পারে",
+			RuleMinItems:         "%s এ কমপক্ষে %dটি এন্ট্রি লাগবে",
+			RuleMinimum:          "%s কমপক্ষে %v হতে হবে",
+			RuleMaximum:          "%s সর্বোচ্চ %v হতে পারে",
+			RuleUnknownField:     "%s একটি পরিচিত ক্ষেত্র নয়",
+		},
+	}
+)
+
+// Register adds or overrides messages for a language, so new translations can be plugged in at startup
+func Register(lang string, msgs map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lang = strings.ToLower(lang)
+	if messages[lang] == nil {
+		messages[lang] = make(map[string]string)
+	}
+	for k, v := range msgs {
+		messages[lang][k] = v
+	}
+}
+
+// Lang picks the best supported language from the Accept-Language header
+func Lang(r *http.Request) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(tag) == 0 {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		prefs = append(prefs, pref{tag, q})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, p := range prefs {
+		if p.q <= 0 {
+			continue
+		}
+		if _, ok := messages[p.tag]; ok {
+			return p.tag
+		}
+		if base, _, found := strings.Cut(p.tag, "-"); found {
+			if _, ok := messages[base]; ok {
+				return base
+			}
+		}
+	}
+	return DefaultLang
+}
+
+// T returns the message for key in the request's language, falling back to English and then to the key itself
+func T(r *http.Request, key string, args ...any) string {
+	return Message(Lang(r), key, args...)
+}
+
+// Message is T for work done outside a request, such as emails, in a language picked earlier
+func Message(lang, key string, args ...any) string {
+	mu.RLock()
+	msg, ok := messages[lang][key]
+	if !ok {
+		msg, ok = messages[DefaultLang][key]
+	}
+	mu.RUnlock()
+
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
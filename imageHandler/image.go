@@ -0,0 +1,107 @@
+package imageHandler
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sync"
+)
+
+const MaxPixels = 40_000_000 //larger images are refused before decoding, a few KB can claim gigabytes of pixels
+
+var ErrTooLarge = errors.New("image has too many pixels")
+
+// Sizes are the cover variants by name with their maximum width, 0 keeps the original dimensions
+var Sizes = map[string]int{
+	"thumbnail": 150,
+	"medium":    600,
+	"original":  0,
+}
+
+// Encoder writes img in one format
+type Encoder func(w io.Writer, img image.Image) error
+
+var (
+	mu       sync.RWMutex
+	encoders = map[string]Encoder{
+		"image/jpeg": func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, &jpeg.Options{Quality: 85}) },
+		"image/png":  png.Encode,
+	}
+)
+
+// Extensions of the content types covers are kept in
+var Extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// RegisterEncoder plugs in an encoder at startup. The standard library can't write WebP,
+// so covers only get WebP variants once one is registered for "image/webp".
+func RegisterEncoder(contentType string, enc Encoder) {
+	mu.Lock()
+	defer mu.Unlock()
+	encoders[contentType] = enc
+}
+
+// EncoderFor returns the encoder for contentType, if there is one
+func EncoderFor(contentType string) (Encoder, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	enc, ok := encoders[contentType]
+	return enc, ok
+}
+
+// Decode reads a JPEG or PNG, refusing images over MaxPixels without decoding them
+func Decode(r io.ReadSeeker) (image.Image, image.Config, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, cfg, err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, cfg, ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, cfg, err
+	}
+	img, _, err := image.Decode(r)
+	return img, cfg, err
+}
+
+// Resize scales img down to width keeping its aspect ratio, averaging the source pixels each
+// destination pixel covers. Images already narrow enough are returned as they are.
+func Resize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if width <= 0 || sw <= width {
+		return img
+	}
+	height := max(1, sh*width/sw)
+
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh)) //premultiplied, so transparent pixels don't darken the average
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for dy := 0; dy < height; dy++ {
+		y0, y1 := dy*sh/height, max((dy+1)*sh/height, dy*sh/height+1)
+		for dx := 0; dx < width; dx++ {
+			x0, x1 := dx*sw/width, max((dx+1)*sw/width, dx*sw/width+1)
+			var r, g, bl, a, n uint32
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint32(row[i])
+					g += uint32(row[i+1])
+					bl += uint32(row[i+2])
+					a += uint32(row[i+3])
+					n++
+				}
+			}
+			o := dy*dst.Stride + dx*4
+			dst.Pix[o], dst.Pix[o+1], dst.Pix[o+2], dst.Pix[o+3] = uint8(r/n), uint8(g/n), uint8(bl/n), uint8(a/n)
+		}
+	}
+	return dst
+}
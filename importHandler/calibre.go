@@ -0,0 +1,83 @@
+package importHandler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// Calibre stores languages as ISO 639-2/3 codes, books keep ISO 639-1
+var calibreLanguages = map[string]string{
+	"ara": "ar", "ben": "bn", "chi": "zh", "zho": "zh", "dut": "nl", "nld": "nl", "eng": "en",
+	"fre": "fr", "fra": "fr", "ger": "de", "deu": "de", "gre": "el", "ell": "el", "heb": "he",
+	"hin": "hi", "ita": "it", "jpn": "ja", "kor": "ko", "per": "fa", "fas": "fa", "pol": "pl",
+	"por": "pt", "rus": "ru", "spa": "es", "swe": "sv", "tur": "tr", "urd": "ur",
+}
+
+// calibreUndefinedDate is what Calibre writes for a missing publication date
+const calibreUndefinedDate = "0101-01-01"
+
+type RowError struct { //a book that was not imported
+	Row int    `json:"row"` //1 based, not counting a CSV header
+	Err string `json:"error"`
+}
+
+// ParseCalibreCSV reads the CSV of Calibre's "Convert books > Create catalog" export.
+// Columns are found by header name so any selection and order Calibre was asked for works,
+// authors are split on " & ", the first tag becomes the genre and Calibre's uuid the book ID.
+func ParseCalibreCSV(r io.Reader) ([]dh.Book, error) {
+	rd := csv.NewReader(r)
+	rd.FieldsPerRecord = -1
+	header, err := rd.Read()
+	if err != nil {
+		return nil, fmt.Errorf("calibre csv header: %w", err)
+	}
+	col := columns(header)
+	if _, ok := col["title"]; !ok {
+		return nil, errors.New(`calibre csv: missing "title" column`)
+	}
+
+	var books []dh.Book
+	for {
+		rec, err := rd.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("calibre csv: %w", err)
+		}
+		get := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+
+		b := dh.Book{ID: get("uuid"), Name: get("title"), ISBN: get("isbn"), Pub: get("publisher")}
+		for _, a := range strings.Split(get("authors"), "&") {
+			if a = strings.TrimSpace(a); len(a) != 0 {
+				b.Authors = append(b.Authors, dh.Author{Name: a})
+			}
+		}
+		if tags := get("tags"); len(tags) != 0 {
+			b.Genre = strings.TrimSpace(strings.Split(tags, ",")[0])
+		}
+		if d := get("pubdate"); len(d) >= 10 && d[:10] != calibreUndefinedDate {
+			b.Published = d[:10]
+		}
+		if langs := get("languages"); len(langs) != 0 {
+			code := dh.SmStr(strings.TrimSpace(strings.Split(langs, ",")[0]))
+			if l, ok := calibreLanguages[code]; ok {
+				b.Language = l
+			} else if dh.ValidLanguage(code) {
+				b.Language = code
+			}
+		}
+		books = append(books, b)
+	}
+	return books, nil
+}
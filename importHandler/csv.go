@@ -0,0 +1,12 @@
+package importHandler
+
+import "strings"
+
+// columns maps lower cased header names to their index, ignoring a UTF-8 byte order mark
+func columns(header []string) map[string]int {
+	col := make(map[string]int, len(header))
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	return col
+}
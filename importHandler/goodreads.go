@@ -0,0 +1,98 @@
+package importHandler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/specHandler"
+)
+
+type GoodreadsRow struct { //one line of a Goodreads library export
+	Row   int    `json:"row"`
+	Title string `json:"title"`
+	dh.ShelfEntry
+	Invalid *dh.FieldError `json:"-"`               //why the row can't be imported, nil when it can
+	Error   string         `json:"error,omitempty"` //Invalid or a later problem in the reader's language, set by the handler
+}
+
+// shelfNames lists the shelves rows can name, for the message of a row naming another
+var shelfNames = strings.Join([]string{dh.ShelfToRead, dh.ShelfReading, dh.ShelfRead}, ", ")
+
+// goodreadsDate turns Goodreads' YYYY/MM/DD into YYYY-MM-DD
+func goodreadsDate(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "/", "-")
+}
+
+// goodreadsISBN strips the ="..." Goodreads wraps ISBNs in to keep spreadsheets from eating leading zeros
+func goodreadsISBN(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `="`)
+}
+
+// ParseGoodreadsCSV reads the CSV from Goodreads' "Import and export > Export library".
+// Each row becomes a shelf entry, rows with problems are kept with Invalid set so they can be shown to the user;
+// a row's ISBN13 is used when present, ISBN otherwise.
+func ParseGoodreadsCSV(r io.Reader) ([]GoodreadsRow, error) {
+	rd := csv.NewReader(r)
+	rd.FieldsPerRecord = -1
+	rd.LazyQuotes = true //older exports leave the ="..." ISBN wrappers unquoted
+	header, err := rd.Read()
+	if err != nil {
+		return nil, fmt.Errorf("goodreads csv header: %w", err)
+	}
+	col := columns(header)
+	if _, ok := col["exclusive shelf"]; !ok {
+		return nil, errors.New(`goodreads csv: missing "Exclusive Shelf" column`)
+	}
+
+	var rows []GoodreadsRow
+	for n := 1; ; n++ {
+		rec, err := rd.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("goodreads csv: %w", err)
+		}
+		get := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+
+		row := GoodreadsRow{Row: n, Title: get("title")}
+		row.ISBN = goodreadsISBN(get("isbn13"))
+		if len(row.ISBN) == 0 {
+			row.ISBN = goodreadsISBN(get("isbn"))
+		}
+		row.Shelf = get("exclusive shelf")
+		row.DateRead = goodreadsDate(get("date read"))
+		row.DateAdded = goodreadsDate(get("date added"))
+		if v := get("my rating"); len(v) != 0 {
+			row.Rating, _ = strconv.Atoi(v)
+		}
+
+		switch {
+		case len(row.ISBN) == 0:
+			row.Invalid = &dh.FieldError{Field: "isbn", Rule: dh.RuleRequired}
+		case !dh.Shelves[row.Shelf]:
+			row.Invalid = &dh.FieldError{Field: "shelf", Rule: specHandler.RuleEnum, Args: []any{shelfNames}}
+		case row.Rating < 0:
+			row.Invalid = &dh.FieldError{Field: "rating", Rule: specHandler.RuleMinimum, Args: []any{0}}
+		case row.Rating > 5:
+			row.Invalid = &dh.FieldError{Field: "rating", Rule: specHandler.RuleMaximum, Args: []any{5}}
+		}
+		for _, d := range [][2]string{{"date_read", row.DateRead}, {"date_added", row.DateAdded}} {
+			if _, err := dh.ParseDate(d[1]); len(d[1]) != 0 && err != nil && row.Invalid == nil {
+				row.Invalid = &dh.FieldError{Field: d[0], Rule: dh.RuleDate}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
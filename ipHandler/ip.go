@@ -0,0 +1,173 @@
+package ipHandler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	eh "github.com/Sabnaj-42/BookServer-API/errHandler"
+	i18n "github.com/Sabnaj-42/BookServer-API/i18nHandler"
+)
+
+// ParsePrefixes parses CIDRs like "10.0.0.0/8", a bare address counts as a single host
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+func contains(list []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range list {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAddr(s string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// ClientIP returns the address of the client, trusting X-Forwarded-For and X-Real-IP
+// only when the connection comes from one of the trusted proxies
+func ClientIP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	peer := peerAddr(r)
+	if !peer.IsValid() || !contains(trusted, peer) {
+		return peer
+	}
+
+	// walk X-Forwarded-For right to left, the first hop not run by us is the client
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			break
+		}
+		if !contains(trusted, addr) {
+			return addr
+		}
+		peer = addr
+	}
+	if addr, ok := parseAddr(r.Header.Get("X-Real-IP")); ok && len(hops) == 0 {
+		return addr
+	}
+	return peer
+}
+
+type ctxKey struct{}
+
+type resolved struct { //what RealIP stores
+	client netip.Addr
+	peer   netip.Addr //the end of the connection, before RemoteAddr was rewritten
+}
+
+// peerAddr is the address at the other end of r's connection
+func peerAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, _ := parseAddr(host)
+	return addr
+}
+
+// RealIP resolves the client address once per request with ClientIP, stores it in the context
+// and rewrites RemoteAddr so request logs show it too
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr := ClientIP(r, trusted)
+			if addr.IsValid() {
+				r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, resolved{client: addr, peer: peerAddr(r)}))
+				r.RemoteAddr = addr.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FromContext returns the address stored by RealIP
+func FromContext(ctx context.Context) (netip.Addr, bool) {
+	res, ok := ctx.Value(ctxKey{}).(resolved)
+	return res.client, ok
+}
+
+// FromTrusted reports whether r reached the server through one of the trusted proxies, so the
+// forwarding headers they set can be believed
+func FromTrusted(r *http.Request, trusted []netip.Prefix) bool {
+	peer := peerAddr(r)
+	if res, ok := r.Context().Value(ctxKey{}).(resolved); ok {
+		peer = res.peer
+	}
+	return peer.IsValid() && contains(trusted, peer)
+}
+
+// HTTPS reports whether the client sent r over TLS, to this server or to a trusted proxy that
+// says so in X-Forwarded-Proto
+func HTTPS(r *http.Request, trusted []netip.Prefix) bool {
+	if r.TLS != nil {
+		return true
+	}
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",") //the first proxy's, the one the client reached
+	return strings.EqualFold(strings.TrimSpace(proto), "https") && FromTrusted(r, trusted)
+}
+
+// IP returns the resolved client address of r, computing it when RealIP didn't run
+func IP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	if addr, ok := FromContext(r.Context()); ok {
+		return addr
+	}
+	return ClientIP(r, trusted)
+}
+
+type Filter struct { //CIDR allow/deny rules, deny wins and an empty allow list allows everyone
+	Allow   []netip.Prefix
+	Deny    []netip.Prefix
+	Trusted []netip.Prefix //proxies whose forwarding headers are believed
+}
+
+func (f Filter) Allowed(addr netip.Addr) bool {
+	if !addr.IsValid() || contains(f.Deny, addr) {
+		return false
+	}
+	return len(f.Allow) == 0 || contains(f.Allow, addr)
+}
+
+// Middleware rejects clients outside the rules with 403
+func (f Filter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.Allowed(IP(r, f.Trusted)) {
+			eh.New(http.StatusForbidden, eh.Forbidden, i18n.Forbidden).Write(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
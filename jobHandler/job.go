@@ -0,0 +1,92 @@
+package jobHandler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Lock elects the instance that runs a job, so scaled out replicas don't all run it at once.
+// Acquire takes or extends the lock for ttl and reports whether this instance holds it.
+type Lock interface {
+	Acquire(ctx context.Context, name string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, name string) error
+}
+
+// LocalLock is always held, for a single instance
+type LocalLock struct{}
+
+func (LocalLock) Acquire(context.Context, string, time.Duration) (bool, error) { return true, nil }
+func (LocalLock) Release(context.Context, string) error                        { return nil }
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs at their interval on whichever instance holds the job's lock
+type Scheduler struct {
+	Lock   Lock
+	Logger *log.Logger
+
+	mu   sync.Mutex
+	jobs []job
+}
+
+func NewScheduler(lock Lock, logger *log.Logger) *Scheduler {
+	if lock == nil {
+		lock = LocalLock{}
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Scheduler{Lock: lock, Logger: logger}
+}
+
+// Every registers fn to run every interval, call it before Start
+func (s *Scheduler) Every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: fn})
+}
+
+// Start runs every registered job in its own goroutine until ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+	defer s.Lock.Release(context.Background(), j.name)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.tick(ctx, j)
+		}
+	}
+}
+
+// tick runs j once if this instance holds its lock, the lock outlives the interval
+// a little so the leader keeps it from one run to the next
+func (s *Scheduler) tick(ctx context.Context, j job) {
+	held, err := s.Lock.Acquire(ctx, j.name, j.interval+j.interval/2)
+	if err != nil {
+		s.Logger.Printf("job %s: lock: %v", j.name, err)
+		return
+	}
+	if !held {
+		return //another instance is the leader
+	}
+	if err := j.run(ctx); err != nil {
+		s.Logger.Printf("job %s: %v", j.name, err)
+	}
+}
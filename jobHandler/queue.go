@@ -0,0 +1,71 @@
+package jobHandler
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+const QueueSize = 256 //jobs waiting before Enqueue refuses more
+
+type task struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Queue runs one-off background jobs, such as work triggered by an upload, on a few workers of this instance
+type Queue struct {
+	Workers int
+	Logger  *log.Logger
+
+	tasks chan task
+	once  sync.Once
+}
+
+func NewQueue(workers int, logger *log.Logger) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Queue{Workers: workers, Logger: logger, tasks: make(chan task, QueueSize)}
+}
+
+// Enqueue schedules fn and reports false when the queue is full, jobs queued before Start wait for it
+func (q *Queue) Enqueue(name string, fn func(ctx context.Context) error) bool {
+	select {
+	case q.tasks <- task{name: name, run: fn}:
+		return true
+	default:
+		q.Logger.Printf("job %s: queue full, dropped", name)
+		return false
+	}
+}
+
+// Pending is the number of jobs waiting for a worker
+func (q *Queue) Pending() int {
+	return len(q.tasks)
+}
+
+// Start runs the workers until ctx is done, jobs still queued then are dropped
+func (q *Queue) Start(ctx context.Context) {
+	q.once.Do(func() {
+		for i := 0; i < q.Workers; i++ {
+			go q.work(ctx)
+		}
+	})
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-q.tasks:
+			if err := t.run(ctx); err != nil {
+				q.Logger.Printf("job %s: %v", t.name, err)
+			}
+		}
+	}
+}
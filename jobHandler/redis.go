@@ -0,0 +1,52 @@
+package jobHandler
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/redisHandler"
+)
+
+// extend the lock only while we still own it, so an expired leader can't steal it back
+const (
+	extendScript  = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+	releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+)
+
+// RedisLock is a lease in Redis owned by Node, whoever sets it first leads until it stops renewing
+type RedisLock struct {
+	Client *redisHandler.Client
+	Prefix string
+	Node   string
+}
+
+func NewRedisLock(addr, node string) *RedisLock {
+	return &RedisLock{Client: redisHandler.NewClient(addr), Prefix: "bookserver:lock:", Node: node}
+}
+
+func (l *RedisLock) Acquire(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	px := strconv.FormatInt(ttl.Milliseconds(), 10)
+	reply, err := l.Client.Do(ctx, "SET", l.Prefix+name, l.Node, "NX", "PX", px)
+	if err != nil {
+		return false, err
+	}
+	if reply != nil {
+		return true, nil
+	}
+	reply, err = l.Client.Do(ctx, "EVAL", extendScript, "1", l.Prefix+name, l.Node, px)
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
+
+func (l *RedisLock) Release(ctx context.Context, name string) error {
+	_, err := l.Client.Do(ctx, "EVAL", releaseScript, "1", l.Prefix+name, l.Node)
+	return err
+}
+
+func (l *RedisLock) Ping(ctx context.Context) error {
+	return l.Client.Ping(ctx)
+}
@@ -0,0 +1,98 @@
+package mailHandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// SMTP sends through a mail server, upgrading to TLS when it offers STARTTLS. Credentials are only
+// sent over TLS or to localhost.
+type SMTP struct {
+	Addr     string //host:port
+	From     string
+	Username string //empty skips authentication
+	Password string
+	Timeout  time.Duration //for a whole message, unless ctx ends sooner
+}
+
+func NewSMTP(addr, from, username, password string) *SMTP {
+	return &SMTP{Addr: addr, From: from, Username: username, Password: password, Timeout: 30 * time.Second}
+}
+
+func (s *SMTP) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return errors.New("mail: line break in recipient")
+	}
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if len(s.Username) != 0 {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message(s.From, to, subject, body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message formats a UTF-8 plain text email
+func message(from, to, subject, body string) []byte {
+	var b bytes.Buffer
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", from, to, mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
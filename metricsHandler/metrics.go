@@ -0,0 +1,24 @@
+package metricsHandler
+
+import "expvar"
+
+// counters published through expvar
+var (
+	Panics    = expvar.NewInt("http_panics_total")     //handler panics recovered by the server
+	Coalesced = expvar.NewInt("store_coalesced_total") //book lookups answered by a concurrent identical lookup
+
+	GCBlobs     = expvar.NewInt("gc_blobs_deleted_total")      //covers and e-book files no book used any more
+	GCBlobBytes = expvar.NewInt("gc_blob_bytes_deleted_total") //their size
+	GCTokens    = expvar.NewInt("gc_tokens_deleted_total")     //expired sessions and remember-me tokens
+
+	ChangesCompacted = expvar.NewInt("changes_compacted_total") //change log entries dropped by retention
+)
+
+// last minute windows behind /admin/dashboard
+var (
+	Requests   = new(Window) //HTTP requests, 5xx responses count as errors
+	StoreCalls = new(Window) //book store calls, lookups of missing books are not errors
+)
+
+// StoreOps are the book store calls since startup by operation, served at /metrics
+var StoreOps = new(Ops)
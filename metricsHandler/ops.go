@@ -0,0 +1,68 @@
+package metricsHandler
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Buckets are the upper bounds of the duration histograms, in seconds
+var Buckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// OpStats are the totals of one operation since the process started
+type OpStats struct {
+	Count   int64
+	Errors  int64
+	Slow    int64         //took at least the slow threshold of Observe
+	Sum     time.Duration //of all durations
+	Buckets []int64       //operations taking at most Buckets[i] seconds, cumulative
+}
+
+// Ops counts timed operations by name with their errors, slow ones and a histogram of their durations
+type Ops struct {
+	mu  sync.Mutex
+	ops map[string]*OpStats
+}
+
+// Observe records an operation name that took d
+func (o *Ops) Observe(name string, d time.Duration, failed, slow bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.ops == nil {
+		o.ops = make(map[string]*OpStats)
+	}
+	s, ok := o.ops[name]
+	if !ok {
+		s = &OpStats{Buckets: make([]int64, len(Buckets))}
+		o.ops[name] = s
+	}
+	s.Count++
+	if failed {
+		s.Errors++
+	}
+	if slow {
+		s.Slow++
+	}
+	s.Sum += d
+	for i, le := range Buckets {
+		if d.Seconds() <= le {
+			s.Buckets[i]++
+		}
+	}
+}
+
+// Stats returns a copy of every operation's totals, by name
+func (o *Ops) Stats() ([]string, map[string]OpStats) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	names := make([]string, 0, len(o.ops))
+	stats := make(map[string]OpStats, len(o.ops))
+	for name, s := range o.ops {
+		names = append(names, name)
+		c := *s
+		c.Buckets = append([]int64(nil), s.Buckets...)
+		stats[name] = c
+	}
+	sort.Strings(names)
+	return names, stats
+}
@@ -0,0 +1,52 @@
+package metricsHandler
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes the expvar counters, the store operations and the store's statement cache
+// in the Prometheus text format
+func WritePrometheus(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	expvar.Do(func(kv expvar.KeyValue) {
+		if n, ok := kv.Value.(*expvar.Int); ok && strings.HasSuffix(kv.Key, "_total") {
+			fmt.Fprintf(b, "# TYPE %s counter\n%s %d\n", kv.Key, kv.Key, n.Value())
+		}
+	})
+
+	names, stats := StoreOps.Stats()
+	counter := func(metric, help string, value func(OpStats) int64) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(b, "%s{op=%q} %d\n", metric, name, value(stats[name]))
+		}
+	}
+	counter("store_operations_total", "Book store calls by operation.", func(s OpStats) int64 { return s.Count })
+	counter("store_errors_total", "Book store calls that failed, not found and conflict answers aside.", func(s OpStats) int64 { return s.Errors })
+	counter("store_slow_operations_total", "Book store calls slower than the slow call threshold.", func(s OpStats) int64 { return s.Slow })
+
+	const hist = "store_operation_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s How long book store calls take.\n# TYPE %s histogram\n", hist, hist)
+	for _, name := range names {
+		s := stats[name]
+		for i, le := range Buckets {
+			fmt.Fprintf(b, "%s_bucket{op=%q,le=%q} %d\n", hist, name, strconv.FormatFloat(le, 'g', -1, 64), s.Buckets[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{op=%q,le=\"+Inf\"} %d\n", hist, name, s.Count)
+		fmt.Fprintf(b, "%s_sum{op=%q} %g\n", hist, name, s.Sum.Seconds())
+		fmt.Fprintf(b, "%s_count{op=%q} %d\n", hist, name, s.Count)
+	}
+
+	if stats := statements.Load(); stats != nil {
+		s := (*stats)()
+		fmt.Fprintf(b, "# HELP store_statements_prepared_total Statements the book store prepared.\n# TYPE store_statements_prepared_total counter\nstore_statements_prepared_total %d\n", s.Prepared)
+		fmt.Fprintf(b, "# HELP store_statements_executed_total Executions of the book store's prepared statements.\n# TYPE store_statements_executed_total counter\nstore_statements_executed_total %d\n", s.Executed)
+		fmt.Fprintf(b, "# HELP store_statements_cached Prepared statements the book store holds.\n# TYPE store_statements_cached gauge\nstore_statements_cached %d\n", s.Cached)
+	}
+	return b.Flush()
+}
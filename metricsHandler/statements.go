@@ -0,0 +1,21 @@
+package metricsHandler
+
+import "sync/atomic"
+
+// StatementStats are the counts of a store that prepares its statements once and reuses them
+type StatementStats struct {
+	Prepared int64 //statements prepared, each one a miss of the cache
+	Executed int64 //executions of prepared statements
+	Cached   int64 //statements held now
+}
+
+var statements atomic.Pointer[func() StatementStats]
+
+// PublishStatements makes stats the statement cache /metrics reports, nil for a store without one
+func PublishStatements(stats func() StatementStats) {
+	if stats == nil {
+		statements.Store(nil)
+		return
+	}
+	statements.Store(&stats)
+}
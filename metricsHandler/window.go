@@ -0,0 +1,77 @@
+package metricsHandler
+
+import (
+	"sync"
+	"time"
+)
+
+const windowSeconds = 60
+
+type bucket struct {
+	sec    int64 //unix second the bucket holds, older contents are stale
+	count  int64
+	errors int64
+	total  time.Duration
+	max    time.Duration
+}
+
+// Window keeps per-second totals of timed operations over the last minute
+type Window struct {
+	mu      sync.Mutex
+	buckets [windowSeconds]bucket
+}
+
+// Summary of a Window's last minute
+type Summary struct {
+	Count  int64         `json:"count"`
+	Errors int64         `json:"errors"`
+	Avg    time.Duration `json:"-"`
+	Max    time.Duration `json:"-"`
+}
+
+// ErrorRate is the share of failed operations, 0 when there were none
+func (s Summary) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Count)
+}
+
+// Observe records an operation that finished at at and took d
+func (w *Window) Observe(at time.Time, d time.Duration, failed bool) {
+	sec := at.Unix()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[sec%windowSeconds]
+	if b.sec != sec {
+		*b = bucket{sec: sec}
+	}
+	b.count++
+	if failed {
+		b.errors++
+	}
+	b.total += d
+	b.max = max(b.max, d)
+}
+
+// Summary adds up the minute before now
+func (w *Window) Summary(now time.Time) Summary {
+	from := now.Unix() - windowSeconds
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var s Summary
+	var total time.Duration
+	for _, b := range w.buckets {
+		if b.sec <= from {
+			continue
+		}
+		s.Count += b.count
+		s.Errors += b.errors
+		total += b.total
+		s.Max = max(s.Max, b.max)
+	}
+	if s.Count != 0 {
+		s.Avg = total / time.Duration(s.Count)
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package moderationHandler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// Action is what moderation decides about a new review or comment
+type Action string
+
+const (
+	Allow      Action = "allow"      //published right away
+	Quarantine Action = "quarantine" //held for a moderator, nobody else sees it until they approve it
+	Reject     Action = "reject"     //refused, the author is told why
+)
+
+var severity = map[Action]int{Allow: 0, Quarantine: 1, Reject: 2}
+
+// Post is what moderators get to judge
+type Post struct {
+	Kind     string `json:"kind"` //dh.ReportReview or dh.ReportComment
+	Username string `json:"username"`
+	Text     string `json:"text"`
+}
+
+type Verdict struct {
+	Action Action `json:"action"`
+	Reason string `json:"reason,omitempty"` //shown to moderators, and to the author of a rejected post
+}
+
+// Moderator judges posts before they are stored. Implementations must be safe for concurrent use.
+type Moderator interface {
+	Moderate(ctx context.Context, p Post) (Verdict, error)
+}
+
+// ModeratorFunc adapts a function to Moderator
+type ModeratorFunc func(ctx context.Context, p Post) (Verdict, error)
+
+func (f ModeratorFunc) Moderate(ctx context.Context, p Post) (Verdict, error) { return f(ctx, p) }
+
+// Pipeline asks its moderators in order, the strictest verdict wins and a rejection ends the run.
+// A moderator failing quarantines the post rather than letting it through unchecked, the error is
+// returned along with that verdict for logging.
+type Pipeline []Moderator
+
+func (p Pipeline) Moderate(ctx context.Context, post Post) (Verdict, error) {
+	verdict := Verdict{Action: Allow}
+	for _, m := range p {
+		v, err := m.Moderate(ctx, post)
+		if err != nil {
+			v = Verdict{Action: Quarantine, Reason: "moderation unavailable"}
+		} else if _, ok := severity[v.Action]; !ok {
+			v, err = Verdict{Action: Quarantine, Reason: "moderation unavailable"}, fmt.Errorf("unknown moderation action %q", v.Action)
+		}
+		if severity[v.Action] > severity[verdict.Action] {
+			verdict = v
+		}
+		if err != nil {
+			return verdict, err
+		}
+		if verdict.Action == Reject {
+			break
+		}
+	}
+	return verdict, nil
+}
+
+// WordList quarantines posts containing a banned word or phrase. Words are matched whole, ignoring
+// case, accents and the script they are spelled in, so lookalike letters from other alphabets don't
+// slip through.
+type WordList struct {
+	Words  func() []string //read on every post, so a reloaded list applies right away
+	Action Action          //Quarantine when empty
+	T      dh.Transliterator
+}
+
+func NewWordList(words func() []string) *WordList {
+	return &WordList{Words: words, Action: Quarantine, T: dh.Romanizer}
+}
+
+func (l *WordList) Moderate(_ context.Context, p Post) (Verdict, error) {
+	text := " " + l.T.Romanize(p.Text) + " "
+	for _, w := range l.Words() {
+		if word := l.T.Romanize(w); len(word) != 0 && strings.Contains(text, " "+word+" ") {
+			action := l.Action
+			if len(action) == 0 {
+				action = Quarantine
+			}
+			return Verdict{Action: action, Reason: fmt.Sprintf("banned word %q", w)}, nil
+		}
+	}
+	return Verdict{Action: Allow}, nil
+}
@@ -0,0 +1,63 @@
+package moderationHandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body under Webhook.Secret, so the
+// moderation service can tell the calls come from this server
+const SignatureHeader = "X-BookServer-Signature"
+
+// Webhook hands posts to an external moderation service. It POSTs the Post as JSON and expects
+// a Verdict back, e.g. {"action": "quarantine", "reason": "likely spam"}.
+type Webhook struct {
+	URL    string
+	Secret []byte //signs requests when set
+	Client *http.Client
+}
+
+func NewWebhook(url string, secret []byte) *Webhook {
+	return &Webhook{URL: url, Secret: secret, Client: outboundHandler.PublicClient(5 * time.Second)} //set Client to reach a private address
+}
+
+func (h *Webhook) Moderate(ctx context.Context, p Post) (Verdict, error) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return Verdict{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(h.Secret) != 0 {
+		m := hmac.New(sha256.New, h.Secret)
+		m.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(m.Sum(nil)))
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return Verdict{}, fmt.Errorf("moderation webhook: %s", resp.Status)
+	}
+	var v Verdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&v); err != nil {
+		return Verdict{}, fmt.Errorf("moderation webhook: %w", err)
+	}
+	return v, nil
+}
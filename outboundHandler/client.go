@@ -0,0 +1,241 @@
+package outboundHandler
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	trace "github.com/Sabnaj-42/BookServer-API/traceHandler"
+)
+
+// ErrCircuitOpen is returned without calling a destination that failed BreakAfter times in a row,
+// until its Cooldown is over
+var ErrCircuitOpen = errors.New("circuit open, destination failing")
+
+// Options of the outbound Transport
+type Options struct {
+	Attempts   int           //tries per call, 1 never retries
+	Backoff    time.Duration //wait before the first retry, doubled for each further one, with jitter
+	MaxBackoff time.Duration
+	BreakAfter int           //consecutive failures that open a destination's circuit, 0 never opens it
+	Cooldown   time.Duration //how long an open circuit refuses calls before one is let through to test it
+	Proxy      string        //URL every call goes through, empty honors $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY
+}
+
+func DefaultOptions() Options {
+	return Options{Attempts: 3, Backoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second, BreakAfter: 5, Cooldown: 30 * time.Second}
+}
+
+// Transport is what every outbound HTTP call goes through: webhooks, push services, blob storage,
+// secret stores. It forwards the request ID, retries network errors and 429, 502, 503 and 504
+// answers with exponential backoff, and keeps a circuit per destination host so a service that is
+// down isn't waited on by every request. Requests whose body can't be replayed are tried once.
+type Transport struct {
+	mu       sync.Mutex
+	opts     Options
+	base     http.RoundTripper
+	proxy    func(*http.Request) (*url.URL, error)
+	guard    bool                //only public addresses, see Public
+	circuits map[string]*circuit //by host
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool //a call is testing the destination after the cooldown
+}
+
+// Default is the Transport of the clients made by Client, for the services the operator configured
+var Default = NewTransport(false)
+
+// Public is the Transport of the clients made by PublicClient, for URLs users registered, such as
+// Web Push endpoints. It refuses hosts inside private networks, so the server can't be made to call
+// internal services for them.
+var Public = NewTransport(true)
+
+// NewTransport makes a Transport with the default options, guard refuses addresses that aren't public
+func NewTransport(guard bool) *Transport {
+	t := &Transport{guard: guard, circuits: make(map[string]*circuit)}
+	t.Configure(DefaultOptions())
+	return t
+}
+
+// Configure sets the options of Default and Public
+func Configure(o Options) error {
+	if err := Default.Configure(o); err != nil {
+		return err
+	}
+	return Public.Configure(o)
+}
+
+// Configure replaces t's options, the circuits are kept
+func (t *Transport) Configure(o Options) error {
+	proxy := http.ProxyFromEnvironment
+	if len(o.Proxy) != 0 {
+		u, err := url.Parse(o.Proxy)
+		if err != nil || len(u.Host) == 0 {
+			return fmt.Errorf("outbound proxy %q: not a URL", o.Proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	if o.Attempts < 1 {
+		return fmt.Errorf("outbound attempts must be at least 1, got %d", o.Attempts)
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = proxy
+	if t.guard {
+		base.DialContext = guardedDial
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.opts = o
+	t.base = trace.Transport{Base: base}
+	t.proxy = proxy
+	return nil
+}
+
+// Client returns a client of the Default transport, timeout bounds a call with its retries
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Default, Timeout: timeout}
+}
+
+// PublicClient returns a client of the Public transport, redirects are checked like the first request
+func PublicClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Public, Timeout: timeout}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	opts, base, proxy := t.opts, t.base, t.proxy
+	t.mu.Unlock()
+	host := req.URL.Host
+	if t.guard {
+		if err := checkHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		if u, _ := proxy(req); u == nil {
+			req = req.WithContext(context.WithValue(req.Context(), directKey{}, true))
+		}
+	}
+	attempts := opts.Attempts
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		attempts = 1
+	}
+	wait := opts.Backoff
+	for try := 1; ; try++ {
+		if err := t.allow(host, opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", host, err)
+		}
+		if try > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		resp, err := base.RoundTrip(req)
+		if errors.Is(err, ErrPrivateAddr) {
+			return nil, err //not the destination's fault
+		}
+		t.done(req.Context(), host, opts, err != nil || resp.StatusCode >= 500)
+		if try >= attempts || !retryable(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+		d := min(wait/2+rand.N(wait/2+1), opts.MaxBackoff)
+		if resp != nil {
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				d = min(max(d, time.Duration(s)*time.Second), opts.MaxBackoff)
+			}
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(d):
+		}
+		wait = min(2*wait, opts.MaxBackoff)
+	}
+}
+
+// retryable reports whether a call may succeed when tried again
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// allow lets a call to host through unless its circuit is open; once the cooldown is over one call
+// at a time tests it
+func (t *Transport) allow(host string, opts Options) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.circuits[host]
+	if !ok || opts.BreakAfter <= 0 || c.failures < opts.BreakAfter {
+		return nil
+	}
+	if c.probing || time.Now().Before(c.openUntil) {
+		return ErrCircuitOpen
+	}
+	c.probing = true
+	return nil
+}
+
+// done records the outcome of a call to host
+func (t *Transport) done(ctx context.Context, host string, opts Options, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.circuits[host]
+	if !ok {
+		c = &circuit{}
+		t.circuits[host] = c
+	}
+	c.probing = false
+	if !failed {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if opts.BreakAfter > 0 && c.failures >= opts.BreakAfter {
+		if c.failures == opts.BreakAfter {
+			trace.Logf(ctx, "outbound: %s failed %d times in a row, pausing calls for %s", host, c.failures, opts.Cooldown)
+		}
+		c.openUntil = time.Now().Add(opts.Cooldown)
+	}
+}
+
+// CircuitStats is the state of one destination's circuit
+type CircuitStats struct {
+	Host      string    `json:"host"`
+	Failures  int       `json:"failures"` //in a row
+	OpenUntil time.Time `json:"open_until,omitzero"`
+}
+
+// Circuits reports the destinations that failed their last call
+func (t *Transport) Circuits() []CircuitStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var stats []CircuitStats
+	for host, c := range t.circuits {
+		if c.failures != 0 {
+			stats = append(stats, CircuitStats{Host: host, Failures: c.failures, OpenUntil: c.openUntil})
+		}
+	}
+	return stats
+}
+
+func init() {
+	expvar.Publish("outbound_circuits", expvar.Func(func() any { return append(Default.Circuits(), Public.Circuits()...) }))
+}
@@ -0,0 +1,90 @@
+package outboundHandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddr refuses calls from Public to addresses inside private networks
+var ErrPrivateAddr = errors.New("not a public address")
+
+// reserved ranges that aren't private, loopback or link-local but still don't reach the internet
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), //carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), //benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), //NAT64, embeds IPv4 addresses of any kind
+	netip.MustParsePrefix("2002::/16"),    //6to4, likewise
+}
+
+// PublicAddr reports whether a is on the internet: not loopback, private, link-local (cloud
+// metadata services live at 169.254.169.254), multicast, unspecified or otherwise reserved
+func PublicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	if !a.IsGlobalUnicast() || a.IsPrivate() {
+		return false
+	}
+	for _, p := range reserved {
+		if p.Contains(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that raw is an http or https URL whose host resolves to public addresses only,
+// for URLs users register. Calls to them go through Public, which checks again when connecting,
+// as the name may resolve elsewhere by then.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || len(u.Hostname()) == 0 {
+		return fmt.Errorf("%q: not an http URL", raw)
+	}
+	return checkHost(ctx, u.Hostname())
+}
+
+func checkHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if !PublicAddr(a) {
+			return fmt.Errorf("%s is %s: %w", host, a.Unmap(), ErrPrivateAddr)
+		}
+	}
+	return nil
+}
+
+type directKey struct{} //marks requests of Public that don't go through a proxy
+
+// guardedDial dials for Public. Requests going straight to their host only connect to public
+// addresses, checked on the address actually dialed, so a name rebound to an internal address since
+// it was checked is caught. Proxy connections aren't checked, the request's host was checked before.
+func guardedDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if ctx.Value(directKey{}) != nil {
+		d.Control = guardControl
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// guardControl is the net.Dialer Control refusing addresses that aren't public
+func guardControl(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil || !PublicAddr(ap.Addr()) {
+		return fmt.Errorf("%s: %w", address, ErrPrivateAddr)
+	}
+	return nil
+}
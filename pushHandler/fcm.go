@@ -0,0 +1,151 @@
+package pushHandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCM sends to Android and iOS apps through the Firebase Cloud Messaging HTTP v1 API, with
+// access tokens for a service account
+type FCM struct {
+	ProjectID string
+	Email     string //the service account's
+	Key       *rsa.PrivateKey
+	TokenURL  string
+	BaseURL   string //https://fcm.googleapis.com
+	Client    *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewFCM reads a service account key file as downloaded from the Firebase console
+func NewFCM(credentials []byte) (*FCM, error) {
+	var sa struct {
+		ProjectID   string `json:"project_id"`
+		PrivateKey  string `json:"private_key"`
+		ClientEmail string `json:"client_email"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(credentials, &sa); err != nil {
+		return nil, fmt.Errorf("fcm credentials: %w", err)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, errors.New("fcm credentials: no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("fcm credentials: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("fcm credentials: not an RSA key")
+	}
+	if len(sa.ProjectID) == 0 || len(sa.ClientEmail) == 0 {
+		return nil, errors.New("fcm credentials: project_id or client_email missing")
+	}
+	if len(sa.TokenURI) == 0 {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &FCM{ProjectID: sa.ProjectID, Email: sa.ClientEmail, Key: key, TokenURL: sa.TokenURI, BaseURL: "https://fcm.googleapis.com", Client: outboundHandler.Client(10 * time.Second)}, nil
+}
+
+func (f *FCM) Send(ctx context.Context, d dh.Device, data map[string]string) error {
+	token, err := f.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	msg := map[string]any{"message": map[string]any{"token": d.Token, "data": data}}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.BaseURL+"/v1/projects/"+url.PathEscape(f.ProjectID)+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	switch {
+	case resp.StatusCode == http.StatusNotFound: //UNREGISTERED, the app was uninstalled or the token rotated
+		return ErrGone
+	case resp.StatusCode == http.StatusUnauthorized:
+		f.mu.Lock()
+		f.token = "" //revoked early, fetch a new one next time
+		f.mu.Unlock()
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("fcm: %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// accessToken exchanges a signed assertion for an OAuth access token, kept until shortly before it expires
+func (f *FCM) accessToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if len(f.token) != 0 && now.Before(f.expires) {
+		return f.token, nil
+	}
+	assertion, err := jwt.NewBuilder().Issuer(f.Email).Audience([]string{f.TokenURL}).Claim("scope", fcmScope).IssuedAt(now).Expiration(now.Add(time.Hour)).Build()
+	if err != nil {
+		return "", err
+	}
+	assertion.Options().Enable(jwt.FlattenAudience)
+	signed, err := jwt.Sign(assertion, jwt.WithKey(jwa.RS256, f.Key))
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {string(signed)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return "", fmt.Errorf("fcm token: %s", resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&tok); err != nil {
+		return "", fmt.Errorf("fcm token: %w", err)
+	}
+	f.token, f.expires = tok.AccessToken, now.Add(time.Duration(tok.ExpiresIn)*time.Second-time.Minute)
+	return f.token, nil
+}
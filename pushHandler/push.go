@@ -0,0 +1,29 @@
+package pushHandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// ErrGone means the push service no longer knows the device, e.g. the app was uninstalled or the
+// browser unsubscribed, and it should be forgotten
+var ErrGone = errors.New("push subscription gone")
+
+// Sender delivers a small data message to one device. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, d dh.Device, data map[string]string) error
+}
+
+// Mux sends through the Sender for each device's platform
+type Mux map[string]Sender
+
+func (m Mux) Send(ctx context.Context, d dh.Device, data map[string]string) error {
+	s, ok := m[d.Platform]
+	if !ok {
+		return fmt.Errorf("push: no sender for platform %q", d.Platform)
+	}
+	return s.Send(ctx, d, data)
+}
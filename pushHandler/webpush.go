@@ -0,0 +1,192 @@
+package pushHandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const recordSize = 4096 //push services accept one aes128gcm record of at most 4096 bytes
+
+var b64 = base64.RawURLEncoding
+
+// WebPush sends to browsers through the push service of their subscription (RFC 8030), payloads
+// encrypted for the subscription's keys (RFC 8291) and calls signed with a VAPID key (RFC 8292)
+type WebPush struct {
+	Key     *ecdsa.PrivateKey //VAPID key, its public half is what browsers subscribe with
+	Subject string            //contact for the push service, a mailto: or https: URL
+	TTL     time.Duration     //how long the push service keeps a message for an offline device
+	Client  *http.Client
+}
+
+// NewWebPush takes the VAPID private key as the base64url P-256 scalar GenerateVAPIDKey prints
+func NewWebPush(key, subject string) (*WebPush, error) {
+	raw, err := b64.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("vapid key: %w", err)
+	}
+	priv, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("vapid key: %w", err)
+	}
+	if len(subject) == 0 {
+		return nil, errors.New("vapid subject missing, push services want a mailto: or https: contact")
+	}
+	pub := priv.PublicKey().Bytes() //0x04 || X || Y
+	k := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(pub[1:33]), Y: new(big.Int).SetBytes(pub[33:])},
+		D:         new(big.Int).SetBytes(raw),
+	}
+	return &WebPush{Key: k, Subject: subject, TTL: 24 * time.Hour, Client: outboundHandler.PublicClient(10 * time.Second)}, nil //endpoints come from browsers
+}
+
+// GenerateVAPIDKey returns a new private key for NewWebPush and its public key, both base64url
+func GenerateVAPIDKey() (string, string, error) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return b64.EncodeToString(priv.Bytes()), b64.EncodeToString(priv.PublicKey().Bytes()), nil
+}
+
+// PublicKey is the applicationServerKey browsers subscribe with
+func (p *WebPush) PublicKey() string {
+	pub, _ := p.Key.PublicKey.ECDH()
+	return b64.EncodeToString(pub.Bytes())
+}
+
+func (p *WebPush) Send(ctx context.Context, d dh.Device, data map[string]string) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	body, err := encrypt(payload, d.P256dh, d.Auth)
+	if err != nil {
+		return err
+	}
+	auth, err := p.vapid(d.Endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(p.TTL.Seconds())))
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("web push: %s", resp.Status)
+	}
+	return nil
+}
+
+// vapid is the Authorization header for the push service serving endpoint
+func (p *WebPush) vapid(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	token, err := jwt.NewBuilder().Audience([]string{u.Scheme + "://" + u.Host}).Subject(p.Subject).Expiration(time.Now().Add(12 * time.Hour)).Build()
+	if err != nil {
+		return "", err
+	}
+	token.Options().Enable(jwt.FlattenAudience) //push services want a single aud string
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.ES256, p.Key))
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + string(signed) + ", k=" + p.PublicKey(), nil
+}
+
+// encrypt seals payload for the subscription keys p256dh and auth as a single aes128gcm record
+func encrypt(payload []byte, p256dh, auth string) ([]byte, error) {
+	rawPub, err := b64.DecodeString(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+	secret, err := b64.DecodeString(auth)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+	if len(payload)+1+16 > recordSize {
+		return nil, fmt.Errorf("web push payload of %d bytes too large", len(payload))
+	}
+	uaPub, err := ecdh.P256().NewPublicKey(rawPub)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := asKey.ECDH(uaPub)
+	if err != nil {
+		return nil, err
+	}
+	asPub := asKey.PublicKey().Bytes()
+	ikm, err := hkdf.Key(sha256.New, shared, secret, "WebPush: info\x00"+string(rawPub)+string(asPub), 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, 16+4+1+len(asPub))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPub)))
+	header = append(header, asPub...)
+	plain := append(append([]byte(nil), payload...), 2) //2 marks the last record, no padding
+	return gcm.Seal(header, nonce, plain, nil), nil
+}
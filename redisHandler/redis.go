@@ -0,0 +1,206 @@
+package redisHandler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client speaks just enough RESP for the commands the server needs, over a single connection
+type Client struct {
+	Addr string
+	Auth func() (username, password string) //credentials sent with AUTH on every new connection, nil for none
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func NewClient(addr string) *Client {
+	return &Client{Addr: addr}
+}
+
+// Do sends one command and reads its reply, reconnecting once if the connection went away
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reply, err := c.roundTrip(ctx, args)
+	if err != nil && c.conn != nil {
+		var redisErr Error
+		if errors.As(err, &redisErr) {
+			return nil, err
+		}
+		c.conn.Close()
+		c.conn = nil
+		reply, err = c.roundTrip(ctx, args)
+	}
+	return reply, err
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+func (c *Client) roundTrip(ctx context.Context, args []string) (any, error) {
+	if c.conn == nil {
+		conn, rd, err := c.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.conn, c.rd = conn, rd
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+	if err := writeCommand(c.conn, args); err != nil {
+		return nil, err
+	}
+	return readReply(c.rd)
+}
+
+// Subscribe listens on channel on its own connection and calls fn with every message,
+// redialing after errors until ctx is done
+func (c *Client) Subscribe(ctx context.Context, channel string, fn func(payload string)) error {
+	for {
+		c.subscribeOnce(ctx, channel, fn) //returns when the connection drops
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (c *Client) subscribeOnce(ctx context.Context, channel string, fn func(string)) error {
+	conn, rd, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := writeCommand(conn, []string{"SUBSCRIBE", channel}); err != nil {
+		return err
+	}
+	for {
+		reply, err := readReply(rd)
+		if err != nil {
+			return err
+		}
+		msg, ok := reply.([]any)
+		if !ok || len(msg) != 3 {
+			continue
+		}
+		if kind, _ := msg[0].(string); kind != "message" {
+			continue //subscribe confirmations
+		}
+		if payload, ok := msg[2].(string); ok {
+			fn(payload)
+		}
+	}
+}
+
+// dial connects and authenticates, credentials are asked for each time since they may be rotated
+func (c *Client) dial(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	rd := bufio.NewReader(conn)
+	if c.Auth == nil {
+		return conn, rd, nil
+	}
+	args := []string{"AUTH"}
+	if user, pass := c.Auth(); len(user) != 0 {
+		args = append(args, user, pass)
+	} else {
+		args = append(args, pass)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+	if err := writeCommand(conn, args); err == nil {
+		_, err = readReply(rd)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, rd, nil
+}
+
+func writeCommand(w io.Writer, args []string) error {
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, a := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := w.Write([]byte(cmd))
+	return err
+}
+
+type Error string //an error reply from the server
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, errors.New("redis: short reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,112 @@
+package secretHandler
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const Prefix = "enc:v1:" //marks values encrypted by a Keyring: enc:v1:<key id>:<base64 nonce+ciphertext>
+
+var (
+	ErrUnknownKey = errors.New("encrypted with an unknown key")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+// Keyring encrypts values with AES-256-GCM under its primary key and decrypts with any key it holds,
+// so a new key can be made primary while values written under older ones stay readable
+type Keyring struct {
+	Primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring takes 32 byte keys by ID, the first one in order is primary
+func NewKeyring(order []string, keys map[string][]byte) (*Keyring, error) {
+	if len(order) == 0 {
+		return nil, errors.New("keyring needs at least one key")
+	}
+	k := &Keyring{Primary: order[0], keys: make(map[string]cipher.AEAD)}
+	for _, id := range order {
+		if len(keys[id]) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, got %d", id, len(keys[id]))
+		}
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("key id %q must not contain ':'", id)
+		}
+		block, err := aes.NewCipher(keys[id])
+		if err != nil {
+			return nil, err
+		}
+		if k.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ParseKeyring reads "id:base64key,id:base64key", newest first, as kept in $BOOKSERVER_ENCRYPTION_KEYS.
+// Rotating means putting a new key in front and keeping the old ones until everything was rewritten.
+func ParseKeyring(s string) (*Keyring, error) {
+	var order []string
+	keys := make(map[string][]byte)
+	for _, part := range strings.Split(s, ",") {
+		id, b64, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("key %q is not id:base64", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		order = append(order, id)
+		keys[id] = key
+	}
+	return NewKeyring(order, keys)
+}
+
+// Encrypt seals plaintext under the primary key. aad binds the value to where it is stored,
+// e.g. its record ID, so it can't be copied into another record.
+func (k *Keyring) Encrypt(plaintext, aad string) (string, error) {
+	aead := k.keys[k.Primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return Prefix + k.Primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value from Encrypt, values without Prefix are returned as they are,
+// so data written before encryption was turned on stays readable
+func (k *Keyring) Decrypt(value, aad string) (string, error) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return value, nil
+	}
+	id, b64, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", ErrMalformed
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(b64)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// Stale reports whether value is plaintext or under an older key and should be written again
+func (k *Keyring) Stale(value string) bool {
+	return !strings.HasPrefix(value, Prefix+k.Primary+":")
+}
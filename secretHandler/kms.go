@@ -0,0 +1,76 @@
+package secretHandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/awsHandler"
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+)
+
+// KMSProvider decrypts secrets encrypted with AWS KMS, the path is the base64 ciphertext blob as
+// printed by `aws kms encrypt --query CiphertextBlob --output text`
+type KMSProvider struct {
+	Endpoint string //defaults to https://kms.<region>.amazonaws.com
+	Signer   awsHandler.Signer
+	Client   *http.Client
+	Now      func() time.Time
+}
+
+func NewKMSProvider(region, accessKey, secretKey string) *KMSProvider {
+	return &KMSProvider{
+		Endpoint: "https://kms." + region + ".amazonaws.com",
+		Signer:   awsHandler.Signer{AccessKey: accessKey, SecretKey: secretKey, Region: region, Service: "kms"},
+		Client:   outboundHandler.Client(10 * time.Second),
+		Now:      time.Now,
+	}
+}
+
+func (k *KMSProvider) call(ctx context.Context, action string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	k.Signer.Sign(req, awsHandler.SHA256Hex(body), k.Now())
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e)
+		return fmt.Errorf("%s: %s: %s %s", action, resp.Status, e.Type, e.Message)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// Fetch decrypts the ciphertext, the plaintext comes back as the single field "value"
+func (k *KMSProvider) Fetch(ctx context.Context, ciphertext string) (*Secret, error) {
+	if _, err := base64.StdEncoding.DecodeString(ciphertext); err != nil {
+		return nil, fmt.Errorf("ciphertext is not base64: %w", err)
+	}
+	var out struct {
+		Plaintext []byte //base64 in JSON
+	}
+	if err := k.call(ctx, "Decrypt", map[string]string{"CiphertextBlob": ciphertext}, &out); err != nil {
+		return nil, err
+	}
+	return &Secret{Data: map[string]string{"value": string(out.Plaintext)}}, nil
+}
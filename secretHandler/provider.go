@@ -0,0 +1,257 @@
+package secretHandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoField = errors.New("secret has no such field")
+
+// Secret is what a Provider returns, dynamic secrets (e.g. database credentials) come with a lease
+type Secret struct {
+	Data          map[string]string
+	LeaseID       string
+	LeaseDuration time.Duration //zero for static secrets
+	Renewable     bool
+}
+
+// Provider fetches secrets by path, e.g. from Vault or by decrypting with KMS
+type Provider interface {
+	Fetch(ctx context.Context, path string) (*Secret, error)
+}
+
+// Renewer is implemented by providers whose leases can be extended, it returns the new lease duration
+type Renewer interface {
+	Renew(ctx context.Context, s *Secret) (time.Duration, error)
+}
+
+// TokenRenewer is implemented by providers that log in with an expiring token, it returns the new token TTL
+type TokenRenewer interface {
+	RenewToken(ctx context.Context) (time.Duration, error)
+}
+
+// EnvProvider reads secrets from environment variables, the path is the variable name
+type EnvProvider struct{}
+
+func (EnvProvider) Fetch(ctx context.Context, name string) (*Secret, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("$%s is not set", name)
+	}
+	return &Secret{Data: map[string]string{"value": v}}, nil
+}
+
+// FileProvider reads secrets from files, e.g. mounted by Kubernetes or docker secrets
+type FileProvider struct{}
+
+func (FileProvider) Fetch(ctx context.Context, path string) (*Secret, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Secret{Data: map[string]string{"value": strings.TrimRight(string(b), "\r\n")}}, nil
+}
+
+// Ref points at a secret as "<provider>:<path>#<field>", e.g. "vault:secret/data/bookserver#jwt_secret",
+// "kms:<base64 ciphertext>", "env:BOOKSERVER_JWT_SECRET" or "file:/run/secrets/jwt"
+type Ref struct {
+	Provider string
+	Path     string
+	Field    string //may be empty when the secret holds a single value
+}
+
+func ParseRef(s string) (Ref, error) {
+	provider, rest, ok := strings.Cut(s, ":")
+	if !ok || len(provider) == 0 || len(rest) == 0 {
+		return Ref{}, fmt.Errorf("secret reference %q is not provider:path", s)
+	}
+	ref := Ref{Provider: provider, Path: rest}
+	if provider != "kms" { //base64 ciphertext has no '#', but keep kms blobs untouched anyway
+		ref.Path, ref.Field, _ = strings.Cut(rest, "#")
+	}
+	return ref, nil
+}
+
+func (r Ref) String() string {
+	if len(r.Field) != 0 {
+		return r.Provider + ":" + r.Path + "#" + r.Field
+	}
+	return r.Provider + ":" + r.Path
+}
+
+// Lease is a resolved secret, kept fresh by Resolver.KeepRenewed
+type Lease struct {
+	Ref Ref
+
+	mu     sync.RWMutex
+	secret *Secret
+}
+
+// Value returns the referenced field, or the only value when the reference names none
+func (l *Lease) Value() (string, error) {
+	if len(l.Ref.Field) != 0 {
+		return l.Get(l.Ref.Field)
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.secret.Data) != 1 {
+		return "", fmt.Errorf("%s holds %d fields, name one with #field", l.Ref, len(l.secret.Data))
+	}
+	for _, v := range l.secret.Data {
+		return v, nil
+	}
+	return "", nil
+}
+
+// Get returns one field of the secret, e.g. "username" of database credentials
+func (l *Lease) Get(field string) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	v, ok := l.secret.Data[field]
+	if !ok {
+		return "", fmt.Errorf("%s: %w: %q", l.Ref, ErrNoField, field)
+	}
+	return v, nil
+}
+
+func (l *Lease) current() *Secret {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.secret
+}
+
+func (l *Lease) set(s *Secret) {
+	l.mu.Lock()
+	l.secret = s
+	l.mu.Unlock()
+}
+
+// Resolver resolves secret references with its providers and keeps leases and login tokens renewed
+type Resolver struct {
+	Providers map[string]Provider
+	Logger    *log.Logger
+
+	mu     sync.Mutex
+	leases []*Lease
+}
+
+func NewResolver(logger *log.Logger) *Resolver {
+	return &Resolver{Providers: map[string]Provider{"env": EnvProvider{}, "file": FileProvider{}}, Logger: logger}
+}
+
+// Resolve fetches the secret ref points at
+func (r *Resolver) Resolve(ctx context.Context, ref string) (*Lease, error) {
+	parsed, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := r.Providers[parsed.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown secrets provider %q in %q", parsed.Provider, ref)
+	}
+	s, err := p.Fetch(ctx, parsed.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", parsed.Provider, err)
+	}
+	l := &Lease{Ref: parsed, secret: s}
+	if len(parsed.Field) != 0 {
+		if _, err := l.Get(parsed.Field); err != nil {
+			return nil, err
+		}
+	}
+	r.mu.Lock()
+	r.leases = append(r.leases, l)
+	r.mu.Unlock()
+	return l, nil
+}
+
+// Ping checks the providers that can be reached over the network
+func (r *Resolver) Ping(ctx context.Context) error {
+	for name, p := range r.Providers {
+		if pinger, ok := p.(interface{ Ping(context.Context) error }); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// renewAt is when a lease or token of ttl is renewed, two thirds in, leaving room for retries
+func renewAt(ttl time.Duration) time.Duration {
+	return ttl * 2 / 3
+}
+
+// KeepRenewed renews resolved leases and provider tokens in the background until ctx is done.
+// A lease that can't be renewed any more (e.g. it reached its max TTL) is fetched again,
+// so callers reading Lease.Get on use always see valid credentials.
+func (r *Resolver) KeepRenewed(ctx context.Context) {
+	r.mu.Lock()
+	leases := append([]*Lease(nil), r.leases...)
+	r.mu.Unlock()
+	for _, l := range leases {
+		if l.current().LeaseDuration > 0 {
+			go r.renewLease(ctx, l)
+		}
+	}
+	for name, p := range r.Providers {
+		if tr, ok := p.(TokenRenewer); ok {
+			go r.renewToken(ctx, name, tr)
+		}
+	}
+}
+
+func (r *Resolver) renewLease(ctx context.Context, l *Lease) {
+	p := r.Providers[l.Ref.Provider]
+	for {
+		s := l.current()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(renewAt(s.LeaseDuration)):
+		}
+
+		if rn, ok := p.(Renewer); ok && s.Renewable {
+			ttl, err := rn.Renew(ctx, s)
+			if err == nil && ttl > 0 {
+				renewed := *s
+				renewed.LeaseDuration = ttl
+				l.set(&renewed)
+				continue
+			}
+			r.Logger.Printf("secrets: renewing %s:%s: %v, fetching it again", l.Ref.Provider, l.Ref.Path, err)
+		}
+		fresh, err := p.Fetch(ctx, l.Ref.Path)
+		if err != nil {
+			r.Logger.Printf("secrets: fetching %s:%s: %v", l.Ref.Provider, l.Ref.Path, err)
+			fresh = &Secret{Data: s.Data, LeaseID: s.LeaseID, LeaseDuration: time.Minute * 3 / 2, Renewable: s.Renewable} //retry in a minute
+		}
+		l.set(fresh)
+	}
+}
+
+func (r *Resolver) renewToken(ctx context.Context, name string, tr TokenRenewer) {
+	ttl, err := tr.RenewToken(ctx)
+	for {
+		wait := renewAt(ttl)
+		switch {
+		case err != nil:
+			r.Logger.Printf("secrets: renewing %s token: %v", name, err)
+			wait = time.Minute
+		case ttl <= 0:
+			return //the token never expires
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		ttl, err = tr.RenewToken(ctx)
+	}
+}
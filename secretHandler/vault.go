@@ -0,0 +1,136 @@
+package secretHandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sabnaj-42/BookServer-API/outboundHandler"
+)
+
+// VaultProvider reads secrets from HashiCorp Vault's HTTP API, both KV (v1 and v2) and dynamic
+// secrets engines such as database/creds/<role>, whose leases are renewed through sys/leases
+type VaultProvider struct {
+	Addr      string //e.g. https://vault.internal:8200, from $VAULT_ADDR
+	Token     string //from $VAULT_TOKEN
+	Namespace string //Vault Enterprise namespace, from $VAULT_NAMESPACE
+	Client    *http.Client
+}
+
+func NewVaultProvider(addr, token string) *VaultProvider {
+	return &VaultProvider{Addr: strings.TrimRight(addr, "/"), Token: token, Namespace: os.Getenv("VAULT_NAMESPACE"), Client: outboundHandler.Client(10 * time.Second)}
+}
+
+type vaultResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"` //seconds
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+	Auth          *struct {
+		LeaseDuration int  `json:"lease_duration"`
+		Renewable     bool `json:"renewable"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+func (v *VaultProvider) call(ctx context.Context, method, path string, body any) (*vaultResponse, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.Addr+"/v1/"+strings.TrimLeft(path, "/"), rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if len(v.Namespace) != 0 {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out vaultResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.Join(out.Errors, "; "))
+	}
+	return &out, nil
+}
+
+// Fetch reads the secret at path, KV v2 responses are unwrapped from their data.data envelope
+func (v *VaultProvider) Fetch(ctx context.Context, path string) (*Secret, error) {
+	resp, err := v.call(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	data := resp.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, kv2 := data["metadata"]; kv2 {
+			data = inner
+		}
+	}
+	s := &Secret{
+		Data:          make(map[string]string, len(data)),
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable,
+	}
+	for k, val := range data {
+		if str, ok := val.(string); ok {
+			s.Data[k] = str
+		} else {
+			s.Data[k] = fmt.Sprint(val)
+		}
+	}
+	return s, nil
+}
+
+// Renew extends the lease of a dynamic secret by its previous duration
+func (v *VaultProvider) Renew(ctx context.Context, s *Secret) (time.Duration, error) {
+	resp, err := v.call(ctx, http.MethodPut, "sys/leases/renew", map[string]any{"lease_id": s.LeaseID, "increment": int(s.LeaseDuration.Seconds())})
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+// RenewToken extends the provider's own token, returning zero for tokens that never expire
+func (v *VaultProvider) RenewToken(ctx context.Context) (time.Duration, error) {
+	self, err := v.call(ctx, http.MethodGet, "auth/token/lookup-self", nil)
+	if err != nil {
+		return 0, err
+	}
+	ttl, _ := self.Data["ttl"].(float64)
+	renewable, _ := self.Data["renewable"].(bool)
+	if ttl == 0 || !renewable {
+		return time.Duration(ttl) * time.Second, nil
+	}
+	resp, err := v.call(ctx, http.MethodPost, "auth/token/renew-self", map[string]any{})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Auth == nil {
+		return 0, fmt.Errorf("renew-self: no auth in response")
+	}
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+func (v *VaultProvider) Ping(ctx context.Context) error {
+	_, err := v.call(ctx, http.MethodGet, "auth/token/lookup-self", nil)
+	return err
+}
@@ -0,0 +1,139 @@
+package specHandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	ch "github.com/Sabnaj-42/BookServer-API/caseHandler"
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+const MaxBody = 64 << 10 //larger JSON bodies aren't checked, the handler's own limit applies
+
+// operation finds what the spec describes for r, with the values of its path parameters
+func (s *Spec) operation(r *http.Request) (PathItem, *Operation, map[string]string) {
+	template, pathParams, ok := s.match(r.URL.Path)
+	if !ok {
+		return PathItem{}, nil, nil
+	}
+	item := s.Paths[template]
+	return item, item.operation(r.Method), pathParams
+}
+
+// CheckRequest validates the path and query parameters of r against the operation the spec
+// describes for it. Requests the spec doesn't describe pass. It reads nothing from the body, so it
+// can run before authentication; CheckBody checks that.
+func (s *Spec) CheckRequest(r *http.Request) []dh.FieldError {
+	item, op, pathParams := s.operation(r)
+	if op == nil {
+		return nil //not described, or the router answers 405
+	}
+
+	var errs []dh.FieldError
+	query := r.URL.Query()
+	for _, p := range append(append([]Parameter(nil), item.Parameters...), op.Parameters...) {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw, present = pathParams[p.Name]
+		case "query":
+			present = query.Has(p.Name)
+			raw = query.Get(p.Name)
+		default:
+			continue
+		}
+		if !present || (p.In == "query" && len(raw) == 0) {
+			if p.Required {
+				errs = append(errs, dh.FieldError{Field: p.Name, Rule: dh.RuleRequired})
+			}
+			continue
+		}
+		errs = append(errs, s.ValidateParam(p.Schema, raw, p.Name)...)
+	}
+	return errs
+}
+
+// CheckBody validates the JSON body of r against the operation the spec describes for it. Requests
+// the spec doesn't describe pass, so do bodies that aren't JSON or are over MaxBody: the handlers
+// report those. The body is buffered and put back for the handler.
+func (s *Spec) CheckBody(r *http.Request) ([]dh.FieldError, error) {
+	_, op, _ := s.operation(r)
+	if op == nil || op.RequestBody == nil {
+		return nil, nil
+	}
+	var errs []dh.FieldError
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if _, ok := op.RequestBody.Content[mt]; !ok {
+		mt = "application/json" //handlers decode JSON whatever the request says it is
+	}
+	media, ok := op.RequestBody.Content[mt]
+	if !ok || mt != "application/json" || r.ContentLength > MaxBody {
+		return errs, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > MaxBody {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return errs, nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			errs = append(errs, dh.FieldError{Field: "body", Rule: dh.RuleRequired})
+		}
+		return errs, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return errs, nil //not JSON, the handler says so in its own words
+	}
+	for _, e := range s.Validate(media.Schema, s.respell(media.Schema, v, ch.Of(r)), "") {
+		if len(e.Field) == 0 {
+			e.Field = "body"
+		}
+		errs = append(errs, e)
+	}
+	return errs, nil
+}
+
+// respell renames the members of a decoded body spelled in c to the property names of sc, which
+// are snake_case like the struct tags the handlers decode into
+func (s *Spec) respell(sc *Schema, v any, c ch.Casing) any {
+	if sc == nil || c == ch.Snake {
+		return v
+	}
+	sc = s.resolve(sc)
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, val := range v {
+			name := key
+			if _, ok := sc.Properties[key]; !ok {
+				for p := range sc.Properties {
+					if c.Name(p) == key {
+						name = p
+						break
+					}
+				}
+			}
+			out[name] = s.respell(sc.Properties[name], val, c)
+		}
+		return out
+	case []any:
+		for i := range v {
+			v[i] = s.respell(sc.Items, v[i], c)
+		}
+	}
+	return v
+}
@@ -0,0 +1,289 @@
+package specHandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Spec is the part of an OpenAPI 3 document requests are validated against
+type Spec struct {
+	OpenAPI    string              `json:"openapi"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema   `json:"schemas"`
+		Parameters map[string]Parameter `json:"parameters"`
+	} `json:"components"`
+
+	Raw    []byte  `json:"-"` //the document as loaded, served to clients unchanged
+	routes []route //Paths split into segments, for matching request paths
+}
+
+type PathItem struct {
+	Parameters []Parameter `json:"parameters"`
+	Get        *Operation  `json:"get"`
+	Put        *Operation  `json:"put"`
+	Post       *Operation  `json:"post"`
+	Patch      *Operation  `json:"patch"`
+	Delete     *Operation  `json:"delete"`
+}
+
+func (p PathItem) operation(method string) *Operation {
+	switch method {
+	case "GET", "HEAD":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "PATCH":
+		return p.Patch
+	case "DELETE":
+		return p.Delete
+	}
+	return nil
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []Parameter  `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"` //to components/parameters, resolved by Load
+	Name     string  `json:"name"`
+	In       string  `json:"in"` //path or query, header and cookie parameters aren't checked
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema the validator understands
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"` //false refuses fields not in Properties
+	Items                *Schema            `json:"items,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Description          string             `json:"description,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+type route struct {
+	template string
+	segments []string
+	literal  int //segments that aren't {parameters}
+}
+
+const refPrefix = "#/components/schemas/"
+
+// Load parses an OpenAPI document, checking that its references resolve and its patterns compile
+func Load(doc []byte) (*Spec, error) {
+	s := &Spec{Raw: doc}
+	if err := json.Unmarshal(doc, s); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(s.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi version %q is not 3.x", s.OpenAPI)
+	}
+	var prepare func(sc *Schema, where string) error
+	prepare = func(sc *Schema, where string) error {
+		if sc == nil {
+			return nil
+		}
+		if len(sc.Ref) != 0 {
+			if s.resolve(sc) == sc {
+				return fmt.Errorf("%s: unresolved reference %q", where, sc.Ref)
+			}
+			return nil
+		}
+		if len(sc.Pattern) != 0 {
+			re, err := regexp.Compile(sc.Pattern)
+			if err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+			sc.pattern = re
+		}
+		for name, p := range sc.Properties {
+			if err := prepare(p, where+"."+name); err != nil {
+				return err
+			}
+		}
+		return prepare(sc.Items, where+"[]")
+	}
+	for name, sc := range s.Components.Schemas {
+		if err := prepare(sc, name); err != nil {
+			return nil, err
+		}
+	}
+	for path, item := range s.Paths {
+		rt := route{template: path, segments: strings.Split(strings.Trim(path, "/"), "/")}
+		for _, seg := range rt.segments {
+			if !strings.HasPrefix(seg, "{") {
+				rt.literal++
+			}
+		}
+		s.routes = append(s.routes, rt)
+		if err := s.resolveParams(item.Parameters, path); err != nil {
+			return nil, err
+		}
+		params := item.Parameters
+		for _, op := range []*Operation{item.Get, item.Put, item.Post, item.Patch, item.Delete} {
+			if op == nil {
+				continue
+			}
+			if err := s.resolveParams(op.Parameters, path); err != nil {
+				return nil, err
+			}
+			params = append(params, op.Parameters...)
+			if op.RequestBody != nil {
+				for ct, mt := range op.RequestBody.Content {
+					if err := prepare(mt.Schema, path+" "+ct); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		for _, p := range params {
+			if err := prepare(p.Schema, path+" "+p.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(s.routes, func(i, j int) bool { //fixed segments win over parameters, like in the router
+		if s.routes[i].literal != s.routes[j].literal {
+			return s.routes[i].literal > s.routes[j].literal
+		}
+		return s.routes[i].template < s.routes[j].template
+	})
+	return s, nil
+}
+
+// MustLoad is Load for documents embedded in the binary
+func MustLoad(doc []byte) *Spec {
+	s, err := Load(doc)
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+	return s
+}
+
+// resolveParams replaces $ref parameters in place by the ones in components/parameters
+func (s *Spec) resolveParams(params []Parameter, path string) error {
+	const prefix = "#/components/parameters/"
+	for i, p := range params {
+		if len(p.Ref) == 0 {
+			continue
+		}
+		target, ok := s.Components.Parameters[strings.TrimPrefix(p.Ref, prefix)]
+		if !ok || !strings.HasPrefix(p.Ref, prefix) {
+			return fmt.Errorf("%s: unresolved reference %q", path, p.Ref)
+		}
+		params[i] = target
+	}
+	return nil
+}
+
+// resolve follows a $ref to components/schemas, returning sc itself when it has none or it is unknown
+func (s *Spec) resolve(sc *Schema) *Schema {
+	if len(sc.Ref) == 0 {
+		return sc
+	}
+	if target, ok := s.Components.Schemas[strings.TrimPrefix(sc.Ref, refPrefix)]; ok && strings.HasPrefix(sc.Ref, refPrefix) {
+		return target
+	}
+	return sc
+}
+
+// match finds the path template for a request path and the values of its {parameters}
+func (s *Spec) match(path string) (string, map[string]string, bool) {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	for _, rt := range s.routes {
+		if len(rt.segments) != len(segs) {
+			continue
+		}
+		params := make(map[string]string)
+		ok := true
+		for i, seg := range rt.segments {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				params[seg[1:len(seg)-1]] = segs[i]
+			} else if seg != segs[i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return rt.template, params, true
+		}
+	}
+	return "", nil, false
+}
+
+// SchemaDialect is the JSON Schema version JSONSchema documents declare
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns the named component schemas, and the ones they refer to, as a standalone
+// JSON Schema document with the definitions under $defs
+func (s *Spec) JSONSchema(id string, names ...string) ([]byte, error) {
+	defs := make(map[string]*Schema)
+	var add func(name string) error
+	add = func(name string) error {
+		if _, done := defs[name]; done {
+			return nil
+		}
+		sc, ok := s.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("no schema %q", name)
+		}
+		defs[name] = sc
+		var refs func(sc *Schema) error
+		refs = func(sc *Schema) error {
+			if sc == nil {
+				return nil
+			}
+			if len(sc.Ref) != 0 {
+				return add(strings.TrimPrefix(sc.Ref, refPrefix))
+			}
+			for _, p := range sc.Properties {
+				if err := refs(p); err != nil {
+					return err
+				}
+			}
+			return refs(sc.Items)
+		}
+		return refs(sc)
+	}
+	for _, name := range names {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+	doc, err := json.Marshal(map[string]any{"$schema": SchemaDialect, "$id": id, "$defs": defs})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.ReplaceAll(doc, []byte(`"`+refPrefix), []byte(`"#/$defs/`)), nil
+}
@@ -0,0 +1,198 @@
+package specHandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+)
+
+// rules reported for values not matching the spec, next to dh.RuleRequired
+const (
+	RuleType         = "type"
+	RuleEnum         = "enum"
+	RulePattern      = "pattern"
+	RuleFormat       = "spec_format"
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleMinItems     = "min_items"
+	RuleMinimum      = "minimum"
+	RuleMaximum      = "maximum"
+	RuleUnknownField = "unknown_field"
+)
+
+// Validate checks a decoded JSON value (numbers as json.Number) against sc, field names the value in violations
+func (s *Spec) Validate(sc *Schema, v any, field string) []dh.FieldError {
+	var errs []dh.FieldError
+	s.validate(sc, v, field, &errs)
+	return errs
+}
+
+func (s *Spec) validate(sc *Schema, v any, field string, errs *[]dh.FieldError) {
+	if sc == nil {
+		return
+	}
+	sc = s.resolve(sc)
+	fail := func(rule string, args ...any) {
+		*errs = append(*errs, dh.FieldError{Field: field, Rule: rule, Args: args})
+	}
+	if v == nil {
+		if !sc.Nullable && len(sc.Type) != 0 {
+			fail(RuleType, sc.Type)
+		}
+		return
+	}
+	if len(sc.Enum) != 0 && !inEnum(sc.Enum, v) {
+		fail(RuleEnum, enumList(sc.Enum))
+		return
+	}
+
+	switch sc.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail(RuleType, sc.Type)
+			return
+		}
+		for _, name := range sc.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, dh.FieldError{Field: join(field, name), Rule: dh.RuleRequired})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names) //violations in a stable order
+		for _, name := range names {
+			if p, ok := sc.Properties[name]; ok {
+				s.validate(p, obj[name], join(field, name), errs)
+			} else if sc.AdditionalProperties != nil && !*sc.AdditionalProperties {
+				*errs = append(*errs, dh.FieldError{Field: join(field, name), Rule: RuleUnknownField})
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			fail(RuleType, sc.Type)
+			return
+		}
+		if sc.MinItems != nil && len(arr) < *sc.MinItems {
+			fail(RuleMinItems, *sc.MinItems)
+		}
+		for i, item := range arr {
+			s.validate(sc.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail(RuleType, sc.Type)
+			return
+		}
+		if n := utf8.RuneCountInString(str); n == 0 && sc.MinLength != nil && *sc.MinLength == 1 {
+			fail(dh.RuleRequired)
+		} else if sc.MinLength != nil && n < *sc.MinLength {
+			fail(RuleMinLength, *sc.MinLength)
+		} else if sc.MaxLength != nil && n > *sc.MaxLength {
+			fail(RuleMaxLength, *sc.MaxLength)
+		}
+		if sc.pattern != nil && !sc.pattern.MatchString(str) {
+			fail(RulePattern, sc.Pattern)
+		}
+		if !validFormat(sc.Format, str) {
+			fail(RuleFormat, sc.Format)
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			fail(RuleType, sc.Type)
+			return
+		}
+		f, err := n.Float64()
+		if _, intErr := n.Int64(); err != nil || (sc.Type == "integer" && intErr != nil) {
+			fail(RuleType, sc.Type)
+			return
+		}
+		if sc.Minimum != nil && f < *sc.Minimum {
+			fail(RuleMinimum, *sc.Minimum)
+		}
+		if sc.Maximum != nil && f > *sc.Maximum {
+			fail(RuleMaximum, *sc.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail(RuleType, sc.Type)
+		}
+	}
+}
+
+// ValidateParam checks a path or query parameter, converting it to the schema's type first
+func (s *Spec) ValidateParam(sc *Schema, raw, field string) []dh.FieldError {
+	if sc == nil {
+		return nil
+	}
+	var v any = raw
+	switch s.resolve(sc).Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return []dh.FieldError{{Field: field, Rule: RuleType, Args: []any{s.resolve(sc).Type}}}
+		}
+		v = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []dh.FieldError{{Field: field, Rule: RuleType, Args: []any{"boolean"}}}
+		}
+		v = b
+	}
+	return s.Validate(sc, v, field)
+}
+
+func validFormat(format, s string) bool {
+	var err error
+	switch format {
+	case "date":
+		_, err = time.Parse(time.DateOnly, s)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	}
+	return err == nil
+}
+
+func inEnum(enum []any, v any) bool {
+	if n, ok := v.(json.Number); ok { //enums are decoded as float64
+		f, _ := n.Float64()
+		v = f
+	}
+	for _, e := range enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []any) string {
+	parts := make([]string, 0, len(enum))
+	for _, e := range enum {
+		if s, ok := e.(string); ok && len(s) == 0 {
+			parts = append(parts, `""`)
+			continue
+		}
+		parts = append(parts, fmt.Sprint(e))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func join(field, name string) string {
+	if len(field) == 0 {
+		return name
+	}
+	return field + "." + name
+}
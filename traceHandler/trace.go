@@ -0,0 +1,47 @@
+package traceHandler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestID returns the chi request ID carried by ctx, or "" outside a request
+func RequestID(ctx context.Context) string {
+	return middleware.GetReqID(ctx)
+}
+
+// WithRequestID returns a context carrying id, for work started outside of an HTTP request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, middleware.RequestIDKey, id)
+}
+
+// Logf logs with the request ID of ctx so lines from handlers, storage and outbound calls can be matched
+func Logf(ctx context.Context, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if id := RequestID(ctx); len(id) != 0 {
+		log.Printf("[%s] %s", id, msg)
+		return
+	}
+	log.Print(msg)
+}
+
+// Transport forwards the request ID of the outgoing request's context as X-Request-Id
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if id := RequestID(req.Context()); len(id) != 0 && len(req.Header.Get(middleware.RequestIDHeader)) == 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,126 @@
+package uiHandler
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	dh "github.com/Sabnaj-42/BookServer-API/dataHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+const PageSize = 20 //books per catalog page
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	listPage = template.Must(template.ParseFS(templates, "templates/layout.html", "templates/list.html"))
+	bookPage = template.Must(template.ParseFS(templates, "templates/layout.html", "templates/book.html"))
+)
+
+type Catalog struct { //server rendered catalog pages
+	Store    dh.Store
+	Translit *dh.TranslitIndex //searches across scripts when set
+	Logger   *log.Logger
+}
+
+type listData struct {
+	Query       string
+	Books       []dh.Book
+	Page, Pages int
+	Prev, Next  int
+}
+
+type bookData struct {
+	Query string
+	Book  dh.Book
+}
+
+// List renders /catalog?q=&page=
+func (c *Catalog) List(w http.ResponseWriter, r *http.Request) {
+	c.serve(w, r, c.list)
+}
+
+// Book renders /catalog/{ref}, ref being the book ID or ISBN
+func (c *Catalog) Book(w http.ResponseWriter, r *http.Request) {
+	c.serve(w, r, c.book)
+}
+
+// serve answers the error page renders fail with: 404 for unknown books, 500 logged for the rest.
+// Pages are rendered into a buffer first, so a failing one never leaves half a page behind.
+func (c *Catalog) serve(w http.ResponseWriter, r *http.Request, page func(w http.ResponseWriter, r *http.Request) error) {
+	err := page(w, r)
+	switch {
+	case err == nil:
+	case errors.Is(err, dh.ErrBookNotFound):
+		http.NotFound(w, r)
+	default:
+		c.Logger.Printf("catalog: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (c *Catalog) list(w http.ResponseWriter, r *http.Request) error {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var books []dh.Book
+	var err error
+	if len(query) != 0 {
+		search := dh.SmStr(dh.Clean(query))
+		var romanized string
+		if c.Translit != nil {
+			romanized = c.Translit.Query(search)
+		}
+		books, err = dh.FindBooks(r.Context(), c.Store, func(b dh.Book) bool {
+			return b.Matches(search) || (c.Translit != nil && c.Translit.Matches(b, romanized))
+		})
+		sort.Slice(books, func(i, j int) bool { return books[i].ISBN < books[j].ISBN }) //ListBooks' order, pages stay put
+	} else {
+		books, err = c.Store.ListBooks(r.Context())
+	}
+	if err != nil {
+		return err
+	}
+
+	data := listData{Query: query, Page: 1, Pages: (len(books) + PageSize - 1) / PageSize}
+	if data.Pages == 0 {
+		data.Pages = 1
+	}
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 && p <= data.Pages {
+		data.Page = p
+	}
+	start := (data.Page - 1) * PageSize
+	end := min(start+PageSize, len(books))
+	data.Books = books[start:end]
+	if data.Page > 1 {
+		data.Prev = data.Page - 1
+	}
+	if data.Page < data.Pages {
+		data.Next = data.Page + 1
+	}
+
+	return render(w, listPage, data)
+}
+
+func (c *Catalog) book(w http.ResponseWriter, r *http.Request) error {
+	book, err := c.Store.GetBook(r.Context(), chi.URLParam(r, "ref"))
+	if err != nil {
+		return err
+	}
+	return render(w, bookPage, bookData{Book: book})
+}
+
+func render(w http.ResponseWriter, t *template.Template, data any) error {
+	var buf strings.Builder
+	if err := t.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(buf.String()))
+	return nil
+}
@@ -0,0 +1,109 @@
+"use strict";
+
+const api = "/api/v1/books";
+const tbody = document.querySelector("#books tbody");
+const form = document.getElementById("book");
+const statusLine = document.getElementById("status");
+let editing = null;
+
+function setStatus(text, isError) {
+  statusLine.textContent = text;
+  statusLine.className = isError ? "error" : "";
+}
+
+async function errorText(res) {
+  const type = res.headers.get("Content-Type") || "";
+  if (type.includes("json")) {
+    const body = await res.json();
+    if (body.errors) {
+      return body.errors.map(e => e.message).join(", ");
+    }
+    return body.detail || body.message || res.statusText;
+  }
+  return (await res.text()) || res.statusText;
+}
+
+async function load(query) {
+  const url = query ? api + "?q=" + encodeURIComponent(query) : api;
+  const res = await fetch(url, {credentials: "same-origin"});
+  if (!res.ok) {
+    setStatus(await errorText(res), true);
+    return;
+  }
+  render(await res.json());
+}
+
+function cell(text) {
+  const td = document.createElement("td");
+  td.textContent = text || "";
+  return td;
+}
+
+function render(books) {
+  tbody.replaceChildren();
+  for (const b of books) {
+    const tr = document.createElement("tr");
+    tr.append(cell(b.isbn), cell(b.name), cell((b.authors || []).map(a => a.name).join(", ")),
+      cell(b.genre), cell(b.published), cell(b.language));
+    const actions = document.createElement("td");
+    const edit = document.createElement("button");
+    edit.textContent = "Edit";
+    edit.onclick = () => fill(b);
+    actions.append(edit);
+    tr.append(actions);
+    tbody.append(tr);
+  }
+}
+
+function fill(b) {
+  editing = b.id;
+  document.getElementById("form-title").textContent = "Edit " + (b.isbn || b.name);
+  for (const el of form.elements) {
+    if (!el.name) continue;
+    el.value = el.name === "authors" ? (b.authors || []).map(a => a.name).join("; ") : (b[el.name] || "");
+  }
+}
+
+form.addEventListener("reset", () => {
+  editing = null;
+  document.getElementById("form-title").textContent = "Add book";
+});
+
+form.addEventListener("submit", async ev => {
+  ev.preventDefault();
+  const data = Object.fromEntries(new FormData(form));
+  data.authors = (data.authors || "").split(";").map(s => s.trim()).filter(Boolean).map(name => ({name}));
+  const res = await fetch(editing ? api + "/" + encodeURIComponent(editing) : api, {
+    method: editing ? "PUT" : "POST",
+    credentials: "same-origin",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify(data),
+  });
+  if (!res.ok) {
+    setStatus(await errorText(res), true);
+    return;
+  }
+  setStatus(editing ? "Saved" : "Created");
+  form.reset();
+  load();
+});
+
+document.getElementById("search").addEventListener("submit", ev => {
+  ev.preventDefault();
+  load(new FormData(ev.target).get("q"));
+});
+
+document.getElementById("login").addEventListener("submit", async ev => {
+  ev.preventDefault();
+  const data = Object.fromEntries(new FormData(ev.target));
+  const res = await fetch("/login", {
+    method: "POST",
+    credentials: "same-origin",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify(data),
+  });
+  setStatus(await res.text(), !res.ok);
+  if (res.ok) ev.target.reset();
+});
+
+load();
@@ -0,0 +1,19 @@
+package uiHandler
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the embedded admin page and its assets
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) //the embedded tree is fixed at build time
+	}
+	return http.FileServer(http.FS(sub))
+}